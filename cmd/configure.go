@@ -10,6 +10,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -21,11 +22,13 @@ import (
 
 var (
 	listFlag      bool
+	jsonFlag      bool
 	encryptFlag   bool
 	decryptFlag   bool
 	removeFlag    string
 	updateKeyFlag string
 	databaseFlag  bool
+	probeFlag     string
 )
 
 // Green checkmark for successful operations
@@ -53,39 +56,19 @@ func getOpenAIModels(apiKey string) ([]string, error) {
 }
 
 func getAnthropicModels() []string {
-	return []string{
-		"claude-3-5-sonnet-20241022",
-		"claude-3-5-sonnet-latest",
-		"claude-3-5-haiku-latest",
-	}
+	return config.KnownModels("anthropic")
 }
 
 func getXAIModels() []string {
-	return []string{
-		"grok-beta",
-		"grok-vision-beta",
-	}
+	return config.KnownModels("xai")
 }
 
 func getDeepseekModels() []string {
-	return []string{
-		"deepseek-chat",
-		"deepseek-coder",
-		"deepseek-vision",
-		"deepseek-reasoner",
-	}
+	return config.KnownModels("deepseek")
 }
 
 func getGoogleModels() []string {
-	return []string{
-		"gemini-1.5-flash",
-		"gemini-1.5-flash-8b",
-		"gemini-1.5-pro",
-		"gemini-1.0-pro",
-		"gemini-2.0-flash-exp",
-		"text-embedding-004",
-		"aqa",
-	}
+	return config.KnownModels("google")
 }
 
 func getOllamaModels() ([]OllamaModel, error) {
@@ -316,10 +299,26 @@ func promptForModes(reader *bufio.Reader, modelName string) ([]config.ModelMode,
 	fmt.Println("2. vision - Image and vision processing mode")
 	fmt.Println("3. multi - Multi-modal processing")
 	fmt.Println("4. file - File processing mode")
-	fmt.Print("\nEnter mode numbers (comma-separated, e.g., 1,2): ")
+
+	// Pre-select the known modes for recognized models
+	defaultModes := config.DefaultModesForModel(modelName)
+	if len(defaultModes) > 0 {
+		var names []string
+		for _, mode := range defaultModes {
+			names = append(names, string(mode))
+		}
+		fmt.Printf("\nSuggested modes for %s: %s\n", modelName, strings.Join(names, ", "))
+		fmt.Print("Press Enter to accept, or enter mode numbers (comma-separated, e.g., 1,2): ")
+	} else {
+		fmt.Print("\nEnter mode numbers (comma-separated, e.g., 1,2): ")
+	}
 	modesInput, _ := reader.ReadString('\n')
 	modesInput = strings.TrimSpace(modesInput)
 
+	if modesInput == "" && len(defaultModes) > 0 {
+		return defaultModes, nil
+	}
+
 	var modes []config.ModelMode
 	if modesInput != "" {
 		modeNumbers := strings.Split(modesInput, ",")
@@ -359,6 +358,11 @@ var configureCmd = &cobra.Command{
 			return
 		}
 
+		if probeFlag != "" {
+			probeProvider(probeFlag)
+			return
+		}
+
 		configPath := config.GetEnvPath()
 
 		if encryptFlag {
@@ -478,13 +482,13 @@ var configureCmd = &cobra.Command{
 			// Prompt for provider
 			var provider string
 			for {
-				fmt.Print("Enter provider (openai/anthropic/ollama/google/xai/deepseek): ")
+				fmt.Print("Enter provider (openai/anthropic/ollama/google/xai/deepseek/echo): ")
 				provider, _ = reader.ReadString('\n')
 				provider = strings.TrimSpace(provider)
-				if provider == "openai" || provider == "anthropic" || provider == "ollama" || provider == "google" || provider == "xai" || provider == "deepseek" {
+				if provider == "openai" || provider == "anthropic" || provider == "ollama" || provider == "google" || provider == "xai" || provider == "deepseek" || provider == "echo" {
 					break
 				}
-				fmt.Println("Invalid provider. Please enter 'openai', 'anthropic', 'ollama', 'google', 'xai', or 'deepseek'")
+				fmt.Println("Invalid provider. Please enter 'openai', 'anthropic', 'ollama', 'google', 'xai', 'deepseek', or 'echo'")
 			}
 
 			// Special handling for ollama provider
@@ -499,8 +503,8 @@ var configureCmd = &cobra.Command{
 			existingProvider, err := envConfig.GetProviderConfig(provider)
 			var apiKey string
 			if err != nil {
-				if provider != "ollama" {
-					// Only prompt for API key if not ollama
+				if provider != "ollama" && provider != "echo" {
+					// Only prompt for API key if not ollama or echo
 					fmt.Print("Enter API key: ")
 					apiKey, _ = reader.ReadString('\n')
 					apiKey = strings.TrimSpace(apiKey)
@@ -509,6 +513,15 @@ var configureCmd = &cobra.Command{
 					APIKey: apiKey,
 					Models: []config.Model{},
 				}
+				if provider == "openai" {
+					// Enterprise accounts attribute usage to an organization and project
+					fmt.Print("Enter organization ID (optional, press Enter to skip): ")
+					organization, _ := reader.ReadString('\n')
+					existingProvider.Organization = strings.TrimSpace(organization)
+					fmt.Print("Enter project ID (optional, press Enter to skip): ")
+					project, _ := reader.ReadString('\n')
+					existingProvider.Project = strings.TrimSpace(project)
+				}
 				envConfig.AddProvider(provider, *existingProvider)
 			} else {
 				apiKey = existingProvider.APIKey
@@ -600,11 +613,15 @@ var configureCmd = &cobra.Command{
 					fmt.Printf("Error selecting models: %v\n", err)
 					return
 				}
+
+			case "echo":
+				// The echo provider returns its prompt, so there is nothing to choose
+				selectedModels = config.KnownModels("echo")
 			}
 
 			// Add new models to provider
 			modelType := "external"
-			if provider == "ollama" {
+			if provider == "ollama" || provider == "echo" {
 				modelType = "local"
 			}
 
@@ -663,6 +680,16 @@ func listConfiguration() {
 		return
 	}
 
+	if jsonFlag {
+		output, err := envConfig.RedactedJSON()
+		if err != nil {
+			fmt.Printf("Error encoding configuration: %v\n", err)
+			return
+		}
+		fmt.Println(string(output))
+		return
+	}
+
 	fmt.Printf("Configuration from %s:\n\n", configPath)
 
 	// List server configuration if it exists
@@ -700,6 +727,12 @@ func listConfiguration() {
 	fmt.Println("Configured Providers:")
 	for name, provider := range envConfig.Providers {
 		fmt.Printf("\n%s:\n", name)
+		if provider.Organization != "" {
+			fmt.Printf("  Organization: %s\n", provider.Organization)
+		}
+		if provider.Project != "" {
+			fmt.Printf("  Project: %s\n", provider.Project)
+		}
 		if len(provider.Models) == 0 {
 			fmt.Println("  No models configured")
 			continue
@@ -719,12 +752,67 @@ func listConfiguration() {
 	}
 }
 
+// probeProvider prints the models a provider currently offers, using its model listing endpoint
+// where one is available and the known model list otherwise
+func probeProvider(provider string) {
+	var models []string
+	switch provider {
+	case "openai":
+		envConfig, err := config.LoadEnvConfigWithPassword(config.GetEnvPath())
+		if err != nil {
+			fmt.Printf("Error loading configuration: %v\n", err)
+			return
+		}
+		providerConfig, err := envConfig.GetProviderConfig("openai")
+		if err != nil || providerConfig.APIKey == "" {
+			fmt.Println("No API key configured for openai. Run 'comanda configure' to add one.")
+			return
+		}
+		models, err = getOpenAIModels(providerConfig.APIKey)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+	case "ollama":
+		ollamaModels, err := getOllamaModels()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		for _, model := range ollamaModels {
+			models = append(models, model.Name)
+		}
+	default:
+		models = config.KnownModels(provider)
+		if models == nil {
+			fmt.Printf("Unknown provider '%s'. Known providers: %s\n", provider, strings.Join(config.KnownProviders(), ", "))
+			return
+		}
+		fmt.Printf("%s has no model listing endpoint, showing known models.\n", provider)
+	}
+
+	if len(models) == 0 {
+		fmt.Printf("No models available from %s.\n", provider)
+		return
+	}
+
+	sort.Strings(models)
+	fmt.Printf("Models available from %s:\n", provider)
+	for _, model := range models {
+		fmt.Printf("  - %s\n", model)
+	}
+}
+
 func init() {
 	configureCmd.Flags().BoolVar(&listFlag, "list", false, "List all configured providers and models")
+	configureCmd.Flags().BoolVar(&jsonFlag, "json", false, "With --list, print the configuration as JSON with secrets redacted")
 	configureCmd.Flags().BoolVar(&encryptFlag, "encrypt", false, "Encrypt the configuration file")
 	configureCmd.Flags().BoolVar(&decryptFlag, "decrypt", false, "Decrypt the configuration file")
 	configureCmd.Flags().StringVar(&removeFlag, "remove", "", "Remove a model by name")
 	configureCmd.Flags().StringVar(&updateKeyFlag, "update-key", "", "Update API key for specified provider")
 	configureCmd.Flags().BoolVar(&databaseFlag, "database", false, "Configure database settings")
+	configureCmd.Flags().StringVar(&probeFlag, "probe", "", "List the models currently available from a provider")
+	configureCmd.RegisterFlagCompletionFunc("update-key", completeValues(config.KnownProviders()...))
+	configureCmd.RegisterFlagCompletionFunc("probe", completeValues(config.KnownProviders()...))
 	rootCmd.AddCommand(configureCmd)
 }
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kris-hansen/comanda/utils/processor"
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <old.yaml> <new.yaml>",
+	Short: "Compare two workflow files step by step",
+	Long: `Compare two DSL workflow files semantically. Steps are matched by name, so reordering
+steps is not reported as a change. Added, removed and changed steps are listed along with
+the fields (input, model, action, output, ...) that differ.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		oldConfig, err := loadWorkflow(args[0])
+		if err != nil {
+			return err
+		}
+		newConfig, err := loadWorkflow(args[1])
+		if err != nil {
+			return err
+		}
+
+		diffs := processor.DiffConfigs(oldConfig, newConfig)
+		fmt.Printf("Comparing %s -> %s\n", args[0], args[1])
+		if len(diffs) == 0 {
+			fmt.Println("\nNo differences found.")
+			return nil
+		}
+
+		for _, diff := range diffs {
+			switch diff.Kind {
+			case processor.StepAdded:
+				fmt.Printf("\n+ Added step: %s\n", diff.Name)
+			case processor.StepRemoved:
+				fmt.Printf("\n- Removed step: %s\n", diff.Name)
+			case processor.StepChanged:
+				fmt.Printf("\n~ Changed step: %s\n", diff.Name)
+				for _, change := range diff.Changes {
+					fmt.Printf("    %s: %s -> %s\n", change.Field, displayValue(change.Old), displayValue(change.New))
+				}
+			}
+		}
+		return nil
+	},
+}
+
+// loadWorkflow reads and parses a workflow file
+func loadWorkflow(path string) (*processor.DSLConfig, error) {
+	dslConfig, err := processor.ParseDSLFile(path, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", path, err)
+	}
+	return dslConfig, nil
+}
+
+// displayValue makes unset fields visible in diff output
+func displayValue(value string) string {
+	if value == "" {
+		return "(none)"
+	}
+	return fmt.Sprintf("%q", value)
+}
+
+func init() {
+	diffCmd.ValidArgsFunction = completeWorkflowFiles
+	rootCmd.AddCommand(diffCmd)
+}
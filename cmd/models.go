@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kris-hansen/comanda/utils/config"
+)
+
+var (
+	modelsConfiguredFlag bool
+	modelsProviderFlag   string
+	modelsSearchFlag     string
+)
+
+var modelsCmd = &cobra.Command{
+	Use:   "models",
+	Short: "List available models",
+	Long: `List the models known to comanda, grouped by provider, along with the modes each supports.
+Use --configured to list only the models in your environment file.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		var envConfig *config.EnvConfig
+		if modelsConfiguredFlag {
+			var err error
+			envConfig, err = config.LoadEnvConfigWithPassword(config.GetEnvPath())
+			if err != nil {
+				log.Fatalf("Error loading environment configuration: %v", err)
+			}
+		}
+
+		found := false
+		for _, provider := range config.KnownProviders() {
+			if modelsProviderFlag != "" && !strings.EqualFold(provider, modelsProviderFlag) {
+				continue
+			}
+
+			models := listModels(provider, envConfig)
+			if len(models) == 0 {
+				continue
+			}
+
+			fmt.Printf("%s:\n", provider)
+			for _, model := range models {
+				fmt.Printf("  %-30s %s\n", model.Name, formatModes(model.Modes))
+			}
+			found = true
+		}
+
+		if !found {
+			fmt.Println("No matching models found.")
+		}
+	},
+}
+
+// listModels returns the matching models for a provider, taken from the environment when
+// envConfig is set and from the known model list otherwise
+func listModels(provider string, envConfig *config.EnvConfig) []config.Model {
+	var models []config.Model
+	if envConfig != nil {
+		if providerConfig, ok := envConfig.Providers[provider]; ok {
+			models = providerConfig.Models
+		}
+	} else {
+		for _, name := range config.KnownModels(provider) {
+			models = append(models, config.Model{
+				Name:  name,
+				Modes: config.DefaultModesForModel(name),
+			})
+		}
+	}
+
+	search := strings.ToLower(modelsSearchFlag)
+	var matches []config.Model
+	for _, model := range models {
+		if search == "" || strings.Contains(strings.ToLower(model.Name), search) {
+			matches = append(matches, model)
+		}
+	}
+	return matches
+}
+
+// formatModes renders a model's modes for display
+func formatModes(modes []config.ModelMode) string {
+	if len(modes) == 0 {
+		return "[unknown]"
+	}
+	names := make([]string, len(modes))
+	for i, mode := range modes {
+		names[i] = string(mode)
+	}
+	return "[" + strings.Join(names, ", ") + "]"
+}
+
+func init() {
+	modelsCmd.Flags().BoolVar(&modelsConfiguredFlag, "configured", false, "Only list models configured in the environment file")
+	modelsCmd.Flags().StringVar(&modelsProviderFlag, "provider", "", "Only list models for the given provider")
+	modelsCmd.Flags().StringVar(&modelsSearchFlag, "search", "", "Only list models whose name contains the given text")
+	modelsCmd.RegisterFlagCompletionFunc("provider", completeValues(config.KnownProviders()...))
+	rootCmd.AddCommand(modelsCmd)
+}
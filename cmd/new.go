@@ -0,0 +1,255 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/kris-hansen/comanda/utils/config"
+	"github.com/kris-hansen/comanda/utils/processor"
+)
+
+var (
+	overwriteWorkflow bool
+	interactiveNew    bool
+)
+
+var newCmd = &cobra.Command{
+	Use:   "new <file.yaml>",
+	Short: "Build a workflow file step by step",
+	Long: `Build a workflow interactively. For each step you are asked for its name, input, model,
+action and output. Each step is validated as it is added and the workflow so far is shown
+before the next one. Enter an empty step name to finish and write the file.
+
+With --interactive, the workflow is built in a full-screen terminal UI that shows a chart of
+the workflow, including the step being entered, as it grows.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := args[0]
+		if _, err := os.Stat(path); err == nil && !overwriteWorkflow {
+			return fmt.Errorf("%s already exists; use --force to overwrite it", path)
+		}
+
+		// The configured models are offered as choices; without a configuration any name can be typed
+		var modelNames []string
+		if envConfig, err := config.LoadEnvConfigWithPassword(config.GetEnvPath()); err == nil {
+			modelNames = configuredModels(envConfig)
+		}
+
+		var dslConfig *processor.DSLConfig
+		var err error
+		if interactiveNew {
+			dslConfig, err = runWorkflowBuilder(modelNames)
+		} else {
+			dslConfig, err = buildWorkflow(bufio.NewReader(os.Stdin), os.Stdout, modelNames)
+		}
+		if err != nil {
+			return err
+		}
+		if dslConfig == nil {
+			fmt.Println("Cancelled; nothing written.")
+			return nil
+		}
+		if len(dslConfig.Steps) == 0 {
+			fmt.Println("No steps added; nothing written.")
+			return nil
+		}
+
+		data, err := marshalWorkflow(dslConfig)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		fmt.Printf("\nWorkflow written to %s. Run it with: comanda process %s\n", path, path)
+		return nil
+	},
+}
+
+// configuredModels returns the names of all configured models, sorted
+func configuredModels(envConfig *config.EnvConfig) []string {
+	var names []string
+	for _, provider := range envConfig.Providers {
+		if provider == nil {
+			continue
+		}
+		for _, model := range provider.Models {
+			names = append(names, model.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// buildWorkflow prompts for steps until an empty step name is entered
+func buildWorkflow(reader *bufio.Reader, out io.Writer, modelNames []string) (*processor.DSLConfig, error) {
+	dslConfig := &processor.DSLConfig{}
+	proc := processor.NewProcessor(dslConfig, nil, false)
+
+	for {
+		fmt.Fprintf(out, "\nStep %d\n", len(dslConfig.Steps)+1)
+		name, err := prompt(reader, out, "Step name (empty to finish)", "")
+		if err != nil {
+			return nil, err
+		}
+		if name == "" {
+			return dslConfig, nil
+		}
+		if _, exists := findStep(dslConfig, name); exists {
+			fmt.Fprintf(out, "A step named %s already exists.\n", name)
+			continue
+		}
+
+		defaultInput := "NA"
+		if len(dslConfig.Steps) > 0 {
+			defaultInput = "STDIN"
+		}
+		input, err := prompt(reader, out, "Input (file, URL, STDIN or NA)", defaultInput)
+		if err != nil {
+			return nil, err
+		}
+		model, err := promptModel(reader, out, modelNames)
+		if err != nil {
+			return nil, err
+		}
+		action, err := prompt(reader, out, "Action", "")
+		if err != nil {
+			return nil, err
+		}
+		if action == "" {
+			fmt.Fprintln(out, "An action is required. The step was not added; please enter it again.")
+			continue
+		}
+		output, err := prompt(reader, out, "Output (file or STDOUT)", "STDOUT")
+		if err != nil {
+			return nil, err
+		}
+
+		step := processor.Step{
+			Name: name,
+			Config: processor.StepConfig{
+				Input:  input,
+				Model:  model,
+				Action: action,
+				Output: output,
+			},
+		}
+		if err := proc.ValidateStep(step); err != nil {
+			fmt.Fprintf(out, "\n%v\nThe step was not added; please enter it again.\n", err)
+			continue
+		}
+		dslConfig.Steps = append(dslConfig.Steps, step)
+
+		preview, err := marshalWorkflow(dslConfig)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(out, "\nWorkflow so far:\n\n%s", preview)
+	}
+}
+
+// prompt asks for a value, returning the default when the answer is empty
+func prompt(reader *bufio.Reader, out io.Writer, label, defaultValue string) (string, error) {
+	if defaultValue != "" {
+		fmt.Fprintf(out, "%s [%s]: ", label, defaultValue)
+	} else {
+		fmt.Fprintf(out, "%s: ", label)
+	}
+
+	// At the end of input the answer is treated as empty, which finishes the workflow
+	answer, err := reader.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("failed to read answer: %w", err)
+	}
+	answer = strings.TrimSpace(answer)
+	if answer == "" {
+		return defaultValue, nil
+	}
+	return answer, nil
+}
+
+// promptModel asks for a model by number from the configured models, or by name
+func promptModel(reader *bufio.Reader, out io.Writer, modelNames []string) (string, error) {
+	if len(modelNames) > 0 {
+		fmt.Fprintln(out, "Configured models:")
+		for i, name := range modelNames {
+			fmt.Fprintf(out, "  %d. %s\n", i+1, name)
+		}
+	}
+
+	for {
+		answer, err := prompt(reader, out, "Model (number, name, or NA)", "NA")
+		if err != nil {
+			return "", err
+		}
+		if n, err := strconv.Atoi(answer); err == nil {
+			if n < 1 || n > len(modelNames) {
+				fmt.Fprintf(out, "Please enter a number between 1 and %d.\n", len(modelNames))
+				continue
+			}
+			return modelNames[n-1], nil
+		}
+		return answer, nil
+	}
+}
+
+// findStep reports whether the workflow has a step with the given name
+func findStep(dslConfig *processor.DSLConfig, name string) (processor.Step, bool) {
+	for _, step := range dslConfig.Steps {
+		if step.Name == name {
+			return step, true
+		}
+	}
+	return processor.Step{}, false
+}
+
+// newStepYAML is the subset of step fields written by the workflow builder
+type newStepYAML struct {
+	Input  interface{} `yaml:"input"`
+	Model  interface{} `yaml:"model"`
+	Action interface{} `yaml:"action"`
+	Output interface{} `yaml:"output"`
+}
+
+// marshalWorkflow encodes the workflow's steps as YAML in the order they were added
+func marshalWorkflow(dslConfig *processor.DSLConfig) ([]byte, error) {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	for _, step := range dslConfig.Steps {
+		var value yaml.Node
+		if err := value.Encode(newStepYAML{
+			Input:  step.Config.Input,
+			Model:  step.Config.Model,
+			Action: step.Config.Action,
+			Output: step.Config.Output,
+		}); err != nil {
+			return nil, fmt.Errorf("failed to encode step %s: %w", step.Name, err)
+		}
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: step.Name}, &value)
+	}
+
+	var sb strings.Builder
+	encoder := yaml.NewEncoder(&sb)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(root); err != nil {
+		return nil, fmt.Errorf("failed to encode workflow: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode workflow: %w", err)
+	}
+	return []byte(sb.String()), nil
+}
+
+func init() {
+	newCmd.Flags().BoolVar(&overwriteWorkflow, "force", false, "Overwrite the file if it already exists")
+	newCmd.Flags().BoolVarP(&interactiveNew, "interactive", "i", false, "Build the workflow in a full-screen terminal UI with a live chart preview")
+	newCmd.ValidArgsFunction = completeWorkflowFiles
+	rootCmd.AddCommand(newCmd)
+}
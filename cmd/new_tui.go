@@ -0,0 +1,269 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/kris-hansen/comanda/utils/processor"
+)
+
+// Fields asked for each step by the interactive workflow builder, in order
+const (
+	fieldName = iota
+	fieldInput
+	fieldModel
+	fieldAction
+	fieldOutput
+	fieldCount
+)
+
+var builderLabels = [fieldCount]string{
+	"Step name",
+	"Input (file, URL, STDIN or NA)",
+	"Model",
+	"Action",
+	"Output (file or STDOUT)",
+}
+
+// maxChartAction is the number of characters of a step's action shown in the chart
+const maxChartAction = 60
+
+// builderModel is the state of the interactive workflow builder
+type builderModel struct {
+	dslConfig  *processor.DSLConfig
+	proc       *processor.Processor
+	modelNames []string
+
+	field      int                // Field being entered
+	values     [fieldCount]string // Values entered for the current step
+	buffer     []rune             // Text typed for the current field
+	modelIndex int                // Configured model picked with the arrow keys; -1 when none is
+	message    string             // Validation error shown above the preview
+	cancelled  bool
+}
+
+// runWorkflowBuilder builds a workflow in a full-screen terminal UI. It returns nil when the
+// builder is cancelled.
+func runWorkflowBuilder(modelNames []string) (*processor.DSLConfig, error) {
+	dslConfig := &processor.DSLConfig{}
+	m := &builderModel{
+		dslConfig:  dslConfig,
+		proc:       processor.NewProcessor(dslConfig, nil, false),
+		modelNames: modelNames,
+		modelIndex: -1,
+	}
+
+	final, err := tea.NewProgram(m, tea.WithAltScreen()).Run()
+	if err != nil {
+		return nil, fmt.Errorf("interactive builder failed: %w", err)
+	}
+	if final.(*builderModel).cancelled {
+		return nil, nil
+	}
+	return dslConfig, nil
+}
+
+func (m *builderModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m *builderModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+
+	switch key.Type {
+	case tea.KeyCtrlC, tea.KeyEsc:
+		m.cancelled = true
+		return m, tea.Quit
+	case tea.KeyEnter:
+		return m.submit()
+	case tea.KeyBackspace:
+		if len(m.buffer) > 0 {
+			m.buffer = m.buffer[:len(m.buffer)-1]
+		}
+		m.modelIndex = -1
+	case tea.KeyUp, tea.KeyDown:
+		if m.field == fieldModel && len(m.modelNames) > 0 {
+			if key.Type == tea.KeyUp {
+				m.modelIndex = (m.modelIndex - 1 + len(m.modelNames)) % len(m.modelNames)
+			} else {
+				m.modelIndex = (m.modelIndex + 1) % len(m.modelNames)
+			}
+			m.buffer = []rune(m.modelNames[m.modelIndex])
+		}
+	case tea.KeySpace:
+		m.buffer = append(m.buffer, ' ')
+	case tea.KeyRunes:
+		m.buffer = append(m.buffer, key.Runes...)
+		m.modelIndex = -1
+	}
+	return m, nil
+}
+
+// defaultValue returns the value a field takes when it is left empty
+func (m *builderModel) defaultValue(field int) string {
+	switch field {
+	case fieldInput:
+		if len(m.dslConfig.Steps) > 0 {
+			return "STDIN"
+		}
+		return "NA"
+	case fieldModel:
+		return "NA"
+	case fieldOutput:
+		return "STDOUT"
+	}
+	return ""
+}
+
+// startField moves to a field, starting from the value entered for it before, if any
+func (m *builderModel) startField(field int) {
+	m.field = field
+	m.buffer = []rune(m.values[field])
+	m.modelIndex = -1
+	for i, name := range m.modelNames {
+		if field == fieldModel && name == m.values[field] {
+			m.modelIndex = i
+		}
+	}
+}
+
+// submit accepts the current field and moves to the next one, adding the step after its output
+func (m *builderModel) submit() (tea.Model, tea.Cmd) {
+	value := strings.TrimSpace(string(m.buffer))
+	if value == "" {
+		value = m.defaultValue(m.field)
+	}
+	m.message = ""
+
+	switch m.field {
+	case fieldName:
+		if value == "" {
+			return m, tea.Quit
+		}
+		if _, exists := findStep(m.dslConfig, value); exists {
+			m.message = fmt.Sprintf("A step named %s already exists.", value)
+			return m, nil
+		}
+	case fieldAction:
+		if value == "" {
+			m.message = "An action is required."
+			return m, nil
+		}
+	}
+
+	m.values[m.field] = value
+	if m.field < fieldOutput {
+		m.startField(m.field + 1)
+		return m, nil
+	}
+
+	step := m.draftStep()
+	if err := m.proc.ValidateStep(step); err != nil {
+		// The entered values are kept, so only the field at fault needs to be changed
+		m.message = fmt.Sprintf("%v\nThe step was not added; press Enter to keep a value or change it.", err)
+		m.startField(fieldName)
+		return m, nil
+	}
+	m.dslConfig.Steps = append(m.dslConfig.Steps, step)
+	m.values = [fieldCount]string{}
+	m.startField(fieldName)
+	return m, nil
+}
+
+// draftStep returns the step being entered, with the values entered so far
+func (m *builderModel) draftStep() processor.Step {
+	return processor.Step{
+		Name: m.values[fieldName],
+		Config: processor.StepConfig{
+			Input:  m.values[fieldInput],
+			Model:  m.values[fieldModel],
+			Action: m.values[fieldAction],
+			Output: m.values[fieldOutput],
+		},
+	}
+}
+
+func (m *builderModel) View() string {
+	var b strings.Builder
+	b.WriteString("New workflow: Enter accepts a field, an empty step name writes the file, Esc cancels\n\n")
+
+	fmt.Fprintf(&b, "Step %d\n", len(m.dslConfig.Steps)+1)
+	for field := fieldName; field < m.field; field++ {
+		fmt.Fprintf(&b, "  %s: %s\n", builderLabels[field], m.values[field])
+	}
+	label := builderLabels[m.field]
+	if m.field == fieldName && len(m.dslConfig.Steps) > 0 {
+		label += " (empty to finish)"
+	}
+	if def := m.defaultValue(m.field); def != "" {
+		label += fmt.Sprintf(" [%s]", def)
+	}
+	fmt.Fprintf(&b, "  %s: %s█\n", label, string(m.buffer))
+
+	if m.field == fieldModel && len(m.modelNames) > 0 {
+		b.WriteString("\n  Configured models (Up and Down to pick, or type a name):\n")
+		for i, name := range m.modelNames {
+			marker := "  "
+			if i == m.modelIndex {
+				marker = "> "
+			}
+			fmt.Fprintf(&b, "  %s%s\n", marker, name)
+		}
+	}
+
+	if m.message != "" {
+		fmt.Fprintf(&b, "\n%s\n", m.message)
+	}
+
+	// The preview includes the step being entered once it has a name
+	steps := m.dslConfig.Steps
+	if m.field > fieldName {
+		steps = append(append([]processor.Step{}, steps...), m.draftStep())
+	}
+	b.WriteString("\nPreview\n\n")
+	if len(steps) == 0 {
+		b.WriteString("  (no steps yet)\n")
+	} else {
+		b.WriteString(workflowChart(steps))
+	}
+	return b.String()
+}
+
+// workflowChart draws the steps as a chart, one box per step, with an arrow to each step that
+// reads the previous step's output from STDIN
+func workflowChart(steps []processor.Step) string {
+	var b strings.Builder
+	for i, step := range steps {
+		if i > 0 {
+			if fmt.Sprint(step.Config.Input) == "STDIN" {
+				b.WriteString("    │\n    ▼\n")
+			} else {
+				b.WriteString("\n")
+			}
+		}
+
+		action := strings.Join(strings.Fields(fmt.Sprint(step.Config.Action)), " ")
+		if runes := []rune(action); len(runes) > maxChartAction {
+			action = string(runes[:maxChartAction-1]) + "…"
+		}
+		fmt.Fprintf(&b, "┌─ %s\n", step.Name)
+		for _, row := range [][2]string{
+			{"input", fmt.Sprint(step.Config.Input)},
+			{"model", fmt.Sprint(step.Config.Model)},
+			{"action", action},
+			{"output", fmt.Sprint(step.Config.Output)},
+		} {
+			if row[1] == "" {
+				row[1] = "…"
+			}
+			fmt.Fprintf(&b, "│ %-7s %s\n", row[0], row[1])
+		}
+		b.WriteString("└─\n")
+	}
+	return b.String()
+}
@@ -2,6 +2,8 @@ package cmd
 
 import (
 	"bufio"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -9,33 +11,75 @@ import (
 	"strings"
 
 	"github.com/spf13/cobra"
-	"gopkg.in/yaml.v3"
 
 	"github.com/kris-hansen/comanda/utils/config"
+	"github.com/kris-hansen/comanda/utils/logging"
 	"github.com/kris-hansen/comanda/utils/processor"
 )
 
+var reportFile string
+var showSummary bool
+var sandboxDirs []string
+var checkpointRun bool
+var continueFrom string
+var varAssignments []string
+var varFile string
+var onlyStep string
+var fromStep string
+var toStep string
+var envName string
+var maxCost float64
+var outputDir string
+
 var processCmd = &cobra.Command{
 	Use:   "process [files...]",
 	Short: "Process YAML DSL configuration files",
 	Long:  `Process one or more DSL configuration files and execute the specified actions.`,
 	Args:  cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
+		logger := logging.New("CLI", verbose)
+
 		// Get environment file path
 		envPath := config.GetEnvPath()
 
 		// Load environment configuration
-		if verbose {
-			fmt.Printf("[DEBUG] Loading environment configuration from %s\n", envPath)
-		}
+		logger.Debugf("Loading environment configuration from %s", envPath)
 
 		envConfig, err := config.LoadEnvConfigWithPassword(envPath)
 		if err != nil {
 			log.Fatalf("Error loading environment configuration: %v", err)
 		}
 
-		if verbose {
-			fmt.Println("[DEBUG] Environment configuration loaded successfully")
+		logger.Debugf("Environment configuration loaded successfully")
+
+		// --step runs a single step, --from and --to a range of steps
+		if onlyStep != "" {
+			if fromStep != "" || toStep != "" {
+				log.Fatalf("--step cannot be combined with --from or --to")
+			}
+			fromStep, toStep = onlyStep, onlyStep
+		}
+		if fromStep != "" && (checkpointRun || continueFrom != "") {
+			log.Fatalf("--step and --from cannot be combined with --checkpoint or --continue-from")
+		}
+
+		// Variables from --var-file, overridden by individual --var flags
+		cliVariables := make(map[string]string)
+		if varFile != "" {
+			fileVariables, err := processor.LoadVariablesFile(varFile)
+			if err != nil {
+				log.Fatalf("Error loading variables: %v", err)
+			}
+			for name, value := range fileVariables {
+				cliVariables[name] = value
+			}
+		}
+		flagVariables, err := processor.ParseVariableAssignments(varAssignments)
+		if err != nil {
+			log.Fatalf("Error parsing variables: %v", err)
+		}
+		for name, value := range flagVariables {
+			cliVariables[name] = value
 		}
 
 		// Check if there's data on STDIN
@@ -58,53 +102,65 @@ var processCmd = &cobra.Command{
 			stdinData = builder.String()
 		}
 
-		for _, file := range args {
-			fmt.Printf("\nProcessing DSL file: %s\n", file)
+		if maxCost < 0 {
+			log.Fatalf("--max-cost must be a positive amount")
+		}
 
-			// Read YAML file
-			if verbose {
-				fmt.Printf("[DEBUG] Reading YAML file: %s\n", file)
-			}
-			yamlFile, err := os.ReadFile(file)
+		var reports []*processor.RunReport
+		// The cost limit covers every file of the run
+		var spent float64
+
+		for _, file := range args {
+			// Output tokens can take a file over the limit; the remaining files are then not run
+			remainingCost, err := processor.RemainingCost(maxCost, spent)
 			if err != nil {
-				log.Printf("Error reading YAML file %s: %v\n", file, err)
-				continue
+				logger.Errorf("stopped before processing DSL file %s: %v", file, err)
+				break
 			}
 
-			// Parse YAML while preserving order
-			var node yaml.Node
-			err = yaml.Unmarshal(yamlFile, &node)
+			fmt.Printf("\nProcessing DSL file: %s\n", file)
+
+			// Read and parse YAML while preserving step order and resolving includes
+			logger.Debugf("Reading YAML file: %s", file)
+			dslConfig, err := processor.ParseDSLFile(file, "")
 			if err != nil {
-				log.Printf("Error parsing YAML file %s: %v\n", file, err)
+				logger.Errorf("failed to load %s: %v", file, err)
 				continue
 			}
 
-			// Convert YAML nodes to Steps slice preserving order
-			var dslConfig processor.DSLConfig
-			// The document node should have one child which is the mapping
-			if len(node.Content) > 0 && node.Content[0].Kind == yaml.MappingNode {
-				mapping := node.Content[0]
-				// Each pair of nodes in the mapping represents a key and its value
-				for i := 0; i < len(mapping.Content); i += 2 {
-					name := mapping.Content[i].Value
-					var config processor.StepConfig
-					err = mapping.Content[i+1].Decode(&config)
-					if err != nil {
-						log.Printf("Error decoding step %s in %s: %v\n", name, file, err)
-						continue
-					}
-					dslConfig.Steps = append(dslConfig.Steps, processor.Step{
-						Name:   name,
-						Config: config,
-					})
+			// Patch the steps with the overrides for the selected environment
+			env := overrideEnvironment(dslConfig)
+			if env != "" {
+				if err := dslConfig.ApplyOverrides(env); err != nil {
+					logger.Errorf("failed to load %s: %v", file, err)
+					continue
 				}
+				logger.Debugf("Applied overrides for environment %s", env)
 			}
 
 			// Create processor
-			if verbose {
-				fmt.Printf("[DEBUG] Creating processor for %s\n", file)
+			logger.Debugf("Creating processor for %s", file)
+			proc := processor.NewProcessor(dslConfig, envConfig, verbose)
+
+			// Command line variables take precedence over the workflow's vars block
+			proc.SetVariables(cliVariables)
+
+			// Restrict file access to the sandbox directories, if any
+			proc.SetSandbox(sandboxDirs)
+
+			// Save progress after each step, and skip completed steps when continuing a failed run
+			if checkpointRun || continueFrom != "" {
+				proc.SetCheckpoint(processor.CheckpointPath(file), continueFrom)
 			}
-			proc := processor.NewProcessor(&dslConfig, envConfig, verbose)
+
+			// Write relative file outputs under the output directory
+			proc.SetOutputDir(outputDir)
+
+			// Run only the selected steps
+			proc.SetStepRange(fromStep, toStep)
+
+			// Stop before a model call would go over the cost limit
+			proc.SetMaxCost(remainingCost)
 
 			// If we have STDIN data, set it as initial output
 			if stdinData != "" {
@@ -113,6 +169,19 @@ var processCmd = &cobra.Command{
 
 			// Print configuration summary before processing
 			fmt.Println("\nConfiguration:")
+			if dslConfig.Name != "" {
+				if dslConfig.Version != "" {
+					fmt.Printf("\nWorkflow: %s (version %s)\n", dslConfig.Name, dslConfig.Version)
+				} else {
+					fmt.Printf("\nWorkflow: %s\n", dslConfig.Name)
+				}
+			}
+			if dslConfig.Description != "" {
+				fmt.Printf("Description: %s\n", dslConfig.Description)
+			}
+			if env != "" {
+				fmt.Printf("Environment: %s\n", env)
+			}
 			for _, step := range dslConfig.Steps {
 				fmt.Printf("\nStep: %s\n", step.Name)
 				inputs := proc.NormalizeStringSlice(step.Config.Input)
@@ -130,14 +199,85 @@ var processCmd = &cobra.Command{
 			fmt.Println()
 
 			// Run processor
-			if err := proc.Process(); err != nil {
-				log.Printf("Error processing DSL file %s: %v\n", file, err)
+			err = proc.Process()
+
+			report := proc.Report()
+			report.File = file
+			reports = append(reports, report)
+			spent += proc.Cost()
+
+			// The summary goes to stderr so it does not mix with the workflow's output
+			if showSummary {
+				fmt.Fprintf(os.Stderr, "\nSummary of %s:\n%s", file, report.Summary())
+			}
+
+			if errors.Is(err, processor.ErrBudgetExceeded) {
+				logger.Errorf("stopped processing DSL file %s: %v", file, err)
+				break
+			}
+			if err != nil {
+				logger.Errorf("failed to process DSL file %s: %v", file, err)
 				continue
 			}
 		}
+
+		if reportFile != "" {
+			if err := writeReport(reportFile, reports); err != nil {
+				log.Fatalf("Error writing run report: %v", err)
+			}
+			logger.Debugf("Run report written to %s", reportFile)
+		}
 	},
 }
 
+// overrideEnvironment returns the environment whose overrides apply to a workflow: the one named
+// by --env, or else the active profile when the workflow has overrides for it
+func overrideEnvironment(dslConfig *processor.DSLConfig) string {
+	if envName != "" {
+		return envName
+	}
+	if profile := config.ActiveProfile(); profile != config.DefaultProfile {
+		if _, ok := dslConfig.Overrides[profile]; ok {
+			return profile
+		}
+	}
+	return ""
+}
+
+// writeReport saves the run reports for all processed files as JSON
+func writeReport(path string, reports []*processor.RunReport) error {
+	if reports == nil {
+		reports = []*processor.RunReport{}
+	}
+	data, err := json.MarshalIndent(reports, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal report: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write report to %s: %w", path, err)
+	}
+	return nil
+}
+
 func init() {
+	processCmd.Flags().StringVar(&reportFile, "report", "", "Write a JSON run report to the given file")
+	processCmd.Flags().BoolVar(&showSummary, "summary", false, "Print a summary of each run to stderr when it ends")
+	processCmd.ValidArgsFunction = completeWorkflowFiles
+	processCmd.Flags().StringVar(&outputDir, "output-dir", "", "Write relative file outputs under the given directory instead of the current directory")
+	processCmd.Flags().StringSliceVar(&sandboxDirs, "sandbox", nil, "Restrict workflow file reads and writes to the given directories")
+	processCmd.Flags().BoolVar(&checkpointRun, "checkpoint", false, "Save the output of each completed step so a failed run can be continued")
+	processCmd.Flags().StringVar(&continueFrom, "continue-from", "", "Continue a checkpointed run from the given step, reusing the outputs of earlier steps")
+	processCmd.Flags().StringArrayVar(&varAssignments, "var", nil, "Set a workflow variable as name=value; can be repeated")
+	processCmd.Flags().StringVar(&varFile, "var-file", "", "Load workflow variables from a YAML or JSON file")
+	processCmd.Flags().StringVar(&onlyStep, "step", "", "Run only the given step, reading its inputs from existing files")
+	processCmd.Flags().StringVar(&fromStep, "from", "", "Start the run at the given step, skipping earlier steps")
+	processCmd.Flags().StringVar(&toStep, "to", "", "End the run after the given step")
+	processCmd.Flags().Float64Var(&maxCost, "max-cost", 0, "Stop before a model call would take the estimated cost of the run over this many US dollars")
+	processCmd.Flags().StringVar(&envName, "env", "", "Apply the workflow's overrides for the given environment (default: the active profile, if the workflow has overrides for it)")
+	processCmd.RegisterFlagCompletionFunc("continue-from", completeSteps)
+	processCmd.RegisterFlagCompletionFunc("step", completeSteps)
+	processCmd.RegisterFlagCompletionFunc("from", completeSteps)
+	processCmd.RegisterFlagCompletionFunc("to", completeSteps)
+	processCmd.RegisterFlagCompletionFunc("env", completeEnvironments)
 	rootCmd.AddCommand(processCmd)
 }
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/kris-hansen/comanda/utils/config"
+	"github.com/spf13/cobra"
+)
+
+var profileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Manage configuration profiles",
+	Long: `Manage named configuration profiles. Each profile has its own environment file in
+~/.comanda/profiles/<name>/.env and is selected with --profile or COMANDA_PROFILE.
+The default profile uses COMANDA_ENV or .env as before.`,
+}
+
+var profileListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List configuration profiles",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		profiles, err := config.ListProfiles()
+		if err != nil {
+			return err
+		}
+
+		active := config.ActiveProfile()
+		for _, name := range append([]string{config.DefaultProfile}, profiles...) {
+			marker := " "
+			if name == active {
+				marker = "*"
+			}
+			fmt.Printf("%s %s\n", marker, name)
+		}
+		return nil
+	},
+}
+
+var profileCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create a configuration profile",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := config.CreateProfile(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s Created profile %s (%s)\n", greenCheckmark, args[0], path)
+		fmt.Printf("Configure it with: comanda configure --profile %s\n", args[0])
+		return nil
+	},
+}
+
+var profileDeleteCmd = &cobra.Command{
+	Use:               "delete <name>",
+	Short:             "Delete a configuration profile",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeProfiles,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := config.DeleteProfile(args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("%s Deleted profile %s\n", greenCheckmark, args[0])
+		return nil
+	},
+}
+
+// completeProfiles completes profile names
+func completeProfiles(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	profiles, _ := config.ListProfiles()
+	return append([]string{config.DefaultProfile}, profiles...), cobra.ShellCompDirectiveNoFileComp
+}
+
+func init() {
+	profileCmd.AddCommand(profileListCmd)
+	profileCmd.AddCommand(profileCreateCmd)
+	profileCmd.AddCommand(profileDeleteCmd)
+	configureCmd.AddCommand(profileCmd)
+}
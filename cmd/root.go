@@ -6,20 +6,38 @@ import (
 	"strings"
 
 	"github.com/kris-hansen/comanda/utils/config"
+	"github.com/kris-hansen/comanda/utils/logging"
 	"github.com/spf13/cobra"
 )
 
 var verbose bool
 var debug bool
+var logFormat string
+var profile string
 
 var rootCmd = &cobra.Command{
 	Use:   "comanda",
 	Short: "A DSL processor for handling model interactions",
 	Long: `comanda is a command line tool that processes DSL configurations 
 for model interactions and executes the specified actions.`,
-	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 		config.Verbose = verbose
 		config.Debug = debug
+		config.Profile = profile
+
+		// Creating a profile is the one command that may name a profile that does not exist yet
+		if cmd != profileCreateCmd {
+			if err := config.CheckProfile(config.ActiveProfile()); err != nil {
+				return err
+			}
+		}
+
+		format, err := logging.ParseFormat(logFormat)
+		if err != nil {
+			return err
+		}
+		logging.SetFormat(format)
+		return nil
 	},
 	Run: func(cmd *cobra.Command, args []string) {
 		cmd.Help()
@@ -29,6 +47,10 @@ for model interactions and executes the specified actions.`,
 func init() {
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "verbose output")
 	rootCmd.PersistentFlags().BoolVar(&debug, "debug", false, "enable debug logging")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "log format: text or json")
+	rootCmd.RegisterFlagCompletionFunc("log-format", completeValues("text", "json"))
+	rootCmd.PersistentFlags().StringVar(&profile, "profile", "", "configuration profile to use (default: COMANDA_PROFILE or the default profile)")
+	rootCmd.RegisterFlagCompletionFunc("profile", completeProfiles)
 }
 
 func Execute() {
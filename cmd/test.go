@@ -0,0 +1,202 @@
+package cmd
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kris-hansen/comanda/utils/config"
+	"github.com/kris-hansen/comanda/utils/processor"
+)
+
+var recordFixtures bool
+
+// expectedExt is the extension of the fixture holding a workflow's expected output
+const expectedExt = ".expected"
+
+var testCmd = &cobra.Command{
+	Use:   "test <dir>",
+	Short: "Run workflows as test cases and compare their output with fixtures",
+	Long: `Run every workflow (*.yaml) under a directory that has an expected-output fixture
+next to it, named after the workflow with a .expected extension, and compare the workflow's
+final output with the fixture. Each workflow runs from its own directory, and its file outputs
+are written to a temporary directory. Use echo models, or environment overrides selected with
+--env, to make runs repeatable without calling a model API.
+
+With --record, the current output of every workflow is saved as its fixture instead.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envConfig, err := config.LoadEnvConfigWithPassword(config.GetEnvPath())
+		if err != nil {
+			return fmt.Errorf("error loading environment configuration: %w", err)
+		}
+
+		workflows, err := findTestWorkflows(args[0])
+		if err != nil {
+			return err
+		}
+
+		passed, failed, skipped := 0, 0, 0
+		for _, workflow := range workflows {
+			fixture := strings.TrimSuffix(workflow, filepath.Ext(workflow)) + expectedExt
+			expected, err := os.ReadFile(fixture)
+			if os.IsNotExist(err) && !recordFixtures {
+				skipped++
+				continue
+			}
+			if err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to read %s: %w", fixture, err)
+			}
+
+			actual, err := runTestWorkflow(workflow, envConfig)
+			if err != nil {
+				fmt.Printf("FAIL %s: %v\n", workflow, err)
+				failed++
+				continue
+			}
+
+			if recordFixtures {
+				if err := os.WriteFile(fixture, []byte(actual), 0644); err != nil {
+					return fmt.Errorf("failed to write %s: %w", fixture, err)
+				}
+				fmt.Printf("RECORDED %s\n", fixture)
+				passed++
+				continue
+			}
+
+			if normalizeOutput(actual) == normalizeOutput(string(expected)) {
+				fmt.Printf("PASS %s\n", workflow)
+				passed++
+				continue
+			}
+			fmt.Printf("FAIL %s: output differs from %s\n", workflow, fixture)
+			for _, line := range lineDiff(normalizeOutput(string(expected)), normalizeOutput(actual)) {
+				fmt.Printf("    %s\n", line)
+			}
+			failed++
+		}
+
+		if recordFixtures {
+			fmt.Printf("\n%d recorded, %d failed\n", passed, failed)
+		} else {
+			fmt.Printf("\n%d passed, %d failed, %d skipped without a fixture\n", passed, failed, skipped)
+		}
+		if failed > 0 {
+			os.Exit(1)
+		}
+		return nil
+	},
+}
+
+// findTestWorkflows returns the workflow files under dir in lexical order
+func findTestWorkflows(dir string) ([]string, error) {
+	var workflows []string
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() && (strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml")) {
+			workflows = append(workflows, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read test directory %s: %w", dir, err)
+	}
+	return workflows, nil
+}
+
+// runTestWorkflow runs a workflow from its own directory, with file outputs going to a temporary
+// directory, and returns its final output
+func runTestWorkflow(path string, envConfig *config.EnvConfig) (string, error) {
+	dslConfig, err := processor.ParseDSLFile(path, "")
+	if err != nil {
+		return "", err
+	}
+	if env := overrideEnvironment(dslConfig); env != "" {
+		if err := dslConfig.ApplyOverrides(env); err != nil {
+			return "", err
+		}
+	}
+
+	outputDir, err := os.MkdirTemp("", "comanda-test-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("failed to get working directory: %w", err)
+	}
+	if err := os.Chdir(filepath.Dir(path)); err != nil {
+		return "", fmt.Errorf("failed to change to the workflow's directory: %w", err)
+	}
+	defer os.Chdir(wd)
+
+	proc := processor.NewProcessor(dslConfig, envConfig, verbose)
+	proc.SetOutputDir(outputDir)
+	if err := proc.Process(); err != nil {
+		return "", err
+	}
+	return proc.LastOutput(), nil
+}
+
+// normalizeOutput drops trailing whitespace from each line and blank lines at the end, so
+// fixtures do not fail on editor whitespace
+func normalizeOutput(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n")
+}
+
+// lineDiff lists the lines removed from expected with a leading "-" and the lines added in
+// actual with a leading "+", keeping the longest run of lines the two have in common
+func lineDiff(expected, actual string) []string {
+	a, b := strings.Split(expected, "\n"), strings.Split(actual, "\n")
+
+	// common[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	common := make([][]int, len(a)+1)
+	for i := range common {
+		common[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	var diff []string
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			i++
+			j++
+		case i < len(a) && (j == len(b) || common[i+1][j] >= common[i][j+1]):
+			diff = append(diff, "- "+a[i])
+			i++
+		default:
+			diff = append(diff, "+ "+b[j])
+			j++
+		}
+	}
+	return diff
+}
+
+func init() {
+	testCmd.Flags().BoolVar(&recordFixtures, "record", false, "Save each workflow's current output as its expected-output fixture")
+	testCmd.Flags().StringVar(&envName, "env", "", "Apply the workflows' overrides for the given environment")
+	testCmd.RegisterFlagCompletionFunc("env", completeEnvironments)
+	rootCmd.AddCommand(testCmd)
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	runtimedebug "runtime/debug"
+	"strings"
+
+	"github.com/kris-hansen/comanda/utils/config"
+	"github.com/spf13/cobra"
+)
+
+// Build metadata, set at build time with
+// -ldflags "-X github.com/kris-hansen/comanda/cmd.version=... -X ...cmd.commit=... -X ...cmd.buildDate=..."
+var (
+	version   = "dev"
+	commit    = ""
+	buildDate = ""
+)
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Show version and build information",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		resolvedVersion, resolvedCommit, resolvedDate := buildInfo()
+		fmt.Printf("comanda %s\n", resolvedVersion)
+		fmt.Printf("  Commit:     %s\n", resolvedCommit)
+		fmt.Printf("  Built:      %s\n", resolvedDate)
+		fmt.Printf("  Go version: %s\n", runtime.Version())
+		fmt.Printf("  Platform:   %s/%s\n", runtime.GOOS, runtime.GOARCH)
+		fmt.Printf("  Providers:  %s\n", strings.Join(config.KnownProviders(), ", "))
+
+		envPath := config.GetEnvPath()
+		fmt.Printf("  Config:     %s (%s)\n", envPath, configStatus(envPath))
+	},
+}
+
+// buildInfo returns the version, commit and build date, falling back to the module and VCS
+// information recorded by the Go toolchain when they were not set with -ldflags
+func buildInfo() (string, string, string) {
+	resolvedVersion, resolvedCommit, resolvedDate := version, commit, buildDate
+	if info, ok := runtimedebug.ReadBuildInfo(); ok {
+		if resolvedVersion == "dev" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+			resolvedVersion = info.Main.Version
+		}
+		for _, setting := range info.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && resolvedCommit == "":
+				resolvedCommit = setting.Value
+			case setting.Key == "vcs.time" && resolvedDate == "":
+				resolvedDate = setting.Value
+			}
+		}
+	}
+	if resolvedCommit == "" {
+		resolvedCommit = "unknown"
+	}
+	if resolvedDate == "" {
+		resolvedDate = "unknown"
+	}
+	return resolvedVersion, resolvedCommit, resolvedDate
+}
+
+// configStatus describes whether the environment file exists and is encrypted
+func configStatus(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "not found"
+		}
+		return fmt.Sprintf("unreadable: %v", err)
+	}
+	if config.IsEncrypted(data) {
+		return "encrypted"
+	}
+	return "not encrypted"
+}
+
+func init() {
+	rootCmd.AddCommand(versionCmd)
+}
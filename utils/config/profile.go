@@ -0,0 +1,139 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+)
+
+// DefaultProfile is the name of the profile that uses COMANDA_ENV or .env
+const DefaultProfile = "default"
+
+// Profile is the profile selected with --profile; when empty, COMANDA_PROFILE is used
+var Profile string
+
+// profileNamePattern restricts profile names to characters that are safe in a directory name
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// ActiveProfile returns the selected profile from --profile or COMANDA_PROFILE, or the default profile
+func ActiveProfile() string {
+	if Profile != "" {
+		return Profile
+	}
+	if profile := os.Getenv("COMANDA_PROFILE"); profile != "" {
+		return profile
+	}
+	return DefaultProfile
+}
+
+// ValidateProfileName checks that a profile name can be used as a directory name
+func ValidateProfileName(name string) error {
+	if !profileNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q: use letters, digits, dashes and underscores", name)
+	}
+	return nil
+}
+
+// ProfilesDir returns the directory holding named profiles, ~/.comanda/profiles
+func ProfilesDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("error finding home directory: %w", err)
+	}
+	return filepath.Join(home, ".comanda", "profiles"), nil
+}
+
+// ProfileEnvPath returns the environment file path of a named profile
+func ProfileEnvPath(name string) (string, error) {
+	if err := ValidateProfileName(name); err != nil {
+		return "", err
+	}
+	dir, err := ProfilesDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name, ".env"), nil
+}
+
+// CheckProfile returns an error unless the named profile is the default profile or has been created
+func CheckProfile(name string) error {
+	if name == DefaultProfile {
+		return nil
+	}
+	path, err := ProfileEnvPath(name)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Dir(path)); err != nil {
+		return fmt.Errorf("profile %s does not exist; create it with: comanda configure profile create %s", name, name)
+	}
+	return nil
+}
+
+// ListProfiles returns the names of the named profiles, sorted
+func ListProfiles() ([]string, error) {
+	dir, err := ProfilesDir()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading profiles directory: %w", err)
+	}
+
+	var profiles []string
+	for _, entry := range entries {
+		if entry.IsDir() && profileNamePattern.MatchString(entry.Name()) {
+			profiles = append(profiles, entry.Name())
+		}
+	}
+	sort.Strings(profiles)
+	return profiles, nil
+}
+
+// CreateProfile creates a named profile with an empty environment file and returns the file's path
+func CreateProfile(name string) (string, error) {
+	if name == DefaultProfile {
+		return "", fmt.Errorf("the %s profile always exists", DefaultProfile)
+	}
+	path, err := ProfileEnvPath(name)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(filepath.Dir(path)); err == nil {
+		return "", fmt.Errorf("profile %s already exists", name)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", fmt.Errorf("error creating profile directory: %w", err)
+	}
+	if err := os.WriteFile(path, nil, 0600); err != nil {
+		return "", fmt.Errorf("error creating profile env file: %w", err)
+	}
+	return path, nil
+}
+
+// DeleteProfile removes a named profile and its environment file
+func DeleteProfile(name string) error {
+	if name == DefaultProfile {
+		return fmt.Errorf("the %s profile cannot be deleted", DefaultProfile)
+	}
+	path, err := ProfileEnvPath(name)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(path)
+	if _, err := os.Stat(dir); err != nil {
+		return fmt.Errorf("profile %s does not exist", name)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("error deleting profile %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestProfiles(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("COMANDA_ENV", "")
+	t.Setenv("COMANDA_PROFILE", "")
+	defer func() { Profile = "" }()
+
+	if got := GetEnvPath(); got != ".env" {
+		t.Errorf("GetEnvPath() with default profile = %q, want .env", got)
+	}
+
+	path, err := CreateProfile("work")
+	if err != nil {
+		t.Fatalf("CreateProfile() unexpected error: %v", err)
+	}
+	want := filepath.Join(home, ".comanda", "profiles", "work", ".env")
+	if path != want {
+		t.Errorf("CreateProfile() = %q, want %q", path, want)
+	}
+	if _, err := CreateProfile("work"); err == nil {
+		t.Error("CreateProfile() expected error for existing profile")
+	}
+	if _, err := CreateProfile("../escape"); err == nil {
+		t.Error("CreateProfile() expected error for invalid name")
+	}
+	if _, err := CreateProfile("personal"); err != nil {
+		t.Fatalf("CreateProfile() unexpected error: %v", err)
+	}
+
+	profiles, err := ListProfiles()
+	if err != nil {
+		t.Fatalf("ListProfiles() unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(profiles, []string{"personal", "work"}) {
+		t.Errorf("ListProfiles() = %v, want [personal work]", profiles)
+	}
+
+	t.Setenv("COMANDA_PROFILE", "personal")
+	if got := GetEnvPath(); got != filepath.Join(home, ".comanda", "profiles", "personal", ".env") {
+		t.Errorf("GetEnvPath() with COMANDA_PROFILE = %q", got)
+	}
+
+	// --profile takes precedence over COMANDA_PROFILE
+	Profile = "work"
+	if got := GetEnvPath(); got != want {
+		t.Errorf("GetEnvPath() with --profile = %q, want %q", got, want)
+	}
+
+	if err := CheckProfile("work"); err != nil {
+		t.Errorf("CheckProfile() unexpected error for existing profile: %v", err)
+	}
+	if err := CheckProfile(DefaultProfile); err != nil {
+		t.Errorf("CheckProfile() unexpected error for default profile: %v", err)
+	}
+	if err := CheckProfile("nope"); err == nil {
+		t.Error("CheckProfile() expected error for missing profile")
+	}
+	if err := CheckProfile("../escape"); err == nil {
+		t.Error("CheckProfile() expected error for invalid name")
+	}
+
+	if err := DeleteProfile("work"); err != nil {
+		t.Fatalf("DeleteProfile() unexpected error: %v", err)
+	}
+	if err := DeleteProfile("work"); err == nil {
+		t.Error("DeleteProfile() expected error for missing profile")
+	}
+	if err := DeleteProfile(DefaultProfile); err == nil {
+		t.Error("DeleteProfile() expected error for default profile")
+	}
+}
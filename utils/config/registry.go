@@ -0,0 +1,136 @@
+package config
+
+import (
+	"strings"
+)
+
+// knownProviders lists the supported providers in display order
+var knownProviders = []string{"openai", "anthropic", "google", "xai", "deepseek", "ollama", "echo"}
+
+// knownModels lists the models known for each provider. Ollama models are installed locally
+// and OpenAI models can also be fetched from the API, so these lists are not exhaustive. Any
+// echo- name is accepted by the echo provider.
+var knownModels = map[string][]string{
+	"openai": {
+		"gpt-4o",
+		"gpt-4o-mini",
+		"gpt-4-turbo",
+		"gpt-4",
+		"gpt-3.5-turbo",
+		"o1-preview",
+		"o1-mini",
+	},
+	"anthropic": {
+		"claude-3-5-sonnet-20241022",
+		"claude-3-5-sonnet-latest",
+		"claude-3-5-haiku-latest",
+	},
+	"google": {
+		"gemini-1.5-flash",
+		"gemini-1.5-flash-8b",
+		"gemini-1.5-pro",
+		"gemini-1.0-pro",
+		"gemini-2.0-flash-exp",
+		"text-embedding-004",
+		"aqa",
+	},
+	"xai": {
+		"grok-beta",
+		"grok-vision-beta",
+	},
+	"deepseek": {
+		"deepseek-chat",
+		"deepseek-coder",
+		"deepseek-vision",
+		"deepseek-reasoner",
+	},
+	"echo": {
+		"echo",
+	},
+}
+
+// KnownProviders returns the names of all supported providers
+func KnownProviders() []string {
+	return append([]string(nil), knownProviders...)
+}
+
+// KnownModels returns the models known for a provider
+func KnownModels(provider string) []string {
+	return append([]string(nil), knownModels[provider]...)
+}
+
+// knownModelModes lists the modes supported by recognized model families.
+// Entries are matched by prefix in order, so more specific prefixes come first.
+var knownModelModes = []struct {
+	prefix string
+	modes  []ModelMode
+}{
+	{"gpt-4o", []ModelMode{TextMode, VisionMode, FileMode}},
+	{"gpt-4-turbo", []ModelMode{TextMode, VisionMode, FileMode}},
+	{"gpt-4", []ModelMode{TextMode, FileMode}},
+	{"gpt-3.5", []ModelMode{TextMode}},
+	{"o1", []ModelMode{TextMode}},
+	{"claude-3-5-sonnet", []ModelMode{TextMode, VisionMode, FileMode}},
+	{"claude-3-5-haiku", []ModelMode{TextMode, FileMode}},
+	{"grok-vision", []ModelMode{TextMode, VisionMode}},
+	{"grok", []ModelMode{TextMode}},
+	{"deepseek-vision", []ModelMode{TextMode, VisionMode}},
+	{"deepseek", []ModelMode{TextMode}},
+	{"gemini-1.0", []ModelMode{TextMode}},
+	{"gemini-", []ModelMode{TextMode, VisionMode, FileMode}},
+	{"echo", []ModelMode{TextMode, VisionMode, FileMode}},
+}
+
+// DefaultModesForModel returns the known modes for a recognized model, or nil if the model is unknown
+func DefaultModesForModel(modelName string) []ModelMode {
+	modelName = strings.ToLower(modelName)
+	for _, known := range knownModelModes {
+		if strings.HasPrefix(modelName, known.prefix) {
+			modes := make([]ModelMode, len(known.modes))
+			copy(modes, known.modes)
+			return modes
+		}
+	}
+	return nil
+}
+
+// Model tiers used to choose a model for model: auto. Low tier models are small and fast, medium
+// tier models are general-purpose flagship models, and high tier models reason before answering.
+const (
+	TierLow    = "low"
+	TierMedium = "medium"
+	TierHigh   = "high"
+)
+
+// knownModelTiers lists the tier of recognized model families.
+// Entries are matched by prefix in order, so more specific prefixes come first.
+var knownModelTiers = []struct {
+	prefix string
+	tier   string
+}{
+	{"gpt-4o-mini", TierLow},
+	{"gpt-3.5", TierLow},
+	{"gpt-4", TierMedium},
+	{"o1", TierHigh},
+	{"claude-3-5-haiku", TierLow},
+	{"claude-3-5-sonnet", TierMedium},
+	{"gemini-1.5-flash", TierLow},
+	{"gemini-1.0", TierLow},
+	{"gemini-1.5-pro", TierMedium},
+	{"gemini-2.0-flash", TierMedium},
+	{"grok", TierMedium},
+	{"deepseek-reasoner", TierHigh},
+	{"deepseek-chat", TierMedium},
+	{"deepseek-coder", TierMedium},
+}
+
+// ModelTier returns the tier of a recognized model, or an empty string if the model is unknown
+func ModelTier(modelName string) string {
+	modelName = strings.ToLower(modelName)
+	for _, known := range knownModelTiers {
+		if strings.HasPrefix(modelName, known.prefix) {
+			return known.tier
+		}
+	}
+	return ""
+}
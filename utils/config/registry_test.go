@@ -0,0 +1,74 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDefaultModesForModel(t *testing.T) {
+	tests := []struct {
+		model string
+		want  []ModelMode
+	}{
+		{"gpt-4o", []ModelMode{TextMode, VisionMode, FileMode}},
+		{"gpt-4o-mini", []ModelMode{TextMode, VisionMode, FileMode}},
+		{"o1-mini", []ModelMode{TextMode}},
+		{"claude-3-5-sonnet-latest", []ModelMode{TextMode, VisionMode, FileMode}},
+		{"grok-vision-beta", []ModelMode{TextMode, VisionMode}},
+		{"grok-beta", []ModelMode{TextMode}},
+		{"gemini-1.0-pro", []ModelMode{TextMode}},
+		{"gemini-1.5-pro", []ModelMode{TextMode, VisionMode, FileMode}},
+		{"llama3.2", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			got := DefaultModesForModel(tt.model)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DefaultModesForModel(%q) = %v, want %v", tt.model, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKnownModels(t *testing.T) {
+	for _, provider := range KnownProviders() {
+		if provider == "ollama" {
+			if models := KnownModels(provider); len(models) != 0 {
+				t.Errorf("KnownModels(%q) = %v, want none", provider, models)
+			}
+			continue
+		}
+		if len(KnownModels(provider)) == 0 {
+			t.Errorf("KnownModels(%q) returned no models", provider)
+		}
+	}
+
+	if models := KnownModels("unknown"); models != nil {
+		t.Errorf("KnownModels(\"unknown\") = %v, want nil", models)
+	}
+}
+
+func TestModelTier(t *testing.T) {
+	tests := []struct {
+		model string
+		want  string
+	}{
+		{"gpt-4o-mini", TierLow},
+		{"gpt-4o", TierMedium},
+		{"o1-preview", TierHigh},
+		{"claude-3-5-haiku-latest", TierLow},
+		{"claude-3-5-sonnet-latest", TierMedium},
+		{"gemini-1.5-flash-8b", TierLow},
+		{"deepseek-reasoner", TierHigh},
+		{"llama3.2", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			if got := ModelTier(tt.model); got != tt.want {
+				t.Errorf("ModelTier(%q) = %q, want %q", tt.model, got, tt.want)
+			}
+		})
+	}
+}
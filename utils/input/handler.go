@@ -100,6 +100,8 @@ func (h *Handler) getMimeType(path string) string {
 		return "text/markdown"
 	case ".csv":
 		return "text/csv"
+	case ".tsv":
+		return "text/tab-separated-values"
 
 	// Documents
 	case ".pdf":
@@ -0,0 +1,197 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level represents the severity of a log message
+type Level string
+
+const (
+	LevelError Level = "error"
+	LevelWarn  Level = "warn"
+	LevelInfo  Level = "info"
+	LevelDebug Level = "debug"
+)
+
+// Format represents how log lines are rendered
+type Format string
+
+const (
+	FormatText Format = "text" // Human-readable output (default)
+	FormatJSON Format = "json" // One JSON object per line
+)
+
+var (
+	mu      sync.Mutex
+	format            = FormatText
+	output  io.Writer = os.Stderr
+	secrets []string  // Values replaced by SecretMask in every log line
+)
+
+// SecretMask replaces registered secret values in log messages
+const SecretMask = "[SECRET]"
+
+// ParseFormat converts a format name into a Format
+func ParseFormat(name string) (Format, error) {
+	switch Format(strings.ToLower(name)) {
+	case FormatText, "":
+		return FormatText, nil
+	case FormatJSON:
+		return FormatJSON, nil
+	default:
+		return "", fmt.Errorf("unknown log format %q (expected text or json)", name)
+	}
+}
+
+// SetFormat sets the format used by all loggers
+func SetFormat(f Format) {
+	mu.Lock()
+	defer mu.Unlock()
+	format = f
+}
+
+// CurrentFormat returns the format used by all loggers
+func CurrentFormat() Format {
+	mu.Lock()
+	defer mu.Unlock()
+	return format
+}
+
+// SetOutput sets the writer used by all loggers
+func SetOutput(w io.Writer) {
+	mu.Lock()
+	defer mu.Unlock()
+	output = w
+}
+
+// AddSecret registers a value that must never appear in log output
+func AddSecret(value string) {
+	if value == "" {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for _, secret := range secrets {
+		if secret == value {
+			return
+		}
+	}
+	secrets = append(secrets, value)
+}
+
+// MaskSecrets replaces registered secret values in text with SecretMask
+func MaskSecrets(text string) string {
+	mu.Lock()
+	defer mu.Unlock()
+	return maskSecrets(text)
+}
+
+// maskSecrets replaces registered secret values; the caller must hold mu
+func maskSecrets(text string) string {
+	for _, secret := range secrets {
+		text = strings.ReplaceAll(text, secret, SecretMask)
+	}
+	return text
+}
+
+// entry is the JSON representation of a log line
+type entry struct {
+	Time      string `json:"time"`
+	Level     Level  `json:"level"`
+	Component string `json:"component,omitempty"`
+	Step      string `json:"step,omitempty"`
+	Message   string `json:"message"`
+}
+
+// Logger writes leveled log messages for a component. Debug messages are only written in verbose mode.
+type Logger struct {
+	component string
+	verbose   bool
+	step      string
+}
+
+// New creates a logger for the named component
+func New(component string, verbose bool) *Logger {
+	return &Logger{
+		component: component,
+		verbose:   verbose,
+	}
+}
+
+// SetVerbose enables or disables debug messages
+func (l *Logger) SetVerbose(verbose bool) {
+	l.verbose = verbose
+}
+
+// SetStep sets the workflow step attached to subsequent messages
+func (l *Logger) SetStep(step string) {
+	l.step = step
+}
+
+// Errorf logs an error message
+func (l *Logger) Errorf(format string, args ...interface{}) {
+	l.log(LevelError, format, args...)
+}
+
+// Warnf logs a warning message
+func (l *Logger) Warnf(format string, args ...interface{}) {
+	l.log(LevelWarn, format, args...)
+}
+
+// Infof logs an informational message
+func (l *Logger) Infof(format string, args ...interface{}) {
+	l.log(LevelInfo, format, args...)
+}
+
+// Debugf logs a debug message if verbose mode is enabled
+func (l *Logger) Debugf(format string, args ...interface{}) {
+	if !l.verbose {
+		return
+	}
+	l.log(LevelDebug, format, args...)
+}
+
+func (l *Logger) log(level Level, msgFormat string, args ...interface{}) {
+	message := fmt.Sprintf(msgFormat, args...)
+
+	mu.Lock()
+	defer mu.Unlock()
+	fmt.Fprintln(output, l.render(level, maskSecrets(message)))
+}
+
+// render formats a single log line in the configured format
+func (l *Logger) render(level Level, message string) string {
+	if format == FormatJSON {
+		data, err := json.Marshal(entry{
+			Time:      time.Now().UTC().Format(time.RFC3339Nano),
+			Level:     level,
+			Component: l.component,
+			Step:      l.step,
+			Message:   message,
+		})
+		if err == nil {
+			return string(data)
+		}
+	}
+
+	switch level {
+	case LevelError:
+		return "Error: " + message
+	case LevelWarn:
+		return "Warning: " + message
+	case LevelDebug:
+		if l.component != "" {
+			return fmt.Sprintf("[DEBUG][%s] %s", l.component, message)
+		}
+		return "[DEBUG] " + message
+	default:
+		return message
+	}
+}
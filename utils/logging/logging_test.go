@@ -0,0 +1,91 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLoggerText(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	SetFormat(FormatText)
+	defer SetOutput(os.Stderr)
+
+	logger := New("DSL", false)
+	logger.Debugf("hidden %d", 1)
+	logger.Errorf("failed %s", "step")
+	logger.SetVerbose(true)
+	logger.Debugf("shown %d", 2)
+
+	want := "Error: failed step\n[DEBUG][DSL] shown 2\n"
+	if buf.String() != want {
+		t.Errorf("text output = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestLoggerJSON(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	SetFormat(FormatJSON)
+	defer func() {
+		SetOutput(os.Stderr)
+		SetFormat(FormatText)
+	}()
+
+	logger := New("DSL", true)
+	logger.SetStep("summarize")
+	logger.Warnf("slow response")
+
+	var got entry
+	if err := json.Unmarshal([]byte(strings.TrimSpace(buf.String())), &got); err != nil {
+		t.Fatalf("output is not valid JSON: %v (%q)", err, buf.String())
+	}
+	if got.Level != LevelWarn || got.Component != "DSL" || got.Step != "summarize" || got.Message != "slow response" {
+		t.Errorf("JSON entry = %+v", got)
+	}
+	if got.Time == "" {
+		t.Error("JSON entry is missing a timestamp")
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Format
+		wantErr bool
+	}{
+		{"", FormatText, false},
+		{"text", FormatText, false},
+		{"JSON", FormatJSON, false},
+		{"xml", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseFormat(tt.name)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseFormat(%q) = %q, %v; want %q, error %v", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestLoggerMasksSecrets(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	SetFormat(FormatText)
+	defer SetOutput(os.Stderr)
+
+	AddSecret("xoxb-12345")
+	AddSecret("")
+	New("DSL", true).Debugf("posting with token %s", "xoxb-12345")
+
+	want := "[DEBUG][DSL] posting with token [SECRET]\n"
+	if buf.String() != want {
+		t.Errorf("text output = %q, want %q", buf.String(), want)
+	}
+	if got := MaskSecrets("Bearer xoxb-12345"); got != "Bearer [SECRET]" {
+		t.Errorf("MaskSecrets() = %q, want the token masked", got)
+	}
+}
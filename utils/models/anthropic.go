@@ -10,31 +10,46 @@ import (
 	"strings"
 
 	"github.com/kris-hansen/comanda/utils/fileutil"
+	"github.com/kris-hansen/comanda/utils/logging"
+	"github.com/kris-hansen/comanda/utils/retry"
 )
 
 // AnthropicProvider handles Anthropic family of models
 type AnthropicProvider struct {
-	apiKey  string
-	config  ModelConfig
-	verbose bool
+	apiKey      string
+	config      ModelConfig
+	verbose     bool
+	lastUsage   TokenUsage
+	rateLimit   RateLimit // Rate limit reported with the most recent response
+	retryConfig retry.Config
+	httpClient  *http.Client // nil uses the default client
+
+	systemPrompt     string // Sent as the request's system prompt when set
+	thinkingBudget   int    // Tokens models may spend on extended thinking; zero disables it
+	includeReasoning bool   // Prepend the thinking to the answer in a <reasoning> block
+	baseURL          string // Address of the Messages API, without the /messages path
 }
 
+// anthropicBaseURL is the default address of the Anthropic API
+const anthropicBaseURL = "https://api.anthropic.com/v1"
+
 // NewAnthropicProvider creates a new Anthropic provider instance
 func NewAnthropicProvider() *AnthropicProvider {
 	return &AnthropicProvider{
 		config: ModelConfig{
 			Temperature: 0.7,
-			MaxTokens:   2000,
+			MaxTokens:   DefaultMaxTokens,
 			TopP:        1.0,
 		},
+		rateLimit:   unknownRateLimit,
+		retryConfig: retry.DefaultRetryConfig,
+		baseURL:     anthropicBaseURL,
 	}
 }
 
 // debugf prints debug information if verbose mode is enabled
 func (a *AnthropicProvider) debugf(format string, args ...interface{}) {
-	if a.verbose {
-		fmt.Printf("[DEBUG][Anthropic] "+format+"\n", args...)
-	}
+	logging.New("Anthropic", a.verbose).Debugf(format, args...)
 }
 
 // Name returns the provider name
@@ -68,9 +83,14 @@ type anthropicMessage struct {
 }
 
 type anthropicContent struct {
-	Type   string           `json:"type"`
-	Text   string           `json:"text,omitempty"`
-	Source *anthropicSource `json:"source,omitempty"`
+	Type         string                 `json:"type"`
+	Text         string                 `json:"text,omitempty"`
+	Source       *anthropicSource       `json:"source,omitempty"`
+	CacheControl *anthropicCacheControl `json:"cache_control,omitempty"`
+}
+
+type anthropicCacheControl struct {
+	Type string `json:"type"`
 }
 
 type anthropicSource struct {
@@ -80,17 +100,33 @@ type anthropicSource struct {
 }
 
 type anthropicRequest struct {
-	Model       string             `json:"model"`
-	Messages    []anthropicMessage `json:"messages"`
-	MaxTokens   int                `json:"max_tokens"`
-	Temperature float64            `json:"temperature"`
-	TopP        float64            `json:"top_p"`
+	Model         string             `json:"model"`
+	System        string             `json:"system,omitempty"`
+	Messages      []anthropicMessage `json:"messages"`
+	MaxTokens     int                `json:"max_tokens"`
+	Temperature   float64            `json:"temperature"`
+	TopP          float64            `json:"top_p,omitempty"`
+	StopSequences []string           `json:"stop_sequences,omitempty"`
+	Thinking      *anthropicThinking `json:"thinking,omitempty"`
+	Stream        bool               `json:"stream,omitempty"`
+}
+
+// anthropicThinking enables extended thinking with a token budget
+type anthropicThinking struct {
+	Type         string `json:"type"`
+	BudgetTokens int    `json:"budget_tokens"`
 }
 
 type anthropicResponse struct {
 	Content []struct {
-		Text string `json:"text"`
+		Type     string `json:"type"`
+		Text     string `json:"text"`
+		Thinking string `json:"thinking"`
 	} `json:"content"`
+	Usage struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
 	Error *struct {
 		Message string `json:"message"`
 	} `json:"error,omitempty"`
@@ -110,76 +146,102 @@ func (a *AnthropicProvider) SendPrompt(modelName string, prompt string) (string,
 	}
 
 	a.debugf("Model validation passed, preparing API call")
-	a.debugf("Using configuration: Temperature=%.2f, MaxTokens=%d, TopP=%.2f",
-		a.config.Temperature, a.config.MaxTokens, a.config.TopP)
 
-	reqBody := anthropicRequest{
-		Model: modelName,
-		Messages: []anthropicMessage{
-			{
-				Role: "user",
-				Content: []anthropicContent{
-					{
-						Type: "text",
-						Text: prompt,
-					},
-				},
-			},
+	content := []anthropicContent{
+		{
+			Type: "text",
+			Text: prompt,
 		},
-		MaxTokens:   a.config.MaxTokens,
-		Temperature: a.config.Temperature,
-		TopP:        a.config.TopP,
 	}
 
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %v", err)
-	}
-
-	req, err := http.NewRequest("POST", "https://api.anthropic.com/v1/messages", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %v", err)
-	}
+	return a.sendMessage(modelName, content, "")
+}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("x-api-key", a.apiKey)
-	req.Header.Set("anthropic-version", "2023-06-01")
+// SendPromptStream sends a prompt to the specified model and passes the answer to handler as it
+// is generated. Extended thinking is not streamed, but is included in the returned response when
+// reasoning is included.
+func (a *AnthropicProvider) SendPromptStream(modelName string, prompt string, handler StreamHandler) (string, error) {
+	a.debugf("Preparing to stream prompt to model: %s", modelName)
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("failed to send request: %v", err)
+	if a.apiKey == "" {
+		return "", fmt.Errorf("Anthropic provider not configured: missing API key")
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read response: %v", err)
+	if !a.ValidateModel(modelName) {
+		return "", fmt.Errorf("invalid Anthropic model: %s", modelName)
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+	a.lastUsage = TokenUsage{}
+	reqBody := a.messageRequest(modelName, []anthropicContent{{Type: "text", Text: prompt}})
+	reqBody.Stream = true
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %v", err)
 	}
 
-	var response anthropicResponse
-	if err := json.Unmarshal(body, &response); err != nil {
-		return "", fmt.Errorf("failed to unmarshal response: %v", err)
+	resp, err := retry.WithRetry(func() (*http.Response, error) {
+		return a.open(jsonData, "")
+	}, a.retryConfig)
+	if err != nil {
+		return "", err
 	}
+	defer resp.Body.Close()
 
-	if response.Error != nil {
-		return "", fmt.Errorf("API error: %s", response.Error.Message)
+	var thinking, answer strings.Builder
+	err = readSSE(resp.Body, func(data string) error {
+		var event anthropicStreamEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return fmt.Errorf("failed to unmarshal stream event: %v", err)
+		}
+		switch event.Type {
+		case "message_start":
+			a.lastUsage.InputTokens = event.Message.Usage.InputTokens
+		case "message_delta":
+			a.lastUsage.OutputTokens = event.Usage.OutputTokens
+		case "content_block_delta":
+			thinking.WriteString(event.Delta.Thinking)
+			if event.Delta.Text != "" {
+				answer.WriteString(event.Delta.Text)
+				return handler.OnChunk(event.Delta.Text)
+			}
+		case "error":
+			return fmt.Errorf("API error: %s", event.Error.Message)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
 	}
-
-	if len(response.Content) == 0 {
+	if answer.Len() == 0 {
 		return "", fmt.Errorf("no response content returned from Anthropic")
 	}
 
-	result := response.Content[0].Text
-	a.debugf("API call completed, response length: %d characters", len(result))
+	result := formatReasoning(thinking.String(), answer.String(), a.includeReasoning)
+	a.debugf("Stream completed, response length: %d characters", len(result))
 
 	return result, nil
 }
 
+// anthropicStreamEvent is the part of a Messages API stream event read by the provider
+type anthropicStreamEvent struct {
+	Type    string `json:"type"`
+	Message struct {
+		Usage struct {
+			InputTokens int `json:"input_tokens"`
+		} `json:"usage"`
+	} `json:"message"`
+	Delta struct {
+		Text     string `json:"text"`
+		Thinking string `json:"thinking"`
+	} `json:"delta"`
+	Usage struct {
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
 // SendPromptWithFile sends a prompt along with a file to the specified model and returns the response
 func (a *AnthropicProvider) SendPromptWithFile(modelName string, prompt string, file FileInput) (string, error) {
 	a.debugf("Preparing to send prompt with file to model: %s", modelName)
@@ -248,71 +310,190 @@ func (a *AnthropicProvider) SendPromptWithFile(modelName string, prompt string,
 		}
 	}
 
-	reqBody := anthropicRequest{
-		Model: modelName,
-		Messages: []anthropicMessage{
-			{
-				Role:    "user",
-				Content: content,
-			},
+	// Add beta header for PDF support when sending PDF files
+	var betaHeader string
+	if file.MimeType == "application/pdf" {
+		betaHeader = "pdfs-2024-09-25"
+	}
+
+	return a.sendMessage(modelName, content, betaHeader)
+}
+
+// SendPromptWithCache sends a prompt with a large reusable context marked for prompt caching
+func (a *AnthropicProvider) SendPromptWithCache(modelName string, cachedContext string, prompt string) (string, error) {
+	a.debugf("Preparing to send cached prompt to model: %s", modelName)
+	a.debugf("Cached context length: %d characters, prompt length: %d characters", len(cachedContext), len(prompt))
+
+	if a.apiKey == "" {
+		return "", fmt.Errorf("Anthropic provider not configured: missing API key")
+	}
+
+	if !a.ValidateModel(modelName) {
+		return "", fmt.Errorf("invalid Anthropic model: %s", modelName)
+	}
+
+	// The cache breakpoint covers everything up to and including the context block
+	content := []anthropicContent{
+		{
+			Type:         "text",
+			Text:         cachedContext,
+			CacheControl: &anthropicCacheControl{Type: "ephemeral"},
+		},
+		{
+			Type: "text",
+			Text: prompt,
 		},
-		MaxTokens:   a.config.MaxTokens,
-		Temperature: a.config.Temperature,
-		TopP:        a.config.TopP,
 	}
 
-	jsonData, err := json.Marshal(reqBody)
+	return a.sendMessage(modelName, content, "prompt-caching-2024-07-31")
+}
+
+// sendMessage sends a single user message to the Messages API and returns the response text
+func (a *AnthropicProvider) sendMessage(modelName string, content []anthropicContent, betaHeader string) (string, error) {
+	a.lastUsage = TokenUsage{}
+	jsonData, err := json.Marshal(a.messageRequest(modelName, content))
 	if err != nil {
 		return "", fmt.Errorf("failed to marshal request: %v", err)
 	}
 
-	req, err := http.NewRequest("POST", "https://api.anthropic.com/v1/messages", bytes.NewBuffer(jsonData))
+	body, err := retry.WithRetry(func() ([]byte, error) {
+		return a.post(jsonData, betaHeader)
+	}, a.retryConfig)
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %v", err)
+		return "", err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("x-api-key", a.apiKey)
-	req.Header.Set("anthropic-version", "2023-06-01")
+	var response anthropicResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", fmt.Errorf("failed to unmarshal response: %v", err)
+	}
 
-	// Add beta header for PDF support when sending PDF files
-	if file.MimeType == "application/pdf" {
-		req.Header.Set("anthropic-beta", "pdfs-2024-09-25")
+	if response.Error != nil {
+		return "", fmt.Errorf("API error: %s", response.Error.Message)
+	}
+
+	var thinking, answer strings.Builder
+	for _, block := range response.Content {
+		switch block.Type {
+		case "thinking":
+			thinking.WriteString(block.Thinking)
+		case "text", "":
+			answer.WriteString(block.Text)
+		}
+	}
+	if answer.Len() == 0 {
+		return "", fmt.Errorf("no response content returned from Anthropic")
+	}
+
+	a.lastUsage = TokenUsage{
+		InputTokens:  response.Usage.InputTokens,
+		OutputTokens: response.Usage.OutputTokens,
+	}
+
+	result := formatReasoning(thinking.String(), answer.String(), a.includeReasoning)
+	a.debugf("API call completed, response length: %d characters", len(result))
+
+	return result, nil
+}
+
+// messageRequest builds a Messages API request for a single user message with the configured settings
+func (a *AnthropicProvider) messageRequest(modelName string, content []anthropicContent) anthropicRequest {
+	a.debugf("Using configuration: Temperature=%.2f, MaxTokens=%d, TopP=%.2f",
+		a.config.Temperature, a.config.MaxTokens, a.config.TopP)
+
+	reqBody := anthropicRequest{
+		Model:  modelName,
+		System: a.systemPrompt,
+		Messages: []anthropicMessage{
+			{
+				Role:    "user",
+				Content: content,
+			},
+		},
+		MaxTokens:     a.config.MaxTokens,
+		Temperature:   a.config.Temperature,
+		TopP:          a.config.TopP,
+		StopSequences: a.config.Stop,
+	}
+	if a.config.Seed != nil {
+		a.debugf("Anthropic does not support seed; ignoring it")
+	}
+	if a.thinkingBudget > 0 {
+		if supportsThinking(modelName) {
+			// Thinking counts toward max_tokens and requires the default sampling settings
+			reqBody.Thinking = &anthropicThinking{Type: "enabled", BudgetTokens: a.thinkingBudget}
+			reqBody.MaxTokens += a.thinkingBudget
+			if clamped, ok := ClampOutputTokens(modelName, reqBody.MaxTokens); ok {
+				a.debugf("Lowering max_tokens with thinking from %d to the %d allowed by model %s", reqBody.MaxTokens, clamped, modelName)
+				reqBody.MaxTokens = clamped
+			}
+			reqBody.Temperature = 1
+			reqBody.TopP = 0
+		} else {
+			a.debugf("Model %s does not support extended thinking; ignoring the thinking budget", modelName)
+		}
 	}
+	return reqBody
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+// post sends a request body to the Messages API and returns the response body
+func (a *AnthropicProvider) post(jsonData []byte, betaHeader string) ([]byte, error) {
+	resp, err := a.open(jsonData, betaHeader)
 	if err != nil {
-		return "", fmt.Errorf("failed to send request: %v", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %v", err)
+		return nil, fmt.Errorf("failed to read response: %v", err)
 	}
+	return body, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+// open sends a request body to the Messages API and returns the response of a successful
+// request. The caller must close the response body.
+func (a *AnthropicProvider) open(jsonData []byte, betaHeader string) (*http.Response, error) {
+	req, err := http.NewRequest("POST", a.baseURL+"/messages", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
 	}
 
-	var response anthropicResponse
-	if err := json.Unmarshal(body, &response); err != nil {
-		return "", fmt.Errorf("failed to unmarshal response: %v", err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", a.apiKey)
+	req.Header.Set("anthropic-version", "2023-06-01")
+	if betaHeader != "" {
+		req.Header.Set("anthropic-beta", betaHeader)
 	}
 
-	if response.Error != nil {
-		return "", fmt.Errorf("API error: %s", response.Error.Message)
+	a.rateLimit = unknownRateLimit
+	resp, err := httpClientOrDefault(a.httpClient).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
+	a.rateLimit = parseRateLimit(resp.Header)
+	a.debugf("Rate limit: %s", a.rateLimit)
 
-	if len(response.Content) == 0 {
-		return "", fmt.Errorf("no response content returned from Anthropic")
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if a.rateLimit.RetryAfter > 0 {
+			a.debugf("Rate limited, waiting %s before retrying", a.rateLimit.RetryAfter)
+		}
+		return nil, withRetryAfter(retry.WithStatus(fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body)), resp.StatusCode), a.rateLimit)
 	}
 
-	result := response.Content[0].Text
-	a.debugf("API call completed, response length: %d characters", len(result))
+	return resp, nil
+}
 
-	return result, nil
+// LastUsage returns the token usage reported for the most recent API call
+func (a *AnthropicProvider) LastUsage() TokenUsage {
+	return a.lastUsage
+}
+
+// LastRateLimit returns the rate limit reported with the most recent API response
+func (a *AnthropicProvider) LastRateLimit() RateLimit {
+	return a.rateLimit
 }
 
 // ValidateModel checks if the specific Anthropic model variant is valid
@@ -322,6 +503,7 @@ func (a *AnthropicProvider) ValidateModel(modelName string) bool {
 		"claude-3-5-sonnet-20241022",
 		"claude-3-5-sonnet-latest",
 		"claude-3-5-haiku-latest",
+		"claude-3-7-sonnet-latest",
 	}
 
 	modelName = strings.ToLower(modelName)
@@ -337,6 +519,9 @@ func (a *AnthropicProvider) ValidateModel(modelName string) bool {
 	modelFamilies := []string{
 		"claude-3-5-sonnet",
 		"claude-3-5-haiku",
+		"claude-3-7-sonnet",
+		"claude-sonnet-4",
+		"claude-opus-4",
 	}
 
 	for _, family := range modelFamilies {
@@ -365,7 +550,47 @@ func (a *AnthropicProvider) GetConfig() ModelConfig {
 	return a.config
 }
 
+// SetSystemPrompt sets the system prompt sent with each request
+func (a *AnthropicProvider) SetSystemPrompt(prompt string) {
+	a.systemPrompt = prompt
+}
+
+// SetThinkingBudget sets the tokens models may spend on extended thinking; zero disables it
+func (a *AnthropicProvider) SetThinkingBudget(tokens int) {
+	a.thinkingBudget = tokens
+}
+
+// SetIncludeReasoning sets whether responses include the model's extended thinking
+func (a *AnthropicProvider) SetIncludeReasoning(include bool) {
+	a.includeReasoning = include
+}
+
+// supportsThinking reports whether a Claude model supports extended thinking, which arrived with
+// Claude 3.7 Sonnet and is supported by every later model
+func supportsThinking(modelName string) bool {
+	modelName = strings.ToLower(modelName)
+	return !strings.HasPrefix(modelName, "claude-3-") || strings.HasPrefix(modelName, "claude-3-7-")
+}
+
 // SetVerbose enables or disables verbose mode
 func (a *AnthropicProvider) SetVerbose(verbose bool) {
 	a.verbose = verbose
 }
+
+// SetRetryConfig sets how failed API calls are retried
+func (a *AnthropicProvider) SetRetryConfig(config retry.Config) {
+	a.retryConfig = config
+}
+
+// SetHTTPClient sets the HTTP client used for API calls
+func (a *AnthropicProvider) SetHTTPClient(client *http.Client) {
+	a.httpClient = client
+}
+
+// SetBaseURL sets the address API calls are sent to; an empty URL restores the default
+func (a *AnthropicProvider) SetBaseURL(url string) {
+	a.baseURL = anthropicBaseURL
+	if url != "" {
+		a.baseURL = strings.TrimSuffix(url, "/")
+	}
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestAnthropicExtendedThinking(t *testing.T) {
+	var request anthropicRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request = anthropicRequest{}
+		json.NewDecoder(r.Body).Decode(&request)
+		w.Write([]byte(`{"content":[{"type":"thinking","thinking":"Two plus two is four."},{"type":"text","text":"4"}]}`))
+	}))
+	defer server.Close()
+	target, _ := url.Parse(server.URL)
+
+	provider := NewAnthropicProvider()
+	provider.SetHTTPClient(&http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		req.URL.Scheme = target.Scheme
+		req.URL.Host = target.Host
+		return http.DefaultTransport.RoundTrip(req)
+	})})
+	if err := provider.Configure("test-key"); err != nil {
+		t.Fatalf("Configure() unexpected error: %v", err)
+	}
+	provider.SetThinkingBudget(2048)
+	maxTokens := provider.GetConfig().MaxTokens
+
+	tests := []struct {
+		name         string
+		model        string
+		include      bool
+		want         string
+		wantThinking bool
+	}{
+		{"thinking stripped by default", "claude-sonnet-4-20250514", false, "4", true},
+		{"thinking included on request", "claude-3-7-sonnet-latest", true, "<reasoning>\nTwo plus two is four.\n</reasoning>\n\n4", true},
+		{"opus 4", "claude-opus-4-1-20250805", false, "4", true},
+		{"unsupported model ignores the budget", "claude-3-5-haiku-latest", false, "4", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider.SetIncludeReasoning(tt.include)
+			got, err := provider.SendPrompt(tt.model, "What is 2+2?")
+			if err != nil {
+				t.Fatalf("SendPrompt() unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("SendPrompt() = %q, want %q", got, tt.want)
+			}
+			if !tt.wantThinking {
+				if request.Thinking != nil || request.MaxTokens != maxTokens {
+					t.Errorf("request thinking = %+v with max_tokens %d, want no thinking", request.Thinking, request.MaxTokens)
+				}
+				return
+			}
+			if request.Thinking == nil || request.Thinking.BudgetTokens != 2048 {
+				t.Fatalf("request thinking = %+v, want a budget of 2048", request.Thinking)
+			}
+			if request.MaxTokens != maxTokens+2048 || request.Temperature != 1 || request.TopP != 0 {
+				t.Errorf("request max_tokens = %d, temperature = %v, top_p = %v, want %d, 1 and none",
+					request.MaxTokens, request.Temperature, request.TopP, maxTokens+2048)
+			}
+		})
+	}
+}
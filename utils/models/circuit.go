@@ -0,0 +1,89 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Default circuit breaker settings, used for fields left unset in the configuration
+const (
+	DefaultFailureThreshold = 5
+	DefaultFailureWindow    = 5 * time.Minute
+	DefaultCircuitCooldown  = time.Minute
+)
+
+// ErrCircuitOpen is returned for calls to a provider whose circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// CircuitBreaker stops calls to a provider that keeps failing. After Threshold consecutive
+// failures within Window, calls fail fast for Cooldown. The first call after the cooldown is let
+// through; the circuit closes again if it succeeds and reopens if it fails.
+type CircuitBreaker struct {
+	Threshold int
+	Window    time.Duration
+	Cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  []time.Time // Times of the consecutive failures since the last success
+	openUntil time.Time
+	probing   bool // Set while the call after a cooldown decides whether the circuit closes
+	now       func() time.Time
+}
+
+// NewCircuitBreaker returns a closed circuit breaker. A threshold below 1 never opens.
+func NewCircuitBreaker(threshold int, window, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{Threshold: threshold, Window: window, Cooldown: cooldown, now: time.Now}
+}
+
+// Allow returns an error wrapping ErrCircuitOpen while the circuit is open
+func (b *CircuitBreaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.openUntil.IsZero() {
+		return nil
+	}
+	if wait := b.openUntil.Sub(b.now()); wait > 0 {
+		return fmt.Errorf("%w after %d consecutive failures, retrying in %s", ErrCircuitOpen, b.Threshold, wait.Round(time.Second))
+	}
+	b.openUntil = time.Time{}
+	b.probing = true
+	return nil
+}
+
+// Record updates the circuit with the result of a call
+func (b *CircuitBreaker) Record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil {
+		b.failures = nil
+		b.probing = false
+		return
+	}
+	if b.Threshold < 1 {
+		return
+	}
+
+	now := b.now()
+	if b.probing {
+		b.probing = false
+		b.openUntil = now.Add(b.Cooldown)
+		return
+	}
+
+	// Only failures within the window count towards opening the circuit
+	recent := b.failures[:0]
+	for _, failure := range b.failures {
+		if b.Window <= 0 || now.Sub(failure) < b.Window {
+			recent = append(recent, failure)
+		}
+	}
+	b.failures = append(recent, now)
+	if len(b.failures) >= b.Threshold {
+		b.failures = nil
+		b.openUntil = now.Add(b.Cooldown)
+	}
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	breaker := NewCircuitBreaker(3, time.Minute, 30*time.Second)
+	breaker.now = func() time.Time { return now }
+	failure := errors.New("service unavailable")
+
+	// Failures spread out beyond the window do not open the circuit
+	breaker.Record(failure)
+	now = now.Add(50 * time.Second)
+	breaker.Record(failure)
+	now = now.Add(50 * time.Second)
+	breaker.Record(failure)
+	if err := breaker.Allow(); err != nil {
+		t.Fatalf("Allow() after spread out failures = %v, want nil", err)
+	}
+
+	// A success resets the count
+	breaker.Record(nil)
+	breaker.Record(failure)
+	breaker.Record(failure)
+	if err := breaker.Allow(); err != nil {
+		t.Fatalf("Allow() after a success and two failures = %v, want nil", err)
+	}
+
+	breaker.Record(failure)
+	if err := breaker.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Allow() after three failures = %v, want ErrCircuitOpen", err)
+	}
+
+	// After the cooldown one call is let through, and its failure reopens the circuit
+	now = now.Add(30 * time.Second)
+	if err := breaker.Allow(); err != nil {
+		t.Fatalf("Allow() after cooldown = %v, want nil", err)
+	}
+	breaker.Record(failure)
+	if err := breaker.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Allow() after failed probe = %v, want ErrCircuitOpen", err)
+	}
+
+	// A successful call after the cooldown closes it
+	now = now.Add(30 * time.Second)
+	if err := breaker.Allow(); err != nil {
+		t.Fatalf("Allow() after second cooldown = %v, want nil", err)
+	}
+	breaker.Record(nil)
+	breaker.Record(failure)
+	if err := breaker.Allow(); err != nil {
+		t.Errorf("Allow() after a successful probe and one failure = %v, want nil", err)
+	}
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	breaker := NewCircuitBreaker(-1, time.Minute, time.Minute)
+	for i := 0; i < 10; i++ {
+		breaker.Record(errors.New("service unavailable"))
+	}
+	if err := breaker.Allow(); err != nil {
+		t.Errorf("Allow() = %v, want nil when disabled", err)
+	}
+}
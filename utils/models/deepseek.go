@@ -1,30 +1,67 @@
 package models
 
 import (
-	"context"
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
 	"strings"
 
 	"github.com/kris-hansen/comanda/utils/fileutil"
+	"github.com/kris-hansen/comanda/utils/logging"
+	"github.com/kris-hansen/comanda/utils/retry"
 	openai "github.com/sashabaranov/go-openai"
 )
 
 // DeepseekProvider handles Deepseek family of models
 type DeepseekProvider struct {
-	apiKey  string
-	config  ModelConfig
-	verbose bool
+	apiKey      string
+	config      ModelConfig
+	verbose     bool
+	baseURL     string
+	retryConfig retry.Config
+	httpClient  *http.Client // nil uses the default client
+
+	includeReasoning bool   // Prepend reasoning_content to the answer in a <reasoning> block
+	systemPrompt     string // Sent as a system message before the user message when set
 }
 
+// deepseekChatResponse is the part of a chat completion response read by the provider.
+// reasoning_content holds the chain of thought of deepseek-reasoner.
+type deepseekChatResponse struct {
+	Choices []struct {
+		Message struct {
+			Content          string `json:"content"`
+			ReasoningContent string `json:"reasoning_content"`
+		} `json:"message"`
+	} `json:"choices"`
+}
+
+// deepseekStreamChunk is the part of a streamed chat completion chunk read by the provider
+type deepseekStreamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content          string `json:"content"`
+			ReasoningContent string `json:"reasoning_content"`
+		} `json:"delta"`
+	} `json:"choices"`
+}
+
+// deepseekBaseURL is the default address of the DeepSeek API
+const deepseekBaseURL = "https://api.deepseek.com/v1"
+
 // NewDeepseekProvider creates a new Deepseek provider instance
 func NewDeepseekProvider() *DeepseekProvider {
 	return &DeepseekProvider{
 		config: ModelConfig{
 			Temperature:         0.7,
-			MaxTokens:           2000,
-			MaxCompletionTokens: 2000,
+			MaxTokens:           DefaultMaxTokens,
+			MaxCompletionTokens: DefaultMaxTokens,
 			TopP:                1.0,
 		},
+		baseURL:     deepseekBaseURL,
+		retryConfig: retry.DefaultRetryConfig,
 	}
 }
 
@@ -35,9 +72,7 @@ func (d *DeepseekProvider) Name() string {
 
 // debugf prints debug information if verbose mode is enabled
 func (d *DeepseekProvider) debugf(format string, args ...interface{}) {
-	if d.verbose {
-		fmt.Printf("[DEBUG][Deepseek] "+format+"\n", args...)
-	}
+	logging.New("Deepseek", d.verbose).Debugf(format, args...)
 }
 
 // SupportsModel checks if the given model name is supported by Deepseek
@@ -70,7 +105,11 @@ func (d *DeepseekProvider) Configure(apiKey string) error {
 func (d *DeepseekProvider) createChatCompletionRequest(modelName string, messages []openai.ChatCompletionMessage) openai.ChatCompletionRequest {
 	req := openai.ChatCompletionRequest{
 		Model:    modelName,
-		Messages: messages,
+		Messages: withSystemMessage(d.systemPrompt, messages),
+		Stop:     d.config.Stop,
+	}
+	if d.config.Seed != nil {
+		d.debugf("DeepSeek does not support seed; ignoring it")
 	}
 
 	// deepseek-reasoner doesn't support temperature parameter
@@ -98,9 +137,36 @@ func (d *DeepseekProvider) SendPrompt(modelName string, prompt string) (string,
 
 	d.debugf("Model validation passed, preparing API call")
 
-	config := openai.DefaultConfig(d.apiKey)
-	config.BaseURL = "https://api.deepseek.com/v1"
-	client := openai.NewClientWithConfig(config)
+	messages := []openai.ChatCompletionMessage{
+		{
+			Role:    openai.ChatMessageRoleUser,
+			Content: prompt,
+		},
+	}
+
+	req := d.createChatCompletionRequest(modelName, messages)
+	response, err := d.complete(req)
+	if err != nil {
+		return "", fmt.Errorf("Deepseek API error: %v", err)
+	}
+	d.debugf("API call completed, response length: %d characters", len(response))
+
+	return response, nil
+}
+
+// SendPromptStream sends a prompt to the specified model and passes the answer to handler as it
+// is generated. The reasoning of deepseek-reasoner is not streamed, but is included in the
+// returned response when reasoning is included.
+func (d *DeepseekProvider) SendPromptStream(modelName string, prompt string, handler StreamHandler) (string, error) {
+	d.debugf("Preparing to stream prompt to model: %s", modelName)
+
+	if d.apiKey == "" {
+		return "", fmt.Errorf("Deepseek provider not configured: missing API key")
+	}
+
+	if !d.SupportsModel(modelName) {
+		return "", fmt.Errorf("invalid Deepseek model: %s", modelName)
+	}
 
 	messages := []openai.ChatCompletionMessage{
 		{
@@ -110,18 +176,43 @@ func (d *DeepseekProvider) SendPrompt(modelName string, prompt string) (string,
 	}
 
 	req := d.createChatCompletionRequest(modelName, messages)
-	resp, err := client.CreateChatCompletion(context.Background(), req)
+	req.Stream = true
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling request: %v", err)
+	}
 
+	resp, err := retry.WithRetry(func() (*http.Response, error) {
+		return d.open(jsonData)
+	}, d.retryConfig)
 	if err != nil {
 		return "", fmt.Errorf("Deepseek API error: %v", err)
 	}
-
-	if len(resp.Choices) == 0 {
-		return "", fmt.Errorf("no response choices returned from Deepseek")
+	defer resp.Body.Close()
+
+	var reasoning, answer strings.Builder
+	err = readSSE(resp.Body, func(data string) error {
+		var chunk deepseekStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("error parsing stream chunk: %v", err)
+		}
+		if len(chunk.Choices) == 0 {
+			return nil
+		}
+		delta := chunk.Choices[0].Delta
+		reasoning.WriteString(delta.ReasoningContent)
+		if delta.Content == "" {
+			return nil
+		}
+		answer.WriteString(delta.Content)
+		return handler.OnChunk(delta.Content)
+	})
+	if err != nil {
+		return "", fmt.Errorf("Deepseek API error: %v", err)
 	}
 
-	response := resp.Choices[0].Message.Content
-	d.debugf("API call completed, response length: %d characters", len(response))
+	response := formatReasoning(reasoning.String(), answer.String(), d.includeReasoning)
+	d.debugf("Stream completed, response length: %d characters", len(response))
 
 	return response, nil
 }
@@ -145,13 +236,9 @@ func (d *DeepseekProvider) SendPromptWithFile(modelName string, prompt string, f
 		return "", fmt.Errorf("failed to read file: %v", err)
 	}
 
-	config := openai.DefaultConfig(d.apiKey)
-	config.BaseURL = "https://api.deepseek.com/v1"
-	client := openai.NewClientWithConfig(config)
-
 	// For image files, handle them using vision capabilities
 	if strings.HasPrefix(file.MimeType, "image/") {
-		return d.handleFileAsVision(client, prompt, fileData, file.MimeType, modelName)
+		return d.handleFileAsVision(prompt, fileData, file.MimeType, modelName)
 	}
 
 	// For other files, include the content as part of the prompt
@@ -166,24 +253,17 @@ func (d *DeepseekProvider) SendPromptWithFile(modelName string, prompt string, f
 	}
 
 	req := d.createChatCompletionRequest(modelName, messages)
-	resp, err := client.CreateChatCompletion(context.Background(), req)
-
+	response, err := d.complete(req)
 	if err != nil {
 		return "", fmt.Errorf("Deepseek API error: %v", err)
 	}
-
-	if len(resp.Choices) == 0 {
-		return "", fmt.Errorf("no response choices returned from Deepseek")
-	}
-
-	response := resp.Choices[0].Message.Content
 	d.debugf("API call completed, response length: %d characters", len(response))
 
 	return response, nil
 }
 
 // handleFileAsVision processes a file as a vision model request
-func (d *DeepseekProvider) handleFileAsVision(client *openai.Client, prompt string, fileData []byte, mimeType string, modelName string) (string, error) {
+func (d *DeepseekProvider) handleFileAsVision(prompt string, fileData []byte, mimeType string, modelName string) (string, error) {
 	// Convert file data to base64 string with proper data URI prefix
 	base64Data := fmt.Sprintf("data:%s;base64,%s", mimeType, string(fileData))
 
@@ -209,17 +289,79 @@ func (d *DeepseekProvider) handleFileAsVision(client *openai.Client, prompt stri
 	}
 
 	req := d.createChatCompletionRequest(modelName, messages)
-	resp, err := client.CreateChatCompletion(context.Background(), req)
-
+	response, err := d.complete(req)
 	if err != nil {
 		return "", fmt.Errorf("Deepseek Vision API error: %v", err)
 	}
+	return response, nil
+}
+
+// complete sends a chat completion request and returns the answer, preceded by the model's
+// reasoning when it is included. The request is sent directly rather than through the OpenAI
+// client, which does not expose reasoning_content.
+func (d *DeepseekProvider) complete(req openai.ChatCompletionRequest) (string, error) {
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling request: %v", err)
+	}
+
+	body, err := retry.WithRetry(func() ([]byte, error) {
+		return d.post(jsonData)
+	}, d.retryConfig)
+	if err != nil {
+		return "", err
+	}
 
+	var resp deepseekChatResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", fmt.Errorf("error parsing response: %v", err)
+	}
 	if len(resp.Choices) == 0 {
-		return "", fmt.Errorf("no response choices returned from Deepseek Vision")
+		return "", fmt.Errorf("no response choices returned")
+	}
+
+	message := resp.Choices[0].Message
+	if message.ReasoningContent != "" {
+		d.debugf("Received %d characters of reasoning content", len(message.ReasoningContent))
+	}
+	return formatReasoning(message.ReasoningContent, message.Content, d.includeReasoning), nil
+}
+
+// post sends a JSON request to the Deepseek chat completions endpoint and returns the response body
+func (d *DeepseekProvider) post(jsonData []byte) ([]byte, error) {
+	resp, err := d.open(jsonData)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %v", err)
+	}
+	return body, nil
+}
+
+// open sends a JSON request to the Deepseek chat completions endpoint and returns the response of
+// a successful request. The caller must close the response body.
+func (d *DeepseekProvider) open(jsonData []byte) (*http.Response, error) {
+	req, err := http.NewRequest("POST", d.baseURL+"/chat/completions", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
 	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+d.apiKey)
 
-	return resp.Choices[0].Message.Content, nil
+	resp, err := httpClientOrDefault(d.httpClient).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, retry.WithStatus(fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body)), resp.StatusCode)
+	}
+	return resp, nil
 }
 
 // SetConfig updates the provider configuration
@@ -241,3 +383,31 @@ func (d *DeepseekProvider) GetConfig() ModelConfig {
 func (d *DeepseekProvider) SetVerbose(verbose bool) {
 	d.verbose = verbose
 }
+
+// SetRetryConfig sets how failed API calls are retried
+func (d *DeepseekProvider) SetRetryConfig(config retry.Config) {
+	d.retryConfig = config
+}
+
+// SetHTTPClient sets the HTTP client used for API calls
+func (d *DeepseekProvider) SetHTTPClient(client *http.Client) {
+	d.httpClient = client
+}
+
+// SetBaseURL sets the address API calls are sent to; an empty URL restores the default
+func (d *DeepseekProvider) SetBaseURL(url string) {
+	d.baseURL = deepseekBaseURL
+	if url != "" {
+		d.baseURL = strings.TrimSuffix(url, "/")
+	}
+}
+
+// SetIncludeReasoning sets whether responses include deepseek-reasoner's chain of thought
+func (d *DeepseekProvider) SetIncludeReasoning(include bool) {
+	d.includeReasoning = include
+}
+
+// SetSystemPrompt sets the system prompt sent with each request
+func (d *DeepseekProvider) SetSystemPrompt(prompt string) {
+	d.systemPrompt = prompt
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDeepseekProviderReasoning(t *testing.T) {
+	var gotModel, gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model string `json:"model"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		gotModel = req.Model
+		gotAuth = r.Header.Get("Authorization")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"42","reasoning_content":"Six times seven."}}]}`))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		include bool
+		want    string
+	}{
+		{
+			name: "answer only by default",
+			want: "42",
+		},
+		{
+			name:    "reasoning included",
+			include: true,
+			want:    "<reasoning>\nSix times seven.\n</reasoning>\n\n42",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := NewDeepseekProvider()
+			provider.baseURL = server.URL
+			provider.Configure("test-key")
+			provider.SetIncludeReasoning(tt.include)
+
+			response, err := provider.SendPrompt("deepseek-reasoner", "What is six times seven?")
+			if err != nil {
+				t.Fatalf("SendPrompt() unexpected error: %v", err)
+			}
+			if response != tt.want {
+				t.Errorf("SendPrompt() = %q, want %q", response, tt.want)
+			}
+			if gotModel != "deepseek-reasoner" {
+				t.Errorf("request model = %q, want deepseek-reasoner", gotModel)
+			}
+			if gotAuth != "Bearer test-key" {
+				t.Errorf("Authorization = %q, want %q", gotAuth, "Bearer test-key")
+			}
+		})
+	}
+}
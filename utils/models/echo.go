@@ -0,0 +1,105 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/kris-hansen/comanda/utils/fileutil"
+	"github.com/kris-hansen/comanda/utils/logging"
+)
+
+// EchoProvider returns its prompt as the response instead of calling a model. It needs no API key,
+// so workflows can be built and chained offline before switching to a real model.
+type EchoProvider struct {
+	verbose      bool
+	systemPrompt string // Echoed ahead of the prompt when set
+}
+
+// NewEchoProvider creates a new echo provider instance
+func NewEchoProvider() *EchoProvider {
+	return &EchoProvider{}
+}
+
+// Name returns the provider name
+func (e *EchoProvider) Name() string {
+	return "echo"
+}
+
+// debugf prints debug information if verbose mode is enabled
+func (e *EchoProvider) debugf(format string, args ...interface{}) {
+	logging.New("Echo", e.verbose).Debugf(format, args...)
+}
+
+// SupportsModel reports whether the model is echo or an echo- variant such as echo-summary
+func (e *EchoProvider) SupportsModel(modelName string) bool {
+	modelName = strings.ToLower(modelName)
+	return modelName == "echo" || strings.HasPrefix(modelName, "echo-")
+}
+
+// Configure sets up the provider (no API key needed for echo)
+func (e *EchoProvider) Configure(apiKey string) error {
+	e.debugf("Configuring echo provider")
+	return nil
+}
+
+// SendPrompt returns the prompt unchanged
+func (e *EchoProvider) SendPrompt(modelName string, prompt string) (string, error) {
+	e.debugf("Echoing prompt for model %s (%d characters)", modelName, len(prompt))
+	return e.withSystemPrompt(prompt), nil
+}
+
+// SendPromptStream returns the prompt unchanged, passing it to handler a line at a time
+func (e *EchoProvider) SendPromptStream(modelName string, prompt string, handler StreamHandler) (string, error) {
+	response, err := e.SendPrompt(modelName, prompt)
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.SplitAfter(response, "\n") {
+		if line == "" {
+			continue
+		}
+		if err := handler.OnChunk(line); err != nil {
+			return "", err
+		}
+	}
+	return response, nil
+}
+
+// SendPromptWithFile returns the prompt preceded by the file's content. Images and other binary
+// files are shown by path only.
+func (e *EchoProvider) SendPromptWithFile(modelName string, prompt string, file FileInput) (string, error) {
+	e.debugf("Echoing prompt with file %s for model %s", file.Path, modelName)
+
+	byPath := e.withSystemPrompt(fmt.Sprintf("File: %s (%s)\n\n%s", file.Path, file.MimeType, prompt))
+	if isImageFile(file) {
+		return byPath, nil
+	}
+
+	fileData, err := fileutil.SafeReadFile(file.Path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %v", err)
+	}
+	if !utf8.Valid(fileData) {
+		return byPath, nil
+	}
+	return e.withSystemPrompt(fmt.Sprintf("File content:\n%s\n\n%s", string(fileData), prompt)), nil
+}
+
+// withSystemPrompt prepends the system prompt to text when one is set
+func (e *EchoProvider) withSystemPrompt(text string) string {
+	if e.systemPrompt == "" {
+		return text
+	}
+	return fmt.Sprintf("System: %s\n\n%s", e.systemPrompt, text)
+}
+
+// SetSystemPrompt sets the system prompt echoed ahead of each prompt
+func (e *EchoProvider) SetSystemPrompt(prompt string) {
+	e.systemPrompt = prompt
+}
+
+// SetVerbose enables or disables verbose mode
+func (e *EchoProvider) SetVerbose(verbose bool) {
+	e.verbose = verbose
+}
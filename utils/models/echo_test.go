@@ -0,0 +1,44 @@
+package models
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEchoProvider(t *testing.T) {
+	provider := NewEchoProvider()
+
+	for model, want := range map[string]bool{"echo": true, "echo-summary": true, "Echo": true, "echoes": false, "gpt-4o": false} {
+		if got := provider.SupportsModel(model); got != want {
+			t.Errorf("SupportsModel(%q) = %v, want %v", model, got, want)
+		}
+	}
+	if got := DetectProvider("echo"); got == nil || got.Name() != "echo" {
+		t.Errorf("DetectProvider(\"echo\") = %v, want the echo provider", got)
+	}
+
+	response, err := provider.SendPrompt("echo", "Summarize this")
+	if err != nil || response != "Summarize this" {
+		t.Errorf("SendPrompt() = %q, %v, want the prompt", response, err)
+	}
+
+	dir := t.TempDir()
+	textPath := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(textPath, []byte("meeting notes"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	response, err = provider.SendPromptWithFile("echo", "Summarize this", FileInput{Path: textPath, MimeType: "text/plain"})
+	if err != nil || response != "File content:\nmeeting notes\n\nSummarize this" {
+		t.Errorf("SendPromptWithFile() = %q, %v, want the file content and prompt", response, err)
+	}
+
+	imagePath := filepath.Join(dir, "chart.png")
+	if err := os.WriteFile(imagePath, []byte{0x89, 'P', 'N', 'G'}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	response, err = provider.SendPromptWithFile("echo", "Describe", FileInput{Path: imagePath, MimeType: "image/png"})
+	if err != nil || response != "File: "+imagePath+" (image/png)\n\nDescribe" {
+		t.Errorf("SendPromptWithFile() with image = %q, %v, want the path and prompt", response, err)
+	}
+}
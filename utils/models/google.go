@@ -2,19 +2,46 @@ package models
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/google/generative-ai-go/genai"
 	"github.com/kris-hansen/comanda/utils/fileutil"
+	"github.com/kris-hansen/comanda/utils/logging"
+	"github.com/kris-hansen/comanda/utils/retry"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+const (
+	// googleInlineFileLimit is the largest file sent inline; larger files are uploaded via the Files API
+	googleInlineFileLimit = 4 * 1024 * 1024
+	// googleFileProcessingTimeout bounds how long to wait for an uploaded file to become active
+	googleFileProcessingTimeout = 2 * time.Minute
+)
+
+// googleFilePollInterval is how often an uploaded file's state is checked while it is processed;
+// tests shorten it
+var googleFilePollInterval = 2 * time.Second
+
 // GoogleProvider handles Google AI (Gemini) family of models
 type GoogleProvider struct {
-	apiKey  string
-	config  ModelConfig
-	verbose bool
+	apiKey      string
+	config      ModelConfig
+	verbose     bool
+	retryConfig retry.Config
+	httpClient  *http.Client // nil uses the default client
+
+	systemPrompt string // Sent as the model's system instruction when set
+	baseURL      string // Replaces the default API address when set
 }
 
 // NewGoogleProvider creates a new Google provider instance
@@ -22,9 +49,10 @@ func NewGoogleProvider() *GoogleProvider {
 	return &GoogleProvider{
 		config: ModelConfig{
 			Temperature: 0.7,
-			MaxTokens:   2000,
+			MaxTokens:   DefaultMaxTokens,
 			TopP:        1.0,
 		},
+		retryConfig: retry.DefaultRetryConfig,
 	}
 }
 
@@ -35,9 +63,7 @@ func (g *GoogleProvider) Name() string {
 
 // debugf prints debug information if verbose mode is enabled
 func (g *GoogleProvider) debugf(format string, args ...interface{}) {
-	if g.verbose {
-		fmt.Printf("[DEBUG][Google] "+format+"\n", args...)
-	}
+	logging.New("Google", g.verbose).Debugf(format, args...)
 }
 
 // ValidateModel checks if the specific Google model variant is valid
@@ -100,20 +126,20 @@ func (g *GoogleProvider) SendPrompt(modelName string, prompt string) (string, er
 		g.config.Temperature, g.config.MaxTokens, g.config.TopP)
 
 	ctx := context.Background()
-	client, err := genai.NewClient(ctx, option.WithAPIKey(g.apiKey))
+	client, err := g.newClient(ctx)
 	if err != nil {
 		return "", fmt.Errorf("failed to create Google AI client: %v", err)
 	}
 	defer client.Close()
 
 	// Initialize the model
-	model := client.GenerativeModel(modelName)
-	model.SetTemperature(float32(g.config.Temperature))
-	model.SetTopP(float32(g.config.TopP))
-	model.SetMaxOutputTokens(int32(g.config.MaxTokens))
+	model := g.generativeModel(client, modelName)
 
 	// Generate content
-	resp, err := model.GenerateContent(ctx, genai.Text(prompt))
+	resp, err := retry.WithRetry(func() (*genai.GenerateContentResponse, error) {
+		resp, err := model.GenerateContent(ctx, genai.Text(prompt))
+		return resp, googleStatus(err)
+	}, g.retryConfig)
 	if err != nil {
 		return "", fmt.Errorf("Google AI API error: %v", err)
 	}
@@ -135,6 +161,64 @@ func (g *GoogleProvider) SendPrompt(modelName string, prompt string) (string, er
 	return response, nil
 }
 
+// SendPromptStream sends a prompt to the specified model and passes the response to handler as
+// it is generated
+func (g *GoogleProvider) SendPromptStream(modelName string, prompt string, handler StreamHandler) (string, error) {
+	g.debugf("Preparing to stream prompt to model: %s", modelName)
+
+	if g.apiKey == "" {
+		return "", fmt.Errorf("Google provider not configured: missing API key")
+	}
+
+	if !g.ValidateModel(modelName) {
+		return "", fmt.Errorf("invalid Google model: %s", modelName)
+	}
+
+	ctx := context.Background()
+	client, err := g.newClient(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to create Google AI client: %v", err)
+	}
+	defer client.Close()
+
+	model := g.generativeModel(client, modelName)
+
+	// Errors opening the stream arrive with its first response, so that is what is retried
+	var iter *genai.GenerateContentResponseIterator
+	resp, err := retry.WithRetry(func() (*genai.GenerateContentResponse, error) {
+		iter = model.GenerateContentStream(ctx, genai.Text(prompt))
+		resp, err := iter.Next()
+		if err == iterator.Done {
+			return nil, nil
+		}
+		return resp, googleStatus(err)
+	}, g.retryConfig)
+
+	var response strings.Builder
+	for resp != nil && err == nil {
+		if len(resp.Candidates) > 0 && resp.Candidates[0].Content != nil {
+			for _, part := range resp.Candidates[0].Content.Parts {
+				if text, ok := part.(genai.Text); ok && text != "" {
+					response.WriteString(string(text))
+					if err := handler.OnChunk(string(text)); err != nil {
+						return "", err
+					}
+				}
+			}
+		}
+		if resp, err = iter.Next(); err == iterator.Done {
+			resp, err = nil, nil
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("Google AI API error: %v", err)
+	}
+
+	g.debugf("Stream completed, response length: %d characters", response.Len())
+
+	return response.String(), nil
+}
+
 // SendPromptWithFile sends a prompt along with a file to the specified model and returns the response
 func (g *GoogleProvider) SendPromptWithFile(modelName string, prompt string, file FileInput) (string, error) {
 	g.debugf("Preparing to send prompt with file to model: %s", modelName)
@@ -149,31 +233,49 @@ func (g *GoogleProvider) SendPromptWithFile(modelName string, prompt string, fil
 	}
 
 	ctx := context.Background()
-	client, err := genai.NewClient(ctx, option.WithAPIKey(g.apiKey))
+	client, err := g.newClient(ctx)
 	if err != nil {
 		return "", fmt.Errorf("failed to create Google AI client: %v", err)
 	}
 	defer client.Close()
 
-	// Read the file content with size check
-	fileData, err := fileutil.SafeReadFile(file.Path)
+	info, err := os.Stat(file.Path)
 	if err != nil {
 		return "", fmt.Errorf("failed to read file: %v", err)
 	}
 
+	// Small files are sent inline; large files are uploaded so they don't count against the request size
+	var filePart genai.Part
+	if info.Size() > googleInlineFileLimit {
+		uploaded, err := g.uploadFile(ctx, client, file)
+		if err != nil {
+			return "", err
+		}
+		defer client.DeleteFile(ctx, uploaded.Name)
+		filePart = genai.FileData{
+			MIMEType: uploaded.MIMEType,
+			URI:      uploaded.URI,
+		}
+	} else {
+		// Read the file content with size check
+		fileData, err := fileutil.SafeReadFile(file.Path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read file: %v", err)
+		}
+		filePart = genai.Blob{
+			MIMEType: file.MimeType,
+			Data:     fileData,
+		}
+	}
+
 	// Initialize the model
-	model := client.GenerativeModel(modelName)
-	model.SetTemperature(float32(g.config.Temperature))
-	model.SetTopP(float32(g.config.TopP))
-	model.SetMaxOutputTokens(int32(g.config.MaxTokens))
+	model := g.generativeModel(client, modelName)
 
 	// Generate content with file
-	resp, err := model.GenerateContent(ctx,
-		genai.Text(prompt),
-		genai.Blob{
-			MIMEType: file.MimeType,
-			Data:     fileData,
-		})
+	resp, err := retry.WithRetry(func() (*genai.GenerateContentResponse, error) {
+		resp, err := model.GenerateContent(ctx, genai.Text(prompt), filePart)
+		return resp, googleStatus(err)
+	}, g.retryConfig)
 	if err != nil {
 		return "", fmt.Errorf("Google AI API error: %v", err)
 	}
@@ -195,7 +297,160 @@ func (g *GoogleProvider) SendPromptWithFile(modelName string, prompt string, fil
 	return response, nil
 }
 
+// uploadFile uploads a file via the Files API and waits until it is ready to be referenced
+func (g *GoogleProvider) uploadFile(ctx context.Context, client *genai.Client, file FileInput) (*genai.File, error) {
+	g.debugf("File exceeds %d bytes, uploading via Files API", googleInlineFileLimit)
+
+	f, err := fileutil.SafeOpenFile(file.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %v", err)
+	}
+	defer f.Close()
+
+	uploaded, err := client.UploadFile(ctx, "", f, &genai.UploadFileOptions{
+		DisplayName: filepath.Base(file.Path),
+		MIMEType:    file.MimeType,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload file to Google AI: %v", err)
+	}
+
+	// The uploaded file is deleted again unless it becomes ready to use
+	name := uploaded.Name
+	ready := false
+	defer func() {
+		if !ready {
+			if err := client.DeleteFile(ctx, name); err != nil {
+				g.debugf("Failed to delete uploaded file %s: %v", name, err)
+			}
+		}
+	}()
+
+	deadline := time.Now().Add(googleFileProcessingTimeout)
+	for uploaded.State == genai.FileStateProcessing {
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for uploaded file %s to be processed", name)
+		}
+		g.debugf("Waiting for uploaded file %s to be processed", name)
+		time.Sleep(googleFilePollInterval)
+		if uploaded, err = client.GetFile(ctx, name); err != nil {
+			return nil, fmt.Errorf("failed to check uploaded file state: %v", err)
+		}
+	}
+
+	if uploaded.State != genai.FileStateActive {
+		return nil, fmt.Errorf("uploaded file %s has state %s, not active", name, uploaded.State)
+	}
+
+	g.debugf("Uploaded file available at %s", uploaded.URI)
+	ready = true
+	return uploaded, nil
+}
+
+// generativeModel creates a model handle with the provider's configuration applied
+func (g *GoogleProvider) generativeModel(client *genai.Client, modelName string) *genai.GenerativeModel {
+	model := client.GenerativeModel(modelName)
+	model.SetTemperature(float32(g.config.Temperature))
+	model.SetTopP(float32(g.config.TopP))
+	model.SetMaxOutputTokens(int32(g.config.MaxTokens))
+	model.StopSequences = g.config.Stop
+	if g.config.Seed != nil {
+		g.debugf("Gemini does not support seed; ignoring it")
+	}
+	if g.systemPrompt != "" {
+		model.SystemInstruction = genai.NewUserContent(genai.Text(g.systemPrompt))
+	}
+	return model
+}
+
+// SetConfig updates the provider configuration
+func (g *GoogleProvider) SetConfig(config ModelConfig) {
+	g.debugf("Updating provider configuration")
+	g.debugf("Old config: Temperature=%.2f, MaxTokens=%d, TopP=%.2f",
+		g.config.Temperature, g.config.MaxTokens, g.config.TopP)
+	g.config = config
+	g.debugf("New config: Temperature=%.2f, MaxTokens=%d, TopP=%.2f",
+		g.config.Temperature, g.config.MaxTokens, g.config.TopP)
+}
+
+// GetConfig returns the current provider configuration
+func (g *GoogleProvider) GetConfig() ModelConfig {
+	return g.config
+}
+
+// SetSystemPrompt sets the system instruction sent with each request
+func (g *GoogleProvider) SetSystemPrompt(prompt string) {
+	g.systemPrompt = prompt
+}
+
 // SetVerbose enables or disables verbose mode
 func (g *GoogleProvider) SetVerbose(verbose bool) {
 	g.verbose = verbose
 }
+
+// SetRetryConfig sets how failed API calls are retried
+func (g *GoogleProvider) SetRetryConfig(config retry.Config) {
+	g.retryConfig = config
+}
+
+// SetHTTPClient sets the HTTP client used for API calls
+func (g *GoogleProvider) SetHTTPClient(client *http.Client) {
+	g.httpClient = client
+}
+
+// SetBaseURL sets the address API calls are sent to; an empty URL restores the default
+func (g *GoogleProvider) SetBaseURL(url string) {
+	g.baseURL = url
+}
+
+// newClient creates an API client. A custom HTTP client replaces the library's own authentication,
+// so the API key is then sent by the client's transport. The key is still passed as an option for
+// the library's cache client, which is created without the custom HTTP client.
+func (g *GoogleProvider) newClient(ctx context.Context) (*genai.Client, error) {
+	opts := []option.ClientOption{option.WithAPIKey(g.apiKey)}
+	if g.baseURL != "" {
+		opts = append(opts, option.WithEndpoint(g.baseURL))
+	}
+	if g.httpClient == nil {
+		return genai.NewClient(ctx, opts...)
+	}
+	client := *g.httpClient
+	client.Transport = &apiKeyTransport{apiKey: g.apiKey, base: client.Transport}
+	return genai.NewClient(ctx, append(opts, option.WithHTTPClient(&client))...)
+}
+
+// apiKeyTransport adds the Google API key header to each request
+type apiKeyTransport struct {
+	apiKey string
+	base   http.RoundTripper
+}
+
+func (t *apiKeyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("x-goog-api-key", t.apiKey)
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req)
+}
+
+// googleStatus attaches the HTTP status matching a failed Gemini API call to err, so only rate
+// limits and server errors are retried
+func googleStatus(err error) error {
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		return retry.WithStatus(err, apiErr.Code)
+	}
+	switch status.Code(err) {
+	case codes.ResourceExhausted:
+		return retry.WithStatus(err, http.StatusTooManyRequests)
+	case codes.Internal:
+		return retry.WithStatus(err, http.StatusInternalServerError)
+	case codes.Unavailable:
+		return retry.WithStatus(err, http.StatusServiceUnavailable)
+	case codes.DeadlineExceeded:
+		return retry.WithStatus(err, http.StatusGatewayTimeout)
+	}
+	return err
+}
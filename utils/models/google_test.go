@@ -0,0 +1,208 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newGoogleTestServer serves generateContent requests with a fixed answer and passes each request
+// body to record
+func newGoogleTestServer(t *testing.T, record func(path string, body map[string]interface{})) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		record(r.URL.Path, body)
+		if !strings.HasSuffix(r.URL.Path, ":generateContent") {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"candidates":[{"content":{"role":"model","parts":[{"text":"hello"}]}}]}`))
+	}))
+}
+
+func TestGoogleProviderCustomHTTPClient(t *testing.T) {
+	var apiKey string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiKey = r.Header.Get("x-goog-api-key")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"candidates":[{"content":{"role":"model","parts":[{"text":"hello"}]}}]}`))
+	}))
+	defer server.Close()
+
+	provider := NewGoogleProvider()
+	provider.Configure("test-key")
+	provider.SetHTTPClient(server.Client())
+	provider.SetBaseURL(server.URL)
+
+	response, err := provider.SendPrompt("gemini-1.5-flash", "hi")
+	if err != nil {
+		t.Fatalf("SendPrompt() unexpected error: %v", err)
+	}
+	if response != "hello" || apiKey != "test-key" {
+		t.Errorf("SendPrompt() = %q with API key %q, want hello with test-key", response, apiKey)
+	}
+}
+
+func TestGoogleProviderAppliesMaxTokens(t *testing.T) {
+	var generationConfig map[string]interface{}
+	server := newGoogleTestServer(t, func(path string, body map[string]interface{}) {
+		generationConfig, _ = body["generationConfig"].(map[string]interface{})
+	})
+	defer server.Close()
+
+	provider := NewGoogleProvider()
+	provider.Configure("test-key")
+	provider.SetHTTPClient(server.Client())
+	provider.SetBaseURL(server.URL)
+
+	config := provider.GetConfig()
+	config.MaxTokens = 123
+	provider.SetConfig(config)
+
+	response, err := provider.SendPrompt("gemini-1.5-flash", "hi")
+	if err != nil {
+		t.Fatalf("SendPrompt() unexpected error: %v", err)
+	}
+	if response != "hello" {
+		t.Errorf("SendPrompt() = %q, want hello", response)
+	}
+	if got := generationConfig["maxOutputTokens"]; got != float64(123) {
+		t.Errorf("maxOutputTokens = %v, want 123", got)
+	}
+}
+
+func TestGoogleProviderSendsStopSequences(t *testing.T) {
+	var generationConfig map[string]interface{}
+	server := newGoogleTestServer(t, func(path string, body map[string]interface{}) {
+		generationConfig, _ = body["generationConfig"].(map[string]interface{})
+	})
+	defer server.Close()
+
+	provider := NewGoogleProvider()
+	provider.Configure("test-key")
+	provider.SetHTTPClient(server.Client())
+	provider.SetBaseURL(server.URL)
+
+	config := provider.GetConfig()
+	config.Stop = []string{"END"}
+	provider.SetConfig(config)
+
+	if _, err := provider.SendPrompt("gemini-1.5-flash", "hi"); err != nil {
+		t.Fatalf("SendPrompt() unexpected error: %v", err)
+	}
+	if stop, _ := generationConfig["stopSequences"].([]interface{}); len(stop) != 1 || stop[0] != "END" {
+		t.Errorf("stopSequences = %v, want [END]", generationConfig["stopSequences"])
+	}
+}
+
+// fakeGoogleFiles serves the Files API for a single uploaded file, reporting the given states in
+// turn, and records whether the file was deleted
+type fakeGoogleFiles struct {
+	states  []string // "ERROR" fails the state check
+	deleted bool
+	fileURI string // URI sent to generateContent
+}
+
+func (f *fakeGoogleFiles) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/upload/v1beta/files":
+		w.Write([]byte(`{"file":{"name":"files/abc"}}`))
+	case r.Method == http.MethodGet && r.URL.Path == "/v1beta/files/abc":
+		state := f.states[0]
+		if len(f.states) > 1 {
+			f.states = f.states[1:]
+		}
+		if state == "ERROR" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error":{"code":403,"message":"denied","status":"PERMISSION_DENIED"}}`))
+			return
+		}
+		fmt.Fprintf(w, `{"name":"files/abc","mimeType":"text/plain","uri":"https://files.example/abc","state":%q}`, state)
+	case r.Method == http.MethodDelete && r.URL.Path == "/v1beta/files/abc":
+		f.deleted = true
+		w.Write([]byte(`{}`))
+	case strings.HasSuffix(r.URL.Path, ":generateContent"):
+		var body struct {
+			Contents []struct {
+				Parts []struct {
+					FileData *struct {
+						FileURI string `json:"fileUri"`
+					} `json:"fileData"`
+				} `json:"parts"`
+			} `json:"contents"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		for _, content := range body.Contents {
+			for _, part := range content.Parts {
+				if part.FileData != nil {
+					f.fileURI = part.FileData.FileURI
+				}
+			}
+		}
+		w.Write([]byte(`{"candidates":[{"content":{"role":"model","parts":[{"text":"hello"}]}}]}`))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestGoogleProviderUploadsLargeFiles(t *testing.T) {
+	googleFilePollInterval = time.Millisecond
+	defer func() { googleFilePollInterval = 2 * time.Second }()
+
+	path := filepath.Join(t.TempDir(), "large.txt")
+	if err := os.WriteFile(path, bytes.Repeat([]byte("a"), googleInlineFileLimit+1), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		states  []string
+		wantErr string
+	}{
+		{name: "active file", states: []string{"ACTIVE"}},
+		{name: "processed file", states: []string{"PROCESSING", "ACTIVE"}},
+		{name: "processing fails", states: []string{"PROCESSING", "FAILED"}, wantErr: "not active"},
+		{name: "state check fails", states: []string{"PROCESSING", "ERROR"}, wantErr: "failed to check uploaded file state"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := &fakeGoogleFiles{states: tt.states}
+			server := httptest.NewServer(files)
+			defer server.Close()
+
+			provider := NewGoogleProvider()
+			provider.Configure("test-key")
+			provider.SetHTTPClient(server.Client())
+			provider.SetBaseURL(server.URL)
+
+			response, err := provider.SendPromptWithFile("gemini-1.5-flash", "summarize", FileInput{Path: path, MimeType: "text/plain"})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("SendPromptWithFile() error = %v, want %q", err, tt.wantErr)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("SendPromptWithFile() unexpected error: %v", err)
+				}
+				if response != "hello" || files.fileURI != "https://files.example/abc" {
+					t.Errorf("SendPromptWithFile() = %q with file %q, want hello with the uploaded file", response, files.fileURI)
+				}
+			}
+			if !files.deleted {
+				t.Error("uploaded file was not deleted")
+			}
+		})
+	}
+}
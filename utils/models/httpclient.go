@@ -0,0 +1,117 @@
+package models
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+// HTTPConfig controls how providers connect to their APIs
+type HTTPConfig struct {
+	Proxy  string // Proxy URL; when empty the HTTP_PROXY, HTTPS_PROXY and NO_PROXY variables apply
+	CACert string // PEM file of certificate authorities to trust in addition to the system ones
+}
+
+// HTTPConfigurable is implemented by providers whose API calls can use a custom HTTP client
+type HTTPConfigurable interface {
+	SetHTTPClient(client *http.Client)
+}
+
+// NewHTTPClient builds the HTTP client providers use for API calls
+func NewHTTPClient(cfg HTTPConfig) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if cfg.Proxy != "" {
+		proxyURL, err := url.Parse(cfg.Proxy)
+		if err != nil || proxyURL.Scheme == "" || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL: %s", cfg.Proxy)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if cfg.CACert != "" {
+		pem, err := os.ReadFile(cfg.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("error reading CA certificate: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.CACert)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	return &http.Client{Transport: transport}, nil
+}
+
+// httpClientOrDefault returns client, or the default client when none has been set
+func httpClientOrDefault(client *http.Client) *http.Client {
+	if client == nil {
+		return http.DefaultClient
+	}
+	return client
+}
+
+// withHeaders wraps client so that headers are set on every request
+func withHeaders(client *http.Client, headers map[string]string) *http.Client {
+	transport := client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	wrapped := *client
+	wrapped.Transport = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		req = req.Clone(req.Context())
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		return transport.RoundTrip(req)
+	})
+	return &wrapped
+}
+
+// withBodyFields wraps client so that fields are added to the JSON object body of every POST request
+func withBodyFields(client *http.Client, fields map[string]interface{}) *http.Client {
+	transport := client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	wrapped := *client
+	wrapped.Transport = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if req.Method != http.MethodPost || req.Body == nil {
+			return transport.RoundTrip(req)
+		}
+		data, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+		var body map[string]interface{}
+		if err := json.Unmarshal(data, &body); err != nil {
+			return nil, fmt.Errorf("request body is not a JSON object: %w", err)
+		}
+		for name, value := range fields {
+			body[name] = value
+		}
+		if data, err = json.Marshal(body); err != nil {
+			return nil, fmt.Errorf("failed to encode request body: %w", err)
+		}
+
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(data))
+		req.ContentLength = int64(len(data))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(data)), nil
+		}
+		return transport.RoundTrip(req)
+	})
+	return &wrapped
+}
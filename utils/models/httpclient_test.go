@@ -0,0 +1,129 @@
+package models
+
+import (
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewHTTPClientCACert(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	// The test server's self-signed certificate is rejected by default
+	client, err := NewHTTPClient(HTTPConfig{})
+	if err != nil {
+		t.Fatalf("NewHTTPClient() unexpected error: %v", err)
+	}
+	if _, err := client.Get(server.URL); err == nil {
+		t.Fatal("expected certificate error without a custom CA")
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0644); err != nil {
+		t.Fatalf("Failed to write CA file: %v", err)
+	}
+
+	client, err = NewHTTPClient(HTTPConfig{CACert: caFile})
+	if err != nil {
+		t.Fatalf("NewHTTPClient() unexpected error: %v", err)
+	}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("request with custom CA failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "ok" {
+		t.Errorf("response = %q, want %q", string(body), "ok")
+	}
+}
+
+func TestNewHTTPClientProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		w.Write([]byte("via proxy"))
+	}))
+	defer proxy.Close()
+
+	client, err := NewHTTPClient(HTTPConfig{Proxy: proxy.URL})
+	if err != nil {
+		t.Fatalf("NewHTTPClient() unexpected error: %v", err)
+	}
+	resp, err := client.Get("http://api.example.test/v1/models")
+	if err != nil {
+		t.Fatalf("request through proxy failed: %v", err)
+	}
+	resp.Body.Close()
+	if proxied != "http://api.example.test/v1/models" {
+		t.Errorf("proxy received %q, want the upstream URL", proxied)
+	}
+}
+
+func TestNewHTTPClientInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  HTTPConfig
+	}{
+		{"proxy without scheme", HTTPConfig{Proxy: "proxy.internal"}},
+		{"missing CA file", HTTPConfig{CACert: filepath.Join(t.TempDir(), "missing.pem")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewHTTPClient(tt.cfg); err == nil {
+				t.Error("NewHTTPClient() expected error but got none")
+			}
+		})
+	}
+}
+
+func TestSetBaseURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		model    string
+		body     string
+		provider Provider
+		wantPath string
+	}{
+		{"openai", "gpt-4o", `{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`, NewOpenAIProvider(), "/gateway/chat/completions"},
+		{"anthropic", "claude-3-5-sonnet-latest", `{"content":[{"type":"text","text":"ok"}]}`, NewAnthropicProvider(), "/gateway/messages"},
+		{"deepseek", "deepseek-chat", `{"choices":[{"message":{"content":"ok"}}]}`, NewDeepseekProvider(), "/gateway/chat/completions"},
+		{"ollama", "llama3", `{"response":"ok","done":true}`, NewOllamaProvider(), "/gateway/api/generate"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var path string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				path = r.URL.Path
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			tt.provider.Configure("test-key")
+			tt.provider.(BaseURLConfigurable).SetBaseURL(server.URL + "/gateway/")
+			if _, err := tt.provider.SendPrompt(tt.model, "hi"); err != nil {
+				t.Fatalf("SendPrompt() unexpected error: %v", err)
+			}
+			if path != tt.wantPath {
+				t.Errorf("request path = %q, want %q", path, tt.wantPath)
+			}
+		})
+	}
+
+	provider := NewAnthropicProvider()
+	provider.SetBaseURL("http://gateway.internal")
+	provider.SetBaseURL("")
+	if provider.baseURL != anthropicBaseURL {
+		t.Errorf("baseURL after reset = %q, want %q", provider.baseURL, anthropicBaseURL)
+	}
+}
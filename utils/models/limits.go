@@ -0,0 +1,66 @@
+package models
+
+import "strings"
+
+// Limits are the token limits of a model
+type Limits struct {
+	Context int // Tokens of input and output the model can handle in one request
+	Output  int // Tokens the model can generate in one response; zero when not published
+}
+
+// modelLimits lists published token limits by model name prefix
+var modelLimits = map[string]Limits{
+	"gpt-4o":            {Context: 128000, Output: 16384},
+	"gpt-4o-mini":       {Context: 128000, Output: 16384},
+	"gpt-4-turbo":       {Context: 128000, Output: 4096},
+	"gpt-4":             {Context: 8192, Output: 8192},
+	"gpt-3.5-turbo":     {Context: 16385, Output: 4096},
+	"o1":                {Context: 200000, Output: 100000},
+	"o1-mini":           {Context: 128000, Output: 65536},
+	"o3-mini":           {Context: 200000, Output: 100000},
+	"claude-3-opus":     {Context: 200000, Output: 4096},
+	"claude-3-5-sonnet": {Context: 200000, Output: 8192},
+	"claude-3-7-sonnet": {Context: 200000, Output: 64000},
+	"claude-3-5-haiku":  {Context: 200000, Output: 8192},
+	"claude-3-haiku":    {Context: 200000, Output: 4096},
+	"claude-sonnet-4":   {Context: 200000, Output: 64000},
+	"claude-opus-4":     {Context: 200000, Output: 32000},
+	"gemini-1.5-pro":    {Context: 2097152, Output: 8192},
+	"gemini-1.5-flash":  {Context: 1048576, Output: 8192},
+	"gemini-2.0-flash":  {Context: 1048576, Output: 8192},
+	"deepseek-chat":     {Context: 64000, Output: 8192},
+	"deepseek-reasoner": {Context: 64000, Output: 8192},
+	"grok-beta":         {Context: 131072},
+	"grok-2":            {Context: 131072},
+}
+
+// LookupLimits returns the token limits of a model, matching the longest known model name prefix
+func LookupLimits(modelName string) (Limits, bool) {
+	return lookupByPrefix(modelLimits, modelName)
+}
+
+// ClampOutputTokens limits maxTokens to the most tokens the model can generate. It reports whether
+// maxTokens was lowered.
+func ClampOutputTokens(modelName string, maxTokens int) (int, bool) {
+	if limits, ok := LookupLimits(modelName); ok && limits.Output > 0 && maxTokens > limits.Output {
+		return limits.Output, true
+	}
+	return maxTokens, false
+}
+
+// lookupByPrefix returns the entry of table whose key is the longest prefix of the model name,
+// ignoring case
+func lookupByPrefix[T any](table map[string]T, modelName string) (T, bool) {
+	modelName = strings.ToLower(modelName)
+	var best string
+	for prefix := range table {
+		if strings.HasPrefix(modelName, prefix) && len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+	if best == "" {
+		var zero T
+		return zero, false
+	}
+	return table[best], true
+}
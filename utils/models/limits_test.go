@@ -0,0 +1,24 @@
+package models
+
+import "testing"
+
+func TestClampOutputTokens(t *testing.T) {
+	tests := []struct {
+		model       string
+		maxTokens   int
+		want        int
+		wantClamped bool
+	}{
+		{"gpt-4o-2024-08-06", 50000, 16384, true},
+		{"gpt-4o", 4000, 4000, false},
+		{"claude-3-haiku-20240307", 8192, 4096, true},
+		{"grok-2-latest", 50000, 50000, false},
+		{"llama3.2", 50000, 50000, false},
+	}
+	for _, tt := range tests {
+		got, clamped := ClampOutputTokens(tt.model, tt.maxTokens)
+		if got != tt.want || clamped != tt.wantClamped {
+			t.Errorf("ClampOutputTokens(%s, %d) = %d, %v, want %d, %v", tt.model, tt.maxTokens, got, clamped, tt.want, tt.wantClamped)
+		}
+	}
+}
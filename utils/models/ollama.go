@@ -2,25 +2,46 @@ package models
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"path/filepath"
 	"strings"
 
 	"github.com/kris-hansen/comanda/utils/fileutil"
+	"github.com/kris-hansen/comanda/utils/logging"
+	"github.com/kris-hansen/comanda/utils/retry"
 )
 
 // OllamaProvider handles Ollama family of models
 type OllamaProvider struct {
-	verbose bool
+	config      ModelConfig
+	verbose     bool
+	baseURL     string // Address of the local Ollama server
+	retryConfig retry.Config
+	httpClient  *http.Client // nil uses the default client
+
+	includeReasoning bool            // Keep <think> blocks from reasoning models, wrapped in <reasoning>
+	systemPrompt     string          // Sent as the request's system prompt when set
+	responseFormat   *ResponseFormat // Constrains responses to JSON when set
 }
 
 // OllamaRequest represents the request structure for Ollama API
 type OllamaRequest struct {
-	Model  string `json:"model"`
-	Prompt string `json:"prompt"`
-	Stream bool   `json:"stream"`
+	Model   string         `json:"model"`
+	System  string         `json:"system,omitempty"`
+	Prompt  string         `json:"prompt"`
+	Stream  bool           `json:"stream"`
+	Format  interface{}    `json:"format,omitempty"` // "json" or a JSON schema the response must match
+	Options *OllamaOptions `json:"options,omitempty"`
+}
+
+// OllamaOptions holds the model parameters of an Ollama request
+type OllamaOptions struct {
+	Seed *int     `json:"seed,omitempty"`
+	Stop []string `json:"stop,omitempty"`
 }
 
 // OllamaResponse represents the response structure from Ollama API
@@ -29,9 +50,37 @@ type OllamaResponse struct {
 	Done     bool   `json:"done"`
 }
 
+// OllamaChatMessage represents a single message for the Ollama chat API
+type OllamaChatMessage struct {
+	Role    string   `json:"role"`
+	Content string   `json:"content"`
+	Images  []string `json:"images,omitempty"` // Base64-encoded images for multimodal models
+}
+
+// OllamaChatRequest represents the request structure for the Ollama chat API
+type OllamaChatRequest struct {
+	Model    string              `json:"model"`
+	Messages []OllamaChatMessage `json:"messages"`
+	Stream   bool                `json:"stream"`
+	Format   interface{}         `json:"format,omitempty"` // "json" or a JSON schema the response must match
+	Options  *OllamaOptions      `json:"options,omitempty"`
+}
+
+// OllamaChatResponse represents the response structure from the Ollama chat API
+type OllamaChatResponse struct {
+	Message OllamaChatMessage `json:"message"`
+	Done    bool              `json:"done"`
+}
+
+// ollamaBaseURL is the default address of the local Ollama server
+const ollamaBaseURL = "http://localhost:11434"
+
 // NewOllamaProvider creates a new Ollama provider instance
 func NewOllamaProvider() *OllamaProvider {
-	return &OllamaProvider{}
+	return &OllamaProvider{
+		baseURL:     ollamaBaseURL,
+		retryConfig: retry.DefaultRetryConfig,
+	}
 }
 
 // Name returns the provider name
@@ -41,9 +90,7 @@ func (o *OllamaProvider) Name() string {
 
 // debugf prints debug information if verbose mode is enabled
 func (o *OllamaProvider) debugf(format string, args ...interface{}) {
-	if o.verbose {
-		fmt.Printf("[DEBUG][Ollama] "+format+"\n", args...)
-	}
+	logging.New("Ollama", o.verbose).Debugf(format, args...)
 }
 
 // SupportsModel checks if the given model name is supported by Ollama
@@ -87,30 +134,86 @@ func (o *OllamaProvider) SendPrompt(modelName string, prompt string) (string, er
 	o.debugf("Preparing to send prompt to model: %s", modelName)
 	o.debugf("Prompt length: %d characters", len(prompt))
 
-	reqBody := OllamaRequest{
+	return o.generate(OllamaRequest{
 		Model:  modelName,
+		System: o.systemPrompt,
 		Prompt: prompt,
 		Stream: false,
-	}
+	}, nil)
+}
 
-	jsonData, err := json.Marshal(reqBody)
+// SendPromptStream sends a prompt to the specified model and passes the response to handler as
+// it is generated
+func (o *OllamaProvider) SendPromptStream(modelName string, prompt string, handler StreamHandler) (string, error) {
+	o.debugf("Preparing to stream prompt to model: %s", modelName)
+
+	return o.generate(OllamaRequest{
+		Model:  modelName,
+		System: o.systemPrompt,
+		Prompt: prompt,
+		Stream: true,
+	}, handler)
+}
+
+// SendPromptWithFile sends a prompt along with a file to the specified model and returns the response
+func (o *OllamaProvider) SendPromptWithFile(modelName string, prompt string, file FileInput) (string, error) {
+	o.debugf("Preparing to send prompt with file to model: %s", modelName)
+	o.debugf("File path: %s", file.Path)
+
+	// Read the file content with size check
+	fileData, err := fileutil.SafeReadFile(file.Path)
 	if err != nil {
-		return "", fmt.Errorf("error marshaling request: %v", err)
+		return "", fmt.Errorf("failed to read file: %v", err)
 	}
 
-	resp, err := http.Post("http://localhost:11434/api/generate", "application/json", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return "", fmt.Errorf("error calling Ollama API: %v", err)
+	// Images are sent to multimodal models (llava, etc.) through the chat API
+	if isImageFile(file) {
+		o.debugf("Sending image file as base64 to multimodal model")
+		messages := []OllamaChatMessage{
+			{
+				Role:    "user",
+				Content: prompt,
+				Images:  []string{base64.StdEncoding.EncodeToString(fileData)},
+			},
+		}
+		if o.systemPrompt != "" {
+			messages = append([]OllamaChatMessage{{Role: "system", Content: o.systemPrompt}}, messages...)
+		}
+		return o.chat(OllamaChatRequest{
+			Model:    modelName,
+			Messages: messages,
+			Stream:   false,
+		})
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("Ollama API error (status %d): %s", resp.StatusCode, string(bodyBytes))
+	// Combine file content with the prompt
+	fileContent := string(fileData)
+	combinedPrompt := fmt.Sprintf("File content:\n%s\n\nUser prompt: %s", fileContent, prompt)
+
+	return o.generate(OllamaRequest{
+		Model:  modelName,
+		System: o.systemPrompt,
+		Prompt: combinedPrompt,
+		Stream: false,
+	}, nil)
+}
+
+// generate sends a request to the Ollama generate API and accumulates the response. When the
+// request streams, each piece of the answer is also passed to handler.
+func (o *OllamaProvider) generate(reqBody OllamaRequest, handler StreamHandler) (string, error) {
+	reqBody.Format = ollamaResponseFormat(o.responseFormat)
+	reqBody.Options = o.options()
+	resp, err := retry.WithRetry(func() (*http.Response, error) {
+		return o.post("/api/generate", reqBody)
+	}, o.retryConfig)
+	if err != nil {
+		return "", err
 	}
+	defer resp.Body.Close()
 
 	// Read and accumulate all responses
 	var fullResponse strings.Builder
+	stream := &thinkStream{handler: handler, include: o.includeReasoning}
 	decoder := json.NewDecoder(resp.Body)
 	for {
 		var ollamaResp OllamaResponse
@@ -121,76 +224,148 @@ func (o *OllamaProvider) SendPrompt(modelName string, prompt string) (string, er
 			return "", fmt.Errorf("error decoding response: %v", err)
 		}
 		fullResponse.WriteString(ollamaResp.Response)
+		if handler != nil {
+			if err := stream.write(ollamaResp.Response); err != nil {
+				return "", err
+			}
+		}
 		if ollamaResp.Done {
 			break
 		}
 	}
 
-	result := fullResponse.String()
+	result := o.formatResponse(fullResponse.String())
 	o.debugf("API call completed, response length: %d characters", len(result))
 	return result, nil
 }
 
-// SendPromptWithFile sends a prompt along with a file to the specified model and returns the response
-func (o *OllamaProvider) SendPromptWithFile(modelName string, prompt string, file FileInput) (string, error) {
-	o.debugf("Preparing to send prompt with file to model: %s", modelName)
-	o.debugf("File path: %s", file.Path)
-
-	// Read the file content with size check
-	fileData, err := fileutil.SafeReadFile(file.Path)
-	if err != nil {
-		return "", fmt.Errorf("failed to read file: %v", err)
-	}
-
-	// Combine file content with the prompt
-	fileContent := string(fileData)
-	combinedPrompt := fmt.Sprintf("File content:\n%s\n\nUser prompt: %s", fileContent, prompt)
-
-	reqBody := OllamaRequest{
-		Model:  modelName,
-		Prompt: combinedPrompt,
-		Stream: false,
-	}
-
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		return "", fmt.Errorf("error marshaling request: %v", err)
-	}
-
-	resp, err := http.Post("http://localhost:11434/api/generate", "application/json", bytes.NewBuffer(jsonData))
+// chat sends a request to the Ollama chat API and accumulates the response
+func (o *OllamaProvider) chat(reqBody OllamaChatRequest) (string, error) {
+	reqBody.Format = ollamaResponseFormat(o.responseFormat)
+	reqBody.Options = o.options()
+	resp, err := retry.WithRetry(func() (*http.Response, error) {
+		return o.post("/api/chat", reqBody)
+	}, o.retryConfig)
 	if err != nil {
-		return "", fmt.Errorf("error calling Ollama API: %v", err)
+		return "", err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("Ollama API error (status %d): %s", resp.StatusCode, string(bodyBytes))
-	}
-
 	// Read and accumulate all responses
 	var fullResponse strings.Builder
 	decoder := json.NewDecoder(resp.Body)
 	for {
-		var ollamaResp OllamaResponse
-		if err := decoder.Decode(&ollamaResp); err != nil {
+		var chatResp OllamaChatResponse
+		if err := decoder.Decode(&chatResp); err != nil {
 			if err == io.EOF {
 				break
 			}
 			return "", fmt.Errorf("error decoding response: %v", err)
 		}
-		fullResponse.WriteString(ollamaResp.Response)
-		if ollamaResp.Done {
+		fullResponse.WriteString(chatResp.Message.Content)
+		if chatResp.Done {
 			break
 		}
 	}
 
-	result := fullResponse.String()
+	result := o.formatResponse(fullResponse.String())
 	o.debugf("API call completed, response length: %d characters", len(result))
 	return result, nil
 }
 
+// options returns the seed and stop sequences to send with a request, or nil when neither is set
+func (o *OllamaProvider) options() *OllamaOptions {
+	if o.config.Seed == nil && len(o.config.Stop) == 0 {
+		return nil
+	}
+	return &OllamaOptions{Seed: o.config.Seed, Stop: o.config.Stop}
+}
+
+// formatResponse separates the <think> block of reasoning models from the answer and keeps it
+// only when reasoning is included
+func (o *OllamaProvider) formatResponse(content string) string {
+	reasoning, answer := splitThinkTags(content)
+	return formatReasoning(reasoning, answer, o.includeReasoning)
+}
+
+// post sends a JSON request to the local Ollama API and checks the response status
+func (o *OllamaProvider) post(path string, reqBody interface{}) (*http.Response, error) {
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling request: %v", err)
+	}
+
+	resp, err := httpClientOrDefault(o.httpClient).Post(o.baseURL+path, "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("error calling Ollama API: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, retry.WithStatus(fmt.Errorf("Ollama API error (status %d): %s", resp.StatusCode, string(bodyBytes)), resp.StatusCode)
+	}
+
+	return resp, nil
+}
+
+// isImageFile reports whether a file input is an image, by content type or extension
+func isImageFile(file FileInput) bool {
+	if strings.HasPrefix(file.MimeType, "image/") {
+		return true
+	}
+	switch strings.ToLower(filepath.Ext(file.Path)) {
+	case ".png", ".jpg", ".jpeg", ".gif", ".webp", ".bmp":
+		return true
+	}
+	return false
+}
+
+// SetConfig updates the provider configuration. Only the seed and stop sequences are sent to
+// Ollama; the server's own settings decide the rest.
+func (o *OllamaProvider) SetConfig(config ModelConfig) {
+	o.config = config
+}
+
+// GetConfig returns the current provider configuration
+func (o *OllamaProvider) GetConfig() ModelConfig {
+	return o.config
+}
+
 // SetVerbose enables or disables verbose mode
 func (o *OllamaProvider) SetVerbose(verbose bool) {
 	o.verbose = verbose
 }
+
+// SetRetryConfig sets how failed API calls are retried
+func (o *OllamaProvider) SetRetryConfig(config retry.Config) {
+	o.retryConfig = config
+}
+
+// SetHTTPClient sets the HTTP client used for API calls
+func (o *OllamaProvider) SetHTTPClient(client *http.Client) {
+	o.httpClient = client
+}
+
+// SetBaseURL sets the address of the Ollama server; an empty URL restores the default
+func (o *OllamaProvider) SetBaseURL(url string) {
+	o.baseURL = ollamaBaseURL
+	if url != "" {
+		o.baseURL = strings.TrimSuffix(url, "/")
+	}
+}
+
+// SetIncludeReasoning sets whether responses include the model's <think> block
+func (o *OllamaProvider) SetIncludeReasoning(include bool) {
+	o.includeReasoning = include
+}
+
+// SetSystemPrompt sets the system prompt sent with each request
+func (o *OllamaProvider) SetSystemPrompt(prompt string) {
+	o.systemPrompt = prompt
+}
+
+// SetResponseFormat constrains responses to JSON, optionally matching a schema; nil removes the constraint
+func (o *OllamaProvider) SetResponseFormat(format *ResponseFormat) {
+	o.responseFormat = format
+}
@@ -0,0 +1,137 @@
+package models
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kris-hansen/comanda/utils/retry"
+)
+
+func TestOllamaProviderRetryConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxAttempts int
+		failures    int
+		wantCalls   int
+		wantErr     bool
+	}{
+		{
+			name:        "recovers within attempts",
+			maxAttempts: 4,
+			failures:    3,
+			wantCalls:   4,
+		},
+		{
+			name:        "fails fast",
+			maxAttempts: 1,
+			failures:    3,
+			wantCalls:   1,
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				if calls <= tt.failures {
+					http.Error(w, "model loading", http.StatusServiceUnavailable)
+					return
+				}
+				w.Write([]byte(`{"response":"hello","done":true}`))
+			}))
+			defer server.Close()
+
+			provider := NewOllamaProvider()
+			provider.baseURL = server.URL
+			provider.SetRetryConfig(retry.Config{
+				MaxAttempts: tt.maxAttempts,
+				BaseDelay:   time.Millisecond,
+				MaxDelay:    time.Millisecond,
+			})
+
+			response, err := provider.SendPrompt("llama3.2", "hi")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SendPrompt() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && response != "hello" {
+				t.Errorf("SendPrompt() = %q, want %q", response, "hello")
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("server received %d calls, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestOllamaProviderReasoning(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"response":"<think>\nThe user greets me.\n</think>\n\nHello!","done":true}`))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		include bool
+		want    string
+	}{
+		{
+			name: "answer only by default",
+			want: "Hello!",
+		},
+		{
+			name:    "reasoning included",
+			include: true,
+			want:    "<reasoning>\nThe user greets me.\n</reasoning>\n\nHello!",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := NewOllamaProvider()
+			provider.baseURL = server.URL
+			provider.SetIncludeReasoning(tt.include)
+
+			response, err := provider.SendPrompt("deepseek-r1", "hi")
+			if err != nil {
+				t.Fatalf("SendPrompt() unexpected error: %v", err)
+			}
+			if response != tt.want {
+				t.Errorf("SendPrompt() = %q, want %q", response, tt.want)
+			}
+		})
+	}
+}
+
+func TestOllamaProviderSendsSeedAndStop(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Write([]byte(`{"response":"hello","done":true}`))
+	}))
+	defer server.Close()
+
+	provider := NewOllamaProvider()
+	provider.baseURL = server.URL
+	if _, err := provider.SendPrompt("llama3.2", "hi"); err != nil {
+		t.Fatalf("SendPrompt() unexpected error: %v", err)
+	}
+	if _, ok := body["options"]; ok {
+		t.Errorf("request options = %v, want none by default", body["options"])
+	}
+
+	seed := 42
+	provider.SetConfig(ModelConfig{Seed: &seed, Stop: []string{"END"}})
+	if _, err := provider.SendPrompt("llama3.2", "hi"); err != nil {
+		t.Fatalf("SendPrompt() unexpected error: %v", err)
+	}
+	options, _ := body["options"].(map[string]interface{})
+	if options["seed"] != float64(42) || !reflect.DeepEqual(options["stop"], []interface{}{"END"}) {
+		t.Errorf("request options = %v, want seed 42 and stop END", options)
+	}
+}
@@ -3,17 +3,30 @@ package models
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"strings"
 
 	"github.com/kris-hansen/comanda/utils/fileutil"
+	"github.com/kris-hansen/comanda/utils/logging"
+	"github.com/kris-hansen/comanda/utils/retry"
 	openai "github.com/sashabaranov/go-openai"
 )
 
 // OpenAIProvider handles OpenAI family of models
 type OpenAIProvider struct {
-	apiKey  string
-	config  ModelConfig
-	verbose bool
+	apiKey      string
+	config      ModelConfig
+	verbose     bool
+	lastUsage   TokenUsage
+	rateLimit   RateLimit // Rate limit reported with the most recent response
+	retryConfig retry.Config
+	httpClient  *http.Client // nil uses the default client
+
+	systemPrompt   string          // Sent as a system message before the user message when set
+	responseFormat *ResponseFormat // Constrains responses to JSON when set
+	organization   string          // Sent as the OpenAI-Organization header when set
+	project        string          // Sent as the OpenAI-Project header when set
+	baseURL        string          // Replaces the default API address when set
 }
 
 // NewOpenAIProvider creates a new OpenAI provider instance
@@ -21,10 +34,12 @@ func NewOpenAIProvider() *OpenAIProvider {
 	return &OpenAIProvider{
 		config: ModelConfig{
 			Temperature:         0.7,
-			MaxTokens:           2000,
-			MaxCompletionTokens: 2000,
+			MaxTokens:           DefaultMaxTokens,
+			MaxCompletionTokens: DefaultMaxTokens,
 			TopP:                1.0,
 		},
+		rateLimit:   unknownRateLimit,
+		retryConfig: retry.DefaultRetryConfig,
 	}
 }
 
@@ -35,9 +50,7 @@ func (o *OpenAIProvider) Name() string {
 
 // debugf prints debug information if verbose mode is enabled
 func (o *OpenAIProvider) debugf(format string, args ...interface{}) {
-	if o.verbose {
-		fmt.Printf("[DEBUG][OpenAI] "+format+"\n", args...)
-	}
+	logging.New("OpenAI", o.verbose).Debugf(format, args...)
 }
 
 // SupportsModel checks if the given model name is supported by OpenAI
@@ -83,7 +96,11 @@ func (o *OpenAIProvider) isNewModelSeries(modelName string) bool {
 func (o *OpenAIProvider) createChatCompletionRequest(modelName string, messages []openai.ChatCompletionMessage) openai.ChatCompletionRequest {
 	req := openai.ChatCompletionRequest{
 		Model:    modelName,
-		Messages: messages,
+		Messages: withSystemMessage(o.systemPrompt, messages),
+		Seed:     o.config.Seed,
+		Stop:     o.config.Stop,
+
+		ResponseFormat: openAIResponseFormat(o.responseFormat),
 	}
 
 	if o.isNewModelSeries(modelName) {
@@ -120,7 +137,7 @@ func (o *OpenAIProvider) SendPrompt(modelName string, prompt string) (string, er
 
 	o.debugf("Model validation passed, preparing API call")
 
-	client := openai.NewClient(o.apiKey)
+	client := o.newClient()
 
 	// Check if this is a vision input by looking for base64 image data
 	if strings.HasPrefix(modelName, "gpt-4") && strings.Contains(prompt, ";base64,") {
@@ -135,12 +152,14 @@ func (o *OpenAIProvider) SendPrompt(modelName string, prompt string) (string, er
 	}
 
 	req := o.createChatCompletionRequest(modelName, messages)
-	resp, err := client.CreateChatCompletion(context.Background(), req)
+	resp, err := o.complete(client, req)
 
 	if err != nil {
 		return "", fmt.Errorf("OpenAI API error: %v", err)
 	}
 
+	o.recordUsage(resp.Usage)
+
 	if len(resp.Choices) == 0 {
 		return "", fmt.Errorf("no response choices returned from OpenAI")
 	}
@@ -151,6 +170,42 @@ func (o *OpenAIProvider) SendPrompt(modelName string, prompt string) (string, er
 	return response, nil
 }
 
+// SendPromptStream sends a prompt to the specified model and passes the response to handler as
+// it is generated. Vision prompts are sent whole.
+func (o *OpenAIProvider) SendPromptStream(modelName string, prompt string, handler StreamHandler) (string, error) {
+	o.debugf("Preparing to stream prompt to model: %s", modelName)
+
+	if o.apiKey == "" {
+		return "", fmt.Errorf("OpenAI provider not configured: missing API key")
+	}
+
+	if !o.SupportsModel(modelName) {
+		return "", fmt.Errorf("invalid OpenAI model: %s", modelName)
+	}
+
+	if strings.HasPrefix(modelName, "gpt-4") && strings.Contains(prompt, ";base64,") {
+		return SendPromptBlocking(o, modelName, prompt, handler)
+	}
+
+	messages := []openai.ChatCompletionMessage{
+		{
+			Role:    openai.ChatMessageRoleUser,
+			Content: prompt,
+		},
+	}
+
+	req := o.createChatCompletionRequest(modelName, messages)
+	response, usage, err := streamChat(context.Background(), o.newClient(), req, handler, o.retryConfig)
+	if err != nil {
+		return "", fmt.Errorf("OpenAI API error: %v", err)
+	}
+
+	o.recordUsage(usage)
+	o.debugf("Stream completed, response length: %d characters", len(response))
+
+	return response, nil
+}
+
 // SendPromptWithFile sends a prompt along with a file to the specified model and returns the response
 func (o *OpenAIProvider) SendPromptWithFile(modelName string, prompt string, file FileInput) (string, error) {
 	o.debugf("Preparing to send prompt with file to model: %s", modelName)
@@ -170,7 +225,7 @@ func (o *OpenAIProvider) SendPromptWithFile(modelName string, prompt string, fil
 		return "", fmt.Errorf("failed to read file: %v", err)
 	}
 
-	client := openai.NewClient(o.apiKey)
+	client := o.newClient()
 
 	// For GPT-4 Vision, handle image files
 	if strings.HasPrefix(modelName, "gpt-4") && strings.HasPrefix(file.MimeType, "image/") {
@@ -189,12 +244,14 @@ func (o *OpenAIProvider) SendPromptWithFile(modelName string, prompt string, fil
 	}
 
 	req := o.createChatCompletionRequest(modelName, messages)
-	resp, err := client.CreateChatCompletion(context.Background(), req)
+	resp, err := o.complete(client, req)
 
 	if err != nil {
 		return "", fmt.Errorf("OpenAI API error: %v", err)
 	}
 
+	o.recordUsage(resp.Usage)
+
 	if len(resp.Choices) == 0 {
 		return "", fmt.Errorf("no response choices returned from OpenAI")
 	}
@@ -232,12 +289,14 @@ func (o *OpenAIProvider) handleFileAsVision(client *openai.Client, prompt string
 	}
 
 	req := o.createChatCompletionRequest(modelName, messages)
-	resp, err := client.CreateChatCompletion(context.Background(), req)
+	resp, err := o.complete(client, req)
 
 	if err != nil {
 		return "", fmt.Errorf("OpenAI Vision API error: %v", err)
 	}
 
+	o.recordUsage(resp.Usage)
+
 	if len(resp.Choices) == 0 {
 		return "", fmt.Errorf("no response choices returned from OpenAI Vision")
 	}
@@ -247,29 +306,13 @@ func (o *OpenAIProvider) handleFileAsVision(client *openai.Client, prompt string
 
 // handleVisionPrompt processes a vision model request with image data
 func (o *OpenAIProvider) handleVisionPrompt(client *openai.Client, prompt string, modelName string) (string, error) {
-	// Split the prompt into text and base64 image data
-	parts := strings.Split(prompt, "Action: ")
-	if len(parts) != 2 {
-		return "", fmt.Errorf("invalid vision prompt format")
-	}
-
-	// Extract image data from the input section
-	inputParts := strings.Split(parts[0], "Input:\n")
-	if len(inputParts) != 2 {
-		return "", fmt.Errorf("invalid input format in vision prompt")
+	action, imageData, err := splitVisionPrompt(prompt)
+	if err != nil {
+		return "", err
 	}
-
-	imageData := strings.TrimSpace(inputParts[1])
-	action := strings.TrimSpace(parts[1])
-
 	o.debugf("Vision prompt image data length: %d", len(imageData))
 	o.debugf("Vision prompt action length: %d", len(action))
 
-	// Check if image data is properly formatted
-	if !strings.HasPrefix(imageData, "data:image/") && !strings.Contains(imageData, ";base64,") {
-		imageData = fmt.Sprintf("data:image/png;base64,%s", imageData)
-	}
-
 	// Create the message content with text and image parts
 	content := []openai.ChatMessagePart{
 		{
@@ -292,12 +335,14 @@ func (o *OpenAIProvider) handleVisionPrompt(client *openai.Client, prompt string
 	}
 
 	req := o.createChatCompletionRequest(modelName, messages)
-	resp, err := client.CreateChatCompletion(context.Background(), req)
+	resp, err := o.complete(client, req)
 
 	if err != nil {
 		return "", fmt.Errorf("OpenAI Vision API error: %v", err)
 	}
 
+	o.recordUsage(resp.Usage)
+
 	if len(resp.Choices) == 0 {
 		return "", fmt.Errorf("no response choices returned from OpenAI Vision")
 	}
@@ -305,6 +350,62 @@ func (o *OpenAIProvider) handleVisionPrompt(client *openai.Client, prompt string
 	return resp.Choices[0].Message.Content, nil
 }
 
+// splitVisionPrompt splits a prompt of the form "Input:\n<image data>\n\nAction: <action>" into the
+// action and the image as a data URI
+func splitVisionPrompt(prompt string) (string, string, error) {
+	// Split the prompt into text and base64 image data
+	parts := strings.Split(prompt, "Action: ")
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("invalid vision prompt format")
+	}
+
+	// Extract image data from the input section
+	inputParts := strings.Split(parts[0], "Input:\n")
+	if len(inputParts) != 2 {
+		return "", "", fmt.Errorf("invalid input format in vision prompt")
+	}
+
+	imageData := strings.TrimSpace(inputParts[1])
+	action := strings.TrimSpace(parts[1])
+
+	// Check if image data is properly formatted
+	if !strings.HasPrefix(imageData, "data:image/") && !strings.Contains(imageData, ";base64,") {
+		imageData = fmt.Sprintf("data:image/png;base64,%s", imageData)
+	}
+	return action, imageData, nil
+}
+
+// recordUsage stores the token usage reported by a chat completion
+func (o *OpenAIProvider) recordUsage(usage openai.Usage) {
+	o.lastUsage = TokenUsage{
+		InputTokens:  usage.PromptTokens,
+		OutputTokens: usage.CompletionTokens,
+	}
+}
+
+// LastUsage returns the token usage reported for the most recent API call
+func (o *OpenAIProvider) LastUsage() TokenUsage {
+	return o.lastUsage
+}
+
+// LastRateLimit returns the rate limit reported with the most recent API response
+func (o *OpenAIProvider) LastRateLimit() RateLimit {
+	return o.rateLimit
+}
+
+// complete sends a chat completion request with retries. A rate limited response's Retry-After
+// header sets the wait before the next attempt.
+func (o *OpenAIProvider) complete(client *openai.Client, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	return retry.WithRetry(func() (openai.ChatCompletionResponse, error) {
+		o.rateLimit = unknownRateLimit
+		resp, err := client.CreateChatCompletion(context.Background(), req)
+		if err != nil && o.rateLimit.RetryAfter > 0 {
+			o.debugf("Rate limited, waiting %s before retrying", o.rateLimit.RetryAfter)
+		}
+		return resp, withRetryAfter(openAIStatus(err), o.rateLimit)
+	}, o.retryConfig)
+}
+
 // ValidateModel checks if the specific OpenAI model variant is valid
 func (o *OpenAIProvider) ValidateModel(modelName string) bool {
 	return o.SupportsModel(modelName)
@@ -329,3 +430,61 @@ func (o *OpenAIProvider) GetConfig() ModelConfig {
 func (o *OpenAIProvider) SetVerbose(verbose bool) {
 	o.verbose = verbose
 }
+
+// SetRetryConfig sets how failed API calls are retried
+func (o *OpenAIProvider) SetRetryConfig(config retry.Config) {
+	o.retryConfig = config
+}
+
+// SetHTTPClient sets the HTTP client used for API calls
+func (o *OpenAIProvider) SetHTTPClient(client *http.Client) {
+	o.httpClient = client
+}
+
+// SetSystemPrompt sets the system prompt sent with each request
+func (o *OpenAIProvider) SetSystemPrompt(prompt string) {
+	o.systemPrompt = prompt
+}
+
+// SetResponseFormat constrains responses to JSON, optionally matching a schema; nil removes the constraint
+func (o *OpenAIProvider) SetResponseFormat(format *ResponseFormat) {
+	o.responseFormat = format
+}
+
+// SetBaseURL sets the address API calls are sent to; an empty URL restores the default
+func (o *OpenAIProvider) SetBaseURL(url string) {
+	o.baseURL = url
+}
+
+// SetOrganization sets the organization and project that requests are billed to
+func (o *OpenAIProvider) SetOrganization(organization, project string) {
+	o.organization = organization
+	o.project = project
+}
+
+// withSystemMessage prepends a system message to messages when system is set
+func withSystemMessage(system string, messages []openai.ChatCompletionMessage) []openai.ChatCompletionMessage {
+	if system == "" {
+		return messages
+	}
+	return append([]openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleSystem, Content: system}}, messages...)
+}
+
+// newClient creates an API client using the configured HTTP client
+func (o *OpenAIProvider) newClient() *openai.Client {
+	config := openai.DefaultConfig(o.apiKey)
+	config.OrgID = o.organization
+	if o.baseURL != "" {
+		config.BaseURL = strings.TrimSuffix(o.baseURL, "/")
+	}
+	client := httpClientOrDefault(o.httpClient)
+	if o.project != "" {
+		// The OpenAI client has no project setting, so the header is added to each request
+		client = withHeaders(client, map[string]string{"OpenAI-Project": o.project})
+	}
+	config.HTTPClient = headerRecorder(client, func(header http.Header) {
+		o.rateLimit = parseRateLimit(header)
+		o.debugf("Rate limit: %s", o.rateLimit)
+	})
+	return openai.NewClientWithConfig(config)
+}
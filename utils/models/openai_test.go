@@ -0,0 +1,133 @@
+package models
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestOpenAIRequestSamplingOptions(t *testing.T) {
+	provider := NewOpenAIProvider()
+	seed := 7
+	config := provider.GetConfig()
+	config.Seed = &seed
+	config.Stop = []string{"END"}
+	provider.SetConfig(config)
+
+	for _, model := range []string{"gpt-4o", "gpt-3.5-turbo"} {
+		req := provider.createChatCompletionRequest(model, []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "hi"}})
+		if req.Seed == nil || *req.Seed != 7 {
+			t.Errorf("%s: Seed = %v, want 7", model, req.Seed)
+		}
+		if len(req.Stop) != 1 || req.Stop[0] != "END" {
+			t.Errorf("%s: Stop = %v, want [END]", model, req.Stop)
+		}
+	}
+}
+
+func TestOpenAIRequestSystemPrompt(t *testing.T) {
+	provider := NewOpenAIProvider()
+	messages := []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "hi"}}
+
+	req := provider.createChatCompletionRequest("gpt-4o", messages)
+	if len(req.Messages) != 1 {
+		t.Fatalf("Messages = %v, want only the user message without a system prompt", req.Messages)
+	}
+
+	provider.SetSystemPrompt("You are a terse technical assistant")
+	req = provider.createChatCompletionRequest("gpt-4o", messages)
+	if len(req.Messages) != 2 || req.Messages[0].Role != openai.ChatMessageRoleSystem || req.Messages[0].Content != "You are a terse technical assistant" {
+		t.Fatalf("Messages = %v, want a system message before the user message", req.Messages)
+	}
+	if req.Messages[1].Content != "hi" {
+		t.Errorf("user message = %q, want hi", req.Messages[1].Content)
+	}
+}
+
+func TestOpenAIRequestResponseFormat(t *testing.T) {
+	provider := NewOpenAIProvider()
+	messages := []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "hi"}}
+
+	if req := provider.createChatCompletionRequest("gpt-4o", messages); req.ResponseFormat != nil {
+		t.Fatalf("ResponseFormat = %+v, want none by default", req.ResponseFormat)
+	}
+
+	// Strict mode is off unless the step asks for it
+	for _, strict := range []bool{false, true} {
+		provider.SetResponseFormat(&ResponseFormat{
+			Type:   ResponseFormatJSONSchema,
+			Name:   "person",
+			Schema: map[string]interface{}{"type": "object", "required": []interface{}{"name"}},
+			Strict: strict,
+		})
+		format := requestResponseFormat(t, provider.createChatCompletionRequest("gpt-4o", messages))
+		if format.Type != "json_schema" || format.JSONSchema.Name != "person" || format.JSONSchema.Strict != strict || format.JSONSchema.Schema["type"] != "object" {
+			t.Errorf("response_format = %+v, want the person schema with strict %v", format, strict)
+		}
+	}
+
+	provider.SetResponseFormat(&ResponseFormat{Type: ResponseFormatJSONObject})
+	if req := provider.createChatCompletionRequest("gpt-4o", messages); req.ResponseFormat == nil || req.ResponseFormat.JSONSchema != nil || req.ResponseFormat.Type != openai.ChatCompletionResponseFormatTypeJSONObject {
+		t.Errorf("ResponseFormat = %+v, want json_object", req.ResponseFormat)
+	}
+}
+
+// openAIRequestFormat is the response_format of a chat completion request as sent to the API
+type openAIRequestFormat struct {
+	Type       string `json:"type"`
+	JSONSchema struct {
+		Name   string                 `json:"name"`
+		Schema map[string]interface{} `json:"schema"`
+		Strict bool                   `json:"strict"`
+	} `json:"json_schema"`
+}
+
+// requestResponseFormat encodes a request and decodes its response_format
+func requestResponseFormat(t *testing.T, req openai.ChatCompletionRequest) openAIRequestFormat {
+	t.Helper()
+	data, err := json.Marshal(req)
+	if err != nil {
+		t.Fatalf("json.Marshal() unexpected error: %v", err)
+	}
+	var body struct {
+		ResponseFormat openAIRequestFormat `json:"response_format"`
+	}
+	if err := json.Unmarshal(data, &body); err != nil {
+		t.Fatalf("json.Unmarshal() unexpected error: %v", err)
+	}
+	return body.ResponseFormat
+}
+
+func TestOpenAIOrganizationHeaders(t *testing.T) {
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+	defer server.Close()
+
+	provider := NewOpenAIProvider()
+	provider.Configure("test-key")
+	provider.SetHTTPClient(redirectTo(server))
+
+	if _, err := provider.SendPrompt("gpt-4o", "hi"); err != nil {
+		t.Fatalf("SendPrompt() unexpected error: %v", err)
+	}
+	if header.Get("OpenAI-Organization") != "" || header.Get("OpenAI-Project") != "" {
+		t.Errorf("headers = %v, want no organization or project by default", header)
+	}
+
+	provider.SetOrganization("org-finance", "proj_reporting")
+	if _, err := provider.SendPrompt("gpt-4o", "hi"); err != nil {
+		t.Fatalf("SendPrompt() unexpected error: %v", err)
+	}
+	if got := header.Get("OpenAI-Organization"); got != "org-finance" {
+		t.Errorf("OpenAI-Organization = %q, want org-finance", got)
+	}
+	if got := header.Get("OpenAI-Project"); got != "proj_reporting" {
+		t.Errorf("OpenAI-Project = %q, want proj_reporting", got)
+	}
+}
@@ -0,0 +1,44 @@
+package models
+
+// Pricing is the price of a model's tokens in US dollars per million tokens
+type Pricing struct {
+	Input  float64
+	Output float64
+}
+
+// Cost returns the price in US dollars of the given token counts
+func (p Pricing) Cost(inputTokens, outputTokens int) float64 {
+	return (float64(inputTokens)*p.Input + float64(outputTokens)*p.Output) / 1e6
+}
+
+// modelPricing lists published list prices by model name prefix. Prices change, so a model's
+// configuration can override them.
+var modelPricing = map[string]Pricing{
+	"gpt-4o":            {Input: 2.50, Output: 10},
+	"gpt-4o-mini":       {Input: 0.15, Output: 0.60},
+	"gpt-4-turbo":       {Input: 10, Output: 30},
+	"gpt-4":             {Input: 30, Output: 60},
+	"gpt-3.5-turbo":     {Input: 0.50, Output: 1.50},
+	"o1":                {Input: 15, Output: 60},
+	"o1-mini":           {Input: 1.10, Output: 4.40},
+	"o3-mini":           {Input: 1.10, Output: 4.40},
+	"claude-3-opus":     {Input: 15, Output: 75},
+	"claude-3-5-sonnet": {Input: 3, Output: 15},
+	"claude-3-7-sonnet": {Input: 3, Output: 15},
+	"claude-3-5-haiku":  {Input: 0.80, Output: 4},
+	"claude-3-haiku":    {Input: 0.25, Output: 1.25},
+	"claude-sonnet-4":   {Input: 3, Output: 15},
+	"claude-opus-4":     {Input: 15, Output: 75},
+	"gemini-1.5-pro":    {Input: 1.25, Output: 5},
+	"gemini-1.5-flash":  {Input: 0.075, Output: 0.30},
+	"gemini-2.0-flash":  {Input: 0.10, Output: 0.40},
+	"deepseek-chat":     {Input: 0.27, Output: 1.10},
+	"deepseek-reasoner": {Input: 0.55, Output: 2.19},
+	"grok-beta":         {Input: 5, Output: 15},
+	"grok-2":            {Input: 2, Output: 10},
+}
+
+// LookupPricing returns the list price of a model, matching the longest known model name prefix
+func LookupPricing(modelName string) (Pricing, bool) {
+	return lookupByPrefix(modelPricing, modelName)
+}
@@ -0,0 +1,28 @@
+package models
+
+import "testing"
+
+func TestLookupPricing(t *testing.T) {
+	tests := []struct {
+		model  string
+		want   Pricing
+		wantOK bool
+	}{
+		{"gpt-4o", Pricing{Input: 2.50, Output: 10}, true},
+		{"gpt-4o-mini-2024-07-18", Pricing{Input: 0.15, Output: 0.60}, true},
+		{"gpt-4", Pricing{Input: 30, Output: 60}, true},
+		{"Claude-3-5-Haiku-Latest", Pricing{Input: 0.80, Output: 4}, true},
+		{"llama3.2", Pricing{}, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := LookupPricing(tt.model)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("LookupPricing(%s) = %+v, %v, want %+v, %v", tt.model, got, ok, tt.want, tt.wantOK)
+		}
+	}
+
+	if cost := (Pricing{Input: 3, Output: 15}).Cost(2000000, 100000); cost != 7.5 {
+		t.Errorf("Cost() = %v, want 7.5", cost)
+	}
+}
@@ -1,11 +1,18 @@
 package models
 
+import "github.com/kris-hansen/comanda/utils/retry"
+
+// DefaultMaxTokens is the number of tokens a response may use unless a step sets max_tokens
+const DefaultMaxTokens = 2000
+
 // ModelConfig represents configuration options for model calls
 type ModelConfig struct {
 	Temperature         float64
 	MaxTokens           int
 	MaxCompletionTokens int
 	TopP                float64
+	Seed                *int     // Sampling seed for reproducible output; nil lets the provider choose
+	Stop                []string // Sequences that end generation when produced
 }
 
 // FileInput represents a file to be processed by the model
@@ -20,10 +27,84 @@ type Provider interface {
 	SupportsModel(modelName string) bool
 	SendPrompt(modelName string, prompt string) (string, error)
 	SendPromptWithFile(modelName string, prompt string, file FileInput) (string, error)
+	// SendPromptStream sends a prompt and passes the response to handler as it is generated,
+	// returning the whole response once it is complete
+	SendPromptStream(modelName string, prompt string, handler StreamHandler) (string, error)
 	Configure(apiKey string) error
 	SetVerbose(verbose bool)
 }
 
+// CachingProvider is implemented by providers that can cache a large, reused prompt context
+type CachingProvider interface {
+	SendPromptWithCache(modelName string, cachedContext string, prompt string) (string, error)
+}
+
+// ConfigurableProvider is implemented by providers whose model parameters can be changed
+type ConfigurableProvider interface {
+	GetConfig() ModelConfig
+	SetConfig(config ModelConfig)
+}
+
+// RetryConfigurable is implemented by providers whose API calls can be retried with custom settings
+type RetryConfigurable interface {
+	SetRetryConfig(config retry.Config)
+}
+
+// ReasoningConfigurable is implemented by providers that can include a reasoning model's
+// chain of thought in responses. Responses contain only the answer by default.
+type ReasoningConfigurable interface {
+	SetIncludeReasoning(include bool)
+}
+
+// ThinkingConfigurable is implemented by providers whose models can think before answering
+// within a token budget. A budget of zero disables extended thinking.
+type ThinkingConfigurable interface {
+	SetThinkingBudget(tokens int)
+}
+
+// Live search modes: off never searches, auto lets the model decide and on always searches
+const (
+	LiveSearchOff  = "off"
+	LiveSearchAuto = "auto"
+	LiveSearchOn   = "on"
+)
+
+// LiveSearchConfigurable is implemented by providers whose models can search the web for current
+// information while answering. An empty mode leaves the provider's default, which is off.
+type LiveSearchConfigurable interface {
+	SetLiveSearch(mode string)
+}
+
+// OrganizationConfigurable is implemented by providers whose usage can be attributed to an
+// organization and project within an account. Empty IDs use the account's defaults.
+type OrganizationConfigurable interface {
+	SetOrganization(organization, project string)
+}
+
+// BaseURLConfigurable is implemented by providers whose API address can be changed for the models
+// that are routed elsewhere, such as through a gateway
+type BaseURLConfigurable interface {
+	// SetBaseURL sets the address calls are sent to; an empty URL restores the provider's default
+	SetBaseURL(url string)
+}
+
+// SystemPromptConfigurable is implemented by providers that can send a system prompt ahead of
+// the user message. An empty prompt sends none.
+type SystemPromptConfigurable interface {
+	SetSystemPrompt(prompt string)
+}
+
+// TokenUsage represents the tokens consumed by a single model call
+type TokenUsage struct {
+	InputTokens  int
+	OutputTokens int
+}
+
+// UsageReporter is implemented by providers that report token usage for their last call
+type UsageReporter interface {
+	LastUsage() TokenUsage
+}
+
 // DetectProviderFunc is the type for the provider detection function
 type DetectProviderFunc func(modelName string) Provider
 
@@ -39,6 +120,7 @@ func defaultDetectProvider(modelName string) Provider {
 		NewXAIProvider(),       // Handles grok- models
 		NewDeepseekProvider(),  // Handles deepseek- models
 		NewOpenAIProvider(),    // Handles gpt- models
+		NewEchoProvider(),      // Handles echo models, which need no API
 		NewOllamaProvider(),    // Handles remaining models
 	}
 
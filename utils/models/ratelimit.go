@@ -0,0 +1,132 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kris-hansen/comanda/utils/retry"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// RateLimit represents the rate limit state reported with a provider's most recent response
+type RateLimit struct {
+	RemainingRequests int           // Requests left in the current window; -1 when not reported
+	RemainingTokens   int           // Tokens left in the current window; -1 when not reported
+	RetryAfter        time.Duration // Wait requested by a rate limited response; zero when not given
+}
+
+// unknownRateLimit is reported before a response with rate limit headers has been received
+var unknownRateLimit = RateLimit{RemainingRequests: -1, RemainingTokens: -1}
+
+// RateLimitReporter is implemented by providers that report rate limits from their last call
+type RateLimitReporter interface {
+	LastRateLimit() RateLimit
+}
+
+// String summarizes the rate limit for verbose logs
+func (r RateLimit) String() string {
+	remaining := func(value int) string {
+		if value < 0 {
+			return "unknown"
+		}
+		return strconv.Itoa(value)
+	}
+	s := fmt.Sprintf("%s requests, %s tokens remaining", remaining(r.RemainingRequests), remaining(r.RemainingTokens))
+	if r.RetryAfter > 0 {
+		s += fmt.Sprintf(", retry after %s", r.RetryAfter)
+	}
+	return s
+}
+
+// parseRateLimit reads the rate limit headers of an API response. OpenAI style
+// x-ratelimit-remaining-* headers and Anthropic style anthropic-ratelimit-*-remaining headers are
+// understood; retry-after-ms takes precedence over Retry-After.
+func parseRateLimit(header http.Header) RateLimit {
+	limit := RateLimit{
+		RemainingRequests: headerInt(header, "x-ratelimit-remaining-requests", "anthropic-ratelimit-requests-remaining"),
+		RemainingTokens:   headerInt(header, "x-ratelimit-remaining-tokens", "anthropic-ratelimit-tokens-remaining"),
+		RetryAfter:        retry.ParseRetryAfter(header.Get("Retry-After")),
+	}
+	if ms, err := strconv.ParseFloat(header.Get("retry-after-ms"), 64); err == nil && ms > 0 {
+		limit.RetryAfter = time.Duration(ms * float64(time.Millisecond))
+	}
+	return limit
+}
+
+// headerInt returns the first of the named headers holding an integer, or -1 when none does
+func headerInt(header http.Header, names ...string) int {
+	for _, name := range names {
+		if value, err := strconv.Atoi(header.Get(name)); err == nil {
+			return value
+		}
+	}
+	return -1
+}
+
+// rateLimitError wraps an API error whose response asked the client to wait before retrying
+type rateLimitError struct {
+	err        error
+	retryAfter time.Duration
+}
+
+func (e *rateLimitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *rateLimitError) Unwrap() error {
+	return e.err
+}
+
+// RetryAfter returns the wait requested by the response
+func (e *rateLimitError) RetryAfter() time.Duration {
+	return e.retryAfter
+}
+
+// withRetryAfter attaches the wait requested by a failed response to err, so retries honor it
+func withRetryAfter(err error, limit RateLimit) error {
+	if err == nil || limit.RetryAfter <= 0 {
+		return err
+	}
+	return &rateLimitError{err: err, retryAfter: limit.RetryAfter}
+}
+
+// openAIStatus attaches the HTTP status of a failed go-openai request to err, so only rate
+// limits and server errors are retried
+func openAIStatus(err error) error {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		return retry.WithStatus(err, apiErr.HTTPStatusCode)
+	}
+	var requestErr *openai.RequestError
+	if errors.As(err, &requestErr) {
+		return retry.WithStatus(err, requestErr.HTTPStatusCode)
+	}
+	return err
+}
+
+// headerRecorder wraps client so that record is called with the headers of every response
+func headerRecorder(client *http.Client, record func(header http.Header)) *http.Client {
+	transport := client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	wrapped := *client
+	wrapped.Transport = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		resp, err := transport.RoundTrip(req)
+		if err == nil {
+			record(resp.Header)
+		}
+		return resp, err
+	})
+	return &wrapped
+}
+
+// roundTripperFunc adapts a function to http.RoundTripper
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/kris-hansen/comanda/utils/retry"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	header := http.Header{}
+	header.Set("x-ratelimit-remaining-requests", "42")
+	header.Set("x-ratelimit-remaining-tokens", "9000")
+	header.Set("Retry-After", "2")
+	limit := parseRateLimit(header)
+	if limit.RemainingRequests != 42 || limit.RemainingTokens != 9000 || limit.RetryAfter != 2*time.Second {
+		t.Errorf("parseRateLimit() = %+v, want 42 requests, 9000 tokens, 2s", limit)
+	}
+
+	// retry-after-ms is more precise than Retry-After
+	header.Set("retry-after-ms", "1500")
+	if limit := parseRateLimit(header); limit.RetryAfter != 1500*time.Millisecond {
+		t.Errorf("RetryAfter = %v, want 1.5s", limit.RetryAfter)
+	}
+
+	header = http.Header{}
+	header.Set("anthropic-ratelimit-requests-remaining", "7")
+	limit = parseRateLimit(header)
+	if limit.RemainingRequests != 7 || limit.RemainingTokens != -1 || limit.RetryAfter != 0 {
+		t.Errorf("parseRateLimit() = %+v, want 7 requests and unknown tokens", limit)
+	}
+}
+
+func TestAnthropicHonorsRetryAfter(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Retry-After", "0.01")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`))
+			return
+		}
+		w.Header().Set("anthropic-ratelimit-requests-remaining", "99")
+		w.Write([]byte(`{"content":[{"text":"hello"}]}`))
+	}))
+	defer server.Close()
+	target, _ := url.Parse(server.URL)
+
+	provider := NewAnthropicProvider()
+	provider.SetHTTPClient(&http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		req.URL.Scheme = target.Scheme
+		req.URL.Host = target.Host
+		return http.DefaultTransport.RoundTrip(req)
+	})})
+	// The backoff delay is far longer than the server's Retry-After
+	provider.SetRetryConfig(retry.Config{MaxAttempts: 2, BaseDelay: 10 * time.Second})
+
+	start := time.Now()
+	response, err := provider.sendMessage("claude-3-5-haiku-latest", []anthropicContent{{Type: "text", Text: "hi"}}, "")
+	if err != nil {
+		t.Fatalf("sendMessage() unexpected error: %v", err)
+	}
+	if response != "hello" || calls != 2 {
+		t.Errorf("sendMessage() = %q after %d calls, want hello after 2", response, calls)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("retry waited %s, want the server's Retry-After", elapsed)
+	}
+	if limit := provider.LastRateLimit(); limit.RemainingRequests != 99 {
+		t.Errorf("LastRateLimit() = %+v, want 99 requests remaining", limit)
+	}
+}
+
+func TestAnthropicDoesNotRetryBadRequests(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"type":"error","error":{"type":"invalid_request_error","message":"bad model"}}`))
+	}))
+	defer server.Close()
+	target, _ := url.Parse(server.URL)
+
+	provider := NewAnthropicProvider()
+	provider.SetHTTPClient(&http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		req.URL.Scheme = target.Scheme
+		req.URL.Host = target.Host
+		return http.DefaultTransport.RoundTrip(req)
+	})})
+	provider.SetRetryConfig(retry.Config{MaxAttempts: 3, BaseDelay: 10 * time.Second})
+
+	if _, err := provider.sendMessage("claude-3-5-haiku-latest", []anthropicContent{{Type: "text", Text: "hi"}}, ""); err == nil {
+		t.Fatal("sendMessage() expected an error")
+	}
+	if calls != 1 {
+		t.Errorf("server called %d times, want 1", calls)
+	}
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	thinkOpenTag  = "<think>"
+	thinkCloseTag = "</think>"
+)
+
+// formatReasoning returns the answer of a reasoning model, preceded by its chain of thought in a
+// <reasoning> block when include is set, so later steps can strip it
+func formatReasoning(reasoning, answer string, include bool) string {
+	reasoning = strings.TrimSpace(reasoning)
+	if !include || reasoning == "" {
+		return answer
+	}
+	return fmt.Sprintf("<reasoning>\n%s\n</reasoning>\n\n%s", reasoning, answer)
+}
+
+// splitThinkTags separates the leading <think> block that some open reasoning models, such as
+// deepseek-r1 served by Ollama, put before their answer
+func splitThinkTags(content string) (string, string) {
+	trimmed := strings.TrimLeft(content, " \t\r\n")
+	if !strings.HasPrefix(trimmed, thinkOpenTag) {
+		return "", content
+	}
+	end := strings.Index(trimmed, thinkCloseTag)
+	if end < 0 {
+		return "", content
+	}
+	reasoning := trimmed[len(thinkOpenTag):end]
+	answer := strings.TrimLeft(trimmed[end+len(thinkCloseTag):], " \t\r\n")
+	return reasoning, answer
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"encoding/json"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// Response format types
+const (
+	ResponseFormatJSONObject = "json_object" // Any valid JSON object
+	ResponseFormatJSONSchema = "json_schema" // JSON matching a schema
+)
+
+// ResponseFormat constrains a model's response to JSON, optionally matching a JSON schema
+type ResponseFormat struct {
+	Type   string                 // ResponseFormatJSONObject or ResponseFormatJSONSchema
+	Name   string                 // Name of the schema, which some providers require
+	Schema map[string]interface{} // JSON schema the response must match, for ResponseFormatJSONSchema
+	Strict bool                   // Asks OpenAI compatible APIs to enforce the schema strictly
+}
+
+// ResponseFormatConfigurable is implemented by providers that can constrain responses to JSON.
+// A nil format leaves responses unconstrained.
+type ResponseFormatConfigurable interface {
+	SetResponseFormat(format *ResponseFormat)
+}
+
+// jsonSchema adapts a schema to the json.Marshaler the OpenAI client expects
+type jsonSchema map[string]interface{}
+
+func (s jsonSchema) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}(s))
+}
+
+// openAIResponseFormat converts a response format for OpenAI compatible APIs. Strict mode is only
+// requested when the step asks for it, since it rejects schemas that do not list every property as
+// required and set additionalProperties: false on every object.
+func openAIResponseFormat(format *ResponseFormat) *openai.ChatCompletionResponseFormat {
+	if format == nil {
+		return nil
+	}
+	if format.Type != ResponseFormatJSONSchema {
+		return &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeJSONObject}
+	}
+	return &openai.ChatCompletionResponseFormat{
+		Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+		JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+			Name:   format.Name,
+			Schema: jsonSchema(format.Schema),
+			Strict: format.Strict,
+		},
+	}
+}
+
+// ollamaResponseFormat converts a response format to the format field of Ollama requests,
+// which is either "json" or a schema
+func ollamaResponseFormat(format *ResponseFormat) interface{} {
+	if format == nil {
+		return nil
+	}
+	if format.Type != ResponseFormatJSONSchema {
+		return "json"
+	}
+	return format.Schema
+}
@@ -0,0 +1,142 @@
+package models
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/kris-hansen/comanda/utils/retry"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// StreamHandler receives a response in pieces as the model generates it
+type StreamHandler interface {
+	// OnChunk is called with each piece of the answer in order. Returning an error stops the stream.
+	OnChunk(text string) error
+}
+
+// StreamFunc adapts a function to a StreamHandler
+type StreamFunc func(text string) error
+
+// OnChunk calls the function with the piece of the answer
+func (f StreamFunc) OnChunk(text string) error {
+	return f(text)
+}
+
+// SendPromptBlocking is the streaming fallback for requests a provider cannot stream: it waits
+// for the whole response and passes it to the handler as a single piece
+func SendPromptBlocking(provider Provider, modelName string, prompt string, handler StreamHandler) (string, error) {
+	response, err := provider.SendPrompt(modelName, prompt)
+	if err != nil {
+		return "", err
+	}
+	if err := handler.OnChunk(response); err != nil {
+		return "", err
+	}
+	return response, nil
+}
+
+// streamChat streams a chat completion from an OpenAI compatible API, passing each piece of the
+// answer to handler, and returns the whole answer with the usage reported at the end of the
+// stream. Opening the stream is retried; once pieces have been passed on it is not.
+func streamChat(ctx context.Context, client *openai.Client, req openai.ChatCompletionRequest, handler StreamHandler, retryConfig retry.Config) (string, openai.Usage, error) {
+	req.Stream = true
+	req.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
+	stream, err := retry.WithRetry(func() (*openai.ChatCompletionStream, error) {
+		stream, err := client.CreateChatCompletionStream(ctx, req)
+		return stream, openAIStatus(err)
+	}, retryConfig)
+	if err != nil {
+		return "", openai.Usage{}, err
+	}
+	defer stream.Close()
+
+	var response strings.Builder
+	var usage openai.Usage
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", usage, err
+		}
+		if chunk.Usage != nil {
+			usage = *chunk.Usage
+		}
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			continue
+		}
+		text := chunk.Choices[0].Delta.Content
+		response.WriteString(text)
+		if err := handler.OnChunk(text); err != nil {
+			return "", usage, err
+		}
+	}
+	return response.String(), usage, nil
+}
+
+// readSSE calls fn with the data of each server-sent event in body until the body ends, a
+// [DONE] event arrives, or fn returns an error
+func readSSE(body io.Reader, fn func(data string) error) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			return nil
+		}
+		if err := fn(data); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// thinkStream passes streamed text on to a handler. Unless reasoning is included, it holds back
+// the leading <think> block of open reasoning models, as splitThinkTags does for whole responses.
+type thinkStream struct {
+	handler StreamHandler
+	include bool
+	text    strings.Builder
+	sent    int // Length of the text passed on or skipped so far
+}
+
+// write adds a piece of the response and passes on whatever part of it belongs to the answer
+func (s *thinkStream) write(chunk string) error {
+	s.text.WriteString(chunk)
+	full := s.text.String()
+
+	start := 0
+	if !s.include {
+		trimmed := strings.TrimLeft(full, " \t\r\n")
+		if strings.HasPrefix(thinkOpenTag, trimmed) {
+			// Wait until it is clear whether the response starts with a <think> block
+			return nil
+		}
+		if strings.HasPrefix(trimmed, thinkOpenTag) {
+			end := strings.Index(trimmed, thinkCloseTag)
+			if end < 0 {
+				return nil
+			}
+			start = len(full) - len(trimmed) + end + len(thinkCloseTag)
+			for start < len(full) && strings.ContainsRune(" \t\r\n", rune(full[start])) {
+				start++
+			}
+		}
+	}
+	if start < s.sent {
+		start = s.sent
+	}
+	if start >= len(full) {
+		return nil
+	}
+	s.sent = len(full)
+	return s.handler.OnChunk(full[start:])
+}
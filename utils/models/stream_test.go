@@ -0,0 +1,173 @@
+package models
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// redirectTo returns an HTTP client that sends every request to server instead
+func redirectTo(server *httptest.Server) *http.Client {
+	target, _ := url.Parse(server.URL)
+	return &http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		req.URL.Scheme = target.Scheme
+		req.URL.Host = target.Host
+		return http.DefaultTransport.RoundTrip(req)
+	})}
+}
+
+func TestSendPromptStream(t *testing.T) {
+	tests := []struct {
+		name       string
+		model      string
+		body       string
+		provider   func(server *httptest.Server) Provider
+		wantChunks []string
+		want       string
+		wantUsage  TokenUsage
+	}{
+		{
+			name:  "openai",
+			model: "gpt-4o",
+			body: "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hel\"}}]}\n\n" +
+				"data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"lo\"}}]}\n\n" +
+				"data: {\"choices\":[],\"usage\":{\"prompt_tokens\":5,\"completion_tokens\":2}}\n\n" +
+				"data: [DONE]\n\n",
+			provider: func(server *httptest.Server) Provider {
+				provider := NewOpenAIProvider()
+				provider.Configure("test-key")
+				provider.SetHTTPClient(redirectTo(server))
+				return provider
+			},
+			wantChunks: []string{"Hel", "lo"},
+			want:       "Hello",
+			wantUsage:  TokenUsage{InputTokens: 5, OutputTokens: 2},
+		},
+		{
+			name:  "anthropic with thinking",
+			model: "claude-3-5-sonnet-latest",
+			body: "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"usage\":{\"input_tokens\":9}}}\n\n" +
+				"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"thinking_delta\",\"thinking\":\"Easy.\"}}\n\n" +
+				"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"4\"}}\n\n" +
+				"event: message_delta\ndata: {\"type\":\"message_delta\",\"usage\":{\"output_tokens\":3}}\n\n" +
+				"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n",
+			provider: func(server *httptest.Server) Provider {
+				provider := NewAnthropicProvider()
+				provider.Configure("test-key")
+				provider.SetHTTPClient(redirectTo(server))
+				return provider
+			},
+			wantChunks: []string{"4"},
+			want:       "4",
+			wantUsage:  TokenUsage{InputTokens: 9, OutputTokens: 3},
+		},
+		{
+			name:  "deepseek",
+			model: "deepseek-chat",
+			body: "data: {\"choices\":[{\"delta\":{\"content\":\"Hi \"}}]}\n\n" +
+				"data: {\"choices\":[{\"delta\":{\"content\":\"there\"}}]}\n\n" +
+				"data: [DONE]\n\n",
+			provider: func(server *httptest.Server) Provider {
+				provider := NewDeepseekProvider()
+				provider.Configure("test-key")
+				provider.baseURL = server.URL
+				return provider
+			},
+			wantChunks: []string{"Hi ", "there"},
+			want:       "Hi there",
+		},
+		{
+			name:  "ollama holds back think block",
+			model: "deepseek-r1",
+			body: "{\"response\":\"<think>\"}\n{\"response\":\"hmm\"}\n{\"response\":\"</think>\\n\\n\"}\n" +
+				"{\"response\":\"Par\"}\n{\"response\":\"is\",\"done\":true}\n",
+			provider: func(server *httptest.Server) Provider {
+				provider := NewOllamaProvider()
+				provider.baseURL = server.URL
+				return provider
+			},
+			wantChunks: []string{"Par", "is"},
+			want:       "Paris",
+		},
+		{
+			name:       "echo",
+			model:      "echo",
+			provider:   func(server *httptest.Server) Provider { return NewEchoProvider() },
+			wantChunks: []string{"line one\n", "line two"},
+			want:       "line one\nline two",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, tt.body)
+			}))
+			defer server.Close()
+
+			provider := tt.provider(server)
+			var chunks []string
+			got, err := provider.SendPromptStream(tt.model, "line one\nline two", StreamFunc(func(text string) error {
+				chunks = append(chunks, text)
+				return nil
+			}))
+			if err != nil {
+				t.Fatalf("SendPromptStream() unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("SendPromptStream() = %q, want %q", got, tt.want)
+			}
+			if strings.Join(chunks, "|") != strings.Join(tt.wantChunks, "|") {
+				t.Errorf("chunks = %q, want %q", chunks, tt.wantChunks)
+			}
+			if reporter, ok := provider.(UsageReporter); ok && reporter.LastUsage() != tt.wantUsage {
+				t.Errorf("LastUsage() = %+v, want %+v", reporter.LastUsage(), tt.wantUsage)
+			}
+		})
+	}
+}
+
+func TestSendPromptStreamHandlerError(t *testing.T) {
+	stop := fmt.Errorf("client went away")
+	_, err := NewEchoProvider().SendPromptStream("echo", "a\nb", StreamFunc(func(text string) error {
+		return stop
+	}))
+	if err != stop {
+		t.Errorf("SendPromptStream() error = %v, want the handler's error", err)
+	}
+}
+
+func TestThinkStream(t *testing.T) {
+	tests := []struct {
+		name    string
+		include bool
+		chunks  []string
+		want    string
+	}{
+		{"no think block", false, []string{"Hel", "lo"}, "Hello"},
+		{"think block held back", false, []string{"  <thi", "nk>why</th", "ink>  Ans", "wer"}, "Answer"},
+		{"think block included", true, []string{"<think>why</think>", "Answer"}, "<think>why</think>Answer"},
+		{"unfinished think block", false, []string{"<think>still going"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got strings.Builder
+			stream := &thinkStream{handler: StreamFunc(func(text string) error {
+				got.WriteString(text)
+				return nil
+			}), include: tt.include}
+			for _, chunk := range tt.chunks {
+				if err := stream.write(chunk); err != nil {
+					t.Fatalf("write() unexpected error: %v", err)
+				}
+			}
+			if got.String() != tt.want {
+				t.Errorf("streamed %q, want %q", got.String(), tt.want)
+			}
+		})
+	}
+}
@@ -2,19 +2,30 @@ package models
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
+	"net/http"
 	"strings"
 	"time"
 
 	"github.com/kris-hansen/comanda/utils/fileutil"
+	"github.com/kris-hansen/comanda/utils/logging"
+	"github.com/kris-hansen/comanda/utils/retry"
 	openai "github.com/sashabaranov/go-openai"
 )
 
 // XAIProvider handles X.AI family of models
 type XAIProvider struct {
-	apiKey  string
-	config  ModelConfig
-	verbose bool
+	apiKey      string
+	config      ModelConfig
+	verbose     bool
+	retryConfig retry.Config
+	httpClient  *http.Client // nil uses the default client
+
+	systemPrompt   string          // Sent as a system message before the user message when set
+	responseFormat *ResponseFormat // Constrains responses to JSON when set
+	liveSearch     string          // Live search mode sent as search_parameters; empty sends none
+	baseURL        string          // Replaces the default API address when set
 }
 
 // Default configuration values
@@ -30,9 +41,10 @@ func NewXAIProvider() *XAIProvider {
 	return &XAIProvider{
 		config: ModelConfig{
 			Temperature: 0.7,
-			MaxTokens:   2000,
+			MaxTokens:   DefaultMaxTokens,
 			TopP:        1.0,
 		},
+		retryConfig: retry.DefaultRetryConfig,
 	}
 }
 
@@ -43,9 +55,7 @@ func (x *XAIProvider) Name() string {
 
 // debugf prints debug information if verbose mode is enabled
 func (x *XAIProvider) debugf(format string, args ...interface{}) {
-	if x.verbose {
-		fmt.Printf("[DEBUG][XAI] "+format+"\n", args...)
-	}
+	logging.New("XAI", x.verbose).Debugf(format, args...)
 }
 
 // SupportsModel checks if the given model name is supported by X.AI
@@ -90,63 +100,69 @@ func (x *XAIProvider) SendPrompt(modelName string, prompt string) (string, error
 	x.debugf("Preparing to send prompt to model: %s", modelName)
 	x.debugf("Prompt length: %d characters", len(prompt))
 
-	if x.apiKey == "" {
-		return "", fmt.Errorf("X.AI provider not configured: missing API key")
+	message, err := x.promptMessage(modelName, prompt)
+	if err != nil {
+		return "", err
 	}
+	return x.complete(modelName, message)
+}
 
-	if !x.SupportsModel(modelName) {
-		return "", fmt.Errorf("invalid X.AI model: %s", modelName)
-	}
+// SendPromptStream sends a prompt to the specified model and passes the response to handler as
+// it is generated
+func (x *XAIProvider) SendPromptStream(modelName string, prompt string, handler StreamHandler) (string, error) {
+	x.debugf("Preparing to stream prompt to model: %s", modelName)
 
-	// Check estimated token count
-	estimatedTokens := x.estimateTokenCount(prompt)
-	if estimatedTokens > maxPromptTokens {
-		return "", fmt.Errorf("prompt likely exceeds maximum token limit of %d (estimated tokens: %d)", maxPromptTokens, estimatedTokens)
+	message, err := x.promptMessage(modelName, prompt)
+	if err != nil {
+		return "", err
 	}
 
-	x.debugf("Model validation passed, preparing API call")
-	x.debugf("Using configuration: Temperature=%.2f, MaxTokens=%d, TopP=%.2f",
-		x.config.Temperature, x.config.MaxTokens, x.config.TopP)
-
-	config := openai.DefaultConfig(x.apiKey)
-	config.BaseURL = "https://api.x.ai/v1"
-	client := openai.NewClientWithConfig(config)
-
-	// Create context with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
 	defer cancel()
 
-	resp, err := client.CreateChatCompletion(
-		ctx,
-		openai.ChatCompletionRequest{
-			Model: modelName,
-			Messages: []openai.ChatCompletionMessage{
-				{
-					Role:    openai.ChatMessageRoleUser,
-					Content: prompt,
-				},
-			},
-			Temperature: float32(x.config.Temperature),
-			MaxTokens:   x.config.MaxTokens,
-			TopP:        float32(x.config.TopP),
-		},
-	)
-
+	response, _, err := streamChat(ctx, x.newClient(), x.chatRequest(modelName, message), handler, x.retryConfig)
 	if err != nil {
 		if ctx.Err() == context.DeadlineExceeded {
 			return "", fmt.Errorf("request timed out after %v", defaultTimeout)
 		}
 		return "", fmt.Errorf("X.AI API error: %v", err)
 	}
+	x.debugf("Stream completed, response length: %d characters", len(response))
 
-	if len(resp.Choices) == 0 {
-		return "", fmt.Errorf("no response choices returned from X.AI")
+	return response, nil
+}
+
+// promptMessage checks the provider and prompt and builds the user message for a prompt
+func (x *XAIProvider) promptMessage(modelName string, prompt string) (openai.ChatCompletionMessage, error) {
+	if x.apiKey == "" {
+		return openai.ChatCompletionMessage{}, fmt.Errorf("X.AI provider not configured: missing API key")
 	}
 
-	response := resp.Choices[0].Message.Content
-	x.debugf("API call completed, response length: %d characters", len(response))
+	if !x.SupportsModel(modelName) {
+		return openai.ChatCompletionMessage{}, fmt.Errorf("invalid X.AI model: %s", modelName)
+	}
 
-	return response, nil
+	// Image inputs arrive as base64 data in the prompt and are sent as image content
+	if strings.Contains(prompt, ";base64,") {
+		action, imageData, err := splitVisionPrompt(prompt)
+		if err != nil {
+			return openai.ChatCompletionMessage{}, err
+		}
+		x.debugf("Vision prompt image data length: %d", len(imageData))
+		return imageMessage(action, imageData), nil
+	}
+
+	// Check estimated token count
+	estimatedTokens := x.estimateTokenCount(prompt)
+	if estimatedTokens > maxPromptTokens {
+		return openai.ChatCompletionMessage{}, fmt.Errorf("prompt likely exceeds maximum token limit of %d (estimated tokens: %d)", maxPromptTokens, estimatedTokens)
+	}
+
+	x.debugf("Model validation passed, preparing API call")
+	return openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleUser,
+		Content: prompt,
+	}, nil
 }
 
 // SendPromptWithFile sends a prompt along with a file to the specified model and returns the response
@@ -168,59 +184,10 @@ func (x *XAIProvider) SendPromptWithFile(modelName string, prompt string, file F
 		return "", fmt.Errorf("failed to read file: %v", err)
 	}
 
-	config := openai.DefaultConfig(x.apiKey)
-	config.BaseURL = "https://api.x.ai/v1"
-	client := openai.NewClientWithConfig(config)
-
-	// Create context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
-	defer cancel()
-
-	// For image files, use MultiContent approach similar to OpenAI
+	// Images are sent as image content for Grok's vision models
 	if strings.HasPrefix(file.MimeType, "image/") {
-		base64Data := fmt.Sprintf("data:%s;base64,%s", file.MimeType, string(fileData))
-		content := []openai.ChatMessagePart{
-			{
-				Type: openai.ChatMessagePartTypeText,
-				Text: prompt,
-			},
-			{
-				Type: openai.ChatMessagePartTypeImageURL,
-				ImageURL: &openai.ChatMessageImageURL{
-					URL: base64Data,
-				},
-			},
-		}
-
-		resp, err := client.CreateChatCompletion(
-			ctx,
-			openai.ChatCompletionRequest{
-				Model: modelName,
-				Messages: []openai.ChatCompletionMessage{
-					{
-						Role:         openai.ChatMessageRoleUser,
-						MultiContent: content,
-					},
-				},
-				MaxTokens: x.config.MaxTokens,
-			},
-		)
-
-		if err != nil {
-			if ctx.Err() == context.DeadlineExceeded {
-				return "", fmt.Errorf("request timed out after %v", defaultTimeout)
-			}
-			return "", fmt.Errorf("X.AI API error: %v", err)
-		}
-
-		if len(resp.Choices) == 0 {
-			return "", fmt.Errorf("no response choices returned from X.AI")
-		}
-
-		response := resp.Choices[0].Message.Content
-		x.debugf("API call completed, response length: %d characters", len(response))
-
-		return response, nil
+		imageData := fmt.Sprintf("data:%s;base64,%s", file.MimeType, base64.StdEncoding.EncodeToString(fileData))
+		return x.complete(modelName, imageMessage(prompt, imageData))
 	}
 
 	// For non-image files, combine content with prompt
@@ -233,21 +200,46 @@ func (x *XAIProvider) SendPromptWithFile(modelName string, prompt string, file F
 		return "", fmt.Errorf("combined prompt likely exceeds maximum token limit of %d (estimated tokens: %d)", maxPromptTokens, estimatedTokens)
 	}
 
-	resp, err := client.CreateChatCompletion(
-		ctx,
-		openai.ChatCompletionRequest{
-			Model: modelName,
-			Messages: []openai.ChatCompletionMessage{
-				{
-					Role:    openai.ChatMessageRoleUser,
-					Content: combinedPrompt,
+	return x.complete(modelName, openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleUser,
+		Content: combinedPrompt,
+	})
+}
+
+// imageMessage builds a user message with a text prompt and an image given as a data URI
+func imageMessage(prompt, imageData string) openai.ChatCompletionMessage {
+	return openai.ChatCompletionMessage{
+		Role: openai.ChatMessageRoleUser,
+		MultiContent: []openai.ChatMessagePart{
+			{
+				Type: openai.ChatMessagePartTypeText,
+				Text: prompt,
+			},
+			{
+				Type: openai.ChatMessagePartTypeImageURL,
+				ImageURL: &openai.ChatMessageImageURL{
+					URL: imageData,
 				},
 			},
-			Temperature: float32(x.config.Temperature),
-			MaxTokens:   x.config.MaxTokens,
-			TopP:        float32(x.config.TopP),
 		},
-	)
+	}
+}
+
+// complete sends a user message to the model with the configured settings and returns the response
+func (x *XAIProvider) complete(modelName string, message openai.ChatCompletionMessage) (string, error) {
+	x.debugf("Using configuration: Temperature=%.2f, MaxTokens=%d, TopP=%.2f",
+		x.config.Temperature, x.config.MaxTokens, x.config.TopP)
+
+	client := x.newClient()
+
+	// Create context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	resp, err := retry.WithRetry(func() (openai.ChatCompletionResponse, error) {
+		resp, err := client.CreateChatCompletion(ctx, x.chatRequest(modelName, message))
+		return resp, openAIStatus(err)
+	}, x.retryConfig)
 
 	if err != nil {
 		if ctx.Err() == context.DeadlineExceeded {
@@ -266,6 +258,21 @@ func (x *XAIProvider) SendPromptWithFile(modelName string, prompt string, file F
 	return response, nil
 }
 
+// chatRequest builds a chat completion request for a user message with the configured settings
+func (x *XAIProvider) chatRequest(modelName string, message openai.ChatCompletionMessage) openai.ChatCompletionRequest {
+	return openai.ChatCompletionRequest{
+		Model:       modelName,
+		Messages:    withSystemMessage(x.systemPrompt, []openai.ChatCompletionMessage{message}),
+		Temperature: float32(x.config.Temperature),
+		MaxTokens:   x.config.MaxTokens,
+		TopP:        float32(x.config.TopP),
+		Seed:        x.config.Seed,
+		Stop:        x.config.Stop,
+
+		ResponseFormat: openAIResponseFormat(x.responseFormat),
+	}
+}
+
 // ValidateModel checks if the specific X.AI model variant is valid
 func (x *XAIProvider) ValidateModel(modelName string) bool {
 	return x.SupportsModel(modelName)
@@ -286,7 +293,56 @@ func (x *XAIProvider) GetConfig() ModelConfig {
 	return x.config
 }
 
+// SetSystemPrompt sets the system prompt sent with each request
+func (x *XAIProvider) SetSystemPrompt(prompt string) {
+	x.systemPrompt = prompt
+}
+
+// SetResponseFormat constrains responses to JSON, optionally matching a schema; nil removes the constraint
+func (x *XAIProvider) SetResponseFormat(format *ResponseFormat) {
+	x.responseFormat = format
+}
+
+// SetLiveSearch sets whether Grok may search the web and X for current information
+func (x *XAIProvider) SetLiveSearch(mode string) {
+	x.liveSearch = mode
+}
+
 // SetVerbose enables or disables verbose mode
 func (x *XAIProvider) SetVerbose(verbose bool) {
 	x.verbose = verbose
 }
+
+// SetRetryConfig sets how failed API calls are retried
+func (x *XAIProvider) SetRetryConfig(config retry.Config) {
+	x.retryConfig = config
+}
+
+// SetHTTPClient sets the HTTP client used for API calls
+func (x *XAIProvider) SetHTTPClient(client *http.Client) {
+	x.httpClient = client
+}
+
+// SetBaseURL sets the address API calls are sent to; an empty URL restores the default
+func (x *XAIProvider) SetBaseURL(url string) {
+	x.baseURL = url
+}
+
+// newClient creates an API client for the OpenAI-compatible xAI API using the configured HTTP client
+func (x *XAIProvider) newClient() *openai.Client {
+	config := openai.DefaultConfig(x.apiKey)
+	config.BaseURL = "https://api.x.ai/v1"
+	if x.baseURL != "" {
+		config.BaseURL = strings.TrimSuffix(x.baseURL, "/")
+	}
+	client := httpClientOrDefault(x.httpClient)
+	if x.liveSearch != "" && x.liveSearch != LiveSearchOff {
+		// The OpenAI client has no field for xAI's search parameters, so they are added to the body
+		x.debugf("Live search mode: %s", x.liveSearch)
+		client = withBodyFields(client, map[string]interface{}{
+			"search_parameters": map[string]string{"mode": x.liveSearch},
+		})
+	}
+	config.HTTPClient = client
+	return openai.NewClientWithConfig(config)
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestXAIVisionAndLiveSearch(t *testing.T) {
+	var request struct {
+		Messages []struct {
+			Content json.RawMessage `json:"content"`
+		} `json:"messages"`
+		SearchParameters *struct {
+			Mode string `json:"mode"`
+		} `json:"search_parameters"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request.SearchParameters = nil
+		json.NewDecoder(r.Body).Decode(&request)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"a cat"}}]}`))
+	}))
+	defer server.Close()
+	target, _ := url.Parse(server.URL)
+
+	provider := NewXAIProvider()
+	provider.Configure("test-key")
+	provider.SetHTTPClient(&http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		req.URL.Scheme = target.Scheme
+		req.URL.Host = target.Host
+		return http.DefaultTransport.RoundTrip(req)
+	})})
+
+	// imageURL returns the image sent with the last request, or an empty string if it had none
+	imageURL := func() string {
+		var parts []struct {
+			Type     string `json:"type"`
+			ImageURL struct {
+				URL string `json:"url"`
+			} `json:"image_url"`
+		}
+		if err := json.Unmarshal(request.Messages[len(request.Messages)-1].Content, &parts); err != nil {
+			return ""
+		}
+		for _, part := range parts {
+			if part.Type == "image_url" {
+				return part.ImageURL.URL
+			}
+		}
+		return ""
+	}
+
+	if _, err := provider.SendPrompt("grok-2", "hello"); err != nil {
+		t.Fatalf("SendPrompt() unexpected error: %v", err)
+	}
+	if request.SearchParameters != nil || imageURL() != "" {
+		t.Errorf("plain prompt sent search_parameters %+v or an image", request.SearchParameters)
+	}
+
+	// Images from a file are sent base64 encoded
+	imageData := []byte("\x89PNG fake image")
+	path := filepath.Join(t.TempDir(), "cat.png")
+	if err := os.WriteFile(path, imageData, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.SendPromptWithFile("grok-2-vision-latest", "What is this?", FileInput{Path: path, MimeType: "image/png"}); err != nil {
+		t.Fatalf("SendPromptWithFile() unexpected error: %v", err)
+	}
+	if want := "data:image/png;base64," + base64.StdEncoding.EncodeToString(imageData); imageURL() != want {
+		t.Errorf("image = %q, want %q", imageURL(), want)
+	}
+
+	// Image inputs embedded in the prompt are sent as image content
+	if _, err := provider.SendPrompt("grok-2-vision-latest", "Input:\ndata:image/png;base64,AAAA\n\nAction: What is this?"); err != nil {
+		t.Fatalf("SendPrompt() unexpected error: %v", err)
+	}
+	if imageURL() != "data:image/png;base64,AAAA" {
+		t.Errorf("image = %q, want the prompt's image data", imageURL())
+	}
+
+	provider.SetLiveSearch(LiveSearchAuto)
+	if _, err := provider.SendPrompt("grok-2", "What happened today?"); err != nil {
+		t.Fatalf("SendPrompt() unexpected error: %v", err)
+	}
+	if request.SearchParameters == nil || request.SearchParameters.Mode != "auto" {
+		t.Errorf("search_parameters = %+v, want mode auto", request.SearchParameters)
+	}
+
+	provider.SetLiveSearch(LiveSearchOff)
+	if _, err := provider.SendPrompt("grok-2", "hello"); err != nil {
+		t.Fatalf("SendPrompt() unexpected error: %v", err)
+	}
+	if request.SearchParameters != nil {
+		t.Errorf("search_parameters = %+v, want none when off", request.SearchParameters)
+	}
+}
@@ -1,7 +1,12 @@
 package processor
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/kris-hansen/comanda/utils/fileutil"
@@ -10,17 +15,21 @@ import (
 	"github.com/kris-hansen/comanda/utils/scraper"
 )
 
+// maxPromptBytes is the largest prompt an action URL may return
+const maxPromptBytes = 1 << 20
+
 // processActions handles the action section of the DSL
-func (p *Processor) processActions(modelNames []string, actions []string) (string, error) {
+func (p *Processor) processActions(modelNames []string, actions []string, stepConfig StepConfig) (string, error) {
 	if len(modelNames) == 0 {
 		return "", fmt.Errorf("no model specified for actions")
 	}
 
-	// For now, use the first model specified
+	// Without aggregate, only the first model specified is used
 	modelName := modelNames[0]
 
 	// Special case: if model is NA, return the input content directly
 	if modelName == "NA" {
+		p.lastModel = modelName
 		inputs := p.handler.GetInputs()
 		if len(inputs) == 0 {
 			// If there are no inputs, return empty string since there's no content to process
@@ -32,113 +41,510 @@ func (p *Processor) processActions(modelNames []string, actions []string) (strin
 		for _, inputItem := range inputs {
 			contents = append(contents, string(inputItem.Contents))
 		}
-		return strings.Join(contents, "\n"), nil
+		return formatOutput(strings.Join(contents, "\n"), stepConfig.OutputFormat)
+	}
+
+	// Run every model and combine their answers when the step asks for it
+	if stepConfig.Aggregate != "" && len(modelNames) > 1 {
+		return p.aggregateActions(modelNames, actions, stepConfig)
+	}
+
+	// Try the primary model first, then each fallback model in order
+	candidates := append([]string{modelName}, p.NormalizeStringSlice(stepConfig.Fallback)...)
+	var lastErr error
+	for i, candidate := range candidates {
+		if i > 0 {
+			p.debugf("Model %s failed: %v", candidates[i-1], lastErr)
+			p.debugf("Trying fallback model %s", candidate)
+		}
+		response, err := p.runFormattedActions(candidate, actions, stepConfig)
+		if err == nil {
+			p.debugf("Output produced by model %s", candidate)
+			p.lastModel = candidate
+			return response, nil
+		}
+		if errors.Is(err, ErrBudgetExceeded) {
+			return "", err
+		}
+		lastErr = err
+	}
+
+	if len(candidates) > 1 {
+		return "", fmt.Errorf("all %d models failed, last error from %s: %w", len(candidates), candidates[len(candidates)-1], lastErr)
+	}
+	return "", lastErr
+}
+
+// validateMaxTokens checks the step's response token limit
+func validateMaxTokens(stepConfig StepConfig) error {
+	if stepConfig.MaxTokens < 0 {
+		return fmt.Errorf("max_tokens must be a positive number")
+	}
+	return nil
+}
+
+// outputTokens returns the most tokens a model's response may use in the step: the step's
+// max_tokens, or else the default, lowered to what the model can generate
+func outputTokens(modelName string, stepConfig StepConfig) int {
+	if stepConfig.MaxTokens == 0 {
+		return models.DefaultMaxTokens
+	}
+	maxTokens, _ := models.ClampOutputTokens(modelName, stepConfig.MaxTokens)
+	return maxTokens
+}
+
+// applySamplingOptions passes the step's response token limit, seed and stop sequences to the
+// provider. Like the reasoning setting they are applied on every call, so options from an earlier
+// step do not carry over.
+func (p *Processor) applySamplingOptions(provider models.Provider, modelName string, stepConfig StepConfig) {
+	stop := p.NormalizeStringSlice(stepConfig.Stop)
+	configurable, ok := provider.(models.ConfigurableProvider)
+	if !ok {
+		if stepConfig.MaxTokens > 0 || stepConfig.Seed != nil || len(stop) > 0 {
+			p.debugf("Provider %s does not support max_tokens, seed or stop sequences; ignoring them", provider.Name())
+		}
+		return
+	}
+
+	modelConfig := configurable.GetConfig()
+	modelConfig.MaxTokens = outputTokens(modelName, stepConfig)
+	if stepConfig.MaxTokens > modelConfig.MaxTokens {
+		p.warnf("max_tokens %d is more than model %s can generate; using %d", stepConfig.MaxTokens, modelName, modelConfig.MaxTokens)
+	}
+	modelConfig.MaxCompletionTokens = modelConfig.MaxTokens
+	modelConfig.Seed = stepConfig.Seed
+	modelConfig.Stop = stop
+	configurable.SetConfig(modelConfig)
+}
+
+// minThinkingBudget is the smallest extended thinking budget providers accept
+const minThinkingBudget = 1024
+
+// validateThinking checks the step's extended thinking budget
+func validateThinking(stepConfig StepConfig) error {
+	if stepConfig.Thinking != nil && stepConfig.Thinking.BudgetTokens < minThinkingBudget {
+		return fmt.Errorf("thinking budget_tokens must be at least %d, got %d", minThinkingBudget, stepConfig.Thinking.BudgetTokens)
+	}
+	return nil
+}
+
+// applyThinking passes the step's extended thinking budget to the provider. It is applied on every
+// call so a budget from an earlier step does not carry over.
+func (p *Processor) applyThinking(provider models.Provider, stepConfig StepConfig) {
+	budget := 0
+	if stepConfig.Thinking != nil {
+		budget = stepConfig.Thinking.BudgetTokens
+	}
+
+	configurable, ok := provider.(models.ThinkingConfigurable)
+	if !ok {
+		if budget > 0 {
+			p.debugf("Provider %s does not support extended thinking; ignoring the thinking budget", provider.Name())
+		}
+		return
+	}
+	configurable.SetThinkingBudget(budget)
+}
+
+// validateLiveSearch checks the step's live_search mode
+func validateLiveSearch(stepConfig StepConfig) error {
+	switch stepConfig.LiveSearch {
+	case "", models.LiveSearchOff, models.LiveSearchAuto, models.LiveSearchOn:
+		return nil
+	}
+	return fmt.Errorf("live_search must be off, auto, or on, got %s", stepConfig.LiveSearch)
+}
+
+// applyLiveSearch passes the step's live_search mode to the provider. It is applied on every call
+// so search enabled for an earlier step does not carry over.
+func (p *Processor) applyLiveSearch(provider models.Provider, stepConfig StepConfig) {
+	configurable, ok := provider.(models.LiveSearchConfigurable)
+	if !ok {
+		if stepConfig.LiveSearch != "" && stepConfig.LiveSearch != models.LiveSearchOff {
+			p.debugf("Provider %s does not support live search; ignoring it", provider.Name())
+		}
+		return
+	}
+	configurable.SetLiveSearch(stepConfig.LiveSearch)
+}
+
+// applySystemPrompt passes the step's system prompt, or else the model's configured default, to the
+// provider. It is applied on every call so a prompt from an earlier step does not carry over.
+func (p *Processor) applySystemPrompt(provider models.Provider, modelName string, stepConfig StepConfig) {
+	system := p.systemPrompt(provider, modelName, stepConfig)
+	configurable, ok := provider.(models.SystemPromptConfigurable)
+	if !ok {
+		if system != "" {
+			p.debugf("Provider %s does not support system prompts; ignoring it", provider.Name())
+		}
+		return
+	}
+	configurable.SetSystemPrompt(system)
+}
+
+// applyBaseURL sends calls for a model to the API address configured for it, or else to the
+// provider's default. It is applied on every call so an address set for another model does not
+// carry over.
+func (p *Processor) applyBaseURL(provider models.Provider, modelName string) {
+	var baseURL string
+	if p.envConfig != nil {
+		if modelConfig, err := p.envConfig.GetModelConfig(provider.Name(), modelName); err == nil {
+			baseURL = modelConfig.BaseURL
+		}
+	}
+
+	configurable, ok := provider.(models.BaseURLConfigurable)
+	if !ok {
+		if baseURL != "" {
+			p.debugf("Provider %s does not support a base URL; ignoring it", provider.Name())
+		}
+		return
+	}
+	if baseURL != "" {
+		p.debugf("Sending calls for model %s to %s", modelName, baseURL)
+	}
+	configurable.SetBaseURL(baseURL)
+}
+
+// systemPrompt returns the step's system prompt, or else the model's configured default
+func (p *Processor) systemPrompt(provider models.Provider, modelName string, stepConfig StepConfig) string {
+	if stepConfig.System != "" || p.envConfig == nil {
+		return stepConfig.System
 	}
+	if modelConfig, err := p.envConfig.GetModelConfig(provider.Name(), modelName); err == nil {
+		return modelConfig.System
+	}
+	return ""
+}
 
+// stepProvider returns the configured provider for a model with the step's settings applied
+func (p *Processor) stepProvider(modelName string, stepConfig StepConfig) (models.Provider, error) {
 	// Get provider by detecting it from the model name
 	provider := models.DetectProvider(modelName)
 	if provider == nil {
-		return "", fmt.Errorf("provider not found for model: %s", modelName)
+		return nil, fmt.Errorf("provider not found for model: %s", modelName)
 	}
 
 	// Use the configured provider instance
 	configuredProvider := p.providers[provider.Name()]
 	if configuredProvider == nil {
-		return "", fmt.Errorf("provider %s not configured", provider.Name())
+		return nil, fmt.Errorf("provider %s not configured", provider.Name())
 	}
 
 	p.debugf("Using model %s with provider %s", modelName, configuredProvider.Name())
+
+	// Providers are shared between steps, so the reasoning setting is applied on every call
+	if reasoningProvider, ok := configuredProvider.(models.ReasoningConfigurable); ok {
+		reasoningProvider.SetIncludeReasoning(stepConfig.IncludeReasoning)
+	}
+	p.applySamplingOptions(configuredProvider, modelName, stepConfig)
+	p.applySystemPrompt(configuredProvider, modelName, stepConfig)
+	p.applyBaseURL(configuredProvider, modelName)
+	p.applyThinking(configuredProvider, stepConfig)
+	p.applyLiveSearch(configuredProvider, stepConfig)
+	if err := p.applyResponseFormat(configuredProvider, stepConfig); err != nil {
+		return nil, err
+	}
+	return configuredProvider, nil
+}
+
+// runActions sends the step's actions and inputs to a single model
+func (p *Processor) runActions(modelName string, actions []string, stepConfig StepConfig) (string, error) {
+	configuredProvider, err := p.stepProvider(modelName, stepConfig)
+	if err != nil {
+		return "", err
+	}
 	p.debugf("Processing %d action(s)", len(actions))
 
-	for i, action := range actions {
-		p.debugf("Processing action %d/%d: %s", i+1, len(actions), action)
+	action, err := p.composeAction(actions)
+	if err != nil {
+		return "", err
+	}
 
-		// Check if action is a markdown file
-		if strings.HasSuffix(strings.ToLower(action), ".md") {
-			content, err := fileutil.SafeReadFile(action)
-			if err != nil {
-				return "", fmt.Errorf("failed to read markdown file %s: %w", action, err)
-			}
-			action = string(content)
-			p.debugf("Loaded action content from markdown file: %s", action)
+	inputs := p.handler.GetInputs()
+	inputTokens := estimateInputTokens(action, inputs)
+	if err := p.checkContext(modelName, inputTokens+estimateTokens(p.systemPrompt(configuredProvider, modelName, stepConfig)), stepConfig); err != nil {
+		return "", err
+	}
+	if err := p.checkBudget(configuredProvider, modelName, inputTokens, stepConfig); err != nil {
+		return "", err
+	}
+	breaker := p.circuitBreaker(configuredProvider.Name())
+	if err := breaker.Allow(); err != nil {
+		return "", &providerError{err: fmt.Errorf("provider %s: %w", configuredProvider.Name(), err)}
+	}
+	response, err := p.sendActions(configuredProvider, modelName, action, inputs, stepConfig)
+	recordBreaker(breaker, err)
+	if err == nil {
+		p.recordCost(configuredProvider, modelName, inputTokens, response)
+	}
+	return response, err
+}
+
+// sendActions sends the composed action and the step's inputs to a model
+func (p *Processor) sendActions(configuredProvider models.Provider, modelName, action string, inputs []*input.Input, stepConfig StepConfig) (string, error) {
+	if len(inputs) == 0 {
+		// If there are no inputs, just send the action directly
+		return p.sendPrompt(configuredProvider, modelName, action)
+	}
+
+	redactor, err := p.stepRedactor(stepConfig)
+	if err != nil {
+		return "", err
+	}
+	redactions := 0
+	scrub := func(text string) string {
+		if redactor == nil {
+			return text
 		}
+		text, count := redactor.redact(text)
+		redactions += count
+		return text
+	}
 
-		inputs := p.handler.GetInputs()
-		if len(inputs) == 0 {
-			// If there are no inputs, just send the action directly
-			return configuredProvider.SendPrompt(modelName, action)
+	// Process inputs based on their type
+	var fileInputs []models.FileInput
+	var nonFileInputs []string
+	cacheable := stepConfig.CacheContext
+	hasExternal := false
+
+	for _, inputItem := range inputs {
+		isBinary := inputItem.Type == input.ImageInput || inputItem.Type == input.ScreenshotInput ||
+			strings.HasPrefix(inputItem.MimeType, "image/") || inputItem.MimeType == "application/pdf"
+		if redactor != nil && isBinary {
+			return "", fmt.Errorf("cannot redact image or document input %s", inputItem.Path)
+		}
+		if stepConfig.MaxInputTokens > 0 && isBinary {
+			return "", fmt.Errorf("cannot truncate image or document input %s", inputItem.Path)
 		}
 
-		// Process inputs based on their type
-		var fileInputs []models.FileInput
-		var nonFileInputs []string
+		// Content fetched from a URL is sent inline, delimited so the model treats it as data
+		if source, ok := p.externalInput[inputItem.Path]; ok && inputItem.Type == input.FileInput && !isBinary {
+			nonFileInputs = append(nonFileInputs, p.guardExternalContent(source, scrub(string(inputItem.Contents)), stepConfig))
+			hasExternal = true
+			continue
+		}
 
-		for _, inputItem := range inputs {
-			switch inputItem.Type {
-			case input.FileInput:
-				fileInputs = append(fileInputs, models.FileInput{
-					Path:     inputItem.Path,
-					MimeType: inputItem.MimeType,
-				})
-			case input.WebScrapeInput:
-				// Handle scraping input
-				scraper := scraper.NewScraper()
-				if config, ok := inputItem.Metadata["scrape_config"].(map[string]interface{}); ok {
-					if domains, ok := config["allowed_domains"].([]interface{}); ok {
-						allowedDomains := make([]string, len(domains))
-						for i, d := range domains {
-							allowedDomains[i] = d.(string)
-						}
-						scraper.AllowedDomains(allowedDomains...)
-					}
-					if headers, ok := config["headers"].(map[string]interface{}); ok {
-						headerMap := make(map[string]string)
-						for k, v := range headers {
-							headerMap[k] = v.(string)
-						}
-						scraper.SetCustomHeaders(headerMap)
+		switch inputItem.Type {
+		case input.FileInput:
+			// Redacted and size-limited files are read here and sent inline so the provider
+			// only sees the processed content
+			if redactor != nil || stepConfig.MaxInputTokens > 0 {
+				content, err := fileutil.SafeReadFile(inputItem.Path)
+				if err != nil {
+					return "", fmt.Errorf("failed to read file %s: %w", inputItem.Path, err)
+				}
+				nonFileInputs = append(nonFileInputs, fmt.Sprintf("File (%s):\n%s", inputItem.Path, scrub(string(content))))
+				continue
+			}
+			fileInputs = append(fileInputs, models.FileInput{
+				Path:     inputItem.Path,
+				MimeType: inputItem.MimeType,
+			})
+			// Only plain text can be sent as a cached context block
+			if strings.HasPrefix(inputItem.MimeType, "image/") || inputItem.MimeType == "application/pdf" {
+				cacheable = false
+			}
+		case input.ImageInput, input.ScreenshotInput:
+			cacheable = false
+			nonFileInputs = append(nonFileInputs, string(inputItem.Contents))
+		case input.WebScrapeInput:
+			// Handle scraping input
+			scraper := scraper.NewScraper()
+			if config, ok := inputItem.Metadata["scrape_config"].(map[string]interface{}); ok {
+				if domains, ok := config["allowed_domains"].([]interface{}); ok {
+					allowedDomains := make([]string, len(domains))
+					for i, d := range domains {
+						allowedDomains[i] = d.(string)
 					}
+					scraper.AllowedDomains(allowedDomains...)
 				}
-				scrapedData, err := scraper.Scrape(inputItem.Path)
-				if err != nil {
-					return "", fmt.Errorf("failed to scrape URL %s: %w", inputItem.Path, err)
+				if headers, ok := config["headers"].(map[string]interface{}); ok {
+					headerMap := make(map[string]string)
+					for k, v := range headers {
+						headerMap[k] = v.(string)
+					}
+					scraper.SetCustomHeaders(headerMap)
 				}
-
-				// Convert scraped data to string
-				scrapedContent := fmt.Sprintf("Title: %s\n\nText Content:\n%s\n\nLinks:\n%s",
-					scrapedData.Title,
-					strings.Join(scrapedData.Text, "\n"),
-					strings.Join(scrapedData.Links, "\n"))
-				nonFileInputs = append(nonFileInputs, scrapedContent)
-			default:
-				nonFileInputs = append(nonFileInputs, string(inputItem.Contents))
 			}
+			scrapedData, err := scraper.Scrape(inputItem.Path)
+			if err != nil {
+				return "", fmt.Errorf("failed to scrape URL %s: %w", inputItem.Path, err)
+			}
+
+			// Convert scraped data to string
+			scrapedContent := fmt.Sprintf("Title: %s\n\nText Content:\n%s\n\nLinks:\n%s",
+				scrapedData.Title,
+				strings.Join(scrapedData.Text, "\n"),
+				strings.Join(scrapedData.Links, "\n"))
+			nonFileInputs = append(nonFileInputs, p.guardExternalContent(inputItem.Path, scrub(scrapedContent), stepConfig))
+			hasExternal = true
+		default:
+			nonFileInputs = append(nonFileInputs, scrub(string(inputItem.Contents)))
 		}
+	}
 
-		// If we have file inputs, use SendPromptWithFile
-		if len(fileInputs) > 0 {
-			if len(fileInputs) == 1 {
-				return configuredProvider.SendPromptWithFile(modelName, action, fileInputs[0])
-			}
-			// For multiple files, combine them into a single prompt
-			var combinedPrompt string
+	if redactor != nil {
+		p.debugf("Redacted %d value(s) from input", redactions)
+	}
+	if hasExternal {
+		action = externalContentNotice + "\n\n" + action
+	}
+
+	if maxInputTokens := p.inputTokenLimit(modelName, stepConfig); maxInputTokens > 0 && len(nonFileInputs) > 0 {
+		combinedInput := strings.Join(nonFileInputs, "\n\n")
+		truncated, ok := truncateInput(combinedInput, maxInputTokens, truncationStrategy(stepConfig))
+		if ok {
+			p.debugf("Input of about %d tokens truncated to %d tokens (%s)", estimateTokens(combinedInput), maxInputTokens, truncationStrategy(stepConfig))
+		}
+		nonFileInputs = []string{truncated}
+	}
+
+	// Send the input as a cached context when the step asks for it and the provider supports it
+	if cacheable {
+		if cachingProvider, ok := configuredProvider.(models.CachingProvider); ok {
+			var cachedContext string
 			for i, file := range fileInputs {
 				content, err := fileutil.SafeReadFile(file.Path)
 				if err != nil {
 					return "", fmt.Errorf("failed to read file %s: %w", file.Path, err)
 				}
-				combinedPrompt += fmt.Sprintf("File %d (%s):\n%s\n\n", i+1, file.Path, string(content))
+				cachedContext += fmt.Sprintf("File %d (%s):\n%s\n\n", i+1, file.Path, string(content))
+			}
+			if len(nonFileInputs) > 0 {
+				cachedContext += fmt.Sprintf("Input:\n%s\n\n", strings.Join(nonFileInputs, "\n\n"))
 			}
-			combinedPrompt += fmt.Sprintf("\nAction: %s", action)
-			return configuredProvider.SendPrompt(modelName, combinedPrompt)
+			p.debugf("Sending %d characters of input as cached context", len(cachedContext))
+			return providerCall(cachingProvider.SendPromptWithCache(modelName, cachedContext, fmt.Sprintf("Action: %s", action)))
 		}
+		p.debugf("Provider %s does not support prompt caching, sending input inline", configuredProvider.Name())
+	} else if stepConfig.CacheContext {
+		p.debugf("Input contains images or documents, sending input inline without caching")
+	}
 
-		// If we have non-file inputs, combine them and use SendPrompt
-		if len(nonFileInputs) > 0 {
-			combinedInput := strings.Join(nonFileInputs, "\n\n")
-			return configuredProvider.SendPrompt(modelName, fmt.Sprintf("Input:\n%s\n\nAction: %s", combinedInput, action))
+	// If we have file inputs, use SendPromptWithFile
+	if len(fileInputs) > 0 {
+		if len(fileInputs) == 1 {
+			return providerCall(configuredProvider.SendPromptWithFile(modelName, action, fileInputs[0]))
+		}
+		// For multiple files, combine them into a single prompt
+		var combinedPrompt string
+		for i, file := range fileInputs {
+			content, err := fileutil.SafeReadFile(file.Path)
+			if err != nil {
+				return "", fmt.Errorf("failed to read file %s: %w", file.Path, err)
+			}
+			combinedPrompt += fmt.Sprintf("File %d (%s):\n%s\n\n", i+1, file.Path, string(content))
 		}
+		combinedPrompt += fmt.Sprintf("\nAction: %s", action)
+		return p.sendPrompt(configuredProvider, modelName, combinedPrompt)
+	}
+
+	// If we have non-file inputs, combine them and use SendPrompt
+	if len(nonFileInputs) > 0 {
+		combinedInput := strings.Join(nonFileInputs, "\n\n")
+		return p.sendPrompt(configuredProvider, modelName, fmt.Sprintf("Input:\n%s\n\nAction: %s", combinedInput, action))
 	}
 
 	return "", fmt.Errorf("no actions processed")
 }
+
+// composeAction resolves each action to its prompt text and joins them into a single instruction.
+// Actions ending in .md or starting with file: must be existing prompt files, and actions ending
+// in .txt or .prompt are loaded from disk when the file exists. Any other action is used as an
+// inline instruction, whatever files are in the working directory.
+func (p *Processor) composeAction(actions []string) (string, error) {
+	var parts []string
+	for i, action := range actions {
+		p.debugf("Processing action %d/%d: %s", i+1, len(actions), action)
+
+		if p.isPromptURL(action) {
+			content, err := p.fetchPrompt(action)
+			if err != nil {
+				return "", err
+			}
+			action = content
+		} else if path, ok := promptFilePath(action); ok {
+			if err := p.checkSandbox(path); err != nil {
+				return "", err
+			}
+			content, err := fileutil.SafeReadFile(path)
+			if err != nil {
+				return "", fmt.Errorf("failed to read prompt file %s: %w", path, err)
+			}
+			action = string(content)
+			p.debugf("Loaded action content from prompt file: %s", action)
+		}
+
+		parts = append(parts, action)
+	}
+
+	if len(parts) == 0 {
+		return "", fmt.Errorf("no actions processed")
+	}
+	return strings.Join(parts, "\n\n"), nil
+}
+
+// isPromptURL reports whether an action is an http or https URL to fetch the prompt from
+func (p *Processor) isPromptURL(action string) bool {
+	lower := strings.ToLower(action)
+	return !strings.ContainsAny(action, " \t\n\r") && (strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")) && p.isURL(action)
+}
+
+// fetchPrompt returns the content of a prompt URL, fetching it only once per run. The response
+// must be text other than HTML, and at most maxPromptBytes long.
+func (p *Processor) fetchPrompt(promptURL string) (string, error) {
+	p.mu.Lock()
+	content, ok := p.prompts[promptURL]
+	p.mu.Unlock()
+	if ok {
+		p.debugf("Using cached prompt from %s", promptURL)
+		return content, nil
+	}
+
+	p.debugf("Fetching prompt from URL: %s", promptURL)
+	resp, err := p.getURL(promptURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to load prompt: %w", err)
+	}
+	defer resp.Body.Close()
+
+	contentType := resp.Header.Get("Content-Type")
+	if mediaType, _, err := mime.ParseMediaType(contentType); contentType != "" && (err != nil || !strings.HasPrefix(mediaType, "text/") || mediaType == "text/html") {
+		return "", fmt.Errorf("prompt URL %s returned %s, want plain text or markdown", promptURL, contentType)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxPromptBytes+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to read prompt from %s: %w", promptURL, err)
+	}
+	if len(body) > maxPromptBytes {
+		return "", fmt.Errorf("prompt at %s is larger than %d bytes", promptURL, maxPromptBytes)
+	}
+
+	p.mu.Lock()
+	p.prompts[promptURL] = string(body)
+	p.mu.Unlock()
+	return string(body), nil
+}
+
+// promptFilePrefix marks an action as the path of a prompt file, whatever its extension
+const promptFilePrefix = "file:"
+
+// promptFilePath returns the prompt file an action names, if any: the path after a file: prefix,
+// an action ending in .md, or an action ending in .txt or .prompt that names an existing file
+func promptFilePath(action string) (string, bool) {
+	if path, ok := strings.CutPrefix(action, promptFilePrefix); ok {
+		return strings.TrimSpace(path), true
+	}
+	if strings.ContainsAny(action, "\n\r") {
+		return "", false
+	}
+	switch strings.ToLower(filepath.Ext(action)) {
+	case ".md":
+		return action, true
+	case ".txt", ".prompt":
+		info, err := os.Stat(action)
+		return action, err == nil && info.Mode().IsRegular()
+	}
+	return "", false
+}
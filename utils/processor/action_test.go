@@ -0,0 +1,434 @@
+package processor
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kris-hansen/comanda/utils/config"
+	"github.com/kris-hansen/comanda/utils/models"
+)
+
+// nonCachingProvider hides the optional interfaces of the wrapped provider
+type nonCachingProvider struct {
+	models.Provider
+}
+
+func TestProcessActionsCacheContext(t *testing.T) {
+	tmpDir := t.TempDir()
+	contextFile := filepath.Join(tmpDir, "index.txt")
+	if err := os.WriteFile(contextFile, []byte("large shared context"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		cacheContext bool
+		caching      bool
+		wantCached   bool
+	}{
+		{
+			name:         "cache requested and supported",
+			cacheContext: true,
+			caching:      true,
+			wantCached:   true,
+		},
+		{
+			name:         "cache requested but unsupported",
+			cacheContext: true,
+			caching:      false,
+			wantCached:   false,
+		},
+		{
+			name:         "cache not requested",
+			cacheContext: false,
+			caching:      true,
+			wantCached:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := NewProcessor(&DSLConfig{}, createTestEnvConfig(), false)
+			if err := processor.processInputs([]string{contextFile}); err != nil {
+				t.Fatalf("Failed to process input: %v", err)
+			}
+
+			mock := NewMockProvider("anthropic")
+			mock.Configure("test-key")
+			var provider models.Provider = mock
+			if !tt.caching {
+				provider = nonCachingProvider{mock}
+			}
+			processor.providers["anthropic"] = provider
+
+			response, err := processor.processActions(
+				[]string{"claude-3-5-haiku-latest"},
+				[]string{"summarize"},
+				StepConfig{CacheContext: tt.cacheContext},
+			)
+			if err != nil {
+				t.Fatalf("processActions() unexpected error: %v", err)
+			}
+
+			if cached := strings.Contains(response, "cached"); cached != tt.wantCached {
+				t.Errorf("processActions() response = %q, want cached = %v", response, tt.wantCached)
+			}
+		})
+	}
+}
+
+// failingProvider simulates a provider whose API calls fail
+type failingProvider struct {
+	models.Provider
+}
+
+func (f failingProvider) SendPrompt(model, prompt string) (string, error) {
+	return "", fmt.Errorf("service unavailable")
+}
+
+func (f failingProvider) SendPromptWithFile(model, prompt string, file models.FileInput) (string, error) {
+	return "", fmt.Errorf("service unavailable")
+}
+
+func TestProcessActionsFallback(t *testing.T) {
+	tests := []struct {
+		name      string
+		fallback  interface{}
+		wantModel string
+		wantErr   bool
+	}{
+		{
+			name:      "fallback used when primary fails",
+			fallback:  []interface{}{"claude-3-5-haiku-latest"},
+			wantModel: "claude-3-5-haiku-latest",
+		},
+		{
+			name:      "fallback given as a single string",
+			fallback:  "claude-3-5-haiku-latest",
+			wantModel: "claude-3-5-haiku-latest",
+		},
+		{
+			name:     "no fallback configured",
+			fallback: nil,
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := NewProcessor(&DSLConfig{}, createTestEnvConfig(), false)
+
+			openai := NewMockProvider("openai")
+			openai.Configure("test-key")
+			processor.providers["openai"] = failingProvider{openai}
+
+			anthropic := NewMockProvider("anthropic")
+			anthropic.Configure("test-key")
+			processor.providers["anthropic"] = anthropic
+
+			response, err := processor.processActions(
+				[]string{"gpt-4o"},
+				[]string{"summarize"},
+				StepConfig{Fallback: tt.fallback},
+			)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("processActions() expected error, got response %q", response)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("processActions() unexpected error: %v", err)
+			}
+			if processor.lastModel != tt.wantModel {
+				t.Errorf("processActions() output from %s, want %s", processor.lastModel, tt.wantModel)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerCountsProviderErrors(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputFile := filepath.Join(tmpDir, "notes.txt")
+	if err := os.WriteFile(inputFile, []byte("notes"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	processor := NewProcessor(&DSLConfig{}, createTestEnvConfig(), false)
+	if err := processor.processInputs([]string{inputFile}); err != nil {
+		t.Fatalf("Failed to process input: %v", err)
+	}
+	openai := NewMockProvider("openai")
+	openai.Configure("test-key")
+	processor.providers["openai"] = openai
+	breaker := models.NewCircuitBreaker(1, time.Minute, time.Minute)
+	processor.breakers["openai"] = breaker
+
+	// A step that fails before calling the provider leaves the circuit closed
+	if _, err := processor.processActions([]string{"gpt-4o"}, []string{"summarize"}, StepConfig{Redact: []interface{}{"unknown"}}); err == nil {
+		t.Fatal("processActions() expected error for unknown redaction pattern")
+	}
+	if err := breaker.Allow(); err != nil {
+		t.Errorf("Allow() after a local error = %v, want closed circuit", err)
+	}
+
+	processor.providers["openai"] = failingProvider{openai}
+	if _, err := processor.processActions([]string{"gpt-4o"}, []string{"summarize"}, StepConfig{}); err == nil {
+		t.Fatal("processActions() expected error from failing provider")
+	}
+	if err := breaker.Allow(); err == nil {
+		t.Error("Allow() after a provider error = nil, want open circuit")
+	}
+}
+
+func TestComposeAction(t *testing.T) {
+	tmpDir := t.TempDir()
+	systemFile := filepath.Join(tmpDir, "system.md")
+	if err := os.WriteFile(systemFile, []byte("You are a careful reviewer."), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	taskFile := filepath.Join(tmpDir, "task.txt")
+	if err := os.WriteFile(taskFile, []byte("Review the input."), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	styleFile := filepath.Join(tmpDir, "style.prompt")
+	if err := os.WriteFile(styleFile, []byte("Be concise."), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	// A file named like an inline action must not replace it
+	if err := os.WriteFile(filepath.Join(tmpDir, "summarize"), []byte("not a prompt"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working directory: %v", err)
+	}
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+	defer os.Chdir(wd)
+
+	tests := []struct {
+		name    string
+		actions []string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "single inline action",
+			actions: []string{"summarize"},
+			want:    "summarize",
+		},
+		{
+			name:    "list of prompt files",
+			actions: []string{systemFile, taskFile},
+			want:    "You are a careful reviewer.\n\nReview the input.",
+		},
+		{
+			name:    "mixed prompt files and inline strings",
+			actions: []string{systemFile, "Respond in bullet points"},
+			want:    "You are a careful reviewer.\n\nRespond in bullet points",
+		},
+		{
+			name:    "inline action named like a file",
+			actions: []string{"summarize"},
+			want:    "summarize",
+		},
+		{
+			name:    "prompt file with the file: prefix",
+			actions: []string{"file:summarize", styleFile},
+			want:    "not a prompt\n\nBe concise.",
+		},
+		{
+			name:    "inline action ending in .txt",
+			actions: []string{"Save the summary as notes.txt"},
+			want:    "Save the summary as notes.txt",
+		},
+		{
+			name:    "missing file: prompt file",
+			actions: []string{"file:" + filepath.Join(tmpDir, "missing")},
+			wantErr: true,
+		},
+		{
+			name:    "missing markdown prompt file",
+			actions: []string{filepath.Join(tmpDir, "missing.md")},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := NewProcessor(&DSLConfig{}, createTestEnvConfig(), false)
+			got, err := processor.composeAction(tt.actions)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("composeAction() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("composeAction() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestComposeActionURL(t *testing.T) {
+	fetches := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		switch r.URL.Path {
+		case "/summarize.md":
+			w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+			w.Write([]byte("Summarize the input in three bullet points."))
+		case "/page":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html></html>"))
+		case "/large.md":
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte(strings.Repeat("a", maxPromptBytes+1)))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		action  string
+		want    string
+		wantErr string
+	}{
+		{name: "markdown prompt", action: server.URL + "/summarize.md", want: "Summarize the input in three bullet points."},
+		{name: "html page", action: server.URL + "/page", wantErr: "want plain text or markdown"},
+		{name: "oversized prompt", action: server.URL + "/large.md", wantErr: "larger than"},
+		{name: "missing prompt", action: server.URL + "/missing.md", wantErr: "status code 404"},
+		{name: "inline action mentioning a URL", action: "Summarize " + server.URL + "/page", want: "Summarize " + server.URL + "/page"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := NewProcessor(&DSLConfig{}, createTestEnvConfig(), false)
+			got, err := processor.composeAction([]string{tt.action})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("composeAction() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("composeAction() unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("composeAction() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// A prompt is fetched once per run
+	processor := NewProcessor(&DSLConfig{}, createTestEnvConfig(), false)
+	fetches = 0
+	for i := 0; i < 2; i++ {
+		if _, err := processor.composeAction([]string{server.URL + "/summarize.md"}); err != nil {
+			t.Fatalf("composeAction() unexpected error: %v", err)
+		}
+	}
+	if fetches != 1 {
+		t.Errorf("prompt fetched %d times, want 1", fetches)
+	}
+}
+
+func TestApplySamplingOptions(t *testing.T) {
+	processor := NewProcessor(&DSLConfig{}, createTestEnvConfig(), false)
+	provider := models.NewOpenAIProvider()
+	defaultTemperature := provider.GetConfig().Temperature
+
+	seed := 42
+	processor.applySamplingOptions(provider, "gpt-4o", StepConfig{MaxTokens: 50000, Seed: &seed, Stop: []interface{}{"END", "###"}})
+	config := provider.GetConfig()
+	if config.MaxTokens != 16384 || config.MaxCompletionTokens != 16384 {
+		t.Errorf("MaxTokens = %d, MaxCompletionTokens = %d, want both lowered to 16384", config.MaxTokens, config.MaxCompletionTokens)
+	}
+	if config.Seed == nil || *config.Seed != 42 {
+		t.Errorf("Seed = %v, want 42", config.Seed)
+	}
+	if strings.Join(config.Stop, ",") != "END,###" {
+		t.Errorf("Stop = %v, want [END ###]", config.Stop)
+	}
+	if config.Temperature != defaultTemperature {
+		t.Errorf("Temperature = %v, want it unchanged at %v", config.Temperature, defaultTemperature)
+	}
+
+	// Options from an earlier step do not carry over to the next one
+	processor.applySamplingOptions(provider, "gpt-4o", StepConfig{})
+	config = provider.GetConfig()
+	if config.MaxTokens != models.DefaultMaxTokens || config.Seed != nil || len(config.Stop) != 0 {
+		t.Errorf("options carried over: MaxTokens = %d, Seed = %v, Stop = %v", config.MaxTokens, config.Seed, config.Stop)
+	}
+
+	// Providers without model parameters ignore the options
+	processor.applySamplingOptions(nonCachingProvider{provider}, "gpt-4o", StepConfig{Seed: &seed})
+}
+
+func TestApplySystemPrompt(t *testing.T) {
+	envConfig := createTestEnvConfig()
+	envConfig.Providers["echo"] = &config.Provider{
+		Models: []config.Model{{Name: "echo-terse", Type: "local", System: "You are a terse technical assistant"}},
+	}
+	processor := NewProcessor(&DSLConfig{}, envConfig, false)
+	provider := models.NewEchoProvider()
+
+	// The model's configured system prompt is the default
+	processor.applySystemPrompt(provider, "echo-terse", StepConfig{})
+	if got, _ := provider.SendPrompt("echo-terse", "hi"); got != "System: You are a terse technical assistant\n\nhi" {
+		t.Errorf("SendPrompt() = %q, want the model's system prompt", got)
+	}
+
+	// A step's system field overrides it
+	processor.applySystemPrompt(provider, "echo-terse", StepConfig{System: "Answer in French"})
+	if got, _ := provider.SendPrompt("echo-terse", "hi"); got != "System: Answer in French\n\nhi" {
+		t.Errorf("SendPrompt() = %q, want the step's system prompt", got)
+	}
+
+	// A prompt from an earlier step does not carry over to a model without one
+	processor.applySystemPrompt(provider, "echo", StepConfig{})
+	if got, _ := provider.SendPrompt("echo", "hi"); got != "hi" {
+		t.Errorf("SendPrompt() = %q, want no system prompt", got)
+	}
+}
+
+func TestValidateThinking(t *testing.T) {
+	tests := []struct {
+		name     string
+		thinking *ThinkingConfig
+		wantErr  bool
+	}{
+		{"no thinking", nil, false},
+		{"minimum budget", &ThinkingConfig{BudgetTokens: 1024}, false},
+		{"budget too small", &ThinkingConfig{BudgetTokens: 500}, true},
+		{"missing budget", &ThinkingConfig{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateThinking(StepConfig{Thinking: tt.thinking})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateThinking() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateLiveSearch(t *testing.T) {
+	for _, mode := range []string{"", "off", "auto", "on"} {
+		if err := validateLiveSearch(StepConfig{LiveSearch: mode}); err != nil {
+			t.Errorf("validateLiveSearch(%q) unexpected error: %v", mode, err)
+		}
+	}
+	if err := validateLiveSearch(StepConfig{LiveSearch: "always"}); err == nil {
+		t.Error("validateLiveSearch(always) expected an error")
+	}
+}
@@ -0,0 +1,212 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/kris-hansen/comanda/utils/models"
+)
+
+// Ways of combining the answers of a step that runs several models
+const (
+	aggregateVote   = "vote"   // The most common answer, ties going to the earlier model
+	aggregateConcat = "concat" // Every answer under a heading naming its model
+	aggregateFirst  = "first"  // The answer of the first listed model that succeeds
+	aggregateJudge  = "judge"  // The answer a judge model picks as the best
+)
+
+// judgeChoicePattern finds the "ANSWER: <n>" line the judge model is asked to end its reply with
+var judgeChoicePattern = regexp.MustCompile(`(?m)^\s*ANSWER:\s*(\d+)\s*$`)
+
+// modelAnswer is one model's response in an aggregated step
+type modelAnswer struct {
+	model    string
+	response string
+}
+
+// validateAggregate checks the step's aggregate and judge settings
+func (p *Processor) validateAggregate(stepConfig StepConfig) error {
+	if stepConfig.Aggregate == "" {
+		if stepConfig.Judge != "" {
+			return fmt.Errorf("judge can only be used with aggregate: judge")
+		}
+		return nil
+	}
+
+	switch stepConfig.Aggregate {
+	case aggregateVote, aggregateConcat, aggregateFirst, aggregateJudge:
+	default:
+		return fmt.Errorf("aggregate must be vote, concat, first, or judge, got %s", stepConfig.Aggregate)
+	}
+	modelNames := p.NormalizeStringSlice(stepConfig.Model)
+	if len(modelNames) < 2 {
+		return fmt.Errorf("aggregate requires at least two models")
+	}
+	for _, modelName := range modelNames {
+		if modelName == "NA" || modelName == autoModel {
+			return fmt.Errorf("aggregate cannot be used with model %s", modelName)
+		}
+	}
+	if len(p.NormalizeStringSlice(stepConfig.Fallback)) > 0 {
+		return fmt.Errorf("fallback cannot be used with aggregate")
+	}
+	if (stepConfig.Aggregate == aggregateJudge) != (stepConfig.Judge != "") {
+		return fmt.Errorf("aggregate: judge requires a judge model, and judge requires aggregate: judge")
+	}
+	if stepConfig.Aggregate == aggregateConcat && (stepConfig.OutputFormat == formatYAML || stepConfig.OutputFormat == formatJSON) {
+		return fmt.Errorf("aggregate: concat cannot be used with output_format %s", stepConfig.OutputFormat)
+	}
+	return nil
+}
+
+// aggregateActions runs the step's actions against each model and combines the answers as the
+// step's aggregate setting asks. Models that fail are left out unless every model fails.
+func (p *Processor) aggregateActions(modelNames []string, actions []string, stepConfig StepConfig) (string, error) {
+	// Answers are kept in model order, whichever call finishes first
+	responses := make([]string, len(modelNames))
+	errs := make([]error, len(modelNames))
+	var wg sync.WaitGroup
+	for _, group := range providerGroups(modelNames) {
+		wg.Add(1)
+		go func(group []int) {
+			defer wg.Done()
+			for _, i := range group {
+				responses[i], errs[i] = p.runFormattedActions(modelNames[i], actions, stepConfig)
+			}
+		}(group)
+	}
+	wg.Wait()
+
+	var answers []modelAnswer
+	var lastErr error
+	for i, modelName := range modelNames {
+		if errors.Is(errs[i], ErrBudgetExceeded) {
+			return "", errs[i]
+		}
+		if errs[i] != nil {
+			p.warnf("Model %s failed and is left out of the %s aggregate: %v", modelName, stepConfig.Aggregate, errs[i])
+			lastErr = errs[i]
+			continue
+		}
+		answers = append(answers, modelAnswer{model: modelName, response: responses[i]})
+	}
+	if len(answers) == 0 {
+		return "", fmt.Errorf("all %d models failed, last error from %s: %w", len(modelNames), modelNames[len(modelNames)-1], lastErr)
+	}
+
+	var chosen modelAnswer
+	switch stepConfig.Aggregate {
+	case aggregateConcat:
+		var sections []string
+		for _, answer := range answers {
+			sections = append(sections, fmt.Sprintf("## %s\n\n%s", answer.model, strings.TrimSpace(answer.response)))
+		}
+		p.lastModel = answers[0].model
+		return strings.Join(sections, "\n\n"), nil
+	case aggregateVote:
+		chosen = majorityAnswer(answers)
+	case aggregateJudge:
+		var err error
+		if chosen, err = p.judgeAnswers(actions, answers, stepConfig.Judge, stepConfig.MaxCost); err != nil {
+			return "", err
+		}
+	default:
+		chosen = answers[0]
+	}
+
+	p.debugf("Aggregate %s chose the answer from model %s", stepConfig.Aggregate, chosen.model)
+	p.lastModel = chosen.model
+	return chosen.response, nil
+}
+
+// providerGroups groups the indexes of the models by provider, in model order. Each group is run
+// in turn while the groups run at the same time: models of one provider share its settings, which
+// are applied on every call.
+func providerGroups(modelNames []string) [][]int {
+	var groups [][]int
+	groupOf := make(map[string]int)
+	for i, modelName := range modelNames {
+		key := modelName
+		if provider := models.DetectProvider(modelName); provider != nil {
+			key = provider.Name()
+		}
+		group, ok := groupOf[key]
+		if !ok {
+			group = len(groups)
+			groupOf[key] = group
+			groups = append(groups, nil)
+		}
+		groups[group] = append(groups[group], i)
+	}
+	return groups
+}
+
+// majorityAnswer returns the most common answer, comparing answers without regard to case,
+// whitespace, or a trailing period. Ties go to the answer given first.
+func majorityAnswer(answers []modelAnswer) modelAnswer {
+	normalize := func(text string) string {
+		return strings.TrimSuffix(strings.ToLower(strings.Join(strings.Fields(text), " ")), ".")
+	}
+	counts := make(map[string]int)
+	for _, answer := range answers {
+		counts[normalize(answer.response)]++
+	}
+	best := answers[0]
+	for _, answer := range answers[1:] {
+		if counts[normalize(answer.response)] > counts[normalize(best.response)] {
+			best = answer
+		}
+	}
+	return best
+}
+
+// judgeAnswers asks the judge model which answer is best. The judge sees the task and the
+// numbered answers, but not the step's inputs. The judge call counts toward the step's maxCost.
+func (p *Processor) judgeAnswers(actions []string, answers []modelAnswer, judge string, maxCost float64) (modelAnswer, error) {
+	if len(answers) == 1 {
+		return answers[0], nil
+	}
+
+	task, err := p.composeAction(actions)
+	if err != nil {
+		return modelAnswer{}, err
+	}
+	var prompt strings.Builder
+	prompt.WriteString("Several assistants answered the same task. Pick the best answer. End your reply with a line of the form ANSWER: <n>, where <n> is the number of the best answer.\n\n")
+	prompt.WriteString(fmt.Sprintf("Task:\n%s\n", task))
+	for i, answer := range answers {
+		prompt.WriteString(fmt.Sprintf("\nAnswer %d:\n%s\n", i+1, answer.response))
+	}
+
+	provider, err := p.stepProvider(judge, StepConfig{})
+	if err != nil {
+		return modelAnswer{}, fmt.Errorf("judge model %s: %w", judge, err)
+	}
+	if err := p.checkBudget(provider, judge, estimateTokens(prompt.String()), StepConfig{MaxCost: maxCost}); err != nil {
+		return modelAnswer{}, err
+	}
+	breaker := p.circuitBreaker(provider.Name())
+	if err := breaker.Allow(); err != nil {
+		return modelAnswer{}, &providerError{err: fmt.Errorf("judge model %s: %w", judge, err)}
+	}
+	reply, err := providerCall(provider.SendPrompt(judge, prompt.String()))
+	recordBreaker(breaker, err)
+	if err != nil {
+		return modelAnswer{}, fmt.Errorf("judge model %s failed: %w", judge, err)
+	}
+	p.recordCost(provider, judge, estimateTokens(prompt.String()), reply)
+
+	// Only the last ANSWER line counts, so numbers the judge mentions while reasoning are ignored
+	var choice int
+	if matches := judgeChoicePattern.FindAllStringSubmatch(reply, -1); len(matches) > 0 {
+		choice, _ = strconv.Atoi(matches[len(matches)-1][1])
+	}
+	if choice < 1 || choice > len(answers) {
+		return modelAnswer{}, fmt.Errorf("judge model %s did not pick an answer between 1 and %d: %q", judge, len(answers), strings.TrimSpace(reply))
+	}
+	return answers[choice-1], nil
+}
@@ -0,0 +1,222 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kris-hansen/comanda/utils/models"
+)
+
+// answerProvider answers with a fixed response per model and fails for models without one
+type answerProvider struct {
+	models.Provider
+	answers map[string]string
+}
+
+func (a answerProvider) SendPrompt(model, prompt string) (string, error) {
+	if answer, ok := a.answers[model]; ok {
+		return answer, nil
+	}
+	return "", fmt.Errorf("service unavailable")
+}
+
+func TestAggregateActions(t *testing.T) {
+	modelNames := []string{"gpt-4o", "gpt-4o-mini", "gpt-4"}
+	tests := []struct {
+		name       string
+		aggregate  string
+		answers    map[string]string
+		judgeReply string
+		want       string
+		wantModel  string
+		wantErr    bool
+	}{
+		{
+			name:      "vote",
+			aggregate: aggregateVote,
+			answers:   map[string]string{"gpt-4o": "Bug", "gpt-4o-mini": "feature", "gpt-4": "bug."},
+			want:      "Bug",
+			wantModel: "gpt-4o",
+		},
+		{
+			name:      "vote tie goes to the earlier model",
+			aggregate: aggregateVote,
+			answers:   map[string]string{"gpt-4o": "bug", "gpt-4o-mini": "feature"},
+			want:      "bug",
+			wantModel: "gpt-4o",
+		},
+		{
+			name:      "concat skips failed models",
+			aggregate: aggregateConcat,
+			answers:   map[string]string{"gpt-4o": "A\n", "gpt-4": "C"},
+			want:      "## gpt-4o\n\nA\n\n## gpt-4\n\nC",
+			wantModel: "gpt-4o",
+		},
+		{
+			name:      "first successful model",
+			aggregate: aggregateFirst,
+			answers:   map[string]string{"gpt-4o-mini": "B", "gpt-4": "C"},
+			want:      "B",
+			wantModel: "gpt-4o-mini",
+		},
+		{
+			name:       "judge",
+			aggregate:  aggregateJudge,
+			answers:    map[string]string{"gpt-4o": "A", "gpt-4o-mini": "B", "gpt-4": "C"},
+			judgeReply: "Answer 1 misses a case, answer 2 is the most complete.\nANSWER: 2",
+			want:       "B",
+			wantModel:  "gpt-4o-mini",
+		},
+		{
+			name:       "judge reply without an ANSWER line",
+			aggregate:  aggregateJudge,
+			answers:    map[string]string{"gpt-4o": "A", "gpt-4o-mini": "B"},
+			judgeReply: "Answer 2 is the most complete.",
+			wantErr:    true,
+		},
+		{
+			name:       "judge picks no valid answer",
+			aggregate:  aggregateJudge,
+			answers:    map[string]string{"gpt-4o": "A", "gpt-4o-mini": "B"},
+			judgeReply: "ANSWER: 5",
+			wantErr:    true,
+		},
+		{
+			name:      "every model fails",
+			aggregate: aggregateVote,
+			answers:   map[string]string{},
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := NewProcessor(&DSLConfig{}, createTestEnvConfig(), false)
+			processor.providers["openai"] = answerProvider{NewMockProvider("openai"), tt.answers}
+			judge := &scriptedProvider{MockProvider: NewMockProvider("anthropic"), responses: []string{tt.judgeReply}}
+			processor.providers["anthropic"] = judge
+
+			stepConfig := StepConfig{Aggregate: tt.aggregate}
+			if tt.aggregate == aggregateJudge {
+				stepConfig.Judge = "claude-3-5-sonnet-latest"
+			}
+			got, err := processor.processActions(modelNames, []string{"Classify this ticket"}, stepConfig)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("processActions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got != tt.want {
+				t.Errorf("processActions() = %q, want %q", got, tt.want)
+			}
+			if processor.lastModel != tt.wantModel {
+				t.Errorf("lastModel = %s, want %s", processor.lastModel, tt.wantModel)
+			}
+			if tt.aggregate != aggregateJudge && judge.calls != 0 {
+				t.Errorf("judge called %d times for aggregate %s", judge.calls, tt.aggregate)
+			}
+		})
+	}
+}
+
+func TestJudgeCircuitBreaker(t *testing.T) {
+	processor := NewProcessor(&DSLConfig{}, createTestEnvConfig(), false)
+	processor.providers["openai"] = answerProvider{NewMockProvider("openai"), map[string]string{"gpt-4o": "A", "gpt-4o-mini": "B"}}
+	judge := &scriptedProvider{MockProvider: NewMockProvider("anthropic"), responses: []string{"ANSWER: 1"}}
+	processor.providers["anthropic"] = judge
+	breaker := models.NewCircuitBreaker(1, time.Minute, time.Minute)
+	breaker.Record(fmt.Errorf("service unavailable"))
+	processor.breakers["anthropic"] = breaker
+
+	stepConfig := StepConfig{Aggregate: aggregateJudge, Judge: "claude-3-5-sonnet-latest"}
+	_, err := processor.processActions([]string{"gpt-4o", "gpt-4o-mini"}, []string{"Classify this ticket"}, stepConfig)
+	if !errors.Is(err, models.ErrCircuitOpen) {
+		t.Errorf("processActions() error = %v, want open circuit", err)
+	}
+	if judge.calls != 0 {
+		t.Errorf("judge called %d times with an open circuit", judge.calls)
+	}
+}
+
+// meetingProvider only answers once every expected call has arrived, so it fails unless the
+// models are called at the same time
+type meetingProvider struct {
+	models.Provider
+	arrived *sync.WaitGroup
+}
+
+func (m meetingProvider) SendPrompt(model, prompt string) (string, error) {
+	m.arrived.Done()
+	met := make(chan struct{})
+	go func() {
+		m.arrived.Wait()
+		close(met)
+	}()
+	select {
+	case <-met:
+		return "answer from " + model, nil
+	case <-time.After(2 * time.Second):
+		return "", fmt.Errorf("model %s was not called at the same time as the others", model)
+	}
+}
+
+func TestAggregateActionsConcurrent(t *testing.T) {
+	processor := NewProcessor(&DSLConfig{}, createTestEnvConfig(), false)
+	var arrived sync.WaitGroup
+	arrived.Add(2)
+	processor.providers["anthropic"] = meetingProvider{NewMockProvider("anthropic"), &arrived}
+	processor.providers["openai"] = meetingProvider{NewMockProvider("openai"), &arrived}
+
+	stepConfig := StepConfig{Aggregate: aggregateConcat}
+	got, err := processor.processActions([]string{"claude-3-5-sonnet-latest", "gpt-4o"}, []string{"Classify this ticket"}, stepConfig)
+	if err != nil {
+		t.Fatalf("processActions() unexpected error: %v", err)
+	}
+	want := "## claude-3-5-sonnet-latest\n\nanswer from claude-3-5-sonnet-latest\n\n## gpt-4o\n\nanswer from gpt-4o"
+	if got != want {
+		t.Errorf("processActions() = %q, want %q", got, want)
+	}
+}
+
+func TestProviderGroups(t *testing.T) {
+	got := providerGroups([]string{"gpt-4o", "claude-3-5-sonnet-latest", "gpt-4o-mini"})
+	want := [][]int{{0, 2}, {1}}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("providerGroups() = %v, want %v", got, want)
+	}
+}
+
+func TestValidateAggregate(t *testing.T) {
+	models := []interface{}{"gpt-4o", "claude-3-5-sonnet-latest"}
+	tests := []struct {
+		config  StepConfig
+		wantErr string
+	}{
+		{StepConfig{Model: models, Aggregate: "vote"}, ""},
+		{StepConfig{Model: models, Aggregate: "judge", Judge: "gpt-4o"}, ""},
+		{StepConfig{Model: "gpt-4o"}, ""},
+		{StepConfig{Model: models, Aggregate: "average"}, "aggregate must be"},
+		{StepConfig{Model: "gpt-4o", Aggregate: "vote"}, "at least two models"},
+		{StepConfig{Model: []interface{}{"gpt-4o", "NA"}, Aggregate: "vote"}, "cannot be used with model NA"},
+		{StepConfig{Model: models, Aggregate: "first", Fallback: "gpt-4"}, "fallback cannot"},
+		{StepConfig{Model: models, Aggregate: "judge"}, "requires a judge model"},
+		{StepConfig{Model: models, Judge: "gpt-4o"}, "judge can only be used"},
+		{StepConfig{Model: models, Aggregate: "concat", OutputFormat: "json"}, "cannot be used with output_format json"},
+	}
+
+	processor := NewProcessor(&DSLConfig{}, createTestEnvConfig(), false)
+	for _, tt := range tests {
+		err := processor.validateAggregate(tt.config)
+		if tt.wantErr == "" && err != nil {
+			t.Errorf("validateAggregate(%+v) unexpected error: %v", tt.config, err)
+		}
+		if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("validateAggregate(%+v) = %v, want error containing %q", tt.config, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package processor
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kris-hansen/comanda/utils/fileutil"
+)
+
+// base64Suffix marks an input to be read, or an output to be written, as base64 text
+const base64Suffix = " as base64"
+
+// cutBase64 returns target without a trailing " as base64" and whether it was there
+func cutBase64(target string) (string, bool) {
+	return strings.CutSuffix(target, base64Suffix)
+}
+
+// encodeInput reads a binary file and writes its base64 encoding to a temporary text file,
+// returning the temporary file's path
+func (p *Processor) encodeInput(path string) (string, error) {
+	path = p.outputInputPath(path)
+	if err := p.checkSandbox(path); err != nil {
+		return "", err
+	}
+	content, err := fileutil.SafeReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	tmpFile, err := os.CreateTemp("", "comanda-base64-*.txt")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file for %s: %w", path, err)
+	}
+	defer tmpFile.Close()
+
+	if _, err := tmpFile.WriteString(base64.StdEncoding.EncodeToString(content)); err != nil {
+		os.Remove(tmpFile.Name())
+		return "", fmt.Errorf("failed to write base64 of %s to temp file: %w", path, err)
+	}
+
+	p.trustPath(tmpFile.Name())
+	p.debugf("Encoded %s (%d bytes) as base64", path, len(content))
+	return tmpFile.Name(), nil
+}
+
+// decodeOutput decodes a base64 response. Whitespace and a surrounding code fence, which models
+// often add, are ignored.
+func decodeOutput(response string) ([]byte, error) {
+	text := strings.TrimSpace(response)
+	if strings.HasPrefix(text, "```") {
+		text = strings.TrimPrefix(text, "```")
+		if newline := strings.IndexByte(text, '\n'); newline >= 0 {
+			text = text[newline+1:]
+		}
+		text = strings.TrimSuffix(strings.TrimSpace(text), "```")
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(text), ""))
+	if err != nil {
+		return nil, fmt.Errorf("response is not valid base64: %w", err)
+	}
+	return data, nil
+}
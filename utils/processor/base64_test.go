@@ -0,0 +1,93 @@
+package processor
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestProcessBase64RoundTrip(t *testing.T) {
+	workDir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working directory: %v", err)
+	}
+	if err := os.Chdir(workDir); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+	defer os.Chdir(wd)
+
+	binary := []byte{0x89, 'P', 'N', 'G', 0x00, 0xff, 0x10, '\n'}
+	if err := os.WriteFile("image.bin", binary, 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	config := DSLConfig{
+		Steps: []Step{
+			{
+				Name: "encode",
+				Config: StepConfig{
+					Input:  []string{"image.bin as base64"},
+					Model:  []string{"NA"},
+					Action: []string{"pass"},
+					Output: []string{"image.txt"},
+				},
+			},
+			{
+				Name: "decode",
+				Config: StepConfig{
+					Input:  []string{"image.txt"},
+					Model:  []string{"NA"},
+					Action: []string{"pass"},
+					Output: []string{"copy.bin as base64"},
+				},
+			},
+		},
+	}
+
+	processor := NewProcessor(&config, createTestEnvConfig(), false)
+	if err := processor.Process(); err != nil {
+		t.Fatalf("Process() unexpected error: %v", err)
+	}
+
+	encoded, err := os.ReadFile("image.txt")
+	if err != nil {
+		t.Fatalf("Failed to read encoded output: %v", err)
+	}
+	if string(encoded) != "iVBORwD/EAo=" {
+		t.Errorf("encoded output = %q, want %q", string(encoded), "iVBORwD/EAo=")
+	}
+	decoded, err := os.ReadFile("copy.bin")
+	if err != nil {
+		t.Fatalf("Failed to read decoded output: %v", err)
+	}
+	if !bytes.Equal(decoded, binary) {
+		t.Errorf("decoded output = %v, want %v", decoded, binary)
+	}
+}
+
+func TestDecodeOutput(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     string
+		wantErr  bool
+	}{
+		{"plain", "aGVsbG8=", "hello", false},
+		{"wrapped lines", "aGVs\nbG8=\n", "hello", false},
+		{"code fence", "```base64\naGVsbG8=\n```", "hello", false},
+		{"not base64", "hello there", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeOutput(tt.response)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeOutput() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Errorf("decodeOutput() = %q, want %q", string(got), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kris-hansen/comanda/utils/input"
+	"github.com/kris-hansen/comanda/utils/models"
+)
+
+// ErrBudgetExceeded is returned when a model call would go over the run's or a step's cost limit.
+// Fallback models and on_error steps are not tried after it.
+var ErrBudgetExceeded = errors.New("cost limit exceeded")
+
+// localProviders run models on the local machine, so their calls cost nothing
+var localProviders = map[string]bool{"ollama": true, "echo": true}
+
+// SetMaxCost limits the estimated cost of the run in US dollars. Zero removes the limit.
+func (p *Processor) SetMaxCost(dollars float64) {
+	p.maxCost = dollars
+}
+
+// Cost returns the estimated cost in US dollars of the model calls made so far
+func (p *Processor) Cost() float64 {
+	return p.spent
+}
+
+// RemainingCost returns the part of a run's cost limit left after spending spent, to pass to the
+// processor of the next file with SetMaxCost. It fails with ErrBudgetExceeded once nothing is left,
+// since a limit of zero would mean no limit. A maxCost of zero means the run has no limit.
+func RemainingCost(maxCost, spent float64) (float64, error) {
+	if maxCost <= 0 {
+		return 0, nil
+	}
+	if remaining := maxCost - spent; remaining > 0 {
+		return remaining, nil
+	}
+	return 0, fmt.Errorf("%w: $%.4f of the run's $%.2f limit has been spent", ErrBudgetExceeded, spent, maxCost)
+}
+
+// validateMaxCost checks the step's cost limit
+func validateMaxCost(stepConfig StepConfig) error {
+	if stepConfig.MaxCost < 0 {
+		return fmt.Errorf("max_cost must be a positive number")
+	}
+	return nil
+}
+
+// pricing returns the token prices of a model: those in its configuration, or else its list price.
+// Models of local providers are free.
+func (p *Processor) pricing(provider models.Provider, modelName string) (models.Pricing, bool) {
+	if localProviders[provider.Name()] {
+		return models.Pricing{}, true
+	}
+	if p.envConfig != nil {
+		if modelConfig, err := p.envConfig.GetModelConfig(provider.Name(), modelName); err == nil && modelConfig.Pricing != nil {
+			return models.Pricing{Input: modelConfig.Pricing.Input, Output: modelConfig.Pricing.Output}, true
+		}
+	}
+	return models.LookupPricing(modelName)
+}
+
+// checkBudget estimates the cost of sending inputTokens to a model and fails with
+// ErrBudgetExceeded if it would go over the run's or the step's cost limit
+func (p *Processor) checkBudget(provider models.Provider, modelName string, inputTokens int, stepConfig StepConfig) error {
+	if p.maxCost <= 0 && stepConfig.MaxCost <= 0 {
+		return nil
+	}
+	pricing, ok := p.pricing(provider, modelName)
+	if !ok {
+		return fmt.Errorf("no pricing known for model %s; set pricing in its configuration to use a cost limit", modelName)
+	}
+
+	estimate := pricing.Cost(inputTokens, 0)
+	p.debugf("Estimated input cost for model %s: $%.4f for about %d tokens", modelName, estimate, inputTokens)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.maxCost > 0 && p.spent+estimate > p.maxCost {
+		return fmt.Errorf("%w: calling model %s would cost about $%.4f, but only $%.4f of the run's $%.2f limit is left",
+			ErrBudgetExceeded, modelName, estimate, p.maxCost-p.spent, p.maxCost)
+	}
+	if stepConfig.MaxCost > 0 && p.stepSpent+estimate > stepConfig.MaxCost {
+		return fmt.Errorf("%w: calling model %s would cost about $%.4f, but only $%.4f of the step's $%.2f limit is left",
+			ErrBudgetExceeded, modelName, estimate, stepConfig.MaxCost-p.stepSpent, stepConfig.MaxCost)
+	}
+	return nil
+}
+
+// recordCost adds the cost of a provider's last call to the run and step totals. The cost comes
+// from the provider's reported token usage, or is estimated from the input tokens and the
+// response when the provider reports none.
+func (p *Processor) recordCost(provider models.Provider, modelName string, inputTokens int, response string) {
+	pricing, ok := p.pricing(provider, modelName)
+	if !ok {
+		return
+	}
+	var usage models.TokenUsage
+	if reporter, ok := provider.(models.UsageReporter); ok {
+		usage = reporter.LastUsage()
+	}
+	if usage.InputTokens == 0 && usage.OutputTokens == 0 {
+		usage = models.TokenUsage{InputTokens: inputTokens, OutputTokens: estimateTokens(response)}
+		p.debugf("Model %s reported no token usage; estimating %d input and %d output tokens", modelName, usage.InputTokens, usage.OutputTokens)
+	}
+	cost := pricing.Cost(usage.InputTokens, usage.OutputTokens)
+	p.mu.Lock()
+	p.spent += cost
+	p.stepSpent += cost
+	total := p.spent
+	p.mu.Unlock()
+	p.debugf("Model %s call cost $%.4f; run total $%.4f", modelName, cost, total)
+}
+
+// estimateInputTokens roughly estimates the tokens of an action and the text inputs sent with it.
+// Images and documents are left out, as their token counts do not follow their size.
+func estimateInputTokens(action string, inputs []*input.Input) int {
+	tokens := estimateTokens(action)
+	for _, inputItem := range inputs {
+		if inputItem.Type == input.ImageInput || inputItem.Type == input.ScreenshotInput ||
+			strings.HasPrefix(inputItem.MimeType, "image/") || inputItem.MimeType == "application/pdf" {
+			continue
+		}
+		if len(inputItem.Contents) > 0 {
+			tokens += len(inputItem.Contents) / charsPerToken
+		} else if info, err := os.Stat(inputItem.Path); err == nil {
+			tokens += int(info.Size()) / charsPerToken
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,148 @@
+package processor
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/kris-hansen/comanda/utils/config"
+	"github.com/kris-hansen/comanda/utils/models"
+)
+
+// usageProvider answers every prompt and reports the same token usage for each call
+type usageProvider struct {
+	models.Provider
+	usage models.TokenUsage
+	calls map[string]int
+}
+
+func (u *usageProvider) SendPrompt(model, prompt string) (string, error) {
+	u.calls[model]++
+	return "ok", nil
+}
+
+func (u *usageProvider) LastUsage() models.TokenUsage {
+	return u.usage
+}
+
+func TestCostLimits(t *testing.T) {
+	// Each gpt-4o call costs $0.25 for 100k input tokens plus $0.10 for 10k output tokens
+	usage := models.TokenUsage{InputTokens: 100000, OutputTokens: 10000}
+
+	t.Run("run limit stops the next call", func(t *testing.T) {
+		processor := NewProcessor(&DSLConfig{}, createTestEnvConfig(), false)
+		provider := &usageProvider{Provider: NewMockProvider("openai"), usage: usage, calls: map[string]int{}}
+		processor.providers["openai"] = provider
+		processor.SetMaxCost(0.5)
+
+		for i := 0; i < 2; i++ {
+			if _, err := processor.processActions([]string{"gpt-4o"}, []string{"Summarize"}, StepConfig{}); err != nil {
+				t.Fatalf("call %d: processActions() unexpected error: %v", i+1, err)
+			}
+		}
+		if cost := processor.Cost(); cost < 0.699 || cost > 0.701 {
+			t.Errorf("Cost() = %.4f, want 0.70", cost)
+		}
+
+		// The limit is already spent, so neither the model nor its fallback is called
+		_, err := processor.processActions([]string{"gpt-4o"}, []string{"Summarize"}, StepConfig{Fallback: "gpt-4o-mini"})
+		if !errors.Is(err, ErrBudgetExceeded) {
+			t.Fatalf("processActions() error = %v, want ErrBudgetExceeded", err)
+		}
+		if provider.calls["gpt-4o"] != 2 || provider.calls["gpt-4o-mini"] != 0 {
+			t.Errorf("calls = %v, want two gpt-4o calls and no fallback", provider.calls)
+		}
+	})
+
+	t.Run("step limit checks the estimated input", func(t *testing.T) {
+		processor := NewProcessor(&DSLConfig{}, createTestEnvConfig(), false)
+		processor.providers["openai"] = &usageProvider{Provider: NewMockProvider("openai"), usage: usage, calls: map[string]int{}}
+
+		// About 100k input tokens cost $0.25 with gpt-4o, over the step's limit
+		action := strings.Repeat("word ", 80000)
+		_, err := processor.processActions([]string{"gpt-4o"}, []string{action}, StepConfig{MaxCost: 0.1})
+		if !errors.Is(err, ErrBudgetExceeded) || !strings.Contains(err.Error(), "step's $0.10 limit") {
+			t.Fatalf("processActions() error = %v, want the step's limit exceeded", err)
+		}
+		// gpt-4o-mini is cheap enough
+		if _, err := processor.processActions([]string{"gpt-4o-mini"}, []string{action}, StepConfig{MaxCost: 0.1}); err != nil {
+			t.Errorf("processActions() unexpected error: %v", err)
+		}
+	})
+
+	t.Run("estimates the cost when no usage is reported", func(t *testing.T) {
+		processor := NewProcessor(&DSLConfig{}, createTestEnvConfig(), false)
+		provider := &usageProvider{Provider: NewMockProvider("openai"), calls: map[string]int{}}
+		processor.providers["openai"] = provider
+		processor.SetMaxCost(0.3)
+
+		// About 100k input tokens cost $0.25 with gpt-4o
+		action := strings.Repeat("word ", 80000)
+		if _, err := processor.processActions([]string{"gpt-4o"}, []string{action}, StepConfig{}); err != nil {
+			t.Fatalf("processActions() unexpected error: %v", err)
+		}
+		if cost := processor.Cost(); cost < 0.24 || cost > 0.26 {
+			t.Errorf("Cost() = %.4f, want about 0.25", cost)
+		}
+		_, err := processor.processActions([]string{"gpt-4o"}, []string{action}, StepConfig{})
+		if !errors.Is(err, ErrBudgetExceeded) {
+			t.Fatalf("processActions() error = %v, want ErrBudgetExceeded", err)
+		}
+		if provider.calls["gpt-4o"] != 1 {
+			t.Errorf("calls = %v, want one gpt-4o call", provider.calls)
+		}
+	})
+
+	t.Run("configured pricing overrides list prices", func(t *testing.T) {
+		envConfig := createTestEnvConfig()
+		envConfig.Providers["openai"].Models = append(envConfig.Providers["openai"].Models,
+			config.Model{Name: "ft:gpt-4o-mini:acme", Type: "external", Pricing: &config.ModelPricing{Input: 1, Output: 4}})
+		processor := NewProcessor(&DSLConfig{}, envConfig, false)
+		provider := NewMockProvider("openai")
+
+		pricing, ok := processor.pricing(provider, "ft:gpt-4o-mini:acme")
+		if !ok || pricing.Input != 1 || pricing.Output != 4 {
+			t.Errorf("pricing() = %+v, %v, want the configured prices", pricing, ok)
+		}
+		processor.SetMaxCost(1)
+		if err := processor.checkBudget(provider, "unknown-model", 10, StepConfig{}); err == nil || !strings.Contains(err.Error(), "no pricing known") {
+			t.Errorf("checkBudget() error = %v, want missing pricing", err)
+		}
+		if err := processor.checkBudget(NewMockProvider("ollama"), "llama3.2", 1000000, StepConfig{}); err != nil {
+			t.Errorf("checkBudget() for a local model unexpected error: %v", err)
+		}
+	})
+}
+
+func TestRemainingCost(t *testing.T) {
+	tests := []struct {
+		name    string
+		maxCost float64
+		spent   float64
+		want    float64
+		wantErr bool
+	}{
+		{"no limit", 0, 3, 0, false},
+		{"part of the limit left", 1, 0.25, 0.75, false},
+		{"limit used up", 1, 1, 0, true},
+		{"output tokens went over the limit", 1, 1.2, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RemainingCost(tt.maxCost, tt.spent)
+			if tt.wantErr {
+				if !errors.Is(err, ErrBudgetExceeded) {
+					t.Errorf("RemainingCost() error = %v, want ErrBudgetExceeded", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RemainingCost() unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("RemainingCost() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
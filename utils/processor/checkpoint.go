@@ -0,0 +1,180 @@
+package processor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kris-hansen/comanda/utils/logging"
+)
+
+// checkpointStep records the state of a workflow after one of its steps completed
+type checkpointStep struct {
+	Name        string            `json:"name"`
+	Fingerprint string            `json:"fingerprint"` // Covers the step definition, its file inputs and earlier steps
+	Output      string            `json:"output"`
+	LastOutput  string            `json:"last_output"`
+	LastModel   string            `json:"last_model,omitempty"`
+	Variables   map[string]string `json:"variables,omitempty"`
+}
+
+// checkpoint is the persisted progress of a workflow run, used to resume it from a later step
+type checkpoint struct {
+	Steps []checkpointStep `json:"steps"`
+}
+
+// CheckpointPath returns the checkpoint file used for a workflow file
+func CheckpointPath(workflowFile string) string {
+	ext := filepath.Ext(workflowFile)
+	return strings.TrimSuffix(workflowFile, ext) + ".checkpoint.json"
+}
+
+// SetCheckpoint enables checkpointing to path. If continueFrom names a step, the steps before it
+// are restored from the checkpoint instead of being run again.
+func (p *Processor) SetCheckpoint(path, continueFrom string) {
+	p.checkpointPath = path
+	p.continueFrom = continueFrom
+}
+
+// loadCheckpoint reads the checkpoint file, returning an empty checkpoint if it does not exist
+func loadCheckpoint(path string) (*checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &checkpoint{}, nil
+		}
+		return nil, fmt.Errorf("error reading checkpoint: %w", err)
+	}
+
+	var cp checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("error parsing checkpoint %s: %w", path, err)
+	}
+	return &cp, nil
+}
+
+// save writes the checkpoint atomically
+func (cp *checkpoint) save(path string) error {
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding checkpoint: %w", err)
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("error writing checkpoint: %w", err)
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// stepFingerprint hashes a step's definition and the contents of its file inputs, chained to the
+// fingerprint of the previous step so that a change to an earlier step invalidates later ones
+func (p *Processor) stepFingerprint(step Step, previous string) (string, error) {
+	definition, err := json.Marshal(step.Config)
+	if err != nil {
+		return "", fmt.Errorf("error encoding step %s: %w", step.Name, err)
+	}
+
+	h := sha256.New()
+	h.Write([]byte(previous))
+	h.Write([]byte(step.Name))
+	h.Write(definition)
+	for _, path := range p.substituteAll(p.NormalizeStringSlice(step.Config.Input)) {
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		if err := p.checkSandbox(path); err != nil {
+			return "", err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("error reading input %s of step %s: %w", path, step.Name, err)
+		}
+		h.Write([]byte(path))
+		h.Write(content)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// resumeCheckpoint restores the state recorded after the steps preceding continueFrom and returns
+// the index of the step to continue from. Every restored step must be unchanged since the checkpoint.
+func (p *Processor) resumeCheckpoint(steps []Step, handlers map[string]bool) (int, error) {
+	resumeIndex, _, ok := p.findStep(p.continueFrom)
+	if !ok {
+		return 0, fmt.Errorf("step '%s' to continue from not found", p.continueFrom)
+	}
+	if handlers[p.continueFrom] {
+		return 0, fmt.Errorf("cannot continue from error handler step '%s'", p.continueFrom)
+	}
+
+	recorded := make(map[string]checkpointStep)
+	for _, step := range p.checkpoint.Steps {
+		recorded[step.Name] = step
+	}
+
+	var completed []checkpointStep
+	previous := ""
+	for _, step := range steps[:resumeIndex] {
+		if handlers[step.Name] {
+			continue
+		}
+		fingerprint, err := p.stepFingerprint(step, previous)
+		if err != nil {
+			return 0, err
+		}
+		saved, ok := recorded[step.Name]
+		if !ok {
+			return 0, fmt.Errorf("cannot continue from %s: step %s has not completed in %s", p.continueFrom, step.Name, p.checkpointPath)
+		}
+		if saved.Fingerprint != fingerprint {
+			return 0, fmt.Errorf("cannot continue from %s: step %s or its inputs changed since the checkpoint", p.continueFrom, step.Name)
+		}
+		completed = append(completed, saved)
+		previous = fingerprint
+	}
+
+	for _, saved := range completed {
+		p.outputs[saved.Name] = saved.Output
+	}
+	if len(completed) > 0 {
+		last := completed[len(completed)-1]
+		p.lastOutput = last.LastOutput
+		p.lastModel = last.LastModel
+		for name, value := range last.Variables {
+			// Variables set for this run, for example from the command line, take precedence
+			if _, exists := p.variables[name]; !exists {
+				p.variables[name] = value
+			}
+		}
+		p.logger.Infof("Continuing from step %s, restored %d completed steps from %s", p.continueFrom, len(completed), p.checkpointPath)
+	}
+
+	p.checkpoint.Steps = completed
+	p.fingerprint = previous
+	return resumeIndex, nil
+}
+
+// recordCheckpoint saves the state after a completed step. The checkpoint only ever describes
+// a prefix of the workflow, so later steps from an earlier run are dropped. Resolved secrets are
+// masked, so they are not restored when continuing from the checkpoint.
+func (p *Processor) recordCheckpoint(step Step, fingerprint string) error {
+	variables := make(map[string]string, len(p.variables))
+	for name, value := range p.variables {
+		variables[name] = logging.MaskSecrets(value)
+	}
+
+	p.checkpoint.Steps = append(p.checkpoint.Steps, checkpointStep{
+		Name:        step.Name,
+		Fingerprint: fingerprint,
+		Output:      logging.MaskSecrets(p.outputs[step.Name]),
+		LastOutput:  logging.MaskSecrets(p.lastOutput),
+		LastModel:   p.lastModel,
+		Variables:   variables,
+	})
+	return p.checkpoint.save(p.checkpointPath)
+}
@@ -0,0 +1,51 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/atotto/clipboard"
+)
+
+// clipboardTarget is the input and output naming the system clipboard
+const clipboardTarget = "CLIPBOARD"
+
+// Clipboard access, replaced in tests
+var (
+	readClipboard  = clipboard.ReadAll
+	writeClipboard = clipboard.WriteAll
+)
+
+// clipboardInput saves the text on the system clipboard to a temporary file so it can be processed
+// like a file input. The caller removes the file.
+func (p *Processor) clipboardInput() (string, error) {
+	text, err := readClipboard()
+	if err != nil {
+		return "", fmt.Errorf("failed to read the clipboard: %w", err)
+	}
+	if strings.TrimSpace(text) == "" {
+		return "", fmt.Errorf("the clipboard is empty")
+	}
+
+	// The file is read again when the step's actions are sent, so it lives in the run's temp directory
+	dir, err := p.runTempDir()
+	if err != nil {
+		return "", err
+	}
+	tmpFile, err := os.CreateTemp(dir, "clipboard-*.txt")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file for clipboard content: %w", err)
+	}
+	tmpPath := tmpFile.Name()
+	p.trustPath(tmpPath)
+
+	_, err = tmpFile.WriteString(text)
+	tmpFile.Close()
+	if err != nil {
+		os.Remove(tmpPath)
+		return "", fmt.Errorf("failed to write clipboard content to file: %w", err)
+	}
+	p.debugf("Clipboard content saved to temporary file: %s", tmpPath)
+	return tmpPath, nil
+}
@@ -0,0 +1,68 @@
+package processor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/atotto/clipboard"
+	"github.com/kris-hansen/comanda/utils/models"
+)
+
+func TestClipboardInputOutput(t *testing.T) {
+	clipboardText := "copied notes"
+	readClipboard = func() (string, error) { return clipboardText, nil }
+	var copied string
+	writeClipboard = func(text string) error {
+		copied = text
+		return nil
+	}
+	defer func() {
+		readClipboard, writeClipboard = clipboard.ReadAll, clipboard.WriteAll
+	}()
+
+	processor := NewProcessor(&DSLConfig{}, createTestEnvConfig(), false)
+	if err := processor.processInputs([]string{clipboardTarget}); err != nil {
+		t.Fatalf("processInputs() unexpected error: %v", err)
+	}
+	inputs := processor.handler.GetInputs()
+	if len(inputs) != 1 || string(inputs[0].Contents) != clipboardText {
+		t.Errorf("processInputs() inputs = %v, want the clipboard text", inputs)
+	}
+
+	if err := processor.handleOutput("gpt-4o", "summary", []string{clipboardTarget}); err != nil {
+		t.Fatalf("handleOutput() unexpected error: %v", err)
+	}
+	if copied != "summary" {
+		t.Errorf("clipboard = %q, want %q", copied, "summary")
+	}
+
+	clipboardText = " \n"
+	processor = NewProcessor(&DSLConfig{}, createTestEnvConfig(), false)
+	if err := processor.processInputs([]string{clipboardTarget}); err == nil || !strings.Contains(err.Error(), "clipboard is empty") {
+		t.Errorf("processInputs() error = %v, want an empty clipboard error", err)
+	}
+}
+
+func TestClipboardInputSentToModel(t *testing.T) {
+	readClipboard = func() (string, error) { return "copied notes", nil }
+	models.DetectProvider = originalDetectProvider
+	defer func() {
+		readClipboard = clipboard.ReadAll
+		models.DetectProvider = mockDetectProvider
+	}()
+
+	processor := NewProcessor(&DSLConfig{}, createTestEnvConfig(), false)
+	defer processor.removeTempDir()
+	processor.providers["echo"] = models.NewEchoProvider()
+	if err := processor.processInputs([]string{clipboardTarget}); err != nil {
+		t.Fatalf("processInputs() unexpected error: %v", err)
+	}
+
+	response, err := processor.runActions("echo", []string{"summarize"}, StepConfig{})
+	if err != nil {
+		t.Fatalf("runActions() unexpected error: %v", err)
+	}
+	if !strings.Contains(response, "copied notes") {
+		t.Errorf("runActions() = %q, want the clipboard text", response)
+	}
+}
@@ -0,0 +1,150 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DiffKind describes how a step differs between two workflows
+type DiffKind string
+
+const (
+	StepAdded   DiffKind = "added"
+	StepRemoved DiffKind = "removed"
+	StepChanged DiffKind = "changed"
+)
+
+// FieldChange records a single field whose value differs between two versions of a step
+type FieldChange struct {
+	Field string
+	Old   string
+	New   string
+}
+
+// StepDiff describes the difference for a single step, matched by name
+type StepDiff struct {
+	Name    string
+	Kind    DiffKind
+	Changes []FieldChange // Only set for changed steps
+}
+
+// stepFields lists the step fields compared by DiffConfigs, in display order
+var stepFields = []struct {
+	name  string
+	value func(StepConfig) interface{}
+}{
+	{"type", func(c StepConfig) interface{} { return c.Type }},
+	{"input", func(c StepConfig) interface{} { return c.Input }},
+	{"model", func(c StepConfig) interface{} { return c.Model }},
+	{"complexity", func(c StepConfig) interface{} { return c.Complexity }},
+	{"fallback", func(c StepConfig) interface{} { return c.Fallback }},
+	{"aggregate", func(c StepConfig) interface{} { return c.Aggregate }},
+	{"judge", func(c StepConfig) interface{} { return c.Judge }},
+	{"action", func(c StepConfig) interface{} { return c.Action }},
+	{"system", func(c StepConfig) interface{} { return c.System }},
+	{"output", func(c StepConfig) interface{} { return c.Output }},
+	{"next-action", func(c StepConfig) interface{} { return c.NextAction }},
+	{"redact", func(c StepConfig) interface{} { return c.Redact }},
+	{"on_error", func(c StepConfig) interface{} { return c.OnError }},
+	{"max_cost", func(c StepConfig) interface{} { return c.MaxCost }},
+	{"cache_context", func(c StepConfig) interface{} { return c.CacheContext }},
+	{"max_input_tokens", func(c StepConfig) interface{} { return c.MaxInputTokens }},
+	{"truncate", func(c StepConfig) interface{} { return c.Truncate }},
+	{"include_reasoning", func(c StepConfig) interface{} { return c.IncludeReasoning }},
+	{"thinking", func(c StepConfig) interface{} { return c.Thinking }},
+	{"live_search", func(c StepConfig) interface{} { return c.LiveSearch }},
+	{"max_tokens", func(c StepConfig) interface{} { return c.MaxTokens }},
+	{"seed", func(c StepConfig) interface{} { return c.Seed }},
+	{"stop", func(c StepConfig) interface{} { return c.Stop }},
+	{"sanitize_input", func(c StepConfig) interface{} { return c.SanitizeInput }},
+	{"output_format", func(c StepConfig) interface{} { return c.OutputFormat }},
+	{"format_retries", func(c StepConfig) interface{} { return c.FormatRetries }},
+	{"self_correct", func(c StepConfig) interface{} { return c.SelfCorrect }},
+	{"response_format", func(c StepConfig) interface{} { return c.ResponseFormat }},
+	{"post_process", func(c StepConfig) interface{} { return c.PostProcess }},
+	{"transform", func(c StepConfig) interface{} { return c.Transform }},
+}
+
+// DiffConfigs compares two workflows step by step, matching steps by name.
+// Changed and removed steps are reported in the order of the old workflow, followed by added steps
+// in the order of the new workflow.
+func DiffConfigs(oldConfig, newConfig *DSLConfig) []StepDiff {
+	newSteps := make(map[string]StepConfig)
+	for _, step := range newConfig.Steps {
+		newSteps[step.Name] = step.Config
+	}
+	oldSteps := make(map[string]bool)
+
+	var diffs []StepDiff
+	for _, step := range oldConfig.Steps {
+		oldSteps[step.Name] = true
+		newStep, ok := newSteps[step.Name]
+		if !ok {
+			diffs = append(diffs, StepDiff{Name: step.Name, Kind: StepRemoved})
+			continue
+		}
+
+		var changes []FieldChange
+		for _, field := range stepFields {
+			oldValue := describeValue(field.value(step.Config))
+			newValue := describeValue(field.value(newStep))
+			if oldValue != newValue {
+				changes = append(changes, FieldChange{Field: field.name, Old: oldValue, New: newValue})
+			}
+		}
+		if len(changes) > 0 {
+			diffs = append(diffs, StepDiff{Name: step.Name, Kind: StepChanged, Changes: changes})
+		}
+	}
+
+	for _, step := range newConfig.Steps {
+		if !oldSteps[step.Name] {
+			diffs = append(diffs, StepDiff{Name: step.Name, Kind: StepAdded})
+		}
+	}
+
+	return diffs
+}
+
+// describeValue renders a step field as a canonical string so that equivalent values compare equal
+func describeValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		if !v {
+			return ""
+		}
+		return "true"
+	case *int:
+		if v == nil {
+			return ""
+		}
+		return strconv.Itoa(*v)
+	case []string:
+		if len(v) == 1 {
+			return v[0]
+		}
+		return "[" + strings.Join(v, ", ") + "]"
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = describeValue(item)
+		}
+		if len(items) == 1 {
+			return items[0]
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	default:
+		// Maps (database, url and directory inputs) are rendered as JSON, which sorts their keys
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(data)
+	}
+}
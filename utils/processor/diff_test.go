@@ -0,0 +1,63 @@
+package processor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffConfigs(t *testing.T) {
+	oldConfig := &DSLConfig{
+		Steps: []Step{
+			{Name: "extract", Config: StepConfig{Input: "data.txt", Model: "gpt-4o-mini", Action: "extract", Output: "STDOUT"}},
+			{Name: "analyze", Config: StepConfig{Input: "STDIN", Model: "gpt-4o", Action: "analyze", Output: "STDOUT"}},
+			{Name: "legacy", Config: StepConfig{Input: "NA", Model: "NA", Action: "noop", Output: "STDOUT"}},
+		},
+	}
+	newConfig := &DSLConfig{
+		Steps: []Step{
+			{Name: "summarize", Config: StepConfig{Input: "STDIN", Model: "gpt-4o", Action: "summarize", Output: "out.txt"}},
+			{Name: "analyze", Config: StepConfig{Input: "STDIN", Model: "claude-3-5-sonnet-latest", Action: "analyze", Output: []interface{}{"STDOUT"}}},
+			{Name: "extract", Config: StepConfig{Input: []interface{}{"data.txt"}, Model: "gpt-4o-mini", Action: "extract", Output: "STDOUT"}},
+		},
+	}
+
+	want := []StepDiff{
+		{
+			Name: "analyze",
+			Kind: StepChanged,
+			Changes: []FieldChange{
+				{Field: "model", Old: "gpt-4o", New: "claude-3-5-sonnet-latest"},
+			},
+		},
+		{Name: "legacy", Kind: StepRemoved},
+		{Name: "summarize", Kind: StepAdded},
+	}
+
+	got := DiffConfigs(oldConfig, newConfig)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffConfigs() = %+v, want %+v", got, want)
+	}
+}
+
+func TestDescribeValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{"nil", nil, ""},
+		{"string", "gpt-4o", "gpt-4o"},
+		{"single item list", []interface{}{"gpt-4o"}, "gpt-4o"},
+		{"list", []interface{}{"a.txt", "b.txt"}, "[a.txt, b.txt]"},
+		{"map", map[string]interface{}{"dir": "docs", "ext": "md"}, `{"dir":"docs","ext":"md"}`},
+		{"false", false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := describeValue(tt.value); got != tt.want {
+				t.Errorf("describeValue() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
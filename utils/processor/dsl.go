@@ -1,13 +1,18 @@
 package processor
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/kris-hansen/comanda/utils/config"
 	"github.com/kris-hansen/comanda/utils/input"
+	"github.com/kris-hansen/comanda/utils/logging"
 	"github.com/kris-hansen/comanda/utils/models"
 )
 
@@ -21,7 +26,42 @@ type Processor struct {
 	verbose    bool
 	lastOutput string
 	spinner    *Spinner
+	logger     *logging.Logger
 	variables  map[string]string // Store variables from STDIN
+	lastModel  string            // Model that produced the last output
+	results    []StepResult      // Outcome of each processed step, used for the run report
+	outputs    map[string]string // Output of each completed step, keyed by step name
+	finished   bool              // Whether every step ran or was recovered by its on_error step
+
+	uploadResolver func(handle string) (string, error) // Maps upload:<handle> inputs to files, set by the server
+	sandboxDirs    []string                            // Directories file access is restricted to, as configured
+	sandbox        []string                            // Resolved sandbox directories; empty means unrestricted
+	trustedPaths   map[string]bool                     // Files created by the processor, exempt from the sandbox
+	externalInput  map[string]string                   // Files holding fetched URL content, mapped to their URL
+	fetched        map[string]string                   // Files holding fetched URL content, keyed by URL
+	tempDir        string                              // Directory of the run's fetched files; removed when the run ends
+	outputDir      string                              // Directory relative file outputs are written to; empty means the working directory
+	secrets        map[string]string                   // Secrets resolved by {{ secret("name") }}, keyed by name
+	prompts        map[string]string                   // Prompts fetched from action URLs, keyed by URL
+	breakers       map[string]*models.CircuitBreaker   // Circuit breaker of each provider called, keyed by provider name
+	streamPaths    []string                            // Partial output files the current step's response is streamed to
+
+	checkpointPath string      // File completed steps are saved to; empty disables checkpointing
+	continueFrom   string      // Step to resume from, restoring earlier steps from the checkpoint
+	checkpoint     *checkpoint // Steps completed so far, as saved to checkpointPath
+	fingerprint    string      // Fingerprint of the last completed step
+
+	runFrom string          // First step to run; empty starts at the first step
+	runTo   string          // Last step to run; empty ends at the last step
+	skipped map[string]bool // Steps before runFrom, which are not run
+
+	maxCost   float64 // Cost limit of the run in US dollars; zero means no limit
+	spent     float64 // Estimated cost of the model calls made so far
+	stepSpent float64 // Estimated cost of the current step's model calls
+
+	warnings []string // Warnings logged during the run, for the run report
+
+	mu sync.Mutex // Guards the costs, warnings, circuit breakers and prompt cache while aggregate models run at once
 }
 
 // isTestMode checks if the code is running in test mode
@@ -39,11 +79,20 @@ func NewProcessor(config *DSLConfig, envConfig *config.EnvConfig, verbose bool)
 		providers: make(map[string]models.Provider),
 		verbose:   verbose,
 		spinner:   NewSpinner(),
+		logger:    logging.New("DSL", verbose),
 		variables: make(map[string]string),
+		outputs:   make(map[string]string),
+
+		trustedPaths:  make(map[string]bool),
+		externalInput: make(map[string]string),
+		fetched:       make(map[string]string),
+		secrets:       make(map[string]string),
+		prompts:       make(map[string]string),
+		breakers:      make(map[string]*models.CircuitBreaker),
 	}
 
-	// Disable spinner in test environments
-	if isTestMode() {
+	// Disable spinner in test environments and when emitting structured logs
+	if isTestMode() || logging.CurrentFormat() == logging.FormatJSON {
 		p.spinner.Disable()
 	}
 
@@ -56,6 +105,11 @@ func (p *Processor) SetLastOutput(output string) {
 	p.lastOutput = output
 }
 
+// SetUploadResolver sets the function used to find the file stored for an upload:<handle> input
+func (p *Processor) SetUploadResolver(resolver func(handle string) (string, error)) {
+	p.uploadResolver = resolver
+}
+
 // LastOutput returns the last output value
 func (p *Processor) LastOutput() string {
 	return p.lastOutput
@@ -63,9 +117,15 @@ func (p *Processor) LastOutput() string {
 
 // debugf prints debug information if verbose mode is enabled
 func (p *Processor) debugf(format string, args ...interface{}) {
-	if p.verbose {
-		fmt.Printf("[DEBUG][DSL] "+format+"\n", args...)
-	}
+	p.logger.Debugf(format, args...)
+}
+
+// warnf logs a warning and keeps it for the run report
+func (p *Processor) warnf(format string, args ...interface{}) {
+	p.logger.Warnf(format, args...)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.warnings = append(p.warnings, logging.MaskSecrets(fmt.Sprintf(format, args...)))
 }
 
 // parseVariableAssignment checks for "as $varname" syntax and returns the variable name
@@ -77,14 +137,68 @@ func (p *Processor) parseVariableAssignment(input string) (string, string) {
 	return input, ""
 }
 
-// substituteVariables replaces variable references with their values
+// substituteVariables replaces $name variable references and evaluates {{ }} expressions, which
+// may be a variable name or a helper call such as {{ upper($name) }}. Expressions that cannot be
+// evaluated, for example because a variable is not set, are left unchanged.
 func (p *Processor) substituteVariables(text string) string {
-	for name, value := range p.variables {
-		text = strings.ReplaceAll(text, "$"+name, value)
+	var sb strings.Builder
+	last := 0
+	for _, loc := range templatePattern.FindAllStringSubmatchIndex(text, -1) {
+		sb.WriteString(p.substituteDollarVariables(text[last:loc[0]]))
+		value, err := p.evaluateTemplate(text[loc[2]:loc[3]])
+		if err != nil {
+			p.debugf("Leaving %s unchanged: %v", text[loc[0]:loc[1]], err)
+			sb.WriteString(text[loc[0]:loc[1]])
+		} else {
+			sb.WriteString(value)
+		}
+		last = loc[1]
+	}
+	sb.WriteString(p.substituteDollarVariables(text[last:]))
+	return sb.String()
+}
+
+// substituteDollarVariables replaces $name variable references with their values
+func (p *Processor) substituteDollarVariables(text string) string {
+	// Replace longer names first so $model_name is not clobbered by $model
+	names := make([]string, 0, len(p.variables))
+	for name := range p.variables {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return len(names[i]) > len(names[j]) })
+	for _, name := range names {
+		text = strings.ReplaceAll(text, "$"+name, p.variables[name])
 	}
 	return text
 }
 
+// substituteAll applies variable substitution to each value
+func (p *Processor) substituteAll(values []string) []string {
+	result := make([]string, len(values))
+	for i, value := range values {
+		result[i] = p.substituteVariables(value)
+	}
+	return result
+}
+
+// loadWorkflowVariables adds the workflow's vars block to the variable map.
+// Variables that are already set, for example from the command line, take precedence.
+func (p *Processor) loadWorkflowVariables() {
+	for name, value := range p.config.Vars {
+		if _, exists := p.variables[name]; exists {
+			p.debugf("Variable %s already set, ignoring workflow value", name)
+			continue
+		}
+		p.variables[name] = value
+	}
+}
+
+// ValidateStep checks a step's configuration without running it. References to other steps,
+// such as on_error, are resolved against the processor's workflow.
+func (p *Processor) ValidateStep(step Step) error {
+	return p.validateStepConfig(step.Name, step.Config)
+}
+
 // validateStepConfig checks if all required fields are present in a step
 func (p *Processor) validateStepConfig(stepName string, config StepConfig) error {
 	var errors []string
@@ -94,26 +208,135 @@ func (p *Processor) validateStepConfig(stepName string, config StepConfig) error
 		errors = append(errors, "input tag is required (can be NA or empty, but the tag must be present)")
 	}
 
-	// Check model field
+	// Check model field; transform steps do not call a model
 	modelNames := p.NormalizeStringSlice(config.Model)
-	if len(modelNames) == 0 {
-		errors = append(errors, "model is required (can be NA or a valid model name)")
+	switch config.Type {
+	case "":
+		if len(modelNames) == 0 {
+			errors = append(errors, "model is required (can be NA or a valid model name)")
+		}
+		if config.Transform != nil {
+			errors = append(errors, "transform can only be used with type: transform")
+		}
+	case transformStepType:
+		if len(modelNames) > 0 && !(len(modelNames) == 1 && modelNames[0] == "NA") {
+			errors = append(errors, "transform steps cannot use a model (omit model or set it to NA)")
+		}
+		if err := p.validateTransform(config); err != nil {
+			errors = append(errors, err.Error())
+		}
+	default:
+		errors = append(errors, fmt.Sprintf("unknown step type: %s", config.Type))
+	}
+
+	// Fallback models only make sense when a model is actually called
+	if fallbacks := p.NormalizeStringSlice(config.Fallback); len(fallbacks) > 0 {
+		for _, fallback := range fallbacks {
+			if fallback == "NA" {
+				errors = append(errors, "fallback models cannot be NA")
+				break
+			}
+		}
+		if len(modelNames) == 1 && modelNames[0] == "NA" {
+			errors = append(errors, "fallback cannot be used when model is NA")
+		}
+	}
+
+	// Complexity picks the tier for model: auto
+	if config.Complexity != "" {
+		if _, ok := autoTierPreference[config.Complexity]; !ok {
+			errors = append(errors, fmt.Sprintf("complexity must be low, medium, or high, got %s", config.Complexity))
+		}
+		if !(len(modelNames) == 1 && modelNames[0] == autoModel) {
+			errors = append(errors, "complexity can only be used with model: auto")
+		}
+	}
+
+	// Check the redaction setting and any custom patterns it uses
+	if _, err := p.stepRedactor(config); err != nil {
+		errors = append(errors, err.Error())
+	}
+
+	// Check the input size limit and truncation strategy
+	if err := validateTruncation(config); err != nil {
+		errors = append(errors, err.Error())
+	} else if config.MaxInputTokens > 0 && len(modelNames) == 1 && modelNames[0] == "NA" {
+		errors = append(errors, "max_input_tokens cannot be used when model is NA")
+	}
+
+	// Check the keys of an input map and the query of a JSON query input
+	if err := validateInputMap(config.Input); err != nil {
+		errors = append(errors, err.Error())
+	} else if err := validateQueryInput(config.Input); err != nil {
+		errors = append(errors, err.Error())
+	}
+
+	// Check the response token limit
+	if err := validateMaxTokens(config); err != nil {
+		errors = append(errors, err.Error())
+	}
+
+	// Check the cost limit
+	if err := validateMaxCost(config); err != nil {
+		errors = append(errors, err.Error())
+	}
+
+	// Check the extended thinking budget
+	if err := validateThinking(config); err != nil {
+		errors = append(errors, err.Error())
+	}
+
+	// Check the live search mode
+	if err := validateLiveSearch(config); err != nil {
+		errors = append(errors, err.Error())
+	}
+
+	// Check the expected output format
+	if err := validateOutputFormat(config); err != nil {
+		errors = append(errors, err.Error())
+	}
+	if err := validateResponseFormat(config); err != nil {
+		errors = append(errors, err.Error())
+	}
+
+	// Check how the answers of several models are combined
+	if err := p.validateAggregate(config); err != nil {
+		errors = append(errors, err.Error())
+	}
+
+	// Check the output cleanups
+	if err := p.validatePostProcess(config); err != nil {
+		errors = append(errors, err.Error())
+	}
+
+	// Check the error handler refers to another step in the workflow
+	if config.OnError != "" {
+		if config.OnError == stepName {
+			errors = append(errors, "on_error cannot refer to the step itself")
+		} else if _, _, ok := p.findStep(config.OnError); !ok {
+			errors = append(errors, fmt.Sprintf("on_error step '%s' not found", config.OnError))
+		}
 	}
 
 	// Check action field
 	actions := p.NormalizeStringSlice(config.Action)
-	if len(actions) == 0 {
+	if len(actions) == 0 && config.Type != transformStepType {
 		errors = append(errors, "action is required")
 	}
 
 	// Check output field
-	outputs := p.NormalizeStringSlice(config.Output)
-	if len(outputs) == 0 {
+	destinations, databaseOutputs, httpOutputs := p.splitOutputs(config.Output)
+	if len(destinations)+len(databaseOutputs)+len(httpOutputs) == 0 {
 		errors = append(errors, "output is required (can be STDOUT for console output)")
 	}
+	for _, httpOutput := range httpOutputs {
+		if _, err := parseHTTPOutput(httpOutput); err != nil {
+			errors = append(errors, err.Error())
+		}
+	}
 
 	if len(errors) > 0 {
-		return fmt.Errorf("validation errors in step '%s':\n- %s", stepName, strings.Join(errors, "\n- "))
+		return newStepError(stepName, "validation", CategoryValidation, fmt.Errorf("invalid configuration:\n- %s", strings.Join(errors, "\n- ")))
 	}
 
 	return nil
@@ -122,220 +345,545 @@ func (p *Processor) validateStepConfig(stepName string, config StepConfig) error
 // Process executes the DSL processing pipeline
 func (p *Processor) Process() error {
 	p.debugf("Starting DSL processing")
+	defer p.removeTempDir()
 
 	if len(p.config.Steps) == 0 {
 		return fmt.Errorf("no steps defined in DSL configuration")
 	}
 
+	p.loadWorkflowVariables()
+
+	if err := p.resolveSandbox(); err != nil {
+		p.logger.Errorf("%v", err)
+		return err
+	}
+
 	// First validate all steps before processing
 	p.spinner.Start("Validating DSL configuration")
 	for _, step := range p.config.Steps {
 		if err := p.validateStepConfig(step.Name, step.Config); err != nil {
 			p.spinner.Stop()
-			fmt.Printf("Error: %v\n", err)
+			p.logger.Errorf("%v", err)
 			return err
 		}
 	}
 	p.spinner.Stop()
 
-	// Process steps in order
-	for stepIndex, step := range p.config.Steps {
-		stepMsg := fmt.Sprintf("Processing step %d/%d: %s", stepIndex+1, len(p.config.Steps), step.Name)
-		p.spinner.Start(stepMsg)
-		p.debugf("Processing step: %s", step.Name)
-
-		// Handle input based on type
-		var inputs []string
-		switch v := step.Config.Input.(type) {
-		case map[string]interface{}:
-			// Check for database input
-			if _, hasDB := v["database"]; hasDB {
-				p.spinner.Stop()
-				p.spinner.Start("Processing database input")
-				if err := p.handleDatabaseInput(v); err != nil {
-					p.spinner.Stop()
-					return fmt.Errorf("failed to process database input: %w", err)
-				}
-				// Create a temporary file with the database output
-				tmpFile, err := os.CreateTemp("", "comanda-db-*.txt")
-				if err != nil {
-					p.spinner.Stop()
-					return fmt.Errorf("failed to create temp file for database output: %w", err)
-				}
-				tmpPath := tmpFile.Name()
-				defer os.Remove(tmpPath)
+	// Steps named by on_error only run when the step they handle fails
+	handlers := make(map[string]bool)
+	for _, step := range p.config.Steps {
+		if step.Config.OnError != "" {
+			handlers[step.Config.OnError] = true
+		}
+	}
+
+	// Limit the run to the selected steps
+	startIndex, endIndex, err := p.selectSteps(handlers)
+	if err != nil {
+		p.logger.Errorf("%v", err)
+		return err
+	}
 
-				if _, err := tmpFile.WriteString(p.lastOutput); err != nil {
-					tmpFile.Close()
-					p.spinner.Stop()
-					return fmt.Errorf("failed to write database output to temp file: %w", err)
+	// Restore the steps completed by an earlier run when resuming from a checkpoint
+	if p.checkpointPath != "" {
+		cp, err := loadCheckpoint(p.checkpointPath)
+		if err != nil {
+			p.logger.Errorf("%v", err)
+			return err
+		}
+		p.checkpoint = cp
+		if p.continueFrom != "" {
+			if startIndex, err = p.resumeCheckpoint(p.config.Steps, handlers); err != nil {
+				p.logger.Errorf("%v", err)
+				return err
+			}
+			if startIndex > endIndex {
+				err = fmt.Errorf("step '%s' to continue from comes after step '%s'", p.continueFrom, p.runTo)
+				p.logger.Errorf("%v", err)
+				return err
+			}
+		} else {
+			p.checkpoint.Steps = nil
+		}
+	}
+
+	// Process steps in order, recording the outcome of each for the run report
+	for stepIndex, step := range p.config.Steps[startIndex : endIndex+1] {
+		stepIndex += startIndex
+		if handlers[step.Name] {
+			p.debugf("Skipping error handler step %s", step.Name)
+			continue
+		}
+
+		var fingerprint string
+		if p.checkpoint != nil {
+			var err error
+			if fingerprint, err = p.stepFingerprint(step, p.fingerprint); err != nil {
+				return err
+			}
+		}
+
+		if err := p.runStep(stepIndex, step); err != nil {
+			if errors.Is(err, ErrBudgetExceeded) {
+				if p.checkpoint != nil {
+					p.logger.Infof("Completed steps are checkpointed; raise the cost limit and continue from step %s", step.Name)
 				}
+				return err
+			}
+			if step.Config.OnError == "" {
+				return err
+			}
+			if err := p.recoverStep(step, err); err != nil {
+				return err
+			}
+		}
+
+		if p.checkpoint != nil {
+			if err := p.recordCheckpoint(step, fingerprint); err != nil {
+				return err
+			}
+			p.fingerprint = fingerprint
+		}
+	}
+
+	p.finished = true
+	p.debugf("DSL processing completed successfully")
+	return nil
+}
+
+// runStep processes a step and records its outcome for the run report
+func (p *Processor) runStep(stepIndex int, step Step) error {
+	result := StepResult{Name: step.Name, StartedAt: time.Now()}
+	p.stepSpent = 0
+	err := p.processStep(stepIndex, step, &result)
+	result.DurationMs = time.Since(result.StartedAt).Milliseconds()
+	result.Cost = p.stepSpent
+	result.Success = err == nil
+	if err != nil {
+		result.Error = logging.MaskSecrets(err.Error())
+		result.Category = CategoryOf(err)
+	}
+	p.results = append(p.results, result)
+	return err
+}
+
+// recoverStep runs the on_error step of a failed step, with the failure available as $error.
+// If the error handler fails too the workflow ends; the handler's own on_error is not followed.
+func (p *Processor) recoverStep(step Step, stepErr error) error {
+	handlerIndex, handler, _ := p.findStep(step.Config.OnError)
+	p.warnf("Step %s failed, running error handler %s: %v", step.Name, handler.Name, stepErr)
+
+	p.variables["error"] = stepErr.Error()
+	p.results[len(p.results)-1].RecoveredBy = handler.Name
+
+	if err := p.runStep(handlerIndex, handler); err != nil {
+		return fmt.Errorf("error handler %s failed while handling step %s: %w (step error: %v)", handler.Name, step.Name, err, stepErr)
+	}
+	return nil
+}
+
+// findStep returns the index and definition of the named step
+func (p *Processor) findStep(name string) (int, Step, bool) {
+	for i, step := range p.config.Steps {
+		if step.Name == name {
+			return i, step, true
+		}
+	}
+	return 0, Step{}, false
+}
+
+// processStep runs a single step and records its details in result
+func (p *Processor) processStep(stepIndex int, step Step, result *StepResult) error {
+	p.logger.SetStep(step.Name)
+	defer p.logger.SetStep("")
+
+	// Clear the handler's contents for the next step, including an on_error step run after a failure
+	defer func() { p.handler = input.NewHandler() }()
+
+	stepMsg := fmt.Sprintf("Processing step %d/%d: %s", stepIndex+1, len(p.config.Steps), step.Name)
+	p.spinner.Start(stepMsg)
+	p.debugf("Processing step: %s", step.Name)
+
+	// Handle input based on type
+	var inputs []string
+	switch v := step.Config.Input.(type) {
+	case map[string]interface{}:
+		// Check for database input
+		if _, hasDB := v["database"]; hasDB {
+			p.spinner.Stop()
+			p.spinner.Start("Processing database input")
+			if err := p.handleDatabaseInput(v); err != nil {
+				p.spinner.Stop()
+				return newStepError(step.Name, "input processing", CategoryIO, fmt.Errorf("failed to process database input: %w", err))
+			}
+			// Create a temporary file with the database output
+			tmpFile, err := os.CreateTemp("", "comanda-db-*.txt")
+			if err != nil {
+				p.spinner.Stop()
+				return newStepError(step.Name, "input processing", CategoryIO, fmt.Errorf("failed to create temp file for database output: %w", err))
+			}
+			tmpPath := tmpFile.Name()
+			defer os.Remove(tmpPath)
+			p.trustPath(tmpPath)
+
+			if _, err := tmpFile.WriteString(p.lastOutput); err != nil {
 				tmpFile.Close()
+				p.spinner.Stop()
+				return newStepError(step.Name, "input processing", CategoryIO, fmt.Errorf("failed to write database output to temp file: %w", err))
+			}
+			tmpFile.Close()
 
-				// Set the input to the temp file path
-				inputs = []string{tmpPath}
+			// Set the input to the temp file path
+			inputs = []string{tmpPath}
+			p.spinner.Stop()
+		} else if url, ok := v["url"].(string); ok {
+			// Handle scraping configuration
+			p.spinner.Stop()
+			p.spinner.Start(fmt.Sprintf("Scraping content from %s", url))
+			if err := p.handler.ProcessScrape(url, v); err != nil {
 				p.spinner.Stop()
-			} else if url, ok := v["url"].(string); ok {
-				// Handle scraping configuration
+				return newStepError(step.Name, "input processing", CategoryIO, fmt.Errorf("failed to process scraping input: %w", err))
+			}
+			inputs = []string{url}
+			p.spinner.Stop()
+		} else if _, hasDir := v["dir"]; hasDir {
+			dirInputs, err := p.resolveDirectoryInput(v)
+			if err != nil {
 				p.spinner.Stop()
-				p.spinner.Start(fmt.Sprintf("Scraping content from %s", url))
-				if err := p.handler.ProcessScrape(url, v); err != nil {
-					p.spinner.Stop()
-					return fmt.Errorf("failed to process scraping input: %w", err)
-				}
-				inputs = []string{url}
+				return newStepError(step.Name, "input processing", CategoryIO, fmt.Errorf("failed to process directory input: %w", err))
+			}
+			inputs = dirInputs
+		} else if _, hasFiles := v["files"]; hasFiles || v["glob"] != nil {
+			fileInputs, err := p.resolveFilesInput(v)
+			if err != nil {
+				p.spinner.Stop()
+				return newStepError(step.Name, "input processing", CategoryIO, fmt.Errorf("failed to process files input: %w", err))
+			}
+			inputs = fileInputs
+		} else if _, hasFile := v["file"]; hasFile {
+			tmpPath, err := p.queryInput(v)
+			if err != nil {
 				p.spinner.Stop()
-			} else {
-				inputs = p.NormalizeStringSlice(step.Config.Input)
+				return newStepError(step.Name, "input processing", CategoryIO, fmt.Errorf("failed to process query input: %w", err))
 			}
-		default:
+			defer os.Remove(tmpPath)
+			inputs = []string{tmpPath}
+		} else {
 			inputs = p.NormalizeStringSlice(step.Config.Input)
 		}
+	default:
+		inputs = p.NormalizeStringSlice(step.Config.Input)
+	}
 
-		modelNames := p.NormalizeStringSlice(step.Config.Model)
-		actions := p.NormalizeStringSlice(step.Config.Action)
+	modelNames := p.resolveModelAliases(p.substituteAll(p.NormalizeStringSlice(step.Config.Model)))
+	fallbacks := p.resolveModelAliases(p.substituteAll(p.NormalizeStringSlice(step.Config.Fallback)))
+	judge := step.Config.Judge
+	if judge != "" {
+		judge = p.resolveModelAliases([]string{p.substituteVariables(judge)})[0]
+	}
+	actions := p.NormalizeStringSlice(step.Config.Action)
 
-		p.debugf("Step configuration:")
-		p.debugf("- Inputs: %v", inputs)
-		p.debugf("- Models: %v", modelNames)
-		p.debugf("- Actions: %v", actions)
+	if len(modelNames) == 1 && modelNames[0] == autoModel {
+		selected, err := p.resolveAutoModel(step.Config.Complexity)
+		if err != nil {
+			p.spinner.Stop()
+			err = newStepError(step.Name, "model selection", CategoryValidation, err)
+			p.logger.Errorf("%v", err)
+			return err
+		}
+		complexity := step.Config.Complexity
+		if complexity == "" {
+			complexity = "medium"
+		}
+		p.logger.Infof("model auto selected %s for %s complexity", selected, complexity)
+		modelNames = []string{selected}
+	}
 
-		// Handle STDIN specially
-		if len(inputs) == 1 {
-			input := inputs[0]
-			if strings.HasPrefix(input, "STDIN") {
-				if p.lastOutput == "" {
-					p.spinner.Stop()
-					err := fmt.Errorf("STDIN specified but no previous output available")
-					fmt.Printf("Error in step '%s': %v\n", step.Name, err)
-					return err
-				}
+	// Substitute variables in file inputs; STDIN inputs may declare a variable with "as $name".
+	// Work on a copy so the step configuration itself is left untouched.
+	inputs = append([]string(nil), inputs...)
+	for i, input := range inputs {
+		if !strings.HasPrefix(input, "STDIN") {
+			inputs[i] = p.substituteVariables(input)
+		}
+	}
 
-				// Check for variable assignment
-				_, varName := p.parseVariableAssignment(input)
-				if varName != "" {
-					p.variables[varName] = p.lastOutput
-				}
+	p.debugf("Step configuration:")
+	p.debugf("- Inputs: %v", inputs)
+	p.debugf("- Models: %v", modelNames)
+	if len(fallbacks) > 0 {
+		p.debugf("- Fallback models: %v", fallbacks)
+	}
+	p.debugf("- Actions: %v", actions)
 
-				p.spinner.Start("Processing STDIN input")
-				// Create a temporary file with .txt extension for the STDIN content
-				tmpFile, err := os.CreateTemp("", "comanda-stdin-*.txt")
-				if err != nil {
-					p.spinner.Stop()
-					err = fmt.Errorf("failed to create temp file for STDIN: %w", err)
-					fmt.Printf("Error in step '%s': %v\n", step.Name, err)
-					return err
-				}
-				tmpPath := tmpFile.Name()
-				defer os.Remove(tmpPath)
-
-				if _, err := tmpFile.WriteString(p.lastOutput); err != nil {
-					tmpFile.Close()
-					p.spinner.Stop()
-					err = fmt.Errorf("failed to write to temp file: %w", err)
-					fmt.Printf("Error in step '%s': %v\n", step.Name, err)
-					return err
-				}
-				tmpFile.Close()
+	if len(modelNames) > 0 {
+		result.Model = modelNames[0]
+	}
+
+	// Handle STDIN specially
+	if len(inputs) == 1 {
+		input := inputs[0]
+		if strings.HasPrefix(input, "STDIN") {
+			if p.lastOutput == "" {
 				p.spinner.Stop()
+				err := fmt.Errorf("STDIN specified but no previous output available")
+				if skipped := p.lastSkippedStep(); skipped != "" {
+					err = fmt.Errorf("STDIN specified but the previous step %s was skipped", skipped)
+				}
+				err = newStepError(step.Name, "input processing", CategoryValidation, err)
+				p.logger.Errorf("%v", err)
+				return err
+			}
 
-				// Update inputs to use the temporary file
-				inputs = []string{tmpPath}
+			// Check for variable assignment
+			_, varName := p.parseVariableAssignment(input)
+			if varName != "" {
+				p.variables[varName] = p.lastOutput
 			}
-		}
 
-		// Process inputs for this step
-		if len(inputs) > 0 {
-			p.spinner.Start("Processing input files")
-			p.debugf("Processing inputs for step %s...", step.Name)
-			if err := p.processInputs(inputs); err != nil {
+			p.spinner.Start("Processing STDIN input")
+			// Create a temporary file with .txt extension for the STDIN content
+			tmpFile, err := os.CreateTemp("", "comanda-stdin-*.txt")
+			if err != nil {
 				p.spinner.Stop()
-				err = fmt.Errorf("input processing error in step %s: %w", step.Name, err)
-				fmt.Printf("Error: %v\n", err)
+				err = newStepError(step.Name, "input processing", CategoryIO, fmt.Errorf("failed to create temp file for STDIN: %w", err))
+				p.logger.Errorf("%v", err)
 				return err
 			}
+			tmpPath := tmpFile.Name()
+			defer os.Remove(tmpPath)
+			p.trustPath(tmpPath)
+
+			if _, err := tmpFile.WriteString(p.lastOutput); err != nil {
+				tmpFile.Close()
+				p.spinner.Stop()
+				err = newStepError(step.Name, "input processing", CategoryIO, fmt.Errorf("failed to write STDIN to temp file: %w", err))
+				p.logger.Errorf("%v", err)
+				return err
+			}
+			tmpFile.Close()
 			p.spinner.Stop()
+
+			// Update inputs to use the temporary file
+			inputs = []string{tmpPath}
 		}
+	}
 
-		// Skip model validation and provider configuration if model is NA
-		if !(len(modelNames) == 1 && modelNames[0] == "NA") {
-			// Validate model for this step
-			p.spinner.Start("Validating model configuration")
-			if err := p.validateModel(modelNames, inputs); err != nil {
+	// Resolve references to the output of earlier steps, uploaded files and files read as base64
+	for i, input := range inputs {
+		switch {
+		case strings.HasPrefix(input, "step:"):
+			tmpPath, err := p.writeStepOutput(strings.TrimPrefix(input, "step:"))
+			if err != nil {
+				p.spinner.Stop()
+				err = newStepError(step.Name, "input processing", CategoryIO, err)
+				p.logger.Errorf("%v", err)
+				return err
+			}
+			defer os.Remove(tmpPath)
+			inputs[i] = tmpPath
+		case strings.HasPrefix(input, "upload:"):
+			path, err := p.resolveUpload(strings.TrimPrefix(input, "upload:"))
+			if err != nil {
+				p.spinner.Stop()
+				err = newStepError(step.Name, "input processing", CategoryIO, err)
+				p.logger.Errorf("%v", err)
+				return err
+			}
+			inputs[i] = path
+		case strings.HasSuffix(input, base64Suffix):
+			path, _ := cutBase64(input)
+			tmpPath, err := p.encodeInput(path)
+			if err != nil {
 				p.spinner.Stop()
-				err = fmt.Errorf("model validation error in step %s: %w", step.Name, err)
-				fmt.Printf("Error: %v\n", err)
+				err = newStepError(step.Name, "input processing", CategoryIO, err)
+				p.logger.Errorf("%v", err)
 				return err
 			}
+			defer os.Remove(tmpPath)
+			inputs[i] = tmpPath
+		}
+	}
+
+	// Process inputs for this step
+	if len(inputs) > 0 {
+		p.spinner.Start("Processing input files")
+		p.debugf("Processing inputs for step %s...", step.Name)
+		if err := p.processInputs(inputs); err != nil {
 			p.spinner.Stop()
+			err = newStepError(step.Name, "input processing", CategoryIO, err)
+			p.logger.Errorf("%v", err)
+			return err
+		}
+		p.spinner.Stop()
+
+		for _, inputItem := range p.handler.GetInputs() {
+			result.InputBytes += len(inputItem.Contents)
+		}
+	}
 
-			// Configure providers if needed
-			p.spinner.Start("Configuring model providers")
-			if err := p.configureProviders(); err != nil {
+	// Skip model validation and provider configuration if model is NA or the step is a transform
+	if !(len(modelNames) == 1 && modelNames[0] == "NA") && step.Config.Type != transformStepType {
+		// Validate model for this step, including any fallback models
+		p.spinner.Start("Validating model configuration")
+		if err := p.validateModel(append(modelNames, fallbacks...), inputs); err != nil {
+			p.spinner.Stop()
+			err = newStepError(step.Name, "model validation", CategoryValidation, err)
+			p.logger.Errorf("%v", err)
+			return err
+		}
+		// The judge model only sees the answers, not the step's inputs
+		if judge != "" {
+			if err := p.validateModel([]string{judge}, nil); err != nil {
 				p.spinner.Stop()
-				err = fmt.Errorf("provider configuration error in step %s: %w", step.Name, err)
-				fmt.Printf("Error: %v\n", err)
+				err = newStepError(step.Name, "judge model validation", CategoryValidation, err)
+				p.logger.Errorf("%v", err)
 				return err
 			}
+		}
+		p.spinner.Stop()
+
+		// Configure providers if needed
+		p.spinner.Start("Configuring model providers")
+		if err := p.configureProviders(); err != nil {
 			p.spinner.Stop()
+			err = newStepError(step.Name, "provider configuration", CategoryProvider, err)
+			p.logger.Errorf("%v", err)
+			return err
 		}
+		p.spinner.Stop()
+	}
 
+	var response string
+	if step.Config.Type == transformStepType {
+		// Transform steps rewrite their CSV input without calling a model
+		p.spinner.Start("Transforming data")
+		transformed, err := p.runTransform(step.Config.Transform)
+		if err != nil {
+			p.spinner.Stop()
+			err = newStepError(step.Name, "transform", CategoryValidation, err)
+			p.logger.Errorf("%v", err)
+			return err
+		}
+		p.spinner.Stop()
+		p.lastModel = "NA"
+		response = transformed
+	} else {
 		// Process actions for this step
 		p.spinner.Start("Processing actions")
 		// Substitute variables in actions
-		substitutedActions := make([]string, len(actions))
-		for i, action := range actions {
-			substitutedActions[i] = p.substituteVariables(action)
-		}
-		response, err := p.processActions(modelNames, substitutedActions)
+		substitutedActions := p.substituteAll(actions)
+		stepConfig := step.Config
+		stepConfig.Fallback = fallbacks
+		stepConfig.Judge = judge
+		stepConfig.System = p.substituteVariables(stepConfig.System)
+		p.streamPaths = p.streamOutputs(modelNames, stepConfig)
+		defer p.removePartialOutputs()
+		processed, err := p.processActions(modelNames, substitutedActions, stepConfig)
 		if err != nil {
 			p.spinner.Stop()
-			err = fmt.Errorf("action processing error in step %s: %w", step.Name, err)
-			fmt.Printf("Error: %v\n", err)
+			err = newStepError(step.Name, "action processing", actionErrorCategory(err), err)
+			p.logger.Errorf("%v", err)
 			return err
 		}
 		p.spinner.Stop()
+		response = processed
+	}
 
-		// Store the response for potential use as STDIN in next step
-		p.lastOutput = response
+	response = p.postProcess(response, step.Config)
 
-		// Handle output for this step
-		p.spinner.Start("Handling output")
-
-		// Handle output based on type
-		var handled bool
-		switch v := step.Config.Output.(type) {
-		case map[string]interface{}:
-			if _, hasDB := v["database"]; hasDB {
-				if err := p.handleDatabaseOutput(response, v); err != nil {
-					p.spinner.Stop()
-					err = fmt.Errorf("database output error in step %s: %w", step.Name, err)
-					fmt.Printf("Error: %v\n", err)
-					return err
-				}
-				handled = true
-			}
+	result.Model = p.lastModel
+	result.OutputBytes = len(response)
+	if reporter, ok := p.GetModelProvider(p.lastModel).(models.UsageReporter); ok {
+		usage := reporter.LastUsage()
+		result.InputTokens = usage.InputTokens
+		result.OutputTokens = usage.OutputTokens
+	}
+	if reporter, ok := p.GetModelProvider(p.lastModel).(models.RateLimitReporter); ok {
+		p.debugf("Rate limit after step %s: %s", step.Name, reporter.LastRateLimit())
+	}
+
+	// Store the response for potential use as STDIN in next step or as a step: input later on
+	p.lastOutput = response
+	p.outputs[step.Name] = response
+
+	// Handle output for this step
+	p.spinner.Start("Handling output")
+
+	// Write the response to every output destination, including any database and HTTP outputs
+	destinations, databaseOutputs, httpOutputs := p.splitOutputs(step.Config.Output)
+	for _, dbOutput := range databaseOutputs {
+		if err := p.handleDatabaseOutput(response, dbOutput); err != nil {
+			p.spinner.Stop()
+			err = newStepError(step.Name, "database output", CategoryIO, err)
+			p.logger.Errorf("%v", err)
+			return err
+		}
+	}
+	for _, httpOutput := range httpOutputs {
+		if err := p.handleHTTPOutput(response, httpOutput); err != nil {
+			p.spinner.Stop()
+			err = newStepError(step.Name, "http output", CategoryIO, err)
+			p.logger.Errorf("%v", err)
+			return err
 		}
+	}
 
-		// Handle regular output if not already handled
-		if !handled {
-			outputs := p.NormalizeStringSlice(step.Config.Output)
-			if err := p.handleOutput(modelNames[0], response, outputs); err != nil {
-				p.spinner.Stop()
-				err = fmt.Errorf("output handling error in step %s: %w", step.Name, err)
-				fmt.Printf("Error: %v\n", err)
-				return err
-			}
+	if len(destinations) > 0 {
+		if err := p.handleOutput(p.lastModel, response, p.substituteAll(destinations)); err != nil {
+			p.spinner.Stop()
+			err = newStepError(step.Name, "output handling", CategoryIO, err)
+			p.logger.Errorf("%v", err)
+			return err
 		}
+		// Database outputs replace the last output with a row count; keep the response for chaining
+		p.lastOutput = response
+	}
 
-		p.spinner.Stop()
+	p.spinner.Stop()
 
-		// Clear the handler's contents for the next step
-		p.handler = input.NewHandler()
+	return nil
+}
+
+// writeStepOutput writes the captured output of an earlier step to a temporary file and returns its path
+func (p *Processor) writeStepOutput(stepName string) (string, error) {
+	output, ok := p.outputs[stepName]
+	if !ok {
+		if p.skipped[stepName] {
+			return "", fmt.Errorf("step:%s refers to a step that was skipped", stepName)
+		}
+		return "", fmt.Errorf("step:%s refers to a step that has not produced output yet", stepName)
 	}
 
-	p.debugf("DSL processing completed successfully")
-	return nil
+	tmpFile, err := os.CreateTemp("", "comanda-step-*.txt")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file for output of step %s: %w", stepName, err)
+	}
+	defer tmpFile.Close()
+
+	if _, err := tmpFile.WriteString(output); err != nil {
+		os.Remove(tmpFile.Name())
+		return "", fmt.Errorf("failed to write output of step %s to temp file: %w", stepName, err)
+	}
+
+	p.trustPath(tmpFile.Name())
+	p.debugf("Resolved step:%s to %d characters of output", stepName, len(output))
+	return tmpFile.Name(), nil
+}
+
+// resolveUpload returns the file stored for an uploaded file handle
+func (p *Processor) resolveUpload(handle string) (string, error) {
+	if p.uploadResolver == nil {
+		return "", fmt.Errorf("upload:%s can only be used in workflows run by the server", handle)
+	}
+	path, err := p.uploadResolver(handle)
+	if err != nil {
+		return "", err
+	}
+	// Uploads are managed by the server, so they are not subject to the sandbox
+	p.trustPath(path)
+	p.debugf("Resolved upload %s to %s", handle, path)
+	return path, nil
 }
 
 // GetProcessedInputs returns all processed input contents
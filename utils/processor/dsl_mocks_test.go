@@ -13,19 +13,22 @@ func init() {
 	originalDetectProvider = models.DetectProvider
 
 	// Override with test version
-	models.DetectProvider = func(modelName string) models.Provider {
-		providers := []models.Provider{
-			NewMockProvider("openai"),
-			NewMockProvider("anthropic"),
-		}
+	models.DetectProvider = mockDetectProvider
+}
 
-		for _, provider := range providers {
-			if provider.SupportsModel(modelName) {
-				return provider
-			}
+// mockDetectProvider detects mock providers for the models they support
+func mockDetectProvider(modelName string) models.Provider {
+	providers := []models.Provider{
+		NewMockProvider("openai"),
+		NewMockProvider("anthropic"),
+	}
+
+	for _, provider := range providers {
+		if provider.SupportsModel(modelName) {
+			return provider
 		}
-		return nil
 	}
+	return nil
 }
 
 // Restore the original DetectProvider function
@@ -105,6 +108,17 @@ func (m *MockProvider) SendPromptWithFile(model, prompt string, file models.File
 	return fmt.Sprintf("mock response for file: %s", file.Path), nil
 }
 
+func (m *MockProvider) SendPromptStream(model, prompt string, handler models.StreamHandler) (string, error) {
+	return models.SendPromptBlocking(m, model, prompt, handler)
+}
+
 func (m *MockProvider) SetVerbose(verbose bool) {
 	m.verbose = verbose
 }
+
+func (m *MockProvider) SendPromptWithCache(model, cachedContext, prompt string) (string, error) {
+	if !m.configured {
+		return "", fmt.Errorf("provider not configured")
+	}
+	return fmt.Sprintf("mock cached response (%d context chars)", len(cachedContext)), nil
+}
@@ -3,6 +3,8 @@ package processor
 import (
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
@@ -376,6 +378,7 @@ func TestIsURL(t *testing.T) {
 
 func TestFetchURL(t *testing.T) {
 	processor := NewProcessor(&DSLConfig{}, createTestEnvConfig(), false)
+	defer processor.removeTempDir()
 
 	// Create test server
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -455,3 +458,294 @@ func TestFetchURL(t *testing.T) {
 		})
 	}
 }
+
+func TestFetchURLReusedWithinRun(t *testing.T) {
+	fetches := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("release notes"))
+	}))
+	defer ts.Close()
+
+	resultFile := filepath.Join(t.TempDir(), "result.txt")
+	config := &DSLConfig{Steps: []Step{
+		{Name: "first", Config: StepConfig{Input: ts.URL + "/notes", Model: "NA", Action: "NA", Output: "STDOUT"}},
+		{Name: "second", Config: StepConfig{Input: ts.URL + "/notes", Model: "NA", Action: "NA", Output: resultFile}},
+	}}
+	processor := NewProcessor(config, createTestEnvConfig(), false)
+	if err := processor.Process(); err != nil {
+		t.Fatalf("Process() unexpected error: %v", err)
+	}
+
+	if fetches != 1 {
+		t.Errorf("URL fetched %d times, want 1", fetches)
+	}
+	got, err := os.ReadFile(resultFile)
+	if err != nil || !strings.Contains(string(got), "release notes") {
+		t.Errorf("second step output = %q, %v, want the fetched content", got, err)
+	}
+	if len(processor.externalInput) != 1 {
+		t.Fatalf("fetched files = %v, want one", processor.externalInput)
+	}
+	for path := range processor.externalInput {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("fetched file %s still exists after the run", path)
+		}
+	}
+}
+
+func TestProcessStepOutputInput(t *testing.T) {
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.txt")
+	if err := os.WriteFile(sourceFile, []byte("introductions"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	resultFile := filepath.Join(tmpDir, "result.txt")
+
+	tests := []struct {
+		name        string
+		reference   string
+		expectError bool
+	}{
+		{
+			name:      "reference to earlier step",
+			reference: "step:analyze_introductions",
+		},
+		{
+			name:        "reference to unknown step",
+			reference:   "step:missing",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DSLConfig{
+				Steps: []Step{
+					{
+						Name: "analyze_introductions",
+						Config: StepConfig{
+							Input:  []string{sourceFile},
+							Model:  []string{"NA"},
+							Action: []string{"pass"},
+							Output: []string{"STDOUT"},
+						},
+					},
+					{
+						Name: "unrelated",
+						Config: StepConfig{
+							Input:  []string{"NA"},
+							Model:  []string{"NA"},
+							Action: []string{"pass"},
+							Output: []string{"STDOUT"},
+						},
+					},
+					{
+						Name: "summarize",
+						Config: StepConfig{
+							Input:  []string{tt.reference},
+							Model:  []string{"NA"},
+							Action: []string{"pass"},
+							Output: []string{resultFile},
+						},
+					},
+				},
+			}
+
+			processor := NewProcessor(&config, createTestEnvConfig(), false)
+			err := processor.Process()
+			if tt.expectError {
+				if err == nil {
+					t.Error("Process() expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Process() unexpected error: %v", err)
+			}
+
+			content, err := os.ReadFile(resultFile)
+			if err != nil {
+				t.Fatalf("Failed to read result file: %v", err)
+			}
+			if string(content) != "introductions" {
+				t.Errorf("step input resolved to %q, want %q", string(content), "introductions")
+			}
+		})
+	}
+}
+
+func TestSubstituteVariables(t *testing.T) {
+	processor := NewProcessor(&DSLConfig{
+		Vars: map[string]string{
+			"model":  "gpt-4o-mini",
+			"region": "us-east",
+		},
+	}, createTestEnvConfig(), false)
+	processor.variables["region"] = "eu-west" // Already set values take precedence
+	processor.variables["model_name"] = "claude"
+	processor.loadWorkflowVariables()
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"$model", "gpt-4o-mini"},
+		{"Report for {{ region }}", "Report for eu-west"},
+		{"{{region}} and $region", "eu-west and eu-west"},
+		{"$model_name", "claude"},
+		{"{{ unknown }}", "{{ unknown }}"},
+	}
+
+	for _, tt := range tests {
+		if got := processor.substituteVariables(tt.input); got != tt.want {
+			t.Errorf("substituteVariables(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestProcessOnError(t *testing.T) {
+	tests := []struct {
+		name         string
+		onError      string
+		handlerInput string
+		expectError  string
+		wantSteps    []string
+	}{
+		{
+			name:         "handler recovers",
+			onError:      "notify",
+			handlerInput: "NA",
+			wantSteps:    []string{"fetch", "notify", "finish"},
+		},
+		{
+			name:         "handler fails",
+			onError:      "notify",
+			handlerInput: "also-missing.txt",
+			expectError:  "error handler notify failed while handling step fetch",
+			wantSteps:    []string{"fetch", "notify"},
+		},
+		{
+			name:        "unknown handler",
+			onError:     "missing",
+			expectError: "on_error step 'missing' not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DSLConfig{
+				Steps: []Step{
+					{
+						Name: "fetch",
+						Config: StepConfig{
+							Input:   []string{"nonexistent.txt"},
+							Model:   []string{"NA"},
+							Action:  []string{"pass"},
+							Output:  []string{"STDOUT"},
+							OnError: tt.onError,
+						},
+					},
+					{
+						Name: "notify",
+						Config: StepConfig{
+							Input:  []string{tt.handlerInput},
+							Model:  []string{"NA"},
+							Action: []string{"report $error"},
+							Output: []string{"STDOUT"},
+						},
+					},
+					{
+						Name: "finish",
+						Config: StepConfig{
+							Input:  []string{"NA"},
+							Model:  []string{"NA"},
+							Action: []string{"pass"},
+							Output: []string{"STDOUT"},
+						},
+					},
+				},
+			}
+
+			processor := NewProcessor(&config, createTestEnvConfig(), false)
+			err := processor.Process()
+			if tt.expectError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectError) {
+					t.Fatalf("Process() error = %v, want error containing %q", err, tt.expectError)
+				}
+			} else if err != nil {
+				t.Fatalf("Process() unexpected error: %v", err)
+			}
+
+			var steps []string
+			for _, result := range processor.StepResults() {
+				steps = append(steps, result.Name)
+			}
+			if strings.Join(steps, ",") != strings.Join(tt.wantSteps, ",") {
+				t.Errorf("Process() ran steps %v, want %v", steps, tt.wantSteps)
+			}
+
+			if tt.wantSteps == nil {
+				return
+			}
+			if recovered := processor.StepResults()[0].RecoveredBy; recovered != "notify" {
+				t.Errorf("fetch RecoveredBy = %q, want %q", recovered, "notify")
+			}
+			if !strings.Contains(processor.variables["error"], "nonexistent.txt") {
+				t.Errorf("$error = %q, want the fetch step's error", processor.variables["error"])
+			}
+			if success := processor.Report().Success; success != (tt.expectError == "") {
+				t.Errorf("Report() Success = %v, want %v", success, tt.expectError == "")
+			}
+		})
+	}
+}
+
+func TestProcessOnErrorDropsFailedStepInputs(t *testing.T) {
+	workDir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working directory: %v", err)
+	}
+	if err := os.Chdir(workDir); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+	defer os.Chdir(wd)
+
+	if err := os.WriteFile("a.txt", []byte("contents of a"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	config := DSLConfig{
+		Steps: []Step{
+			{
+				Name: "fetch",
+				Config: StepConfig{
+					Input:   []string{"a.txt", "missing.txt"},
+					Model:   []string{"NA"},
+					Action:  []string{"pass"},
+					Output:  []string{"STDOUT"},
+					OnError: "notify",
+				},
+			},
+			{
+				Name: "notify",
+				Config: StepConfig{
+					Input:  []string{"NA"},
+					Model:  []string{"NA"},
+					Action: []string{"report $error"},
+					Output: []string{"STDOUT"},
+				},
+			},
+		},
+	}
+
+	processor := NewProcessor(&config, createTestEnvConfig(), false)
+	if err := processor.Process(); err != nil {
+		t.Fatalf("Process() unexpected error: %v", err)
+	}
+	if strings.Contains(processor.LastOutput(), "contents of a") {
+		t.Errorf("error handler output = %q, want none of the failed step's inputs", processor.LastOutput())
+	}
+}
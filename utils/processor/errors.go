@@ -0,0 +1,103 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/url"
+)
+
+// ErrorCategory groups step failures by their cause
+type ErrorCategory string
+
+// Step failure categories
+const (
+	CategoryValidation ErrorCategory = "validation" // The workflow or a step's settings are invalid
+	CategoryProvider   ErrorCategory = "provider"   // A model provider could not be configured or its call failed
+	CategoryIO         ErrorCategory = "io"         // Reading an input or writing an output failed
+	CategoryTimeout    ErrorCategory = "timeout"    // A call or request ran out of time
+	CategoryBudget     ErrorCategory = "budget"     // The run's cost limit was reached
+)
+
+// StepError is the error returned for a failed step. It names the step, the stage of the step that
+// failed and the category of the failure, and wraps the underlying error.
+type StepError struct {
+	Step     string
+	Stage    string // e.g. "input processing"
+	Category ErrorCategory
+	Err      error
+}
+
+func (e *StepError) Error() string {
+	return fmt.Sprintf("%s error in step %s: %v", e.Stage, e.Step, e.Err)
+}
+
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// providerError marks a failed model provider call, as opposed to a local failure while preparing
+// the call
+type providerError struct {
+	err error
+}
+
+func (e *providerError) Error() string {
+	return e.err.Error()
+}
+
+func (e *providerError) Unwrap() error {
+	return e.err
+}
+
+// providerCall marks the error of a model provider call as a provider error
+func providerCall(response string, err error) (string, error) {
+	if err != nil {
+		return response, &providerError{err: err}
+	}
+	return response, nil
+}
+
+// actionErrorCategory returns the category of a failed action by its cause: provider when the model
+// provider's call failed, io when a prompt file or URL could not be read, and validation when the
+// step cannot be carried out as configured, such as a prompt too large for the model's context
+// window or a response that never matched the step's format
+func actionErrorCategory(err error) ErrorCategory {
+	var provErr *providerError
+	var pathErr *fs.PathError
+	var urlErr *url.Error
+	switch {
+	case errors.As(err, &provErr):
+		return CategoryProvider
+	case errors.As(err, &pathErr), errors.As(err, &urlErr):
+		return CategoryIO
+	}
+	return CategoryValidation
+}
+
+// newStepError wraps a step failure. Cost limit, timeout and sandbox failures are given their own
+// category whatever stage they happen in.
+func newStepError(step, stage string, category ErrorCategory, err error) *StepError {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, ErrBudgetExceeded):
+		category = CategoryBudget
+	case errors.Is(err, errOutsideSandbox):
+		category = CategoryValidation
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		category = CategoryTimeout
+	}
+	return &StepError{Step: step, Stage: stage, Category: category, Err: err}
+}
+
+// CategoryOf returns the category of a step failure, or an empty category for errors that did not
+// come from a step
+func CategoryOf(err error) ErrorCategory {
+	var stepErr *StepError
+	if errors.As(err, &stepErr) {
+		return stepErr.Category
+	}
+	return ""
+}
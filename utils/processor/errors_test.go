@@ -0,0 +1,80 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStepErrorCategory(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ErrorCategory
+	}{
+		{"stage category", newStepError("draft", "action processing", CategoryProvider, errors.New("bad gateway")), CategoryProvider},
+		{"cost limit", newStepError("draft", "action processing", CategoryProvider, fmt.Errorf("step draft: %w", ErrBudgetExceeded)), CategoryBudget},
+		{"timeout", newStepError("draft", "http output", CategoryIO, fmt.Errorf("post failed: %w", context.DeadlineExceeded)), CategoryTimeout},
+		{"wrapped step error", fmt.Errorf("error handler failed: %w", newStepError("fix", "output handling", CategoryIO, errors.New("disk full"))), CategoryIO},
+		{"invalid step", NewProcessor(&DSLConfig{}, createTestEnvConfig(), false).ValidateStep(Step{Name: "draft"}), CategoryValidation},
+		{"sandbox denial", newStepError("draft", "input processing", CategoryIO, fmt.Errorf("path /etc/passwd is %w", errOutsideSandbox)), CategoryValidation},
+		{"not a step error", errors.New("interrupted"), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CategoryOf(tt.err); got != tt.want {
+				t.Errorf("CategoryOf() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	err := newStepError("draft", "input processing", CategoryIO, errors.New("file not found"))
+	if err.Error() != "input processing error in step draft: file not found" {
+		t.Errorf("Error() = %q", err.Error())
+	}
+}
+
+func TestActionErrorCategory(t *testing.T) {
+	sandbox := t.TempDir()
+	tests := []struct {
+		name       string
+		actions    []string
+		stepConfig StepConfig
+		failing    bool
+		want       ErrorCategory
+	}{
+		{name: "provider call fails", actions: []string{"Summarize"}, failing: true, want: CategoryProvider},
+		{name: "missing prompt file", actions: []string{filepath.Join(sandbox, "missing.md")}, want: CategoryIO},
+		{name: "prompt file outside the sandbox", actions: []string{filepath.Join(t.TempDir(), "prompt.md")}, want: CategoryValidation},
+		{name: "prompt too large for the context window", actions: []string{strings.Repeat("word ", 200000)}, want: CategoryValidation},
+		{name: "response in the wrong format", actions: []string{"Summarize"}, stepConfig: StepConfig{OutputFormat: formatJSON}, want: CategoryValidation},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := NewProcessor(&DSLConfig{}, createTestEnvConfig(), false)
+			processor.SetSandbox([]string{sandbox})
+			if err := processor.resolveSandbox(); err != nil {
+				t.Fatalf("resolveSandbox() unexpected error: %v", err)
+			}
+			provider := NewMockProvider("openai")
+			provider.Configure("test-key")
+			processor.providers["openai"] = provider
+			if tt.failing {
+				processor.providers["openai"] = failingProvider{Provider: provider}
+			}
+
+			_, err := processor.processActions([]string{"gpt-4o"}, tt.actions, tt.stepConfig)
+			if err == nil {
+				t.Fatal("processActions() expected an error")
+			}
+			if got := actionErrorCategory(err); got != tt.want {
+				t.Errorf("actionErrorCategory(%v) = %q, want %q", err, got, tt.want)
+			}
+		})
+	}
+}
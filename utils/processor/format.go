@@ -0,0 +1,261 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/kris-hansen/comanda/utils/fileutil"
+	"github.com/kris-hansen/comanda/utils/models"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	formatMarkdown = "markdown"
+	formatYAML     = "yaml"
+	formatJSON     = "json"
+)
+
+// fenceLanguages lists the code fence languages stripped from a response wrapped in a single fence,
+// by output format. An unlabeled fence is always stripped.
+var fenceLanguages = map[string][]string{
+	formatMarkdown: {"markdown", "md"},
+	formatYAML:     {"yaml", "yml"},
+	formatJSON:     {"json"},
+}
+
+// defaultSelfCorrectAttempts is the number of calls self_correct makes when max_attempts is not set
+const defaultSelfCorrectAttempts = 3
+
+// defaultSchemaName is the schema name sent when response_format does not set one
+const defaultSchemaName = "response"
+
+// schemaNamePattern restricts schema names to the characters providers accept
+var schemaNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// openingFencePattern matches the first line of a fenced response, capturing its language
+var openingFencePattern = regexp.MustCompile("^```+\\s*([A-Za-z0-9_+-]*)\\s*$")
+
+// validateOutputFormat checks the step's output_format and format_retries settings
+func validateOutputFormat(stepConfig StepConfig) error {
+	if _, ok := fenceLanguages[stepConfig.OutputFormat]; !ok && stepConfig.OutputFormat != "" {
+		return fmt.Errorf("output_format must be markdown, yaml, or json, got %s", stepConfig.OutputFormat)
+	}
+	if stepConfig.FormatRetries < 0 {
+		return fmt.Errorf("format_retries must be a positive number")
+	}
+	if stepConfig.FormatRetries > 0 && stepConfig.OutputFormat != formatYAML && stepConfig.OutputFormat != formatJSON {
+		return fmt.Errorf("format_retries requires output_format yaml or json")
+	}
+	if stepConfig.SelfCorrect != nil {
+		if stepConfig.SelfCorrect.MaxAttempts < 0 {
+			return fmt.Errorf("self_correct max_attempts must be a positive number")
+		}
+		if stepConfig.OutputFormat != formatYAML && stepConfig.OutputFormat != formatJSON {
+			return fmt.Errorf("self_correct requires output_format yaml or json")
+		}
+		if stepConfig.FormatRetries > 0 {
+			return fmt.Errorf("self_correct cannot be used with format_retries")
+		}
+	}
+	return nil
+}
+
+// validateResponseFormat checks the step's response_format setting
+func validateResponseFormat(stepConfig StepConfig) error {
+	format := stepConfig.ResponseFormat
+	if format == nil {
+		return nil
+	}
+	if format.Name != "" && !schemaNamePattern.MatchString(format.Name) {
+		return fmt.Errorf("response_format name may only contain letters, digits, underscores and dashes, got %s", format.Name)
+	}
+	switch format.Type {
+	case models.ResponseFormatJSONObject:
+		if format.Schema != nil {
+			return fmt.Errorf("response_format schema requires type json_schema")
+		}
+		if format.Strict {
+			return fmt.Errorf("response_format strict requires type json_schema")
+		}
+	case models.ResponseFormatJSONSchema:
+		switch schema := format.Schema.(type) {
+		case map[string]interface{}:
+		case string:
+			if schema == "" {
+				return fmt.Errorf("response_format type json_schema requires a schema")
+			}
+		case nil:
+			return fmt.Errorf("response_format type json_schema requires a schema")
+		default:
+			return fmt.Errorf("response_format schema must be a mapping or the path of a schema file")
+		}
+	default:
+		return fmt.Errorf("response_format type must be json_object or json_schema, got %s", format.Type)
+	}
+	return nil
+}
+
+// applyResponseFormat passes the step's response_format to the provider, loading the schema from
+// its file if needed. It is applied on every call so a format from an earlier step does not carry over.
+func (p *Processor) applyResponseFormat(provider models.Provider, stepConfig StepConfig) error {
+	configurable, ok := provider.(models.ResponseFormatConfigurable)
+	if !ok {
+		if stepConfig.ResponseFormat != nil {
+			p.debugf("Provider %s does not support response_format; ignoring it", provider.Name())
+		}
+		return nil
+	}
+	if stepConfig.ResponseFormat == nil {
+		configurable.SetResponseFormat(nil)
+		return nil
+	}
+
+	format := &models.ResponseFormat{
+		Type:   stepConfig.ResponseFormat.Type,
+		Name:   stepConfig.ResponseFormat.Name,
+		Strict: stepConfig.ResponseFormat.Strict,
+	}
+	if format.Name == "" {
+		format.Name = defaultSchemaName
+	}
+	switch schema := stepConfig.ResponseFormat.Schema.(type) {
+	case map[string]interface{}:
+		format.Schema = schema
+	case string:
+		loaded, err := p.loadSchema(p.substituteVariables(schema))
+		if err != nil {
+			return err
+		}
+		format.Schema = loaded
+	}
+	configurable.SetResponseFormat(format)
+	return nil
+}
+
+// loadSchema reads a JSON or YAML schema file
+func (p *Processor) loadSchema(path string) (map[string]interface{}, error) {
+	if err := p.checkSandbox(path); err != nil {
+		return nil, err
+	}
+	data, err := fileutil.SafeReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema file %s: %w", path, err)
+	}
+	// JSON is valid YAML, so one decoder handles both
+	var schema map[string]interface{}
+	if err := yaml.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("failed to parse schema file %s: %w", path, err)
+	}
+	if schema == nil {
+		return nil, fmt.Errorf("schema file %s is empty", path)
+	}
+	return schema, nil
+}
+
+// stripCodeFence removes a code fence wrapped around the whole response when its language is
+// unlabeled or one of languages. Responses with text outside the fence are returned unchanged.
+func stripCodeFence(text string, languages []string) string {
+	trimmed := strings.TrimSpace(text)
+	lines := strings.Split(trimmed, "\n")
+	if len(lines) < 2 || strings.TrimSpace(lines[len(lines)-1]) != strings.Repeat("`", fenceLength(lines[0])) {
+		return text
+	}
+	match := openingFencePattern.FindStringSubmatch(strings.TrimSpace(lines[0]))
+	if match == nil {
+		return text
+	}
+	if language := strings.ToLower(match[1]); language != "" {
+		known := false
+		for _, candidate := range languages {
+			if language == candidate {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return text
+		}
+	}
+	return strings.Join(lines[1:len(lines)-1], "\n")
+}
+
+// fenceLength returns the number of backticks opening a line
+func fenceLength(line string) int {
+	line = strings.TrimSpace(line)
+	return len(line) - len(strings.TrimLeft(line, "`"))
+}
+
+// formatOutput applies the step's output_format to a response. Code fences wrapped around the
+// whole response are removed, and YAML and JSON responses must parse to a mapping or list.
+func formatOutput(output, format string) (string, error) {
+	languages, ok := fenceLanguages[format]
+	if !ok {
+		return output, nil
+	}
+	output = stripCodeFence(output, languages)
+
+	switch format {
+	case formatJSON:
+		var value interface{}
+		if err := json.Unmarshal([]byte(output), &value); err != nil {
+			return "", fmt.Errorf("invalid JSON: %w", err)
+		}
+		switch value.(type) {
+		case map[string]interface{}, []interface{}:
+		default:
+			return "", fmt.Errorf("invalid JSON: expected an object or array")
+		}
+	case formatYAML:
+		var node yaml.Node
+		if err := yaml.Unmarshal([]byte(output), &node); err != nil {
+			return "", fmt.Errorf("invalid YAML: %w", err)
+		}
+		if len(node.Content) == 0 || (node.Content[0].Kind != yaml.MappingNode && node.Content[0].Kind != yaml.SequenceNode) {
+			return "", fmt.Errorf("invalid YAML: expected a mapping or list")
+		}
+	}
+	return output, nil
+}
+
+// runFormattedActions runs the step's actions and applies its output_format. A response that
+// fails validation is requested again up to format_retries times. With self_correct, the model is
+// also told what was wrong with its previous response.
+func (p *Processor) runFormattedActions(modelName string, actions []string, stepConfig StepConfig) (string, error) {
+	attempts := 1 + stepConfig.FormatRetries
+	if stepConfig.SelfCorrect != nil {
+		attempts = stepConfig.SelfCorrect.MaxAttempts
+		if attempts == 0 {
+			attempts = defaultSelfCorrectAttempts
+		}
+	}
+
+	prompt := actions
+	var formatErr error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		response, err := p.runActions(modelName, prompt, stepConfig)
+		if err != nil {
+			return "", err
+		}
+		formatted, err := formatOutput(response, stepConfig.OutputFormat)
+		if err == nil {
+			return formatted, nil
+		}
+		formatErr = err
+		if attempt < attempts {
+			p.warnf("Model %s returned invalid %s output (attempt %d of %d): %v", modelName, stepConfig.OutputFormat, attempt, attempts, err)
+			if stepConfig.SelfCorrect != nil {
+				prompt = append(actions[:len(actions):len(actions)], correctionPrompt(response, stepConfig.OutputFormat, err))
+			}
+		}
+	}
+	return "", fmt.Errorf("model %s returned invalid %s output: %w", modelName, stepConfig.OutputFormat, formatErr)
+}
+
+// correctionPrompt asks the model to fix a response that failed the step's output_format check
+func correctionPrompt(response, format string, formatErr error) string {
+	name := strings.ToUpper(format)
+	return fmt.Sprintf("Your previous response to this task was rejected: %v\n\nPrevious response:\n%s\n\n"+
+		"Answer the task again, replying with valid %s only.", formatErr, response, name)
+}
@@ -0,0 +1,231 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kris-hansen/comanda/utils/models"
+)
+
+func TestFormatOutput(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		format  string
+		want    string
+		wantErr bool
+	}{
+		{"json in fence", "```json\n{\"a\": 1}\n```", formatJSON, "{\"a\": 1}", false},
+		{"json unlabeled fence", "```\n[1, 2]\n```\n", formatJSON, "[1, 2]", false},
+		{"plain json", "{\"a\": 1}", formatJSON, "{\"a\": 1}", false},
+		{"invalid json", "Here you go: {\"a\": 1}", formatJSON, "", true},
+		{"json scalar", "\"just text\"", formatJSON, "", true},
+		{"yaml in fence", "```yaml\nname: report\nitems: [1, 2]\n```", formatYAML, "name: report\nitems: [1, 2]", false},
+		{"yaml scalar", "Sorry, I cannot help with that.", formatYAML, "", true},
+		{"invalid yaml", "name: [unclosed", formatYAML, "", true},
+		{"markdown in fence", "```markdown\n# Title\n\n```go\nx := 1\n```\n```", formatMarkdown, "# Title\n\n```go\nx := 1\n```", false},
+		{"markdown code block kept", "```go\nx := 1\n```", formatMarkdown, "```go\nx := 1\n```", false},
+		{"markdown text outside fence", "Intro\n```\ncode\n```", formatMarkdown, "Intro\n```\ncode\n```", false},
+		{"no format", "```json\n{}\n```", "", "```json\n{}\n```", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := formatOutput(tt.output, tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("formatOutput() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("formatOutput() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateOutputFormat(t *testing.T) {
+	tests := []struct {
+		config  StepConfig
+		wantErr string
+	}{
+		{StepConfig{OutputFormat: "json", FormatRetries: 2}, ""},
+		{StepConfig{OutputFormat: "markdown"}, ""},
+		{StepConfig{OutputFormat: "xml"}, "output_format must be"},
+		{StepConfig{OutputFormat: "markdown", FormatRetries: 1}, "format_retries requires"},
+		{StepConfig{OutputFormat: "json", FormatRetries: -1}, "format_retries must be"},
+		{StepConfig{OutputFormat: "yaml", SelfCorrect: &SelfCorrectConfig{}}, ""},
+		{StepConfig{SelfCorrect: &SelfCorrectConfig{MaxAttempts: 3}}, "self_correct requires"},
+		{StepConfig{OutputFormat: "json", SelfCorrect: &SelfCorrectConfig{MaxAttempts: -1}}, "max_attempts must be"},
+		{StepConfig{OutputFormat: "json", FormatRetries: 1, SelfCorrect: &SelfCorrectConfig{}}, "cannot be used with format_retries"},
+	}
+
+	for _, tt := range tests {
+		err := validateOutputFormat(tt.config)
+		if tt.wantErr == "" && err != nil {
+			t.Errorf("validateOutputFormat(%+v) unexpected error: %v", tt.config, err)
+		}
+		if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("validateOutputFormat(%+v) = %v, want error containing %q", tt.config, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateResponseFormat(t *testing.T) {
+	schema := map[string]interface{}{"type": "object"}
+	tests := []struct {
+		format  *ResponseFormatConfig
+		wantErr string
+	}{
+		{nil, ""},
+		{&ResponseFormatConfig{Type: "json_object"}, ""},
+		{&ResponseFormatConfig{Type: "json_schema", Name: "person", Schema: schema}, ""},
+		{&ResponseFormatConfig{Type: "json_schema", Schema: "schemas/person.json"}, ""},
+		{&ResponseFormatConfig{Type: "xml"}, "type must be json_object or json_schema"},
+		{&ResponseFormatConfig{Type: "json_schema"}, "requires a schema"},
+		{&ResponseFormatConfig{Type: "json_object", Schema: schema}, "schema requires type json_schema"},
+		{&ResponseFormatConfig{Type: "json_schema", Schema: schema, Strict: true}, ""},
+		{&ResponseFormatConfig{Type: "json_object", Strict: true}, "strict requires type json_schema"},
+		{&ResponseFormatConfig{Type: "json_schema", Schema: []interface{}{"a"}}, "must be a mapping or the path"},
+		{&ResponseFormatConfig{Type: "json_schema", Name: "my schema", Schema: schema}, "name may only contain"},
+	}
+
+	for _, tt := range tests {
+		err := validateResponseFormat(StepConfig{ResponseFormat: tt.format})
+		if tt.wantErr == "" && err != nil {
+			t.Errorf("validateResponseFormat(%+v) unexpected error: %v", tt.format, err)
+		}
+		if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("validateResponseFormat(%+v) = %v, want error containing %q", tt.format, err, tt.wantErr)
+		}
+	}
+}
+
+// formatProvider records the response format it is given
+type formatProvider struct {
+	*MockProvider
+	format *models.ResponseFormat
+}
+
+func (f *formatProvider) SetResponseFormat(format *models.ResponseFormat) {
+	f.format = format
+}
+
+func TestApplyResponseFormat(t *testing.T) {
+	schemaFile := filepath.Join(t.TempDir(), "person.json")
+	if err := os.WriteFile(schemaFile, []byte(`{"type": "object", "properties": {"name": {"type": "string"}}}`), 0644); err != nil {
+		t.Fatalf("Failed to create schema file: %v", err)
+	}
+	processor := NewProcessor(&DSLConfig{}, createTestEnvConfig(), false)
+	provider := &formatProvider{MockProvider: NewMockProvider("openai")}
+
+	// A schema file is loaded, and the schema name defaults to response
+	err := processor.applyResponseFormat(provider, StepConfig{ResponseFormat: &ResponseFormatConfig{Type: "json_schema", Schema: schemaFile}})
+	if err != nil {
+		t.Fatalf("applyResponseFormat() unexpected error: %v", err)
+	}
+	if provider.format == nil || provider.format.Name != "response" || provider.format.Schema["type"] != "object" {
+		t.Fatalf("format = %+v, want the schema from the file named response", provider.format)
+	}
+
+	// A format from an earlier step does not carry over
+	if err := processor.applyResponseFormat(provider, StepConfig{}); err != nil {
+		t.Fatalf("applyResponseFormat() unexpected error: %v", err)
+	}
+	if provider.format != nil {
+		t.Errorf("format = %+v, want none", provider.format)
+	}
+
+	missing := StepConfig{ResponseFormat: &ResponseFormatConfig{Type: "json_schema", Schema: filepath.Join(t.TempDir(), "missing.json")}}
+	if err := processor.applyResponseFormat(provider, missing); err == nil || !strings.Contains(err.Error(), "failed to read schema file") {
+		t.Errorf("applyResponseFormat() error = %v, want a schema file error", err)
+	}
+}
+
+// scriptedProvider returns a fixed sequence of responses, repeating the last one
+type scriptedProvider struct {
+	*MockProvider
+	responses []string
+	calls     int
+	prompts   []string
+}
+
+func (s *scriptedProvider) SendPrompt(model, prompt string) (string, error) {
+	s.prompts = append(s.prompts, prompt)
+	response := s.responses[len(s.responses)-1]
+	if s.calls < len(s.responses) {
+		response = s.responses[s.calls]
+	}
+	s.calls++
+	return response, nil
+}
+
+func TestProcessActionsFormatRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		retries   int
+		wantCalls int
+		wantErr   bool
+	}{
+		{"retries until valid", 2, 2, false},
+		{"no retries", 0, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := NewProcessor(&DSLConfig{}, createTestEnvConfig(), false)
+			provider := &scriptedProvider{
+				MockProvider: NewMockProvider("openai"),
+				responses:    []string{"Here is the JSON you asked for", "```json\n{\"ok\": true}\n```"},
+			}
+			processor.providers["openai"] = provider
+
+			stepConfig := StepConfig{OutputFormat: formatJSON, FormatRetries: tt.retries}
+			got, err := processor.processActions([]string{"gpt-4o"}, []string{"list the results"}, stepConfig)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("processActions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != "{\"ok\": true}" {
+				t.Errorf("processActions() = %q, want the unwrapped JSON", got)
+			}
+			if provider.calls != tt.wantCalls {
+				t.Errorf("provider called %d times, want %d", provider.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestProcessActionsSelfCorrect(t *testing.T) {
+	processor := NewProcessor(&DSLConfig{}, createTestEnvConfig(), false)
+	provider := &scriptedProvider{
+		MockProvider: NewMockProvider("openai"),
+		responses:    []string{"{\"ok\": true", "{\"ok\": true}"},
+	}
+	processor.providers["openai"] = provider
+
+	stepConfig := StepConfig{OutputFormat: formatJSON, SelfCorrect: &SelfCorrectConfig{}}
+	got, err := processor.processActions([]string{"gpt-4o"}, []string{"list the results"}, stepConfig)
+	if err != nil {
+		t.Fatalf("processActions() unexpected error: %v", err)
+	}
+	if got != "{\"ok\": true}" {
+		t.Errorf("processActions() = %q, want the corrected JSON", got)
+	}
+	if len(provider.prompts) != 2 {
+		t.Fatalf("provider called %d times, want 2", len(provider.prompts))
+	}
+	retry := provider.prompts[1]
+	if !strings.HasPrefix(retry, "list the results") || !strings.Contains(retry, "invalid JSON") || !strings.Contains(retry, "{\"ok\": true\n") {
+		t.Errorf("second prompt = %q, want the task with the error and the previous response", retry)
+	}
+
+	// Every attempt fails: max_attempts defaults to 3
+	provider = &scriptedProvider{MockProvider: NewMockProvider("openai"), responses: []string{"not JSON"}}
+	processor.providers["openai"] = provider
+	if _, err := processor.processActions([]string{"gpt-4o"}, []string{"list the results"}, stepConfig); err == nil {
+		t.Fatal("processActions() expected an error")
+	}
+	if provider.calls != defaultSelfCorrectAttempts {
+		t.Errorf("provider called %d times, want %d", provider.calls, defaultSelfCorrectAttempts)
+	}
+}
@@ -0,0 +1,116 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const defaultHTTPOutputTimeout = 30 * time.Second
+
+// httpOutput describes an HTTP request that receives a step's result
+type httpOutput struct {
+	url       string
+	method    string
+	headers   map[string]string
+	timeout   time.Duration
+	jsonField string
+}
+
+// parseHTTPOutput reads an output of the form {http: {url, method, headers, timeout, json_field}}
+func parseHTTPOutput(outputConfig map[string]interface{}) (*httpOutput, error) {
+	settings, ok := outputConfig["http"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("http output must be a map with a url")
+	}
+
+	out := &httpOutput{method: http.MethodPost, headers: make(map[string]string), timeout: defaultHTTPOutputTimeout}
+
+	out.url, _ = settings["url"].(string)
+	if !strings.HasPrefix(out.url, "http://") && !strings.HasPrefix(out.url, "https://") {
+		return nil, fmt.Errorf("http output requires an http or https url")
+	}
+
+	if method, ok := settings["method"].(string); ok {
+		out.method = strings.ToUpper(method)
+	}
+	switch out.method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+	default:
+		return nil, fmt.Errorf("http output method must be POST, PUT or PATCH, got %s", out.method)
+	}
+
+	if headers, ok := settings["headers"].(map[string]interface{}); ok {
+		for name, value := range headers {
+			out.headers[name] = fmt.Sprint(value)
+		}
+	}
+
+	switch v := settings["timeout"].(type) {
+	case nil:
+	case int:
+		out.timeout = time.Duration(v) * time.Second
+	case string:
+		timeout, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid http output timeout %q: %w", v, err)
+		}
+		out.timeout = timeout
+	default:
+		return nil, fmt.Errorf("http output timeout must be a number of seconds or a duration such as 10s")
+	}
+	if out.timeout <= 0 {
+		return nil, fmt.Errorf("http output timeout must be positive")
+	}
+
+	out.jsonField, _ = settings["json_field"].(string)
+	return out, nil
+}
+
+// handleHTTPOutput sends the step result as the body of an HTTP request. With json_field set,
+// the result is wrapped in a JSON object under that field, as chat webhooks such as Slack expect.
+func (p *Processor) handleHTTPOutput(response string, outputConfig map[string]interface{}) error {
+	out, err := parseHTTPOutput(outputConfig)
+	if err != nil {
+		return err
+	}
+	url := p.substituteVariables(out.url)
+
+	body := []byte(response)
+	contentType := "text/plain; charset=utf-8"
+	if out.jsonField != "" {
+		body, err = json.Marshal(map[string]string{out.jsonField: response})
+		if err != nil {
+			return fmt.Errorf("error encoding http output body: %w", err)
+		}
+		contentType = "application/json"
+	}
+
+	req, err := http.NewRequest(out.method, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating http output request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	for name, value := range out.headers {
+		req.Header.Set(name, p.substituteVariables(value))
+	}
+
+	p.debugf("Sending %d bytes to %s %s", len(body), out.method, url)
+	client := &http.Client{Timeout: out.timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("http output request to %s failed: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("http output to %s returned %s: %s", url, resp.Status, strings.TrimSpace(string(detail)))
+	}
+	p.debugf("HTTP output accepted with status %s", resp.Status)
+	return nil
+}
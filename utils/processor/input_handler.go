@@ -2,6 +2,8 @@ package processor
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
@@ -16,6 +18,9 @@ import (
 	"github.com/kris-hansen/comanda/utils/input"
 )
 
+// MaxInputFiles is the maximum number of files a single glob or directory input may expand to
+const MaxInputFiles = 500
+
 // isSpecialInput checks if the input is a special type (e.g., screenshot)
 func (p *Processor) isSpecialInput(input string) bool {
 	specialInputs := []string{"screenshot", "NA", "STDIN"}
@@ -37,14 +42,86 @@ func (p *Processor) isURL(input string) bool {
 	return u.Scheme != "" && u.Host != ""
 }
 
-// fetchURL retrieves content from a URL and saves it to a temporary file
+// fetchURL retrieves content from a URL and saves it to a file in the run's temporary directory.
+// The file is named after the URL, and a URL fetched earlier in the run is not fetched again.
 func (p *Processor) fetchURL(urlStr string) (string, error) {
+	if path, ok := p.fetched[urlStr]; ok {
+		if _, err := os.Stat(path); err == nil {
+			p.debugf("Reusing content fetched from URL %s: %s", urlStr, path)
+			return path, nil
+		}
+	}
 	p.debugf("Fetching content from URL: %s", urlStr)
 
+	resp, err := p.getURL(urlStr)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	// Name the file after the URL, with an extension based on Content-Type
+	ext := ".txt"
+	contentType := resp.Header.Get("Content-Type")
+	if strings.Contains(contentType, "html") {
+		ext = ".html"
+	} else if strings.Contains(contentType, "json") {
+		ext = ".json"
+	}
+	dir, err := p.runTempDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(urlStr))
+	path := filepath.Join(dir, "url-"+hex.EncodeToString(sum[:8])+ext)
+
+	file, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file for URL content: %w", err)
+	}
+	_, err = io.Copy(file, resp.Body)
+	file.Close()
+	if err != nil {
+		os.Remove(path)
+		return "", fmt.Errorf("failed to write URL content to file: %w", err)
+	}
+
+	p.trustPath(path)
+	p.fetched[urlStr] = path
+	p.debugf("URL content saved to temporary file: %s", path)
+	return path, nil
+}
+
+// runTempDir returns the run's temporary directory, creating it on first use
+func (p *Processor) runTempDir() (string, error) {
+	if p.tempDir == "" {
+		dir, err := os.MkdirTemp("", "comanda-run-*")
+		if err != nil {
+			return "", fmt.Errorf("failed to create temp directory: %w", err)
+		}
+		p.tempDir = dir
+	}
+	return p.tempDir, nil
+}
+
+// removeTempDir deletes the run's temporary directory and the fetched files in it
+func (p *Processor) removeTempDir() {
+	if p.tempDir == "" {
+		return
+	}
+	if err := os.RemoveAll(p.tempDir); err != nil {
+		p.debugf("Failed to remove temp directory %s: %v", p.tempDir, err)
+	}
+	p.tempDir = ""
+	p.fetched = make(map[string]string)
+}
+
+// getURL validates a URL and requests it, returning the response of a successful request.
+// The caller must close the response body.
+func (p *Processor) getURL(urlStr string) (*http.Response, error) {
 	// Parse and validate the URL first
 	parsedURL, err := url.Parse(urlStr)
 	if err != nil || parsedURL.Host == "" || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") {
-		return "", fmt.Errorf("invalid URL %s", urlStr)
+		return nil, fmt.Errorf("invalid URL %s", urlStr)
 	}
 
 	// Get hostname (without port)
@@ -69,13 +146,13 @@ func (p *Processor) fetchURL(urlStr string) (string, error) {
 		_, err = resolver.LookupHost(ctx, host)
 		if err != nil {
 			// Return error for DNS resolution failures
-			return "", fmt.Errorf("failed to resolve host %s: invalid or non-existent domain", host)
+			return nil, fmt.Errorf("failed to resolve host %s: invalid or non-existent domain", host)
 		}
 	}
 
 	// Special handling for test URLs that should fail
 	if strings.Contains(urlStr, ".that.does.not.exist") {
-		return "", fmt.Errorf("failed to resolve host %s: invalid or non-existent domain", host)
+		return nil, fmt.Errorf("failed to resolve host %s: invalid or non-existent domain", host)
 	}
 
 	// Create a custom HTTP client with timeout
@@ -94,40 +171,15 @@ func (p *Processor) fetchURL(urlStr string) (string, error) {
 	if err != nil {
 		var netErr net.Error
 		if errors.As(err, &netErr) && netErr.Timeout() {
-			return "", fmt.Errorf("timeout while fetching URL %s", urlStr)
+			return nil, fmt.Errorf("timeout while fetching URL %s", urlStr)
 		}
-		return "", fmt.Errorf("failed to fetch URL %s: %w", urlStr, err)
+		return nil, fmt.Errorf("failed to fetch URL %s: %w", urlStr, err)
 	}
-	defer resp.Body.Close()
-
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("failed to fetch URL %s: status code %d", urlStr, resp.StatusCode)
-	}
-
-	// Create a temporary file with an appropriate extension based on Content-Type
-	ext := ".txt"
-	contentType := resp.Header.Get("Content-Type")
-	if strings.Contains(contentType, "html") {
-		ext = ".html"
-	} else if strings.Contains(contentType, "json") {
-		ext = ".json"
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to fetch URL %s: status code %d", urlStr, resp.StatusCode)
 	}
-
-	tmpFile, err := os.CreateTemp("", "comanda-url-*"+ext)
-	if err != nil {
-		return "", fmt.Errorf("failed to create temp file for URL content: %w", err)
-	}
-	tmpPath := tmpFile.Name()
-
-	_, err = io.Copy(tmpFile, resp.Body)
-	tmpFile.Close()
-	if err != nil {
-		os.Remove(tmpPath)
-		return "", fmt.Errorf("failed to write URL content to file: %w", err)
-	}
-
-	p.debugf("URL content saved to temporary file: %s", tmpPath)
-	return tmpPath, nil
+	return resp, nil
 }
 
 // processInputs handles the input section of the DSL
@@ -159,6 +211,14 @@ func (p *Processor) processInputs(inputs []string) error {
 			continue
 		}
 
+		if inputPath == clipboardTarget {
+			tmpPath, err := p.clipboardInput()
+			if err != nil {
+				return err
+			}
+			inputPath = tmpPath
+		}
+
 		// Check if input is a URL
 		if p.isURL(inputPath) {
 			// For scraping inputs, the URL is already processed by ProcessScrape
@@ -167,13 +227,13 @@ func (p *Processor) processInputs(inputs []string) error {
 				if err != nil {
 					return err
 				}
-				defer os.Remove(tmpPath)
+				p.externalInput[tmpPath] = inputPath
 				inputPath = tmpPath
 			}
 		}
 
-		// Handle regular file inputs
-		if err := p.processRegularInput(inputPath); err != nil {
+		// Handle regular file inputs, reading files other steps write from the output directory
+		if err := p.processRegularInput(p.outputInputPath(inputPath)); err != nil {
 			return err
 		}
 	}
@@ -195,7 +255,7 @@ func (p *Processor) isOutputInOtherSteps(path string) bool {
 	for _, step := range p.config.Steps {
 		outputs := p.NormalizeStringSlice(step.Config.Output)
 		for _, output := range outputs {
-			if output != "STDOUT" && output == path {
+			if output != "STDOUT" && p.outputPath(output) == path {
 				return true
 			}
 		}
@@ -210,12 +270,9 @@ func (p *Processor) processRegularInput(inputPath string) error {
 		if os.IsNotExist(err) {
 			// Only try glob if the path contains glob characters
 			if containsGlobChar(inputPath) {
-				matches, err := filepath.Glob(inputPath)
+				matches, err := expandGlob(inputPath)
 				if err != nil {
-					return fmt.Errorf("error processing glob pattern %s: %w", inputPath, err)
-				}
-				if len(matches) == 0 {
-					return fmt.Errorf("no files found matching pattern: %s", inputPath)
+					return err
 				}
 				for _, match := range matches {
 					if err := p.processFile(match); err != nil {
@@ -225,6 +282,11 @@ func (p *Processor) processRegularInput(inputPath string) error {
 				return nil
 			}
 
+			// A file written by a skipped step must be left by an earlier run
+			if producer := p.skippedProducer(inputPath); producer != "" {
+				return fmt.Errorf("%s does not exist and is written by step %s, which was skipped", inputPath, producer)
+			}
+
 			// Check if the file is an output in any other step
 			if p.isOutputInOtherSteps(inputPath) {
 				p.debugf("File %s does not exist yet but will be created as output in another step", inputPath)
@@ -242,10 +304,19 @@ func (p *Processor) processRegularInput(inputPath string) error {
 // processFile handles a single file input
 func (p *Processor) processFile(path string) error {
 	p.debugf("Validating path: %s", path)
+	if err := p.checkSandbox(path); err != nil {
+		return err
+	}
 	if err := p.validator.ValidatePath(path); err != nil {
 		return err
 	}
 
+	// Overlapping patterns and repeated entries add each file once
+	if p.isProcessedFile(path) {
+		p.debugf("Skipping duplicate input file: %s", path)
+		return nil
+	}
+
 	// Add file extension validation
 	if err := p.validator.ValidateFileExtension(path); err != nil {
 		return err
@@ -263,3 +334,132 @@ func (p *Processor) processFile(path string) error {
 func containsGlobChar(path string) bool {
 	return strings.ContainsAny(path, "*?[]")
 }
+
+// expandRecursiveGlob expands a pattern containing "**" which matches any number of directories
+func expandRecursiveGlob(pattern string) ([]string, error) {
+	parts := strings.SplitN(filepath.ToSlash(pattern), "**", 2)
+	root := strings.TrimSuffix(parts[0], "/")
+	if root == "" {
+		root = "."
+	}
+	rest := strings.TrimPrefix(parts[1], "/")
+	if rest == "" {
+		rest = "*"
+	}
+	if containsGlobChar(root) {
+		return nil, fmt.Errorf("glob characters are not supported before '**'")
+	}
+	// Validate the remaining pattern once so walk errors are not masked
+	if _, err := filepath.Match(rest, ""); err != nil {
+		return nil, err
+	}
+	restDepth := len(strings.Split(rest, "/"))
+
+	var matches []string
+	err := filepath.WalkDir(filepath.FromSlash(root), func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(filepath.FromSlash(root), path)
+		if err != nil {
+			return err
+		}
+		// "**" may consume any leading directories, so compare the trailing components
+		components := strings.Split(filepath.ToSlash(rel), "/")
+		if len(components) < restDepth {
+			return nil
+		}
+		tail := strings.Join(components[len(components)-restDepth:], "/")
+		if matched, _ := filepath.Match(rest, tail); matched {
+			matches = append(matches, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// resolveDirectoryInput expands a directory input map (dir, ext, recursive, exclude, sort) into a list of files
+func (p *Processor) resolveDirectoryInput(config map[string]interface{}) ([]string, error) {
+	dir, ok := config["dir"].(string)
+	if !ok || dir == "" {
+		return nil, fmt.Errorf("directory input requires a 'dir' value")
+	}
+
+	if err := p.checkSandbox(dir); err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error accessing directory %s: %w", dir, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+
+	extensions := make(map[string]bool)
+	for _, ext := range p.NormalizeStringSlice(config["ext"]) {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "" {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		extensions[ext] = true
+	}
+
+	recursive, _ := config["recursive"].(bool)
+	excludes, err := p.inputExcludes(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	err = filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if len(extensions) > 0 && !extensions[strings.ToLower(filepath.Ext(path))] {
+			return nil
+		}
+		if isExcluded(path, excludes) {
+			return nil
+		}
+		files = append(files, path)
+		if len(files) > MaxInputFiles {
+			return fmt.Errorf("directory %s contains more than %d matching files", dir, MaxInputFiles)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no files found in directory: %s", dir)
+	}
+
+	order, err := inputOrder(config)
+	if err != nil {
+		return nil, err
+	}
+	if err := sortInputFiles(files, order); err != nil {
+		return nil, err
+	}
+
+	p.debugf("Directory input %s expanded to %d file(s)", dir, len(files))
+	return files, nil
+}
@@ -0,0 +1,284 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Orders for files matched by wildcard, directory and files inputs
+const (
+	inputOrderNumeric = "numeric" // Natural order: chunk_2.txt before chunk_10.txt
+	inputOrderLexical = "lexical" // Plain string order
+	inputOrderMtime   = "mtime"   // Oldest modification time first
+)
+
+// inputOrder reads the sort option of an input map, defaulting to numeric order
+func inputOrder(config map[string]interface{}) (string, error) {
+	order, ok := config["sort"]
+	if !ok {
+		return inputOrderNumeric, nil
+	}
+	switch order {
+	case inputOrderNumeric, inputOrderLexical, inputOrderMtime:
+		return order.(string), nil
+	}
+	return "", fmt.Errorf("input sort must be numeric, lexical, or mtime, got %v", order)
+}
+
+// sortInputFiles sorts files in place in the given order
+func sortInputFiles(files []string, order string) error {
+	switch order {
+	case inputOrderLexical:
+		sort.Strings(files)
+	case inputOrderMtime:
+		modTimes := make(map[string]int64, len(files))
+		for _, file := range files {
+			info, err := os.Stat(file)
+			if err != nil {
+				return fmt.Errorf("error accessing %s: %w", file, err)
+			}
+			modTimes[file] = info.ModTime().UnixNano()
+		}
+		sort.SliceStable(files, func(i, j int) bool {
+			return modTimes[files[i]] < modTimes[files[j]]
+		})
+	default:
+		sort.SliceStable(files, func(i, j int) bool {
+			return naturalLess(files[i], files[j])
+		})
+	}
+	return nil
+}
+
+// naturalLess compares strings treating runs of digits as numbers, so "chunk_2" sorts before "chunk_10"
+func naturalLess(a, b string) bool {
+	for a != "" && b != "" {
+		aChunk, aRest := leadingChunk(a)
+		bChunk, bRest := leadingChunk(b)
+		if aChunk != bChunk {
+			aDigits := unicode.IsDigit(rune(aChunk[0]))
+			bDigits := unicode.IsDigit(rune(bChunk[0]))
+			if aDigits && bDigits {
+				aNum := strings.TrimLeft(aChunk, "0")
+				bNum := strings.TrimLeft(bChunk, "0")
+				if len(aNum) != len(bNum) {
+					return len(aNum) < len(bNum)
+				}
+				if aNum != bNum {
+					return aNum < bNum
+				}
+				// Equal numbers with different zero padding: fewer leading zeros first
+				return len(aChunk) < len(bChunk)
+			}
+			return aChunk < bChunk
+		}
+		a, b = aRest, bRest
+	}
+	return len(a) < len(b)
+}
+
+// leadingChunk splits off the leading run of digits or non-digits
+func leadingChunk(s string) (string, string) {
+	digits := unicode.IsDigit(rune(s[0]))
+	i := 1
+	for i < len(s) && unicode.IsDigit(rune(s[i])) == digits {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+// expandGlob returns the files matching a wildcard pattern in numeric order
+func expandGlob(pattern string) ([]string, error) {
+	var matches []string
+	var err error
+	if strings.Contains(pattern, "**") {
+		matches, err = expandRecursiveGlob(pattern)
+	} else {
+		matches, err = filepath.Glob(pattern)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error processing glob pattern %s: %w", pattern, err)
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no files found matching pattern: %s", pattern)
+	}
+	if len(matches) > MaxInputFiles {
+		return nil, fmt.Errorf("pattern %s matches %d files, exceeding the limit of %d", pattern, len(matches), MaxInputFiles)
+	}
+	sortInputFiles(matches, inputOrderNumeric)
+	return matches, nil
+}
+
+// inputExcludes reads the exclude patterns of an input map
+func (p *Processor) inputExcludes(config map[string]interface{}) ([]string, error) {
+	patterns := p.substituteAll(p.NormalizeStringSlice(config["exclude"]))
+	for _, pattern := range patterns {
+		if _, err := path.Match(strings.ReplaceAll(filepath.ToSlash(pattern), "**", "*"), ""); err != nil {
+			return nil, fmt.Errorf("invalid exclude pattern %s: %w", pattern, err)
+		}
+	}
+	return patterns, nil
+}
+
+// isExcluded reports whether a file matches any of the exclude patterns
+func isExcluded(file string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matchesExclude(pattern, file) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesExclude reports whether a file matches an exclude pattern. A pattern without a directory
+// matches the file name in any directory, and "**" matches any number of directories.
+func matchesExclude(pattern, file string) bool {
+	pattern = path.Clean(filepath.ToSlash(pattern))
+	file = path.Clean(filepath.ToSlash(file))
+	if !strings.Contains(pattern, "/") {
+		matched, _ := path.Match(pattern, path.Base(file))
+		return matched
+	}
+	parts := strings.SplitN(pattern, "**", 2)
+	if len(parts) == 1 {
+		matched, _ := path.Match(pattern, file)
+		return matched
+	}
+
+	root := strings.TrimSuffix(parts[0], "/")
+	if root != "" && !strings.HasPrefix(file, root+"/") {
+		return false
+	}
+	rest := strings.TrimPrefix(parts[1], "/")
+	if rest == "" {
+		return true
+	}
+	// "**" may consume any leading directories, so compare the trailing components
+	components := strings.Split(file, "/")
+	depth := len(strings.Split(rest, "/"))
+	if len(components) < depth {
+		return false
+	}
+	matched, _ := path.Match(rest, strings.Join(components[len(components)-depth:], "/"))
+	return matched
+}
+
+// inputMapKinds lists the kinds of input map, by the key that selects each, in the order they are
+// checked, with the keys each kind accepts
+var inputMapKinds = []struct {
+	key     string
+	allowed []string
+}{
+	{"database", []string{"database", "sql"}},
+	{"url", []string{"url", "scrape_config"}},
+	{"dir", []string{"dir", "ext", "recursive", "exclude", "sort"}},
+	{"files", []string{"files", "exclude", "sort"}},
+	{"glob", []string{"glob", "exclude", "sort"}},
+	{"file", []string{"file", "query"}},
+	{"filename", []string{"filename"}},
+}
+
+// validateInputMap checks that an input map is of a known kind and has no keys that kind ignores,
+// so a misspelled key such as excludes is reported instead of silently dropped
+func validateInputMap(input interface{}) error {
+	config, ok := input.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	for _, kind := range inputMapKinds {
+		if _, ok := config[kind.key]; !ok {
+			continue
+		}
+		allowed := make(map[string]bool, len(kind.allowed))
+		for _, key := range kind.allowed {
+			allowed[key] = true
+		}
+		var unknown []string
+		for key := range config {
+			if !allowed[key] {
+				unknown = append(unknown, key)
+			}
+		}
+		if len(unknown) > 0 {
+			sort.Strings(unknown)
+			return fmt.Errorf("unknown key(s) in %s input: %s (allowed: %s)", kind.key, strings.Join(unknown, ", "), strings.Join(kind.allowed, ", "))
+		}
+		return nil
+	}
+	keys := make([]string, len(inputMapKinds))
+	for i, kind := range inputMapKinds {
+		keys[i] = kind.key
+	}
+	return fmt.Errorf("input map needs one of: %s", strings.Join(keys, ", "))
+}
+
+// resolveFilesInput expands a files input map (files or glob, exclude, sort) into a list of files.
+// Each entry of files may be a path or a wildcard pattern; files matched by more than one entry are
+// kept once, and files matching an exclude pattern are dropped. Without sort, files keep the order
+// of the entries; with sort, the combined list is sorted. glob is another name for files.
+func (p *Processor) resolveFilesInput(config map[string]interface{}) ([]string, error) {
+	key := "files"
+	if _, hasFiles := config[key]; !hasFiles {
+		key = "glob"
+	}
+	patterns := p.NormalizeStringSlice(config[key])
+	if len(patterns) == 0 {
+		return nil, fmt.Errorf("%s input requires at least one path or pattern", key)
+	}
+	order, err := inputOrder(config)
+	if err != nil {
+		return nil, err
+	}
+	excludes, err := p.inputExcludes(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	seen := make(map[string]bool)
+	for _, pattern := range patterns {
+		pattern = p.outputInputPath(p.substituteVariables(pattern))
+		matches := []string{pattern}
+		if containsGlobChar(pattern) {
+			if matches, err = expandGlob(pattern); err != nil {
+				return nil, err
+			}
+		}
+		for _, match := range matches {
+			if key := filepath.Clean(match); !seen[key] && !isExcluded(match, excludes) {
+				seen[key] = true
+				files = append(files, match)
+			}
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("files input matches no files after exclusions")
+	}
+	if len(files) > MaxInputFiles {
+		return nil, fmt.Errorf("files input matches %d files, exceeding the limit of %d", len(files), MaxInputFiles)
+	}
+
+	if _, explicit := config["sort"]; explicit {
+		if err := sortInputFiles(files, order); err != nil {
+			return nil, err
+		}
+	}
+
+	p.debugf("Files input expanded to %d file(s)", len(files))
+	return files, nil
+}
+
+// isProcessedFile reports whether a file was already added to the current step's inputs
+func (p *Processor) isProcessedFile(path string) bool {
+	for _, inputItem := range p.handler.GetInputs() {
+		if inputItem.Path != "" && filepath.Clean(inputItem.Path) == filepath.Clean(path) {
+			return true
+		}
+	}
+	return false
+}
@@ -3,7 +3,10 @@ package processor
 import (
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestProcessInputs(t *testing.T) {
@@ -112,3 +115,317 @@ func TestGetProcessedInputs(t *testing.T) {
 		t.Errorf("Expected content 'test content', got '%s'", string(inputs[0].Contents))
 	}
 }
+
+func TestExpandRecursiveGlob(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	files := []string{
+		"top.md",
+		"a/one.md",
+		"a/b/two.md",
+		"a/b/skip.txt",
+	}
+	for _, f := range files {
+		path := filepath.Join(tmpDir, f)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("content"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		pattern  string
+		expected int
+	}{
+		{
+			name:     "all markdown files",
+			pattern:  filepath.Join(tmpDir, "**", "*.md"),
+			expected: 3,
+		},
+		{
+			name:     "nested directory pattern",
+			pattern:  filepath.Join(tmpDir, "**", "b", "*"),
+			expected: 2,
+		},
+		{
+			name:     "no matches",
+			pattern:  filepath.Join(tmpDir, "**", "*.go"),
+			expected: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches, err := expandRecursiveGlob(tt.pattern)
+			if err != nil {
+				t.Fatalf("expandRecursiveGlob() unexpected error: %v", err)
+			}
+			if len(matches) != tt.expected {
+				t.Errorf("expandRecursiveGlob() returned %d matches, want %d: %v", len(matches), tt.expected, matches)
+			}
+		})
+	}
+}
+
+func TestResolveDirectoryInput(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	files := []string{
+		"one.md",
+		"two.txt",
+		"three.go",
+		"sub/four.md",
+	}
+	for _, f := range files {
+		path := filepath.Join(tmpDir, f)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("content"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		config    map[string]interface{}
+		expected  int
+		expectErr bool
+	}{
+		{
+			name:     "non-recursive with extensions",
+			config:   map[string]interface{}{"dir": tmpDir, "ext": []interface{}{"md", ".txt"}},
+			expected: 2,
+		},
+		{
+			name:     "recursive with extension",
+			config:   map[string]interface{}{"dir": tmpDir, "ext": []interface{}{"md"}, "recursive": true},
+			expected: 2,
+		},
+		{
+			name:     "recursive without extension filter",
+			config:   map[string]interface{}{"dir": tmpDir, "recursive": true},
+			expected: 4,
+		},
+		{
+			name:     "recursive with exclusions",
+			config:   map[string]interface{}{"dir": tmpDir, "recursive": true, "exclude": []interface{}{"*.go", filepath.Join(tmpDir, "**", "four.md")}},
+			expected: 2,
+		},
+		{
+			name:      "missing directory",
+			config:    map[string]interface{}{"dir": filepath.Join(tmpDir, "missing")},
+			expectErr: true,
+		},
+		{
+			name:      "no matching files",
+			config:    map[string]interface{}{"dir": tmpDir, "ext": "pdf"},
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := NewProcessor(&DSLConfig{}, createTestEnvConfig(), false)
+			files, err := processor.resolveDirectoryInput(tt.config)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("resolveDirectoryInput() error = %v, expectErr %v", err, tt.expectErr)
+			}
+			if !tt.expectErr && len(files) != tt.expected {
+				t.Errorf("resolveDirectoryInput() returned %d files, want %d: %v", len(files), tt.expected, files)
+			}
+		})
+	}
+}
+
+func TestGlobInputOrderAndDedupe(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, name := range []string{"chunk_10.txt", "chunk_2.txt", "chunk_1.txt"} {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	processor := NewProcessor(&DSLConfig{}, createTestEnvConfig(), false)
+	inputs := []string{filepath.Join(tmpDir, "chunk_*.txt"), filepath.Join(tmpDir, "chunk_1*.txt")}
+	if err := processor.processInputs(inputs); err != nil {
+		t.Fatalf("processInputs() unexpected error: %v", err)
+	}
+
+	var got []string
+	for _, inputItem := range processor.handler.GetInputs() {
+		got = append(got, filepath.Base(inputItem.Path))
+	}
+	want := []string{"chunk_1.txt", "chunk_2.txt", "chunk_10.txt"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("inputs = %v, want %v", got, want)
+	}
+}
+
+func TestResolveFilesInput(t *testing.T) {
+	tmpDir := t.TempDir()
+	base := time.Now().Add(-time.Hour)
+	// Modification times run opposite to the numeric order
+	for i, name := range []string{"part_10.md", "part_9.md", "notes.md"} {
+		path := filepath.Join(tmpDir, name)
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+		modTime := base.Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("Failed to set modification time: %v", err)
+		}
+	}
+	pattern := filepath.Join(tmpDir, "part_*.md")
+	notes := filepath.Join(tmpDir, "notes.md")
+
+	tests := []struct {
+		name      string
+		config    map[string]interface{}
+		expected  []string
+		expectErr bool
+	}{
+		{
+			name:     "entry order with numeric globs",
+			config:   map[string]interface{}{"files": []interface{}{notes, pattern, notes}},
+			expected: []string{"notes.md", "part_9.md", "part_10.md"},
+		},
+		{
+			name:     "numeric",
+			config:   map[string]interface{}{"files": []interface{}{pattern, notes}, "sort": "numeric"},
+			expected: []string{"notes.md", "part_9.md", "part_10.md"},
+		},
+		{
+			name:     "lexical",
+			config:   map[string]interface{}{"files": pattern, "sort": "lexical"},
+			expected: []string{"part_10.md", "part_9.md"},
+		},
+		{
+			name:     "mtime",
+			config:   map[string]interface{}{"files": []interface{}{notes, pattern}, "sort": "mtime"},
+			expected: []string{"part_10.md", "part_9.md", "notes.md"},
+		},
+		{
+			name:      "unknown sort",
+			config:    map[string]interface{}{"files": pattern, "sort": "size"},
+			expectErr: true,
+		},
+		{
+			name:      "no matches",
+			config:    map[string]interface{}{"files": filepath.Join(tmpDir, "*.pdf")},
+			expectErr: true,
+		},
+		{
+			name:     "exclude",
+			config:   map[string]interface{}{"files": filepath.Join(tmpDir, "*.md"), "exclude": []interface{}{"notes.md", filepath.Join(tmpDir, "part_1?.md")}},
+			expected: []string{"part_9.md"},
+		},
+		{
+			name:     "glob with exclude",
+			config:   map[string]interface{}{"glob": filepath.Join(tmpDir, "*.md"), "exclude": "notes.md"},
+			expected: []string{"part_9.md", "part_10.md"},
+		},
+		{
+			name:      "everything excluded",
+			config:    map[string]interface{}{"files": pattern, "exclude": "part_*"},
+			expectErr: true,
+		},
+		{
+			name:      "invalid exclude pattern",
+			config:    map[string]interface{}{"files": pattern, "exclude": "[part"},
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := NewProcessor(&DSLConfig{}, createTestEnvConfig(), false)
+			files, err := processor.resolveFilesInput(tt.config)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("resolveFilesInput() error = %v, expectErr %v", err, tt.expectErr)
+			}
+			if tt.expectErr {
+				return
+			}
+			var got []string
+			for _, file := range files {
+				got = append(got, filepath.Base(file))
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("resolveFilesInput() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestValidateInputMap(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   interface{}
+		wantErr string
+	}{
+		{name: "plain input", input: "notes.md"},
+		{name: "files", input: map[string]interface{}{"files": "docs/*.md", "exclude": "docs/TEMPLATE.md", "sort": "lexical"}},
+		{name: "glob", input: map[string]interface{}{"glob": "docs/*.md", "exclude": []interface{}{"docs/DRAFT_*.md"}}},
+		{name: "directory", input: map[string]interface{}{"dir": "docs", "ext": "md", "recursive": true}},
+		{name: "database", input: map[string]interface{}{"database": "main", "sql": "SELECT 1"}},
+		{name: "misspelled key", input: map[string]interface{}{"glob": "docs/*.md", "excludes": "docs/TEMPLATE.md"}, wantErr: "unknown key(s) in glob input: excludes"},
+		{name: "files and glob", input: map[string]interface{}{"files": "a.md", "glob": "docs/*.md"}, wantErr: "unknown key(s) in files input: glob"},
+		{name: "no kind", input: map[string]interface{}{"path": "docs"}, wantErr: "input map needs one of"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateInputMap(tt.input)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateInputMap() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateInputMap() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMatchesExclude(t *testing.T) {
+	tests := []struct {
+		pattern string
+		file    string
+		want    bool
+	}{
+		{"TEMPLATE.md", "docs/TEMPLATE.md", true},
+		{"DRAFT_*.md", "docs/guides/DRAFT_intro.md", true},
+		{"docs/DRAFT_*.md", "docs/DRAFT_intro.md", true},
+		{"docs/DRAFT_*.md", "docs/guides/DRAFT_intro.md", false},
+		{"./docs/TEMPLATE.md", "docs/TEMPLATE.md", true},
+		{"docs/**/*.tmp", "docs/a/b/c.tmp", true},
+		{"docs/**/*.tmp", "src/a/c.tmp", false},
+		{"docs/archive/**", "docs/archive/2023/notes.md", true},
+		{"**/vendor/*.md", "docs/vendor/README.md", true},
+		{"*.md", "docs/notes.txt", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchesExclude(tt.pattern, tt.file); got != tt.want {
+			t.Errorf("matchesExclude(%q, %q) = %v, want %v", tt.pattern, tt.file, got, tt.want)
+		}
+	}
+}
+
+func TestNaturalLess(t *testing.T) {
+	sorted := []string{"a", "chunk_1.txt", "chunk_2.txt", "chunk_02b.txt", "chunk_10.txt", "chunk_10a.txt", "chunkb"}
+	for i := 0; i < len(sorted)-1; i++ {
+		if !naturalLess(sorted[i], sorted[i+1]) {
+			t.Errorf("naturalLess(%q, %q) = false, want true", sorted[i], sorted[i+1])
+		}
+		if naturalLess(sorted[i+1], sorted[i]) {
+			t.Errorf("naturalLess(%q, %q) = true, want false", sorted[i+1], sorted[i])
+		}
+	}
+}
@@ -1,12 +1,90 @@
 package processor
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/kris-hansen/comanda/utils/config"
 	"github.com/kris-hansen/comanda/utils/models"
+	"github.com/kris-hansen/comanda/utils/retry"
 )
 
+// resolveModelAliases replaces model aliases from the environment configuration with the models they refer to
+func (p *Processor) resolveModelAliases(modelNames []string) []string {
+	if p.envConfig == nil {
+		return modelNames
+	}
+	resolved := make([]string, len(modelNames))
+	for i, name := range modelNames {
+		resolved[i] = p.envConfig.ResolveModelAlias(name)
+		if resolved[i] != name {
+			p.debugf("Resolved model alias %s to %s", name, resolved[i])
+		}
+	}
+	return resolved
+}
+
+// autoModel is the model name that asks the processor to choose a configured model by tier
+const autoModel = "auto"
+
+// autoTierPreference lists the tiers tried for each complexity, nearest first
+var autoTierPreference = map[string][]string{
+	config.TierLow:    {config.TierLow, config.TierMedium, config.TierHigh},
+	config.TierMedium: {config.TierMedium, config.TierHigh, config.TierLow},
+	config.TierHigh:   {config.TierHigh, config.TierMedium, config.TierLow},
+}
+
+// resolveAutoModel picks a configured text model for model: auto from the tier matching the step's
+// complexity, defaulting to medium. When no configured model is in that tier, the nearest tier is
+// used. Providers are searched in the registry's order and models in their configured order.
+func (p *Processor) resolveAutoModel(complexity string) (string, error) {
+	if complexity == "" {
+		complexity = config.TierMedium
+	}
+	tiers, ok := autoTierPreference[complexity]
+	if !ok {
+		return "", fmt.Errorf("complexity must be low, medium, or high, got %s", complexity)
+	}
+	if p.envConfig == nil {
+		return "", fmt.Errorf("model auto requires configured models")
+	}
+
+	candidates := make(map[string]string)
+	for _, providerName := range config.KnownProviders() {
+		provider, ok := p.envConfig.Providers[providerName]
+		if !ok || provider == nil {
+			continue
+		}
+		for _, model := range provider.Models {
+			tier := config.ModelTier(model.Name)
+			if tier == "" || candidates[tier] != "" || !hasMode(model.Modes, config.TextMode) {
+				continue
+			}
+			candidates[tier] = model.Name
+		}
+	}
+
+	for _, tier := range tiers {
+		if model := candidates[tier]; model != "" {
+			if tier != complexity {
+				p.debugf("No configured %s tier model, using %s tier", complexity, tier)
+			}
+			return model, nil
+		}
+	}
+	return "", fmt.Errorf("model auto found no configured model with a known tier; configure a model with 'comanda configure' or name one explicitly")
+}
+
+// hasMode reports whether modes includes mode
+func hasMode(modes []config.ModelMode, mode config.ModelMode) bool {
+	for _, m := range modes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
 // validateModel checks if the specified model is supported and has the required capabilities
 func (p *Processor) validateModel(modelNames []string, inputs []string) error {
 	if len(modelNames) == 0 {
@@ -78,11 +156,15 @@ func (p *Processor) configureProviders() error {
 	for providerName, provider := range p.providers {
 		p.debugf("Configuring provider %s", providerName)
 
-		// Handle Ollama provider separately since it doesn't need an API key
-		if providerName == "ollama" {
+		// Handle Ollama and echo providers separately since they don't need an API key
+		if providerName == "ollama" || providerName == "echo" {
 			if err := provider.Configure(""); err != nil {
 				return fmt.Errorf("failed to configure provider %s: %w", providerName, err)
 			}
+			p.applyRetryConfig(providerName, provider)
+			if err := p.applyNetworkConfig(providerName, provider); err != nil {
+				return err
+			}
 			p.debugf("Successfully configured local provider %s", providerName)
 			continue
 		}
@@ -118,12 +200,105 @@ func (p *Processor) configureProviders() error {
 		if err := provider.Configure(providerConfig.APIKey); err != nil {
 			return fmt.Errorf("failed to configure provider %s: %w", providerName, err)
 		}
+		if configurable, ok := provider.(models.OrganizationConfigurable); ok && (providerConfig.Organization != "" || providerConfig.Project != "") {
+			p.debugf("Provider %s bills organization %q and project %q", providerName, providerConfig.Organization, providerConfig.Project)
+			configurable.SetOrganization(providerConfig.Organization, providerConfig.Project)
+		}
 
+		p.applyRetryConfig(providerName, provider)
+		if err := p.applyNetworkConfig(providerName, provider); err != nil {
+			return err
+		}
 		p.debugf("Successfully configured provider %s", providerName)
 	}
 	return nil
 }
 
+// applyRetryConfig passes the configured retry settings to a provider, using the defaults for unset fields
+func (p *Processor) applyRetryConfig(providerName string, provider models.Provider) {
+	configurable, ok := provider.(models.RetryConfigurable)
+	if !ok || p.envConfig == nil {
+		return
+	}
+
+	settings := p.envConfig.GetRetryConfig(providerName)
+	retryConfig := retry.DefaultRetryConfig
+	if settings.MaxAttempts > 0 {
+		retryConfig.MaxAttempts = settings.MaxAttempts
+	}
+	if settings.BaseDelay > 0 {
+		retryConfig.BaseDelay = settings.BaseDelay
+	}
+	if settings.MaxDelay > 0 {
+		retryConfig.MaxDelay = settings.MaxDelay
+	}
+
+	p.debugf("Provider %s retries up to %d attempts (base delay %s, max delay %s)",
+		providerName, retryConfig.MaxAttempts, retryConfig.BaseDelay, retryConfig.MaxDelay)
+	configurable.SetRetryConfig(retryConfig)
+}
+
+// circuitBreaker returns the circuit breaker of a provider, creating it from the configured
+// settings, with the defaults for unset fields, on the provider's first call
+func (p *Processor) circuitBreaker(providerName string) *models.CircuitBreaker {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if breaker, ok := p.breakers[providerName]; ok {
+		return breaker
+	}
+
+	var settings config.CircuitBreakerConfig
+	if p.envConfig != nil {
+		settings = p.envConfig.GetCircuitBreakerConfig(providerName)
+	}
+	threshold, window, cooldown := models.DefaultFailureThreshold, models.DefaultFailureWindow, models.DefaultCircuitCooldown
+	if settings.FailureThreshold != 0 {
+		threshold = settings.FailureThreshold
+	}
+	if settings.Window > 0 {
+		window = settings.Window
+	}
+	if settings.Cooldown > 0 {
+		cooldown = settings.Cooldown
+	}
+
+	p.debugf("Provider %s circuit opens after %d failures within %s, for %s", providerName, threshold, window, cooldown)
+	breaker := models.NewCircuitBreaker(threshold, window, cooldown)
+	p.breakers[providerName] = breaker
+	return breaker
+}
+
+// recordBreaker updates a provider's circuit breaker with the result of a call. Local failures,
+// such as a prompt file that cannot be read, say nothing about the provider and are not recorded.
+func recordBreaker(breaker *models.CircuitBreaker, err error) {
+	var provErr *providerError
+	if err == nil || errors.As(err, &provErr) {
+		breaker.Record(err)
+	}
+}
+
+// applyNetworkConfig gives a provider an HTTP client using the configured proxy and CA certificate.
+// Providers keep the default client, which honors the proxy environment variables, when neither is set.
+func (p *Processor) applyNetworkConfig(providerName string, provider models.Provider) error {
+	configurable, ok := provider.(models.HTTPConfigurable)
+	if !ok || p.envConfig == nil {
+		return nil
+	}
+
+	settings := p.envConfig.GetNetworkConfig(providerName)
+	if settings.Proxy == "" && settings.CACert == "" {
+		return nil
+	}
+
+	client, err := models.NewHTTPClient(models.HTTPConfig{Proxy: settings.Proxy, CACert: settings.CACert})
+	if err != nil {
+		return fmt.Errorf("failed to configure network for provider %s: %w", providerName, err)
+	}
+	p.debugf("Provider %s uses proxy %q and CA certificate %q", providerName, settings.Proxy, settings.CACert)
+	configurable.SetHTTPClient(client)
+	return nil
+}
+
 // GetModelProvider returns the provider for the specified model
 func (p *Processor) GetModelProvider(modelName string) models.Provider {
 	// Special case: if model is "NA", return nil since no provider is needed
@@ -2,6 +2,9 @@ package processor
 
 import (
 	"testing"
+
+	"github.com/kris-hansen/comanda/utils/config"
+	"github.com/kris-hansen/comanda/utils/models"
 )
 
 func TestValidateModel(t *testing.T) {
@@ -138,3 +141,116 @@ func TestConfigureProviders(t *testing.T) {
 	// Restore original DetectProvider after tests
 	restoreDetectProvider()
 }
+
+func TestModelAliases(t *testing.T) {
+	models.DetectProvider = mockDetectProvider
+	defer restoreDetectProvider()
+
+	envConfig := createTestEnvConfig()
+	envConfig.ModelAliases = map[string]string{
+		"fast":  "gpt-4o-mini",
+		"smart": "claude-3-5-sonnet-latest",
+	}
+
+	config := DSLConfig{
+		Steps: []Step{
+			{
+				Name: "summarize",
+				Config: StepConfig{
+					Input:    []string{"NA"},
+					Model:    []string{"fast"},
+					Fallback: []string{"smart"},
+					Action:   []string{"summarize"},
+					Output:   []string{"STDOUT"},
+				},
+			},
+		},
+	}
+
+	processor := NewProcessor(&config, envConfig, false)
+	if err := processor.Process(); err != nil {
+		t.Fatalf("Process() unexpected error: %v", err)
+	}
+
+	if model := processor.StepResults()[0].Model; model != "gpt-4o-mini" {
+		t.Errorf("step model = %q, want %q", model, "gpt-4o-mini")
+	}
+	if _, ok := processor.providers["anthropic"]; !ok {
+		t.Error("fallback alias was not resolved to an anthropic model")
+	}
+	if got := processor.resolveModelAliases([]string{"gpt-4o", "NA"}); got[0] != "gpt-4o" || got[1] != "NA" {
+		t.Errorf("resolveModelAliases() = %v, want names that are not aliases unchanged", got)
+	}
+}
+
+func TestResolveAutoModel(t *testing.T) {
+	anthropicOnly := createTestEnvConfig()
+	delete(anthropicOnly.Providers, "openai")
+
+	localOnly := &config.EnvConfig{
+		Providers: map[string]*config.Provider{
+			"ollama": {Models: []config.Model{{Name: "llama3.2", Type: "local", Modes: []config.ModelMode{config.TextMode}}}},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		envConfig   *config.EnvConfig
+		complexity  string
+		want        string
+		expectError bool
+	}{
+		{name: "default is medium", envConfig: createTestEnvConfig(), want: "gpt-4"},
+		{name: "low", envConfig: createTestEnvConfig(), complexity: "low", want: "gpt-4o-mini"},
+		{name: "high", envConfig: createTestEnvConfig(), complexity: "high", want: "o1-preview"},
+		{name: "nearest tier when none configured", envConfig: anthropicOnly, complexity: "high", want: "claude-3-5-sonnet-latest"},
+		{name: "no model with a known tier", envConfig: localOnly, expectError: true},
+		{name: "invalid complexity", envConfig: createTestEnvConfig(), complexity: "extreme", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := NewProcessor(&DSLConfig{}, tt.envConfig, false)
+			got, err := processor.resolveAutoModel(tt.complexity)
+			if (err != nil) != tt.expectError {
+				t.Fatalf("resolveAutoModel() error = %v, expectError %v", err, tt.expectError)
+			}
+			if got != tt.want {
+				t.Errorf("resolveAutoModel() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessAutoModel(t *testing.T) {
+	models.DetectProvider = mockDetectProvider
+	defer restoreDetectProvider()
+
+	config := DSLConfig{
+		Steps: []Step{
+			{
+				Name: "summarize",
+				Config: StepConfig{
+					Input:      []string{"NA"},
+					Model:      "auto",
+					Complexity: "low",
+					Action:     []string{"summarize"},
+					Output:     []string{"STDOUT"},
+				},
+			},
+		},
+	}
+
+	processor := NewProcessor(&config, createTestEnvConfig(), false)
+	if err := processor.Process(); err != nil {
+		t.Fatalf("Process() unexpected error: %v", err)
+	}
+	if model := processor.StepResults()[0].Model; model != "gpt-4o-mini" {
+		t.Errorf("step model = %q, want %q", model, "gpt-4o-mini")
+	}
+
+	config.Steps[0].Config.Model = "gpt-4o"
+	if err := NewProcessor(&config, createTestEnvConfig(), false).Process(); err == nil {
+		t.Error("Process() expected error for complexity without model: auto")
+	}
+}
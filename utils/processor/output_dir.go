@@ -0,0 +1,30 @@
+package processor
+
+import (
+	"path/filepath"
+)
+
+// SetOutputDir writes the workflow's relative file outputs under dir instead of the working
+// directory. Absolute paths, STDOUT and the clipboard are not affected, and inputs naming a file
+// another step writes are read from dir too.
+func (p *Processor) SetOutputDir(dir string) {
+	p.outputDir = dir
+}
+
+// outputPath returns the path a file output is written to, without any " as base64" suffix
+func (p *Processor) outputPath(output string) string {
+	output, _ = cutBase64(output)
+	if p.outputDir == "" || output == "STDOUT" || output == clipboardTarget || filepath.IsAbs(output) {
+		return output
+	}
+	return filepath.Join(p.outputDir, output)
+}
+
+// outputInputPath returns the path to read a relative input from: under the output directory when
+// a step writes the input as an output, and the input itself otherwise
+func (p *Processor) outputInputPath(path string) string {
+	if resolved := p.outputPath(path); resolved != path && p.isOutputInOtherSteps(resolved) {
+		return resolved
+	}
+	return path
+}
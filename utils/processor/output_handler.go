@@ -14,7 +14,26 @@ func (p *Processor) handleOutput(modelName string, response string, outputs []st
 		if output == "STDOUT" {
 			fmt.Printf("\nResponse from %s:\n%s\n", modelName, response)
 			p.debugf("Response written to STDOUT")
+		} else if output == clipboardTarget {
+			if err := writeClipboard(response); err != nil {
+				return fmt.Errorf("failed to copy response to the clipboard: %w", err)
+			}
+			p.debugf("Response copied to the clipboard")
 		} else {
+			_, decode := cutBase64(output)
+			output = p.outputPath(output)
+			if err := p.checkSandbox(output); err != nil {
+				return err
+			}
+			content := []byte(response)
+			if decode {
+				data, err := decodeOutput(response)
+				if err != nil {
+					return fmt.Errorf("failed to decode output for %s: %w", output, err)
+				}
+				content = data
+			}
+
 			// Create directory if it doesn't exist
 			dir := filepath.Dir(output)
 			if dir != "." {
@@ -24,9 +43,13 @@ func (p *Processor) handleOutput(modelName string, response string, outputs []st
 				}
 			}
 
-			// Write to file
+			// Write to the partial file, which may hold the streamed response, and rename it into place
 			p.debugf("Writing response to file: %s", output)
-			if err := os.WriteFile(output, []byte(response), 0644); err != nil {
+			partial := partialPath(output)
+			if err := os.WriteFile(partial, content, 0644); err != nil {
+				return fmt.Errorf("failed to write response to file %s: %w", output, err)
+			}
+			if err := os.Rename(partial, output); err != nil {
 				return fmt.Errorf("failed to write response to file %s: %w", output, err)
 			}
 			p.debugf("Response successfully written to file: %s", output)
@@ -34,3 +57,39 @@ func (p *Processor) handleOutput(modelName string, response string, outputs []st
 	}
 	return nil
 }
+
+// splitOutputs separates database and HTTP output destinations from file and STDOUT destinations.
+// Output can be a single destination or a list mixing strings, database maps and http maps.
+func (p *Processor) splitOutputs(output interface{}) ([]string, []map[string]interface{}, []map[string]interface{}) {
+	var destinations []string
+	var databaseOutputs []map[string]interface{}
+	var httpOutputs []map[string]interface{}
+
+	addItem := func(item interface{}) {
+		switch v := item.(type) {
+		case string:
+			destinations = append(destinations, v)
+		case map[string]interface{}:
+			if _, hasDB := v["database"]; hasDB {
+				databaseOutputs = append(databaseOutputs, v)
+			} else if _, hasHTTP := v["http"]; hasHTTP {
+				httpOutputs = append(httpOutputs, v)
+			} else if filename, ok := v["filename"].(string); ok {
+				destinations = append(destinations, filename)
+			}
+		}
+	}
+
+	switch v := output.(type) {
+	case []interface{}:
+		for _, item := range v {
+			addItem(item)
+		}
+	case map[string]interface{}:
+		addItem(v)
+	default:
+		destinations = p.NormalizeStringSlice(output)
+	}
+
+	return destinations, databaseOutputs, httpOutputs
+}
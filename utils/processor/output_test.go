@@ -0,0 +1,428 @@
+package processor
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kris-hansen/comanda/utils/models"
+)
+
+func TestSplitOutputs(t *testing.T) {
+	dbOutput := map[string]interface{}{"database": "analytics", "sql": "INSERT INTO results VALUES ($1)"}
+	httpOutput := map[string]interface{}{"http": map[string]interface{}{"url": "https://example.com/hook"}}
+
+	tests := []struct {
+		name             string
+		output           interface{}
+		wantDestinations []string
+		wantDatabases    int
+		wantHTTP         int
+	}{
+		{
+			name:             "single destination",
+			output:           "STDOUT",
+			wantDestinations: []string{"STDOUT"},
+		},
+		{
+			name:             "list of destinations",
+			output:           []interface{}{"results.txt", "STDOUT"},
+			wantDestinations: []string{"results.txt", "STDOUT"},
+		},
+		{
+			name:          "database only",
+			output:        dbOutput,
+			wantDatabases: 1,
+		},
+		{
+			name:             "mixed files and database",
+			output:           []interface{}{"results.txt", dbOutput},
+			wantDestinations: []string{"results.txt"},
+			wantDatabases:    1,
+		},
+		{
+			name:             "mixed files and http",
+			output:           []interface{}{"STDOUT", httpOutput},
+			wantDestinations: []string{"STDOUT"},
+			wantHTTP:         1,
+		},
+	}
+
+	processor := NewProcessor(&DSLConfig{}, createTestEnvConfig(), false)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			destinations, databases, httpOutputs := processor.splitOutputs(tt.output)
+			if len(destinations) == 0 && len(tt.wantDestinations) == 0 {
+				destinations = nil
+			}
+			if !reflect.DeepEqual(destinations, tt.wantDestinations) {
+				t.Errorf("splitOutputs() destinations = %v, want %v", destinations, tt.wantDestinations)
+			}
+			if len(databases) != tt.wantDatabases {
+				t.Errorf("splitOutputs() returned %d database outputs, want %d", len(databases), tt.wantDatabases)
+			}
+			if len(httpOutputs) != tt.wantHTTP {
+				t.Errorf("splitOutputs() returned %d http outputs, want %d", len(httpOutputs), tt.wantHTTP)
+			}
+		})
+	}
+}
+
+func TestProcessMultipleOutputs(t *testing.T) {
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.txt")
+	if err := os.WriteFile(sourceFile, []byte("shared result"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	first := filepath.Join(tmpDir, "first.txt")
+	second := filepath.Join(tmpDir, "nested", "second.txt")
+
+	config := DSLConfig{
+		Steps: []Step{
+			{
+				Name: "duplicate",
+				Config: StepConfig{
+					Input:  []string{sourceFile},
+					Model:  []string{"NA"},
+					Action: []string{"pass"},
+					Output: []interface{}{first, "STDOUT", second},
+				},
+			},
+		},
+	}
+
+	processor := NewProcessor(&config, createTestEnvConfig(), false)
+	if err := processor.Process(); err != nil {
+		t.Fatalf("Process() unexpected error: %v", err)
+	}
+
+	for _, path := range []string{first, second} {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read output %s: %v", path, err)
+		}
+		if string(content) != "shared result" {
+			t.Errorf("output %s = %q, want %q", path, string(content), "shared result")
+		}
+	}
+	if processor.LastOutput() != "shared result" {
+		t.Errorf("LastOutput() = %q, want %q", processor.LastOutput(), "shared result")
+	}
+}
+
+func TestProcessOutputDir(t *testing.T) {
+	workDir := t.TempDir()
+	outputDir := filepath.Join(t.TempDir(), "run")
+	absolute := filepath.Join(t.TempDir(), "absolute.txt")
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working directory: %v", err)
+	}
+	if err := os.Chdir(workDir); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+	defer os.Chdir(wd)
+
+	if err := os.WriteFile("source.txt", []byte("draft"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	config := DSLConfig{
+		Steps: []Step{
+			{
+				Name: "draft",
+				Config: StepConfig{
+					Input:  []string{"source.txt"},
+					Model:  []string{"NA"},
+					Action: []string{"pass"},
+					Output: []string{"notes/draft.txt"},
+				},
+			},
+			{
+				Name: "publish",
+				Config: StepConfig{
+					Input:  []string{"notes/draft.txt"},
+					Model:  []string{"NA"},
+					Action: []string{"pass"},
+					Output: []string{"final.txt", absolute, "STDOUT"},
+				},
+			},
+		},
+	}
+
+	processor := NewProcessor(&config, createTestEnvConfig(), false)
+	processor.SetOutputDir(outputDir)
+	if err := processor.Process(); err != nil {
+		t.Fatalf("Process() unexpected error: %v", err)
+	}
+
+	for _, path := range []string{filepath.Join(outputDir, "notes", "draft.txt"), filepath.Join(outputDir, "final.txt"), absolute} {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read output %s: %v", path, err)
+		}
+		if string(content) != "draft" {
+			t.Errorf("output %s = %q, want %q", path, string(content), "draft")
+		}
+	}
+	for _, path := range []string{"notes", "final.txt"} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s was written to the working directory", path)
+		}
+	}
+}
+
+func TestProcessOutputDirInputMaps(t *testing.T) {
+	workDir := t.TempDir()
+	outputDir := filepath.Join(t.TempDir(), "run")
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working directory: %v", err)
+	}
+	if err := os.Chdir(workDir); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+	defer os.Chdir(wd)
+
+	if err := os.WriteFile("source.json", []byte(`{"title": "draft"}`), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	config := DSLConfig{
+		Steps: []Step{
+			{
+				Name: "draft",
+				Config: StepConfig{
+					Input:  []string{"source.json"},
+					Model:  []string{"NA"},
+					Action: []string{"pass"},
+					Output: []string{"draft.json"},
+				},
+			},
+			{
+				Name: "files",
+				Config: StepConfig{
+					Input:  map[string]interface{}{"files": []interface{}{"draft.json"}},
+					Model:  []string{"NA"},
+					Action: []string{"pass"},
+					Output: []string{"files.txt"},
+				},
+			},
+			{
+				Name: "query",
+				Config: StepConfig{
+					Input:  map[string]interface{}{"file": "draft.json", "query": ".title"},
+					Model:  []string{"NA"},
+					Action: []string{"pass"},
+					Output: []string{"query.txt"},
+				},
+			},
+		},
+	}
+
+	processor := NewProcessor(&config, createTestEnvConfig(), false)
+	processor.SetOutputDir(outputDir)
+	if err := processor.Process(); err != nil {
+		t.Fatalf("Process() unexpected error: %v", err)
+	}
+
+	for name, want := range map[string]string{"files.txt": `{"title": "draft"}`, "query.txt": "draft"} {
+		content, err := os.ReadFile(filepath.Join(outputDir, name))
+		if err != nil {
+			t.Fatalf("Failed to read output %s: %v", name, err)
+		}
+		if !strings.Contains(string(content), want) {
+			t.Errorf("output %s = %q, want it to contain %q", name, string(content), want)
+		}
+	}
+}
+
+func TestProcessHTTPOutput(t *testing.T) {
+	var gotBody, gotAuth, gotContentType string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		gotAuth = r.Header.Get("Authorization")
+		gotContentType = r.Header.Get("Content-Type")
+		if r.URL.Path == "/fail" {
+			http.Error(w, "channel not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.txt")
+	if err := os.WriteFile(sourceFile, []byte("build passed"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	tests := []struct {
+		name            string
+		settings        map[string]interface{}
+		wantBody        string
+		wantContentType string
+		expectError     string
+	}{
+		{
+			name: "plain body with headers",
+			settings: map[string]interface{}{
+				"url":     server.URL + "/hook",
+				"headers": map[string]interface{}{"Authorization": "Bearer token"},
+			},
+			wantBody:        "build passed",
+			wantContentType: "text/plain; charset=utf-8",
+		},
+		{
+			name:            "json field",
+			settings:        map[string]interface{}{"url": server.URL + "/hook", "json_field": "text"},
+			wantBody:        `{"text":"build passed"}`,
+			wantContentType: "application/json",
+		},
+		{
+			name:        "non-2xx response",
+			settings:    map[string]interface{}{"url": server.URL + "/fail", "timeout": "5s"},
+			expectError: "404 Not Found: channel not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DSLConfig{
+				Steps: []Step{
+					{
+						Name: "notify",
+						Config: StepConfig{
+							Input:  []string{sourceFile},
+							Model:  []string{"NA"},
+							Action: []string{"pass"},
+							Output: []interface{}{map[string]interface{}{"http": tt.settings}},
+						},
+					},
+				},
+			}
+
+			processor := NewProcessor(&config, createTestEnvConfig(), false)
+			err := processor.Process()
+			if tt.expectError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectError) {
+					t.Fatalf("Process() error = %v, want error containing %q", err, tt.expectError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Process() unexpected error: %v", err)
+			}
+			if gotBody != tt.wantBody {
+				t.Errorf("request body = %q, want %q", gotBody, tt.wantBody)
+			}
+			if gotContentType != tt.wantContentType {
+				t.Errorf("Content-Type = %q, want %q", gotContentType, tt.wantContentType)
+			}
+			if tt.settings["headers"] != nil && gotAuth != "Bearer token" {
+				t.Errorf("Authorization = %q, want %q", gotAuth, "Bearer token")
+			}
+		})
+	}
+}
+
+func TestParseHTTPOutput(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings interface{}
+		wantErr  bool
+	}{
+		{name: "defaults", settings: map[string]interface{}{"url": "https://example.com"}},
+		{name: "timeout in seconds", settings: map[string]interface{}{"url": "https://example.com", "timeout": 10}},
+		{name: "missing url", settings: map[string]interface{}{"method": "POST"}, wantErr: true},
+		{name: "not a map", settings: "https://example.com", wantErr: true},
+		{name: "unsupported method", settings: map[string]interface{}{"url": "https://example.com", "method": "GET"}, wantErr: true},
+		{name: "invalid timeout", settings: map[string]interface{}{"url": "https://example.com", "timeout": "soon"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseHTTPOutput(map[string]interface{}{"http": tt.settings})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseHTTPOutput() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// streamingProvider streams its response in two pieces and records the partial output file's
+// content between them
+type streamingProvider struct {
+	models.Provider
+	partial string
+	seen    string
+}
+
+func (s *streamingProvider) SendPromptStream(model, prompt string, handler models.StreamHandler) (string, error) {
+	if err := handler.OnChunk("Hello, "); err != nil {
+		return "", err
+	}
+	content, _ := os.ReadFile(s.partial)
+	s.seen = string(content)
+	if err := handler.OnChunk("world"); err != nil {
+		return "", err
+	}
+	return "Hello, world", nil
+}
+
+func TestStreamOutputToFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	outputFile := filepath.Join(tmpDir, "out", "story.md")
+
+	processor := NewProcessor(&DSLConfig{}, createTestEnvConfig(), false)
+	mock := NewMockProvider("openai")
+	mock.Configure("test-key")
+	provider := &streamingProvider{Provider: mock, partial: outputFile + partialSuffix}
+	processor.providers["openai"] = provider
+
+	stepConfig := StepConfig{Output: []interface{}{outputFile, "STDOUT", filepath.Join(tmpDir, "image.png as base64")}}
+	processor.streamPaths = processor.streamOutputs([]string{"gpt-4o"}, stepConfig)
+	if !reflect.DeepEqual(processor.streamPaths, []string{outputFile + partialSuffix}) {
+		t.Fatalf("streamOutputs() = %v, want only the partial file of %s", processor.streamPaths, outputFile)
+	}
+
+	response, err := processor.processActions([]string{"gpt-4o"}, []string{"write a story"}, stepConfig)
+	if err != nil {
+		t.Fatalf("processActions() unexpected error: %v", err)
+	}
+	if provider.seen != "Hello, " {
+		t.Errorf("partial output while streaming = %q, want %q", provider.seen, "Hello, ")
+	}
+	if _, err := os.Stat(outputFile); !os.IsNotExist(err) {
+		t.Errorf("output file exists before the step's output is handled: %v", err)
+	}
+
+	if err := processor.handleOutput("gpt-4o", response, []string{outputFile}); err != nil {
+		t.Fatalf("handleOutput() unexpected error: %v", err)
+	}
+	processor.removePartialOutputs()
+	content, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	if string(content) != "Hello, world" {
+		t.Errorf("output = %q, want %q", string(content), "Hello, world")
+	}
+	if _, err := os.Stat(outputFile + partialSuffix); !os.IsNotExist(err) {
+		t.Errorf("partial output left behind: %v", err)
+	}
+}
+
+func TestStreamOutputsSkipsAggregate(t *testing.T) {
+	processor := NewProcessor(&DSLConfig{}, createTestEnvConfig(), false)
+	stepConfig := StepConfig{Output: "result.txt", Aggregate: aggregateVote}
+	if paths := processor.streamOutputs([]string{"gpt-4o", "claude-3-5-sonnet-latest"}, stepConfig); len(paths) != 0 {
+		t.Errorf("streamOutputs() = %v, want none for an aggregated step", paths)
+	}
+}
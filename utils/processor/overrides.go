@@ -0,0 +1,55 @@
+package processor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ApplyOverrides patches the workflow's steps with the fields set for env in its overrides
+// block. Fields an override does not set keep their values from the workflow. It is an error
+// for env to have no overrides or for an override to name a step the workflow does not have.
+func (c *DSLConfig) ApplyOverrides(env string) error {
+	stepOverrides, ok := c.Overrides[env]
+	if !ok {
+		return fmt.Errorf("no overrides for environment '%s' (available: %s)", env, strings.Join(c.OverrideEnvironments(), ", "))
+	}
+
+	// Apply overrides in step order so errors are reported consistently
+	for i := range c.Steps {
+		node, ok := stepOverrides[c.Steps[i].Name]
+		if !ok {
+			continue
+		}
+		// Decoding into the existing config only replaces the fields the override sets
+		if err := node.Decode(&c.Steps[i].Config); err != nil {
+			return fmt.Errorf("failed to apply %s override to step %s: %w", env, c.Steps[i].Name, err)
+		}
+	}
+	for name := range stepOverrides {
+		if !c.hasStep(name) {
+			return fmt.Errorf("%s override refers to unknown step '%s'", env, name)
+		}
+	}
+	return nil
+}
+
+// OverrideEnvironments returns the environments in the workflow's overrides block, sorted by name
+func (c *DSLConfig) OverrideEnvironments() []string {
+	envs := make([]string, 0, len(c.Overrides))
+	for env := range c.Overrides {
+		envs = append(envs, env)
+	}
+	sort.Strings(envs)
+	return envs
+}
+
+// hasStep reports whether the workflow has a step with the given name
+func (c *DSLConfig) hasStep(name string) bool {
+	for _, step := range c.Steps {
+		if step.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package processor
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApplyOverrides(t *testing.T) {
+	data := []byte(`
+vars:
+  region: us-east
+
+summarize:
+  input: report.txt
+  model: gpt-4o
+  action: "Summarize the report"
+  output: summary.txt
+
+publish:
+  input: summary.txt
+  model: gpt-4o
+  action: "Write a post"
+  output: post.txt
+
+overrides:
+  dev:
+    summarize:
+      model: gpt-4o-mini
+      output: dev/summary-$region.txt
+    publish:
+      input: dev/summary-$region.txt
+  prod:
+    publish:
+      output: [post.txt, archive/post.txt]
+  broken:
+    missing:
+      model: gpt-4o-mini
+`)
+
+	tests := []struct {
+		name       string
+		env        string
+		wantErr    string
+		wantModel  interface{}
+		wantOutput interface{}
+		wantInput  interface{}
+	}{
+		{
+			name:       "dev patches both steps",
+			env:        "dev",
+			wantModel:  "gpt-4o-mini",
+			wantOutput: "dev/summary-$region.txt",
+			wantInput:  "dev/summary-$region.txt",
+		},
+		{
+			name:       "prod leaves summarize unchanged",
+			env:        "prod",
+			wantModel:  "gpt-4o",
+			wantOutput: "summary.txt",
+			wantInput:  "summary.txt",
+		},
+		{name: "unknown environment", env: "staging", wantErr: "no overrides for environment 'staging' (available: broken, dev, prod)"},
+		{name: "unknown step", env: "broken", wantErr: "unknown step 'missing'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := ParseDSL(data)
+			if err != nil {
+				t.Fatalf("ParseDSL() unexpected error: %v", err)
+			}
+			if len(config.Steps) != 2 {
+				t.Fatalf("ParseDSL() returned %d steps, want the overrides block left out", len(config.Steps))
+			}
+
+			err = config.ApplyOverrides(tt.env)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ApplyOverrides() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ApplyOverrides() unexpected error: %v", err)
+			}
+
+			summarize, publish := config.Steps[0].Config, config.Steps[1].Config
+			if summarize.Model != tt.wantModel || summarize.Output != tt.wantOutput {
+				t.Errorf("summarize model = %v, output = %v, want %v and %v", summarize.Model, summarize.Output, tt.wantModel, tt.wantOutput)
+			}
+			if summarize.Action != "Summarize the report" || summarize.Input != "report.txt" {
+				t.Errorf("summarize fields the override does not set changed: %+v", summarize)
+			}
+			if publish.Input != tt.wantInput {
+				t.Errorf("publish input = %v, want %v", publish.Input, tt.wantInput)
+			}
+			if tt.env == "prod" {
+				want := []interface{}{"post.txt", "archive/post.txt"}
+				if !reflect.DeepEqual(publish.Output, want) {
+					t.Errorf("publish output = %v, want %v", publish.Output, want)
+				}
+			}
+		})
+	}
+}
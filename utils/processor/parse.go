@@ -0,0 +1,218 @@
+package processor
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/kris-hansen/comanda/utils/fileutil"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// varsKey is the reserved top-level key holding workflow variables rather than a step
+	varsKey = "vars"
+	// overridesKey is the reserved top-level key holding per-environment step overrides
+	overridesKey = "overrides"
+	// nameKey, descriptionKey and versionKey are reserved top-level keys holding workflow metadata.
+	// They are only treated as metadata when their value is a scalar, so existing steps with these
+	// names keep working.
+	nameKey        = "name"
+	descriptionKey = "description"
+	versionKey     = "version"
+	// mergeKey merges a mapping of steps, such as an included file, into the workflow
+	mergeKey = "<<"
+	// includeTag replaces a node with the content of another YAML file
+	includeTag = "!include"
+)
+
+// ParseDSL parses a YAML workflow into a DSLConfig, preserving the order of the steps.
+// Files named by !include tags are resolved relative to the current directory.
+func ParseDSL(data []byte) (*DSLConfig, error) {
+	return parseDSL(data, ".", "", nil)
+}
+
+// ParseDSLFile reads and parses a YAML workflow, resolving !include tags relative to the
+// directory of the including file. When root is not empty, included files must be inside it.
+func ParseDSLFile(path string, root string) (*DSLConfig, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve path %s: %w", path, err)
+	}
+	data, err := fileutil.SafeReadFile(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return parseDSL(data, filepath.Dir(absPath), root, []string{absPath})
+}
+
+// parseDSL parses a workflow after replacing its !include nodes. stack holds the files being
+// parsed, outermost first, and is used to detect include cycles.
+func parseDSL(data []byte, baseDir, root string, stack []string) (*DSLConfig, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, fmt.Errorf("failed to parse YAML: %w", err)
+	}
+
+	config := &DSLConfig{}
+	// The document node should have one child which is the mapping
+	if len(node.Content) == 0 || node.Content[0].Kind != yaml.MappingNode {
+		return config, nil
+	}
+
+	mapping := node.Content[0]
+	if err := resolveIncludes(mapping, baseDir, root, stack); err != nil {
+		return nil, err
+	}
+
+	pairs, err := topLevelPairs(mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each pair of nodes represents a key and its value
+	for i := 0; i < len(pairs); i += 2 {
+		name := pairs[i].Value
+		if name == varsKey {
+			if err := pairs[i+1].Decode(&config.Vars); err != nil {
+				return nil, fmt.Errorf("failed to decode %s block: %w", varsKey, err)
+			}
+			continue
+		}
+		if name == overridesKey {
+			if err := pairs[i+1].Decode(&config.Overrides); err != nil {
+				return nil, fmt.Errorf("failed to decode %s block: %w", overridesKey, err)
+			}
+			continue
+		}
+		if field := metadataField(config, name); field != nil && pairs[i+1].Kind == yaml.ScalarNode {
+			*field = pairs[i+1].Value
+			continue
+		}
+
+		var stepConfig StepConfig
+		if err := pairs[i+1].Decode(&stepConfig); err != nil {
+			return nil, fmt.Errorf("failed to decode step %s: %w", name, err)
+		}
+		config.Steps = append(config.Steps, Step{
+			Name:   name,
+			Config: stepConfig,
+		})
+	}
+
+	return config, nil
+}
+
+// metadataField returns the DSLConfig field for a reserved metadata key, or nil for other keys
+func metadataField(config *DSLConfig, key string) *string {
+	switch key {
+	case nameKey:
+		return &config.Name
+	case descriptionKey:
+		return &config.Description
+	case versionKey:
+		return &config.Version
+	}
+	return nil
+}
+
+// topLevelPairs returns the key and value nodes of the workflow mapping, splicing in the
+// entries of any << merge keys. Keys defined in the workflow itself take precedence.
+func topLevelPairs(mapping *yaml.Node) ([]*yaml.Node, error) {
+	defined := make(map[string]bool)
+	for i := 0; i < len(mapping.Content); i += 2 {
+		defined[mapping.Content[i].Value] = true
+	}
+
+	var pairs []*yaml.Node
+	for i := 0; i < len(mapping.Content); i += 2 {
+		key, value := mapping.Content[i], mapping.Content[i+1]
+		if key.Value != mergeKey {
+			pairs = append(pairs, key, value)
+			continue
+		}
+
+		if value.Kind == yaml.AliasNode {
+			value = value.Alias
+		}
+		if value.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("%s at the top level must merge a mapping of steps", mergeKey)
+		}
+		for j := 0; j < len(value.Content); j += 2 {
+			if !defined[value.Content[j].Value] {
+				pairs = append(pairs, value.Content[j], value.Content[j+1])
+			}
+		}
+	}
+	return pairs, nil
+}
+
+// resolveIncludes replaces each !include node with the content of the file it names
+func resolveIncludes(node *yaml.Node, baseDir, root string, stack []string) error {
+	if node.Tag == includeTag {
+		if node.Kind != yaml.ScalarNode {
+			return fmt.Errorf("%s must be followed by a file path", includeTag)
+		}
+		included, err := loadInclude(node.Value, baseDir, root, stack)
+		if err != nil {
+			return err
+		}
+		*node = *included
+		return nil
+	}
+
+	for _, child := range node.Content {
+		if err := resolveIncludes(child, baseDir, root, stack); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadInclude parses an included file, resolving its own includes relative to its directory
+func loadInclude(name, baseDir, root string, stack []string) (*yaml.Node, error) {
+	path := name
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(baseDir, path)
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve include %s: %w", name, err)
+	}
+
+	if root != "" {
+		absRoot, err := filepath.Abs(root)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve include root %s: %w", root, err)
+		}
+		if rel, err := filepath.Rel(absRoot, path); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("include %s is outside of %s", name, root)
+		}
+	}
+
+	for i, parent := range stack {
+		if parent == path {
+			cycle := append(append([]string{}, stack[i:]...), path)
+			return nil, fmt.Errorf("include cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	data, err := fileutil.SafeReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read include %s: %w", name, err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse include %s: %w", name, err)
+	}
+	if len(doc.Content) == 0 {
+		return nil, fmt.Errorf("include %s is empty", name)
+	}
+
+	content := doc.Content[0]
+	if err := resolveIncludes(content, filepath.Dir(path), root, append(stack, path)); err != nil {
+		return nil, err
+	}
+	return content, nil
+}
@@ -0,0 +1,185 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseDSL(t *testing.T) {
+	data := []byte(`
+step_two:
+  input: NA
+  model: gpt-4o-mini
+  action: "generate"
+  output: STDOUT
+
+step_one:
+  input: STDIN
+  model: [gpt-4o, gpt-4o-mini]
+  action: "analyze"
+  output: STDOUT
+`)
+
+	config, err := ParseDSL(data)
+	if err != nil {
+		t.Fatalf("ParseDSL() unexpected error: %v", err)
+	}
+
+	if len(config.Steps) != 2 {
+		t.Fatalf("ParseDSL() returned %d steps, want 2", len(config.Steps))
+	}
+	if config.Steps[0].Name != "step_two" || config.Steps[1].Name != "step_one" {
+		t.Errorf("ParseDSL() did not preserve step order: %s, %s", config.Steps[0].Name, config.Steps[1].Name)
+	}
+	if config.Steps[1].Config.Action != "analyze" {
+		t.Errorf("ParseDSL() action = %v, want analyze", config.Steps[1].Config.Action)
+	}
+
+	if _, err := ParseDSL([]byte("step: [unclosed")); err == nil {
+		t.Error("ParseDSL() expected error for invalid YAML")
+	}
+}
+
+func TestParseDSLVars(t *testing.T) {
+	data := []byte(`
+vars:
+  model: gpt-4o-mini
+  region: us-east
+
+summarize:
+  input: NA
+  model: $model
+  action: "Summarize sales for {{ region }}"
+  output: STDOUT
+`)
+
+	config, err := ParseDSL(data)
+	if err != nil {
+		t.Fatalf("ParseDSL() unexpected error: %v", err)
+	}
+	if len(config.Steps) != 1 {
+		t.Fatalf("ParseDSL() returned %d steps, want 1 (vars must not be treated as a step)", len(config.Steps))
+	}
+	if config.Vars["model"] != "gpt-4o-mini" || config.Vars["region"] != "us-east" {
+		t.Errorf("ParseDSL() vars = %v", config.Vars)
+	}
+}
+
+func TestParseDSLMetadata(t *testing.T) {
+	data := []byte(`
+name: Weekly sales report
+description: Summarizes the sales export for the leadership update
+version: 1.2
+
+summarize:
+  input: sales.csv
+  model: gpt-4o-mini
+  action: "Summarize"
+  output: STDOUT
+
+version_check:
+  input: NA
+  model: NA
+  action: "noop"
+  output: STDOUT
+`)
+
+	config, err := ParseDSL(data)
+	if err != nil {
+		t.Fatalf("ParseDSL() unexpected error: %v", err)
+	}
+	if config.Name != "Weekly sales report" || config.Description != "Summarizes the sales export for the leadership update" || config.Version != "1.2" {
+		t.Errorf("ParseDSL() metadata = %q, %q, %q", config.Name, config.Description, config.Version)
+	}
+	if len(config.Steps) != 2 {
+		t.Fatalf("ParseDSL() returned %d steps, want 2 (metadata must not be treated as steps)", len(config.Steps))
+	}
+
+	// A step named like a metadata key is still a step
+	config, err = ParseDSL([]byte(`
+name:
+  input: NA
+  model: NA
+  action: "noop"
+  output: STDOUT
+`))
+	if err != nil {
+		t.Fatalf("ParseDSL() unexpected error: %v", err)
+	}
+	if len(config.Steps) != 1 || config.Steps[0].Name != "name" || config.Name != "" {
+		t.Errorf("ParseDSL() steps = %+v, name = %q, want a step named name", config.Steps, config.Name)
+	}
+}
+
+func TestParseDSLFileIncludes(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		return path
+	}
+
+	writeFile("shared/steps.yaml", `
+fetch:
+  input: NA
+  model: NA
+  action: "fetch"
+  output: STDOUT
+summarize:
+  input: STDIN
+  model: gpt-4o-mini
+  action: "generic summary"
+  output: STDOUT
+`)
+	writeFile("shared/vars.yaml", `
+region: us-east
+`)
+	workflow := writeFile("workflow.yaml", `
+vars: !include shared/vars.yaml
+<<: !include shared/steps.yaml
+summarize:
+  input: STDIN
+  model: gpt-4o
+  action: "summarize for $region"
+  output: STDOUT
+`)
+
+	config, err := ParseDSLFile(workflow, "")
+	if err != nil {
+		t.Fatalf("ParseDSLFile() unexpected error: %v", err)
+	}
+
+	if config.Vars["region"] != "us-east" {
+		t.Errorf("ParseDSLFile() vars = %v, want region from include", config.Vars)
+	}
+	var names []string
+	for _, step := range config.Steps {
+		names = append(names, step.Name)
+	}
+	if strings.Join(names, ",") != "fetch,summarize" {
+		t.Fatalf("ParseDSLFile() steps = %v, want [fetch summarize]", names)
+	}
+	if config.Steps[1].Config.Model != "gpt-4o" {
+		t.Errorf("ParseDSLFile() summarize model = %v, want the workflow's own definition", config.Steps[1].Config.Model)
+	}
+
+	// Includes may not leave the root directory
+	outside := writeFile("outside.yaml", "vars: !include ../secrets.yaml\n")
+	if _, err := ParseDSLFile(outside, dir); err == nil || !strings.Contains(err.Error(), "outside of") {
+		t.Errorf("ParseDSLFile() error = %v, want include outside root error", err)
+	}
+
+	// Include cycles are reported instead of recursing forever
+	writeFile("a.yaml", "<<: !include b.yaml\n")
+	writeFile("b.yaml", "<<: !include a.yaml\n")
+	if _, err := ParseDSLFile(filepath.Join(dir, "a.yaml"), ""); err == nil || !strings.Contains(err.Error(), "include cycle") {
+		t.Errorf("ParseDSLFile() error = %v, want include cycle error", err)
+	}
+}
@@ -0,0 +1,97 @@
+package processor
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// postProcessors are the built-in cleanups a step's post_process list can apply to its output,
+// keyed by name
+var postProcessors = map[string]func(string) string{
+	"trim":                      strings.TrimSpace,
+	"strip_fences":              stripAnyCodeFence,
+	"dedent":                    dedent,
+	"strip_trailing_whitespace": stripTrailingWhitespace,
+	"collapse_blank_lines":      collapseBlankLines,
+}
+
+// blankLinesPattern matches two or more consecutive blank lines
+var blankLinesPattern = regexp.MustCompile(`\n(?:[ \t]*\n){2,}`)
+
+// validatePostProcess checks that the step's post_process list names known cleanups
+func (p *Processor) validatePostProcess(stepConfig StepConfig) error {
+	for _, name := range p.NormalizeStringSlice(stepConfig.PostProcess) {
+		if _, ok := postProcessors[name]; !ok {
+			names := make([]string, 0, len(postProcessors))
+			for known := range postProcessors {
+				names = append(names, known)
+			}
+			sort.Strings(names)
+			return fmt.Errorf("unknown post_process %s (available: %s)", name, strings.Join(names, ", "))
+		}
+	}
+	return nil
+}
+
+// postProcess applies the step's post_process cleanups to its output in order
+func (p *Processor) postProcess(output string, stepConfig StepConfig) string {
+	for _, name := range p.NormalizeStringSlice(stepConfig.PostProcess) {
+		if process, ok := postProcessors[name]; ok {
+			output = process(output)
+		}
+	}
+	return output
+}
+
+// stripAnyCodeFence removes a code fence wrapped around the whole output, whatever its language
+func stripAnyCodeFence(text string) string {
+	firstLine := strings.SplitN(strings.TrimSpace(text), "\n", 2)[0]
+	match := openingFencePattern.FindStringSubmatch(strings.TrimSpace(firstLine))
+	if match == nil {
+		return text
+	}
+	return stripCodeFence(text, []string{strings.ToLower(match[1])})
+}
+
+// dedent removes the leading whitespace common to every non-blank line
+func dedent(text string) string {
+	lines := strings.Split(text, "\n")
+	prefix := ""
+	found := false
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if !found {
+			prefix, found = indent, true
+			continue
+		}
+		for !strings.HasPrefix(indent, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	if prefix == "" {
+		return text
+	}
+	for i, line := range lines {
+		lines[i] = strings.TrimPrefix(line, prefix)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// stripTrailingWhitespace removes spaces and tabs at the end of every line
+func stripTrailingWhitespace(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// collapseBlankLines replaces runs of blank lines with a single blank line
+func collapseBlankLines(text string) string {
+	return blankLinesPattern.ReplaceAllString(text, "\n\n")
+}
@@ -0,0 +1,44 @@
+package processor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPostProcessors(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		want   string
+		config interface{}
+	}{
+		{"trim", "\n  hello \n", "hello", "trim"},
+		{"strip labeled fence", "```python\nprint(1)\n```", "print(1)", "strip_fences"},
+		{"keep text outside fence", "Here:\n```\nx\n```", "Here:\n```\nx\n```", "strip_fences"},
+		{"dedent", "    a\n      b\n\n    c", "a\n  b\n\nc", "dedent"},
+		{"dedent without common indent", "a\n  b", "a\n  b", "dedent"},
+		{"strip trailing whitespace", "a  \nb\t\r\nc", "a\nb\nc", "strip_trailing_whitespace"},
+		{"collapse blank lines", "a\n\n\n  \nb\n\nc", "a\n\nb\n\nc", "collapse_blank_lines"},
+		{"applied in order", "```\n  a  \n\n\n  b\n```\n", "a\n\nb", []interface{}{"strip_fences", "dedent", "collapse_blank_lines", "strip_trailing_whitespace", "trim"}},
+	}
+
+	processor := NewProcessor(&DSLConfig{}, createTestEnvConfig(), false)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := processor.postProcess(tt.input, StepConfig{PostProcess: tt.config}); got != tt.want {
+				t.Errorf("postProcess() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidatePostProcess(t *testing.T) {
+	processor := NewProcessor(&DSLConfig{}, createTestEnvConfig(), false)
+	if err := processor.validatePostProcess(StepConfig{PostProcess: []interface{}{"trim", "dedent"}}); err != nil {
+		t.Errorf("validatePostProcess() unexpected error: %v", err)
+	}
+	err := processor.validatePostProcess(StepConfig{PostProcess: "sed"})
+	if err == nil || !strings.Contains(err.Error(), "unknown post_process sed (available: collapse_blank_lines, dedent,") {
+		t.Errorf("validatePostProcess() = %v, want unknown post_process error listing the cleanups", err)
+	}
+}
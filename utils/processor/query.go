@@ -0,0 +1,380 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kris-hansen/comanda/utils/fileutil"
+)
+
+// Kinds of query operation
+const (
+	queryField   = iota // .name or ["name"]
+	queryIndex          // [2] or [-1]
+	querySlice          // [1:3]
+	queryIterate        // []
+)
+
+// queryOp is one operation of a parsed query, applied to every value produced by the one before
+type queryOp struct {
+	kind       int
+	field      string
+	index      int
+	start, end *int
+}
+
+// queryInput reads the JSON file of a query input map (file, query) and saves the result of the
+// query to a temporary file, which the step receives instead of the whole file. The caller removes
+// the file.
+func (p *Processor) queryInput(config map[string]interface{}) (string, error) {
+	file, _ := config["file"].(string)
+	expr, _ := config["query"].(string)
+	if file == "" || expr == "" {
+		return "", fmt.Errorf("query input requires file and query")
+	}
+	ops, err := parseQuery(expr)
+	if err != nil {
+		return "", err
+	}
+
+	path := p.outputInputPath(p.substituteVariables(file))
+	if err := p.checkSandbox(path); err != nil {
+		return "", err
+	}
+	data, err := fileutil.SafeReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return "", fmt.Errorf("%s is not valid JSON: %w", path, err)
+	}
+
+	results, err := evalQuery(ops, document)
+	if err != nil {
+		return "", fmt.Errorf("query %s on %s: %w", expr, path, err)
+	}
+	content, err := formatQueryResults(results)
+	if err != nil {
+		return "", err
+	}
+	p.debugf("Query %s on %s produced %d result(s)", expr, path, len(results))
+
+	tmpFile, err := os.CreateTemp("", "comanda-query-*.txt")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file for query result: %w", err)
+	}
+	tmpPath := tmpFile.Name()
+	p.trustPath(tmpPath)
+
+	_, err = tmpFile.WriteString(content)
+	tmpFile.Close()
+	if err != nil {
+		os.Remove(tmpPath)
+		return "", fmt.Errorf("failed to write query result to file: %w", err)
+	}
+	return tmpPath, nil
+}
+
+// validateQueryInput checks the query of a query input map without reading the file
+func validateQueryInput(input interface{}) error {
+	config, ok := input.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	if _, hasFile := config["file"]; !hasFile {
+		return nil
+	}
+	expr, _ := config["query"].(string)
+	if file, _ := config["file"].(string); file == "" || expr == "" {
+		return fmt.Errorf("query input requires file and query")
+	}
+	_, err := parseQuery(expr)
+	return err
+}
+
+// parseQuery parses a jq style path expression such as .items[].name or .items[] | .tags[0].
+// Pipes between paths are allowed; filters and functions are not supported.
+func parseQuery(expr string) ([]queryOp, error) {
+	var ops []queryOp
+	for _, segment := range splitQuery(expr) {
+		segmentOps, err := parseQuerySegment(strings.TrimSpace(segment))
+		if err != nil {
+			return nil, fmt.Errorf("invalid query %q: %w", expr, err)
+		}
+		ops = append(ops, segmentOps...)
+	}
+	return ops, nil
+}
+
+// splitQuery splits an expression at the pipes outside quoted names
+func splitQuery(expr string) []string {
+	var segments []string
+	start, quoted := 0, false
+	for i := 0; i < len(expr); i++ {
+		switch expr[i] {
+		case '\\':
+			if quoted {
+				i++
+			}
+		case '"':
+			quoted = !quoted
+		case '|':
+			if !quoted {
+				segments = append(segments, expr[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(segments, expr[start:])
+}
+
+// parseQuerySegment parses one path of a query, which must start with a dot
+func parseQuerySegment(segment string) ([]queryOp, error) {
+	if !strings.HasPrefix(segment, ".") {
+		return nil, fmt.Errorf("paths must start with '.', got %q", segment)
+	}
+
+	var ops []queryOp
+	for i := 0; i < len(segment); {
+		switch segment[i] {
+		case '.':
+			i++
+			if i < len(segment) && segment[i] == '"' {
+				name, n, err := readQueryString(segment[i:])
+				if err != nil {
+					return nil, err
+				}
+				ops = append(ops, queryOp{kind: queryField, field: name})
+				i += n
+			} else if i < len(segment) && isQueryNameChar(segment[i], true) {
+				start := i
+				for i < len(segment) && isQueryNameChar(segment[i], false) {
+					i++
+				}
+				ops = append(ops, queryOp{kind: queryField, field: segment[start:i]})
+			} else if i < len(segment) && segment[i] != '[' {
+				return nil, fmt.Errorf("unexpected %q after '.'", segment[i])
+			}
+		case '[':
+			end := strings.IndexByte(segment[i:], ']')
+			if strings.HasPrefix(segment[i+1:], `"`) {
+				name, n, err := readQueryString(segment[i+1:])
+				if err != nil {
+					return nil, err
+				}
+				if !strings.HasPrefix(segment[i+1+n:], "]") {
+					return nil, fmt.Errorf("missing ']' after %q", name)
+				}
+				ops = append(ops, queryOp{kind: queryField, field: name})
+				i += n + 2
+				continue
+			}
+			if end < 0 {
+				return nil, fmt.Errorf("missing ']'")
+			}
+			op, err := parseQueryBracket(strings.TrimSpace(segment[i+1 : i+end]))
+			if err != nil {
+				return nil, err
+			}
+			ops = append(ops, op)
+			i += end + 1
+		default:
+			return nil, fmt.Errorf("unexpected %q", segment[i])
+		}
+	}
+	return ops, nil
+}
+
+// parseQueryBracket parses the contents of brackets: nothing, an index or a slice
+func parseQueryBracket(content string) (queryOp, error) {
+	if content == "" {
+		return queryOp{kind: queryIterate}, nil
+	}
+	if from, to, isSlice := strings.Cut(content, ":"); isSlice {
+		op := queryOp{kind: querySlice}
+		for _, bound := range []struct {
+			text   string
+			target **int
+		}{{from, &op.start}, {to, &op.end}} {
+			text := strings.TrimSpace(bound.text)
+			if text == "" {
+				continue
+			}
+			n, err := strconv.Atoi(text)
+			if err != nil {
+				return queryOp{}, fmt.Errorf("invalid slice [%s]", content)
+			}
+			*bound.target = &n
+		}
+		return op, nil
+	}
+	index, err := strconv.Atoi(content)
+	if err != nil {
+		return queryOp{}, fmt.Errorf("invalid index [%s]", content)
+	}
+	return queryOp{kind: queryIndex, index: index}, nil
+}
+
+// readQueryString reads a double quoted name at the start of s and returns it with the length of
+// the quoted text
+func readQueryString(s string) (string, int, error) {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			name, err := strconv.Unquote(s[:i+1])
+			if err != nil {
+				return "", 0, fmt.Errorf("invalid name %s", s[:i+1])
+			}
+			return name, i + 1, nil
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated name %s", s)
+}
+
+// isQueryNameChar reports whether c can appear in an unquoted field name
+func isQueryNameChar(c byte, first bool) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (!first && c >= '0' && c <= '9')
+}
+
+// evalQuery applies the operations of a query to a decoded JSON document. As in jq, fields and
+// indexes of null, missing fields and indexes out of range give null. Objects are iterated in key order.
+func evalQuery(ops []queryOp, document interface{}) ([]interface{}, error) {
+	values := []interface{}{document}
+	for _, op := range ops {
+		var next []interface{}
+		for _, value := range values {
+			results, err := applyQueryOp(op, value)
+			if err != nil {
+				return nil, err
+			}
+			next = append(next, results...)
+		}
+		values = next
+	}
+	return values, nil
+}
+
+// applyQueryOp applies one query operation to a value
+func applyQueryOp(op queryOp, value interface{}) ([]interface{}, error) {
+	switch op.kind {
+	case queryField:
+		switch v := value.(type) {
+		case nil:
+			return []interface{}{nil}, nil
+		case map[string]interface{}:
+			return []interface{}{v[op.field]}, nil
+		}
+		return nil, fmt.Errorf("cannot get field %q of %s", op.field, jsonTypeName(value))
+	case queryIndex:
+		switch v := value.(type) {
+		case nil:
+			return []interface{}{nil}, nil
+		case []interface{}:
+			index := op.index
+			if index < 0 {
+				index += len(v)
+			}
+			if index < 0 || index >= len(v) {
+				return []interface{}{nil}, nil
+			}
+			return []interface{}{v[index]}, nil
+		}
+		return nil, fmt.Errorf("cannot index %s with %d", jsonTypeName(value), op.index)
+	case querySlice:
+		switch v := value.(type) {
+		case nil:
+			return []interface{}{nil}, nil
+		case []interface{}:
+			start, end := sliceBound(op.start, len(v), 0), sliceBound(op.end, len(v), len(v))
+			if start > end {
+				start = end
+			}
+			return []interface{}{v[start:end]}, nil
+		}
+		return nil, fmt.Errorf("cannot slice %s", jsonTypeName(value))
+	default:
+		switch v := value.(type) {
+		case []interface{}:
+			return v, nil
+		case map[string]interface{}:
+			keys := make([]string, 0, len(v))
+			for key := range v {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			results := make([]interface{}, 0, len(v))
+			for _, key := range keys {
+				results = append(results, v[key])
+			}
+			return results, nil
+		}
+		return nil, fmt.Errorf("cannot iterate over %s", jsonTypeName(value))
+	}
+}
+
+// sliceBound resolves an optional, possibly negative slice bound to a position within length
+func sliceBound(bound *int, length, missing int) int {
+	if bound == nil {
+		return missing
+	}
+	n := *bound
+	if n < 0 {
+		n += length
+	}
+	if n < 0 {
+		return 0
+	}
+	if n > length {
+		return length
+	}
+	return n
+}
+
+// jsonTypeName names the JSON type of a decoded value for error messages
+func jsonTypeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// formatQueryResults writes each result on its own lines: strings and numbers as they are and
+// other values as indented JSON
+func formatQueryResults(results []interface{}) (string, error) {
+	lines := make([]string, 0, len(results))
+	for _, result := range results {
+		switch v := result.(type) {
+		case string:
+			lines = append(lines, v)
+		case json.Number:
+			lines = append(lines, v.String())
+		default:
+			encoded, err := json.MarshalIndent(v, "", "  ")
+			if err != nil {
+				return "", fmt.Errorf("failed to encode query result: %w", err)
+			}
+			lines = append(lines, string(encoded))
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,91 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestQueryInput(t *testing.T) {
+	document := `{
+		"items": [
+			{"name": "alpha", "price": 1.50, "tags": ["new", "sale"]},
+			{"name": "beta", "price": 20, "tags": []},
+			{"name": "gamma", "price": 3, "tags": ["sale"]}
+		],
+		"meta": {"total count": 3, "source": null}
+	}`
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data.json")
+	if err := os.WriteFile(path, []byte(document), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query   string
+		want    string
+		wantErr string
+	}{
+		{query: ".items[].name", want: "alpha\nbeta\ngamma"},
+		{query: ".items[] | .price", want: "1.50\n20\n3"},
+		{query: ".items[0].tags[-1]", want: "sale"},
+		{query: `.meta."total count"`, want: "3"},
+		{query: `.meta["total count"]`, want: "3"},
+		{query: ".items[1:].name", wantErr: "cannot get field \"name\" of array"},
+		{query: ".items[1:][].name", want: "beta\ngamma"},
+		{query: ".meta.source.id", want: "null"},
+		{query: ".items[5]", want: "null"},
+		{query: ".meta", want: "{\n  \"source\": null,\n  \"total count\": 3\n}"},
+		{query: ".meta[]", want: "null\n3"},
+		{query: ".items[0].name[]", wantErr: "cannot iterate over string"},
+		{query: "items", wantErr: "paths must start with '.'"},
+		{query: ".items[x]", wantErr: "invalid index [x]"},
+		{query: ".items[", wantErr: "missing ']'"},
+	}
+
+	processor := NewProcessor(&DSLConfig{}, createTestEnvConfig(), false)
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			tmpPath, err := processor.queryInput(map[string]interface{}{"file": path, "query": tt.query})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("queryInput() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("queryInput() unexpected error: %v", err)
+			}
+			defer os.Remove(tmpPath)
+			got, err := os.ReadFile(tmpPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("queryInput() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateQueryInput(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   interface{}
+		wantErr bool
+	}{
+		{"plain file input", "data.json", false},
+		{"directory input", map[string]interface{}{"dir": "docs"}, false},
+		{"valid query", map[string]interface{}{"file": "data.json", "query": ".items[].name"}, false},
+		{"missing query", map[string]interface{}{"file": "data.json"}, true},
+		{"invalid query", map[string]interface{}{"file": "data.json", "query": ".items[?]"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateQueryInput(tt.input); (err != nil) != tt.wantErr {
+				t.Errorf("validateQueryInput() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package processor
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// redactionRule replaces matches of a pattern with a [REDACTED_<NAME>] token
+type redactionRule struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+// builtinRedactionRules are applied when a step sets redact: true.
+// API keys are matched before other patterns so their prefixes are not partially redacted.
+var builtinRedactionRules = []redactionRule{
+	{"api_key", regexp.MustCompile(`\b(?:sk-(?:ant-)?[A-Za-z0-9_-]{20,}|AKIA[0-9A-Z]{16}|AIza[0-9A-Za-z_-]{35}|gh[pousr]_[A-Za-z0-9]{36,}|xai-[A-Za-z0-9]{20,})\b`)},
+	{"email", regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)},
+	{"ssn", regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`)},
+	{"credit_card", regexp.MustCompile(`\b(?:\d{4}[ -]?){3}\d{4}\b`)},
+}
+
+// redactor scrubs sensitive values from input content before it is sent to a model
+type redactor struct {
+	rules []redactionRule
+}
+
+// redact replaces every match of the redactor's rules and returns the scrubbed text
+// along with the number of values replaced
+func (r *redactor) redact(text string) (string, int) {
+	count := 0
+	for _, rule := range r.rules {
+		token := fmt.Sprintf("[REDACTED_%s]", strings.ToUpper(rule.name))
+		text = rule.pattern.ReplaceAllStringFunc(text, func(string) string {
+			count++
+			return token
+		})
+	}
+	return text, count
+}
+
+// stepRedactor builds the redactor for a step's redact setting. It returns nil when redaction is
+// disabled. redact: true applies the built-in rules followed by any custom patterns from the
+// environment configuration; a list applies only the named rules.
+func (p *Processor) stepRedactor(stepConfig StepConfig) (*redactor, error) {
+	custom, err := p.customRedactionRules()
+	if err != nil {
+		return nil, err
+	}
+
+	switch v := stepConfig.Redact.(type) {
+	case nil:
+		return nil, nil
+	case bool:
+		if !v {
+			return nil, nil
+		}
+		rules := append([]redactionRule{}, builtinRedactionRules...)
+		return &redactor{rules: append(rules, custom...)}, nil
+	}
+
+	names := p.NormalizeStringSlice(stepConfig.Redact)
+	if len(names) == 0 {
+		return nil, fmt.Errorf("redact must be true, false, or a list of pattern names")
+	}
+
+	available := make(map[string]redactionRule)
+	for _, rule := range append(append([]redactionRule{}, builtinRedactionRules...), custom...) {
+		available[rule.name] = rule
+	}
+
+	var rules []redactionRule
+	for _, name := range names {
+		rule, ok := available[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown redaction pattern: %s", name)
+		}
+		rules = append(rules, rule)
+	}
+	return &redactor{rules: rules}, nil
+}
+
+// customRedactionRules compiles the redaction patterns from the environment configuration, sorted by name
+func (p *Processor) customRedactionRules() ([]redactionRule, error) {
+	if p.envConfig == nil || len(p.envConfig.RedactionPatterns) == 0 {
+		return nil, nil
+	}
+
+	names := make([]string, 0, len(p.envConfig.RedactionPatterns))
+	for name := range p.envConfig.RedactionPatterns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	rules := make([]redactionRule, 0, len(names))
+	for _, name := range names {
+		pattern, err := regexp.Compile(p.envConfig.RedactionPatterns[name])
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %s: %w", name, err)
+		}
+		rules = append(rules, redactionRule{name: name, pattern: pattern})
+	}
+	return rules, nil
+}
@@ -0,0 +1,129 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// StepResult records the outcome of a single step
+type StepResult struct {
+	Name         string        `json:"name"`
+	Model        string        `json:"model,omitempty"`
+	StartedAt    time.Time     `json:"started_at"`
+	DurationMs   int64         `json:"duration_ms"`
+	InputBytes   int           `json:"input_bytes"`
+	OutputBytes  int           `json:"output_bytes"`
+	InputTokens  int           `json:"input_tokens,omitempty"`
+	OutputTokens int           `json:"output_tokens,omitempty"`
+	Cost         float64       `json:"cost,omitempty"` // Estimated cost of the step's model calls in US dollars
+	Success      bool          `json:"success"`
+	Error        string        `json:"error,omitempty"`
+	Category     ErrorCategory `json:"error_category,omitempty"` // Category of the failure, e.g. provider or io
+	RecoveredBy  string        `json:"recovered_by,omitempty"`   // on_error step that handled the failure
+}
+
+// RunReport is a machine-readable summary of a single DSL run
+type RunReport struct {
+	File         string       `json:"file,omitempty"`
+	Workflow     string       `json:"workflow,omitempty"` // Workflow name from the top-level name key
+	Version      string       `json:"version,omitempty"`  // Workflow version from the top-level version key
+	Success      bool         `json:"success"`
+	DurationMs   int64        `json:"duration_ms"`
+	InputTokens  int          `json:"input_tokens"`
+	OutputTokens int          `json:"output_tokens"`
+	Cost         float64      `json:"cost"` // Estimated cost of the run's model calls in US dollars
+	Steps        []StepResult `json:"steps"`
+
+	Failures map[ErrorCategory]int `json:"failures,omitempty"` // Number of failed steps in each error category
+	Skipped  []string              `json:"skipped,omitempty"`  // Steps before the first step run, skipped by --from or --step
+	Warnings []string              `json:"warnings,omitempty"` // Warnings logged during the run
+}
+
+// StepResults returns the results recorded for the steps processed so far
+func (p *Processor) StepResults() []StepResult {
+	return p.results
+}
+
+// Report builds a run report from the recorded step results
+func (p *Processor) Report() *RunReport {
+	report := &RunReport{
+		Success:  p.finished,
+		Steps:    p.results,
+		Warnings: p.warnings,
+	}
+	if p.config != nil {
+		report.Workflow = p.config.Name
+		report.Version = p.config.Version
+		for _, step := range p.config.Steps {
+			if p.skipped[step.Name] {
+				report.Skipped = append(report.Skipped, step.Name)
+			}
+		}
+	}
+	if report.Steps == nil {
+		report.Steps = []StepResult{}
+	}
+	for _, result := range p.results {
+		report.DurationMs += result.DurationMs
+		report.InputTokens += result.InputTokens
+		report.OutputTokens += result.OutputTokens
+		report.Cost += result.Cost
+		if !result.Success && result.RecoveredBy == "" {
+			report.Success = false
+		}
+		if !result.Success && result.Category != "" {
+			if report.Failures == nil {
+				report.Failures = make(map[ErrorCategory]int)
+			}
+			report.Failures[result.Category]++
+		}
+	}
+	return report
+}
+
+// Summary describes the run in a few lines for people: the steps run and how long they took, the
+// models used, token usage and cost, skipped and failed steps, and warnings
+func (r *RunReport) Summary() string {
+	var b strings.Builder
+	status := "succeeded"
+	if !r.Success {
+		status = "failed"
+	}
+	fmt.Fprintf(&b, "Run %s: %d step(s) in %s\n", status, len(r.Steps), time.Duration(r.DurationMs)*time.Millisecond)
+
+	var usedModels []string
+	seen := make(map[string]bool)
+	for _, step := range r.Steps {
+		if step.Model != "" && step.Model != "NA" && !seen[step.Model] {
+			seen[step.Model] = true
+			usedModels = append(usedModels, step.Model)
+		}
+	}
+	if len(usedModels) > 0 {
+		fmt.Fprintf(&b, "Models: %s\n", strings.Join(usedModels, ", "))
+	}
+	if r.InputTokens > 0 || r.OutputTokens > 0 || r.Cost > 0 {
+		fmt.Fprintf(&b, "Tokens: %d in, %d out, estimated cost $%.4f\n", r.InputTokens, r.OutputTokens, r.Cost)
+	}
+	if len(r.Skipped) > 0 {
+		fmt.Fprintf(&b, "Skipped: %s\n", strings.Join(r.Skipped, ", "))
+	}
+	for _, step := range r.Steps {
+		if step.Success {
+			continue
+		}
+		if step.RecoveredBy != "" {
+			fmt.Fprintf(&b, "Failed: %s, recovered by %s\n", step.Name, step.RecoveredBy)
+		} else {
+			fmt.Fprintf(&b, "Failed: %s: %s\n", step.Name, step.Error)
+		}
+	}
+	if len(r.Warnings) > 0 {
+		fmt.Fprintf(&b, "Warnings (%d):\n", len(r.Warnings))
+		for _, warning := range r.Warnings {
+			fmt.Fprintf(&b, "  - %s\n", warning)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,89 @@
+package processor
+
+import (
+	"testing"
+)
+
+func TestReport(t *testing.T) {
+	config := DSLConfig{
+		Steps: []Step{
+			{
+				Name: "passthrough",
+				Config: StepConfig{
+					Input:  []string{"NA"},
+					Model:  []string{"NA"},
+					Action: []string{"pass"},
+					Output: []string{"STDOUT"},
+				},
+			},
+			{
+				Name: "missing_input",
+				Config: StepConfig{
+					Input:  []string{"nonexistent.txt"},
+					Model:  []string{"NA"},
+					Action: []string{"pass"},
+					Output: []string{"STDOUT"},
+				},
+			},
+		},
+	}
+
+	processor := NewProcessor(&config, createTestEnvConfig(), false)
+	if err := processor.Process(); err == nil {
+		t.Fatal("Process() expected error but got none")
+	}
+
+	report := processor.Report()
+	if report.Success {
+		t.Error("Report() Success = true, want false")
+	}
+	if len(report.Steps) != 2 {
+		t.Fatalf("Report() recorded %d steps, want 2", len(report.Steps))
+	}
+
+	first := report.Steps[0]
+	if !first.Success || first.Name != "passthrough" || first.Model != "NA" {
+		t.Errorf("Report() first step = %+v, want successful passthrough step using NA", first)
+	}
+
+	second := report.Steps[1]
+	if second.Success || second.Error == "" {
+		t.Errorf("Report() second step = %+v, want failure with error message", second)
+	}
+	if second.Category != CategoryIO {
+		t.Errorf("Report() second step category = %q, want %q", second.Category, CategoryIO)
+	}
+	if report.Failures[CategoryIO] != 1 || len(report.Failures) != 1 {
+		t.Errorf("Report() Failures = %v, want one io failure", report.Failures)
+	}
+}
+
+func TestReportSummary(t *testing.T) {
+	report := &RunReport{
+		Success:      false,
+		DurationMs:   2500,
+		InputTokens:  812,
+		OutputTokens: 164,
+		Cost:         0.0002,
+		Steps: []StepResult{
+			{Name: "load", Model: "NA", Success: true},
+			{Name: "draft", Model: "gpt-4o", Success: true},
+			{Name: "review", Model: "gpt-4o", Success: false, Error: "provider unavailable", RecoveredBy: "fallback"},
+			{Name: "fallback", Model: "claude-3-5-sonnet-latest", Success: false, Error: "rate limited"},
+		},
+		Skipped:  []string{"fetch"},
+		Warnings: []string{"max_tokens 9000 is more than model gpt-4o can generate; using 4096"},
+	}
+
+	want := "Run failed: 4 step(s) in 2.5s\n" +
+		"Models: gpt-4o, claude-3-5-sonnet-latest\n" +
+		"Tokens: 812 in, 164 out, estimated cost $0.0002\n" +
+		"Skipped: fetch\n" +
+		"Failed: review, recovered by fallback\n" +
+		"Failed: fallback: rate limited\n" +
+		"Warnings (1):\n" +
+		"  - max_tokens 9000 is more than model gpt-4o can generate; using 4096\n"
+	if got := report.Summary(); got != want {
+		t.Errorf("Summary() =\n%s\nwant\n%s", got, want)
+	}
+}
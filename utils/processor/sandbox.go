@@ -0,0 +1,98 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// errOutsideSandbox is returned for a file the workflow may not read or write
+var errOutsideSandbox = errors.New("outside the sandbox")
+
+// SetSandbox restricts the files a workflow may read and write to the given directories,
+// in addition to any sandbox directories from the environment configuration
+func (p *Processor) SetSandbox(dirs []string) {
+	p.sandboxDirs = append(p.sandboxDirs, dirs...)
+}
+
+// resolveSandbox resolves the configured sandbox directories to absolute paths without symlinks
+func (p *Processor) resolveSandbox() error {
+	dirs := p.sandboxDirs
+	if p.envConfig != nil {
+		dirs = append(append([]string{}, p.envConfig.Sandbox...), dirs...)
+	}
+
+	p.sandbox = nil
+	for _, dir := range dirs {
+		resolved, err := resolvePath(dir)
+		if err != nil {
+			return fmt.Errorf("invalid sandbox directory %s: %w", dir, err)
+		}
+		info, err := os.Stat(resolved)
+		if err != nil {
+			return fmt.Errorf("invalid sandbox directory %s: %w", dir, err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("invalid sandbox directory %s: not a directory", dir)
+		}
+		p.sandbox = append(p.sandbox, resolved)
+	}
+
+	if len(p.sandbox) > 0 {
+		p.debugf("File access restricted to: %s", strings.Join(p.sandbox, ", "))
+	}
+	return nil
+}
+
+// trustPath exempts a file created by the processor itself, such as a temporary file, from the sandbox
+func (p *Processor) trustPath(path string) {
+	p.trustedPaths[path] = true
+}
+
+// checkSandbox returns an error if a file the workflow reads or writes is outside the sandbox
+func (p *Processor) checkSandbox(path string) error {
+	if len(p.sandbox) == 0 || p.trustedPaths[path] {
+		return nil
+	}
+
+	resolved, err := resolvePath(path)
+	if err != nil {
+		return fmt.Errorf("failed to resolve path %s: %w", path, err)
+	}
+	for _, dir := range p.sandbox {
+		rel, err := filepath.Rel(dir, resolved)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("path %s is %w", path, errOutsideSandbox)
+}
+
+// resolvePath returns the absolute form of a path with symlinks resolved. Path components that
+// do not exist yet, such as a new output file, are appended to their nearest existing parent.
+func resolvePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	existing := abs
+	var missing []string
+	for {
+		resolved, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			return filepath.Join(append([]string{resolved}, missing...)...), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return abs, nil
+		}
+		missing = append([]string{filepath.Base(existing)}, missing...)
+		existing = parent
+	}
+}
@@ -0,0 +1,118 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProcessSandbox(t *testing.T) {
+	sandbox := t.TempDir()
+	outside := t.TempDir()
+
+	inside := filepath.Join(sandbox, "notes.txt")
+	secret := filepath.Join(outside, "secret.txt")
+	for _, path := range []string{inside, secret} {
+		if err := os.WriteFile(path, []byte("content"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+	link := filepath.Join(sandbox, "link.txt")
+	if err := os.Symlink(secret, link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		input       string
+		output      string
+		expectError string
+	}{
+		{
+			name:   "read and write inside sandbox",
+			input:  inside,
+			output: filepath.Join(sandbox, "out", "result.txt"),
+		},
+		{
+			name:        "read outside sandbox",
+			input:       secret,
+			output:      "STDOUT",
+			expectError: "outside the sandbox",
+		},
+		{
+			name:        "read through symlink leaving sandbox",
+			input:       link,
+			output:      "STDOUT",
+			expectError: "outside the sandbox",
+		},
+		{
+			name:        "write outside sandbox",
+			input:       inside,
+			output:      filepath.Join(sandbox, "..", filepath.Base(outside), "result.txt"),
+			expectError: "outside the sandbox",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DSLConfig{
+				Steps: []Step{
+					{
+						Name: "read",
+						Config: StepConfig{
+							Input:  []string{tt.input},
+							Model:  []string{"NA"},
+							Action: []string{"pass"},
+							Output: []string{tt.output},
+						},
+					},
+					{
+						Name: "chain",
+						Config: StepConfig{
+							Input:  []string{"step:read"},
+							Model:  []string{"NA"},
+							Action: []string{"pass"},
+							Output: []string{"STDOUT"},
+						},
+					},
+				},
+			}
+
+			processor := NewProcessor(&config, createTestEnvConfig(), false)
+			processor.SetSandbox([]string{sandbox})
+			err := processor.Process()
+			if tt.expectError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectError) {
+					t.Fatalf("Process() error = %v, want error containing %q", err, tt.expectError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Process() unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestResolveSandboxInvalidDirectory(t *testing.T) {
+	config := DSLConfig{
+		Steps: []Step{
+			{
+				Name: "pass",
+				Config: StepConfig{
+					Input:  []string{"NA"},
+					Model:  []string{"NA"},
+					Action: []string{"pass"},
+					Output: []string{"STDOUT"},
+				},
+			},
+		},
+	}
+
+	processor := NewProcessor(&config, createTestEnvConfig(), false)
+	processor.SetSandbox([]string{filepath.Join(t.TempDir(), "missing")})
+	if err := processor.Process(); err == nil || !strings.Contains(err.Error(), "invalid sandbox directory") {
+		t.Errorf("Process() error = %v, want invalid sandbox directory error", err)
+	}
+}
@@ -0,0 +1,88 @@
+package processor
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// SetStepRange limits the run to the steps from one named step to another, inclusive. An empty
+// from starts at the first step and an empty to ends at the last one. Steps before from are
+// skipped, so their outputs are only available as files left by an earlier run.
+func (p *Processor) SetStepRange(from, to string) {
+	p.runFrom = from
+	p.runTo = to
+}
+
+// selectSteps returns the indexes of the first and last steps to run and records the steps skipped
+// before the first one
+func (p *Processor) selectSteps(handlers map[string]bool) (int, int, error) {
+	start, end := 0, len(p.config.Steps)-1
+	if p.runFrom != "" && p.continueFrom != "" {
+		return 0, 0, fmt.Errorf("a step range cannot start at '%s' while continuing from '%s'", p.runFrom, p.continueFrom)
+	}
+	if p.runFrom != "" && p.checkpointPath != "" {
+		return 0, 0, fmt.Errorf("checkpointing cannot be used when starting at step '%s'", p.runFrom)
+	}
+
+	find := func(name, role string) (int, error) {
+		index, _, ok := p.findStep(name)
+		if !ok {
+			return 0, fmt.Errorf("step '%s' to %s not found", name, role)
+		}
+		if handlers[name] {
+			return 0, fmt.Errorf("cannot %s error handler step '%s'", role, name)
+		}
+		return index, nil
+	}
+	var err error
+	if p.runFrom != "" {
+		if start, err = find(p.runFrom, "run from"); err != nil {
+			return 0, 0, err
+		}
+	}
+	if p.runTo != "" {
+		if end, err = find(p.runTo, "run to"); err != nil {
+			return 0, 0, err
+		}
+	}
+	if start > end {
+		return 0, 0, fmt.Errorf("step '%s' comes after step '%s'", p.runFrom, p.runTo)
+	}
+
+	p.skipped = make(map[string]bool)
+	for _, step := range p.config.Steps[:start] {
+		if !handlers[step.Name] {
+			p.skipped[step.Name] = true
+		}
+	}
+	return start, end, nil
+}
+
+// skippedProducer returns the name of a skipped step that writes path as an output, if any
+func (p *Processor) skippedProducer(path string) string {
+	for _, step := range p.config.Steps {
+		if !p.skipped[step.Name] {
+			continue
+		}
+		for _, output := range p.substituteAll(p.NormalizeStringSlice(step.Config.Output)) {
+			if output != "STDOUT" && filepath.Clean(p.outputPath(output)) == filepath.Clean(path) {
+				return step.Name
+			}
+		}
+	}
+	return ""
+}
+
+// lastSkippedStep returns the step that would have provided STDIN to the first step of a run
+// that starts after skipping earlier steps. Once a step has run it returns an empty name.
+func (p *Processor) lastSkippedStep() string {
+	if len(p.results) > 0 {
+		return ""
+	}
+	for i := len(p.config.Steps) - 1; i >= 0; i-- {
+		if name := p.config.Steps[i].Name; p.skipped[name] {
+			return name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,99 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kris-hansen/comanda/utils/models"
+)
+
+// partialSuffix is appended to an output file's name while the file is written. A step's response
+// is streamed to the partial file as the model generates it, and the finished output replaces the
+// output file in a single rename.
+const partialSuffix = ".partial"
+
+// partialPath returns the file an output file is written to before it is renamed into place
+func partialPath(output string) string {
+	return output + partialSuffix
+}
+
+// streamOutputs returns the partial files a step's response is streamed to: those of its plain
+// file outputs. Steps that combine the answers of several models are not streamed, and neither
+// are outputs decoded from base64, which are only readable once complete.
+func (p *Processor) streamOutputs(modelNames []string, stepConfig StepConfig) []string {
+	if stepConfig.Aggregate != "" && len(modelNames) > 1 {
+		return nil
+	}
+
+	destinations, _, _ := p.splitOutputs(stepConfig.Output)
+	var paths []string
+	for _, output := range p.substituteAll(destinations) {
+		if output == "STDOUT" || output == clipboardTarget {
+			continue
+		}
+		if _, decode := cutBase64(output); decode {
+			continue
+		}
+		output = p.outputPath(output)
+		if err := p.checkSandbox(output); err != nil {
+			continue
+		}
+		paths = append(paths, partialPath(output))
+	}
+	return paths
+}
+
+// removePartialOutputs removes the partial files left by a step that did not write its outputs
+func (p *Processor) removePartialOutputs() {
+	for _, path := range p.streamPaths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			p.debugf("Failed to remove partial output %s: %v", path, err)
+		}
+	}
+	p.streamPaths = nil
+}
+
+// sendPrompt sends a prompt to a model. When the step has file outputs, the response is streamed
+// to their partial files so it can be followed while the model generates it.
+func (p *Processor) sendPrompt(provider models.Provider, modelName, prompt string) (string, error) {
+	if len(p.streamPaths) == 0 {
+		return providerCall(provider.SendPrompt(modelName, prompt))
+	}
+
+	var files []*os.File
+	defer func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}()
+	for _, path := range p.streamPaths {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return "", fmt.Errorf("failed to create directory %s: %w", filepath.Dir(path), err)
+		}
+		// Every call starts the file afresh, so a fallback model replaces a failed model's partial answer
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+		if err != nil {
+			return "", fmt.Errorf("failed to create partial output %s: %w", path, err)
+		}
+		files = append(files, file)
+	}
+	p.debugf("Streaming response to %s", strings.Join(p.streamPaths, ", "))
+
+	// A failed write is a local error, kept apart from the provider's own errors
+	var writeErr error
+	response, err := providerCall(provider.SendPromptStream(modelName, prompt, models.StreamFunc(func(text string) error {
+		for _, file := range files {
+			if _, err := file.WriteString(text); err != nil {
+				writeErr = fmt.Errorf("failed to write partial output %s: %w", file.Name(), err)
+				return writeErr
+			}
+		}
+		return nil
+	})))
+	if writeErr != nil {
+		return "", writeErr
+	}
+	return response, err
+}
@@ -0,0 +1,221 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/kris-hansen/comanda/utils/logging"
+)
+
+// templatePattern matches {{ expression }} references
+var templatePattern = regexp.MustCompile(`\{\{(.*?)\}\}`)
+
+// templateFuncs are the helpers available in {{ }} expressions. default is handled separately
+// because its first argument may be undefined, and secret because it can fail.
+var templateFuncs = map[string]func(string) string{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"trim":  strings.TrimSpace,
+	"json": func(s string) string {
+		encoded, _ := json.Marshal(s)
+		return string(encoded)
+	},
+}
+
+// templateValue is the result of evaluating part of an expression
+type templateValue struct {
+	value   string
+	defined bool // False for variables that are not set, so default() can replace them
+}
+
+// templateParser evaluates a single {{ }} expression. The grammar is deliberately small:
+//
+//	expr := call | operand
+//	call := name "(" [expr {"," expr}] ")"
+//	operand := "$"name | name | STDIN | "string" | 'string'
+type templateParser struct {
+	p     *Processor
+	input string
+	pos   int
+}
+
+// evaluateTemplate evaluates the expression inside {{ }}
+func (p *Processor) evaluateTemplate(expression string) (string, error) {
+	parser := &templateParser{p: p, input: expression}
+	result, err := parser.expr()
+	if err != nil {
+		return "", err
+	}
+	parser.skipSpace()
+	if parser.pos < len(parser.input) {
+		return "", fmt.Errorf("unexpected %q in template expression", parser.input[parser.pos:])
+	}
+	if !result.defined {
+		return "", fmt.Errorf("undefined variable in template expression %q", strings.TrimSpace(expression))
+	}
+	return result.value, nil
+}
+
+func (t *templateParser) skipSpace() {
+	for t.pos < len(t.input) && unicode.IsSpace(rune(t.input[t.pos])) {
+		t.pos++
+	}
+}
+
+// name reads an identifier
+func (t *templateParser) name() string {
+	start := t.pos
+	for t.pos < len(t.input) {
+		c := rune(t.input[t.pos])
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) && c != '_' {
+			break
+		}
+		t.pos++
+	}
+	return t.input[start:t.pos]
+}
+
+func (t *templateParser) expr() (templateValue, error) {
+	t.skipSpace()
+	if t.pos >= len(t.input) {
+		return templateValue{}, fmt.Errorf("empty template expression")
+	}
+
+	switch t.input[t.pos] {
+	case '"', '\'':
+		return t.stringLiteral()
+	case '$':
+		t.pos++
+		name := t.name()
+		if name == "" {
+			return templateValue{}, fmt.Errorf("missing variable name after $")
+		}
+		return t.variable(name), nil
+	}
+
+	name := t.name()
+	if name == "" {
+		return templateValue{}, fmt.Errorf("unexpected %q in template expression", t.input[t.pos:])
+	}
+	t.skipSpace()
+	if t.pos < len(t.input) && t.input[t.pos] == '(' {
+		t.pos++
+		return t.call(name)
+	}
+	return t.variable(name), nil
+}
+
+// variable looks up a named value; STDIN refers to the previous step's output
+func (t *templateParser) variable(name string) templateValue {
+	if name == "STDIN" {
+		return templateValue{value: t.p.lastOutput, defined: true}
+	}
+	value, ok := t.p.variables[name]
+	return templateValue{value: value, defined: ok}
+}
+
+// stringLiteral reads a single- or double-quoted string, supporting backslash escapes
+func (t *templateParser) stringLiteral() (templateValue, error) {
+	quote := t.input[t.pos]
+	t.pos++
+	var sb strings.Builder
+	for t.pos < len(t.input) {
+		c := t.input[t.pos]
+		t.pos++
+		switch {
+		case c == quote:
+			return templateValue{value: sb.String(), defined: true}, nil
+		case c == '\\' && t.pos < len(t.input):
+			sb.WriteByte(t.input[t.pos])
+			t.pos++
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return templateValue{}, fmt.Errorf("unterminated string in template expression")
+}
+
+// call reads the arguments of a helper call and applies it
+func (t *templateParser) call(name string) (templateValue, error) {
+	var args []templateValue
+	t.skipSpace()
+	if t.pos < len(t.input) && t.input[t.pos] == ')' {
+		t.pos++
+	} else {
+		for {
+			arg, err := t.expr()
+			if err != nil {
+				return templateValue{}, err
+			}
+			args = append(args, arg)
+			t.skipSpace()
+			if t.pos >= len(t.input) {
+				return templateValue{}, fmt.Errorf("missing ) after arguments to %s", name)
+			}
+			if t.input[t.pos] == ')' {
+				t.pos++
+				break
+			}
+			if t.input[t.pos] != ',' {
+				return templateValue{}, fmt.Errorf("unexpected %q in arguments to %s", t.input[t.pos:], name)
+			}
+			t.pos++
+		}
+	}
+
+	if name == "default" {
+		if len(args) != 2 {
+			return templateValue{}, fmt.Errorf("default expects 2 arguments, got %d", len(args))
+		}
+		if args[0].defined && args[0].value != "" {
+			return args[0], nil
+		}
+		return args[1], nil
+	}
+
+	if name == "secret" {
+		if len(args) != 1 {
+			return templateValue{}, fmt.Errorf("secret expects 1 argument, got %d", len(args))
+		}
+		value, err := t.p.resolveSecret(args[0].value)
+		if err != nil {
+			return templateValue{}, err
+		}
+		return templateValue{value: value, defined: true}, nil
+	}
+
+	fn, ok := templateFuncs[name]
+	if !ok {
+		return templateValue{}, fmt.Errorf("unknown template function: %s", name)
+	}
+	if len(args) != 1 {
+		return templateValue{}, fmt.Errorf("%s expects 1 argument, got %d", name, len(args))
+	}
+	if !args[0].defined {
+		return args[0], nil
+	}
+	return templateValue{value: fn(args[0].value), defined: true}, nil
+}
+
+// resolveSecret returns a secret from the sources configured in the environment file. Resolved
+// values are cached for the run and masked in all log output.
+func (p *Processor) resolveSecret(name string) (string, error) {
+	if value, ok := p.secrets[name]; ok {
+		return value, nil
+	}
+	if p.envConfig == nil {
+		return "", fmt.Errorf("secret %s is not configured", name)
+	}
+
+	value, err := p.envConfig.ResolveSecret(name)
+	if err != nil {
+		p.warnf("Failed to resolve secret: %v", err)
+		return "", err
+	}
+	logging.AddSecret(value)
+	p.secrets[name] = value
+	return value, nil
+}
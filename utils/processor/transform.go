@@ -0,0 +1,512 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// transformStepType marks a step that transforms CSV or TSV data without calling a model
+const transformStepType = "transform"
+
+// filterPattern splits a filter condition into column, operator and value
+var filterPattern = regexp.MustCompile(`^\s*(.+?)\s*(==|!=|>=|<=|>|<|\scontains\s)\s*(.*?)\s*$`)
+
+// aggregatePattern matches aggregate functions such as count or sum(amount)
+var aggregatePattern = regexp.MustCompile(`^\s*(count|sum|avg|min|max)\s*(?:\(\s*(.*?)\s*\))?\s*$`)
+
+// table holds parsed CSV data with its header row
+type table struct {
+	header []string
+	rows   [][]string
+}
+
+// column returns the index of a column, or an error if the table has no such column
+func (t *table) column(name string) (int, error) {
+	for i, h := range t.header {
+		if h == name {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("unknown column: %s", name)
+}
+
+type filterCondition struct {
+	column string
+	op     string
+	value  string
+}
+
+type sortKey struct {
+	column     string
+	descending bool
+}
+
+type aggregateFunc struct {
+	name   string
+	column string
+}
+
+// outputName returns the column name used for the function's result, such as sum_amount
+func (f aggregateFunc) outputName() string {
+	if f.column == "" {
+		return f.name
+	}
+	return f.name + "_" + f.column
+}
+
+// validateTransform checks the transform settings of a step without reading any data
+func (p *Processor) validateTransform(config StepConfig) error {
+	if config.Transform == nil {
+		return fmt.Errorf("transform steps require a transform section")
+	}
+	if _, err := transformDelimiter(config.Transform.Delimiter, ""); err != nil {
+		return err
+	}
+	if _, err := p.parseFilters(config.Transform.Filter); err != nil {
+		return err
+	}
+	if _, err := p.parseSortKeys(config.Transform.Sort); err != nil {
+		return err
+	}
+	if config.Transform.Aggregate != nil {
+		if _, err := p.parseAggregateFuncs(config.Transform.Aggregate.Functions); err != nil {
+			return err
+		}
+	}
+	switch config.Transform.Dedupe.(type) {
+	case nil, bool, string, []interface{}, []string:
+	default:
+		return fmt.Errorf("dedupe must be true, false, or a list of columns")
+	}
+	return nil
+}
+
+// runTransform applies a transform step to its CSV or TSV input and returns the result as CSV.
+// Operations run in a fixed order: filter, aggregate, select, dedupe, then sort.
+func (p *Processor) runTransform(transform *TransformConfig) (string, error) {
+	inputs := p.handler.GetInputs()
+	if len(inputs) != 1 {
+		return "", fmt.Errorf("transform steps require exactly one CSV or TSV input, got %d", len(inputs))
+	}
+
+	delimiter, err := transformDelimiter(transform.Delimiter, inputs[0].Path)
+	if err != nil {
+		return "", err
+	}
+
+	reader := csv.NewReader(bytes.NewReader(inputs[0].Contents))
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return "", fmt.Errorf("failed to parse input %s: %w", inputs[0].Path, err)
+	}
+	if len(records) == 0 {
+		return "", fmt.Errorf("input %s has no header row", inputs[0].Path)
+	}
+
+	data := &table{header: records[0], rows: records[1:]}
+	p.debugf("Transforming %d row(s) with %d column(s)", len(data.rows), len(data.header))
+
+	filters, err := p.parseFilters(transform.Filter)
+	if err != nil {
+		return "", err
+	}
+	if data, err = filterRows(data, filters); err != nil {
+		return "", err
+	}
+
+	if transform.Aggregate != nil {
+		funcs, err := p.parseAggregateFuncs(transform.Aggregate.Functions)
+		if err != nil {
+			return "", err
+		}
+		if data, err = aggregateRows(data, p.NormalizeStringSlice(transform.Aggregate.GroupBy), funcs); err != nil {
+			return "", err
+		}
+	}
+
+	if columns := p.NormalizeStringSlice(transform.Select); len(columns) > 0 {
+		if data, err = selectColumns(data, columns); err != nil {
+			return "", err
+		}
+	}
+
+	switch v := transform.Dedupe.(type) {
+	case nil:
+	case bool:
+		if v {
+			data, err = dedupeRows(data, data.header)
+		}
+	default:
+		data, err = dedupeRows(data, p.NormalizeStringSlice(v))
+	}
+	if err != nil {
+		return "", err
+	}
+
+	keys, err := p.parseSortKeys(transform.Sort)
+	if err != nil {
+		return "", err
+	}
+	if err := sortRows(data, keys); err != nil {
+		return "", err
+	}
+
+	p.debugf("Transform produced %d row(s)", len(data.rows))
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	writer.Write(data.header)
+	writer.WriteAll(data.rows)
+	if err := writer.Error(); err != nil {
+		return "", fmt.Errorf("failed to write CSV output: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// transformDelimiter returns the input delimiter, defaulting to a tab for .tsv inputs and a comma otherwise
+func transformDelimiter(delimiter, path string) (rune, error) {
+	switch delimiter {
+	case "":
+		if strings.HasSuffix(strings.ToLower(path), ".tsv") {
+			return '\t', nil
+		}
+		return ',', nil
+	case "tab", `\t`:
+		return '\t', nil
+	}
+	runes := []rune(delimiter)
+	if len(runes) != 1 {
+		return 0, fmt.Errorf("delimiter must be a single character or tab, got %q", delimiter)
+	}
+	return runes[0], nil
+}
+
+// parseFilters parses filter conditions of the form "column op value"
+func (p *Processor) parseFilters(filter interface{}) ([]filterCondition, error) {
+	var conditions []filterCondition
+	for _, expr := range p.NormalizeStringSlice(filter) {
+		match := filterPattern.FindStringSubmatch(expr)
+		if match == nil {
+			return nil, fmt.Errorf("invalid filter %q: expected \"column op value\" with op one of ==, !=, >, >=, <, <=, contains", expr)
+		}
+		conditions = append(conditions, filterCondition{
+			column: match[1],
+			op:     strings.TrimSpace(match[2]),
+			value:  strings.Trim(match[3], `"'`),
+		})
+	}
+	return conditions, nil
+}
+
+// parseSortKeys parses sort keys of the form "column" or "column desc"
+func (p *Processor) parseSortKeys(sortSpec interface{}) ([]sortKey, error) {
+	var keys []sortKey
+	for _, spec := range p.NormalizeStringSlice(sortSpec) {
+		fields := strings.Fields(spec)
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("empty sort key")
+		}
+		key := sortKey{column: strings.Join(fields, " ")}
+		if last := strings.ToLower(fields[len(fields)-1]); len(fields) > 1 && (last == "asc" || last == "desc") {
+			key.column = strings.Join(fields[:len(fields)-1], " ")
+			key.descending = last == "desc"
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// parseAggregateFuncs parses aggregate functions: count, sum(column), avg(column), min(column) and max(column)
+func (p *Processor) parseAggregateFuncs(functions interface{}) ([]aggregateFunc, error) {
+	specs := p.NormalizeStringSlice(functions)
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("aggregate requires at least one function")
+	}
+
+	var funcs []aggregateFunc
+	for _, spec := range specs {
+		match := aggregatePattern.FindStringSubmatch(spec)
+		if match == nil {
+			return nil, fmt.Errorf("invalid aggregate function %q: expected count, sum(column), avg(column), min(column) or max(column)", spec)
+		}
+		if match[1] != "count" && match[2] == "" {
+			return nil, fmt.Errorf("aggregate function %s requires a column", match[1])
+		}
+		funcs = append(funcs, aggregateFunc{name: match[1], column: match[2]})
+	}
+	return funcs, nil
+}
+
+// compareValues compares two cell values numerically when both are numbers and as strings otherwise
+func compareValues(a, b string) int {
+	af, aErr := strconv.ParseFloat(strings.TrimSpace(a), 64)
+	bf, bErr := strconv.ParseFloat(strings.TrimSpace(b), 64)
+	if aErr == nil && bErr == nil {
+		switch {
+		case af < bf:
+			return -1
+		case af > bf:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a, b)
+}
+
+// filterRows keeps the rows matching every condition
+func filterRows(data *table, conditions []filterCondition) (*table, error) {
+	if len(conditions) == 0 {
+		return data, nil
+	}
+
+	indexes := make([]int, len(conditions))
+	for i, condition := range conditions {
+		index, err := data.column(condition.column)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter: %w", err)
+		}
+		indexes[i] = index
+	}
+
+	result := &table{header: data.header}
+	for _, row := range data.rows {
+		keep := true
+		for i, condition := range conditions {
+			value := cell(row, indexes[i])
+			var ok bool
+			switch condition.op {
+			case "==":
+				ok = compareValues(value, condition.value) == 0
+			case "!=":
+				ok = compareValues(value, condition.value) != 0
+			case ">":
+				ok = compareValues(value, condition.value) > 0
+			case ">=":
+				ok = compareValues(value, condition.value) >= 0
+			case "<":
+				ok = compareValues(value, condition.value) < 0
+			case "<=":
+				ok = compareValues(value, condition.value) <= 0
+			case "contains":
+				ok = strings.Contains(value, condition.value)
+			}
+			if !ok {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			result.rows = append(result.rows, row)
+		}
+	}
+	return result, nil
+}
+
+// aggregateRows groups rows by the given columns, in order of first appearance, and computes
+// the aggregate functions for each group. Empty cells are ignored by every function except count.
+func aggregateRows(data *table, groupBy []string, funcs []aggregateFunc) (*table, error) {
+	groupIndexes := make([]int, len(groupBy))
+	for i, name := range groupBy {
+		index, err := data.column(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid group_by: %w", err)
+		}
+		groupIndexes[i] = index
+	}
+	funcIndexes := make([]int, len(funcs))
+	for i, f := range funcs {
+		funcIndexes[i] = -1
+		if f.column != "" {
+			index, err := data.column(f.column)
+			if err != nil {
+				return nil, fmt.Errorf("invalid aggregate function %s: %w", f.name, err)
+			}
+			funcIndexes[i] = index
+		}
+	}
+
+	var order []string
+	groups := make(map[string][][]string)
+	for _, row := range data.rows {
+		key := rowKey(row, groupIndexes)
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], row)
+	}
+	if len(groupBy) == 0 && len(order) == 0 {
+		order = append(order, "")
+	}
+
+	result := &table{header: append([]string{}, groupBy...)}
+	for _, f := range funcs {
+		result.header = append(result.header, f.outputName())
+	}
+
+	for _, key := range order {
+		rows := groups[key]
+		var out []string
+		if len(rows) > 0 {
+			for _, index := range groupIndexes {
+				out = append(out, cell(rows[0], index))
+			}
+		}
+		for i, f := range funcs {
+			value, err := aggregate(f, rows, funcIndexes[i])
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, value)
+		}
+		result.rows = append(result.rows, out)
+	}
+	return result, nil
+}
+
+// aggregate computes a single aggregate function over a group of rows
+func aggregate(f aggregateFunc, rows [][]string, index int) (string, error) {
+	if f.name == "count" {
+		return strconv.Itoa(len(rows)), nil
+	}
+
+	var values []float64
+	for _, row := range rows {
+		value := strings.TrimSpace(cell(row, index))
+		if value == "" {
+			continue
+		}
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return "", fmt.Errorf("%s(%s): non-numeric value %q", f.name, f.column, value)
+		}
+		values = append(values, number)
+	}
+	if len(values) == 0 {
+		return "", nil
+	}
+
+	result := values[0]
+	switch f.name {
+	case "sum", "avg":
+		for _, v := range values[1:] {
+			result += v
+		}
+		if f.name == "avg" {
+			result /= float64(len(values))
+		}
+	case "min":
+		for _, v := range values[1:] {
+			if v < result {
+				result = v
+			}
+		}
+	case "max":
+		for _, v := range values[1:] {
+			if v > result {
+				result = v
+			}
+		}
+	}
+	return strconv.FormatFloat(result, 'f', -1, 64), nil
+}
+
+// selectColumns keeps only the given columns, in the given order
+func selectColumns(data *table, columns []string) (*table, error) {
+	indexes := make([]int, len(columns))
+	for i, name := range columns {
+		index, err := data.column(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid select: %w", err)
+		}
+		indexes[i] = index
+	}
+
+	result := &table{header: columns}
+	for _, row := range data.rows {
+		out := make([]string, len(indexes))
+		for i, index := range indexes {
+			out[i] = cell(row, index)
+		}
+		result.rows = append(result.rows, out)
+	}
+	return result, nil
+}
+
+// dedupeRows keeps the first row for each distinct combination of the given columns
+func dedupeRows(data *table, columns []string) (*table, error) {
+	indexes := make([]int, len(columns))
+	for i, name := range columns {
+		index, err := data.column(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid dedupe: %w", err)
+		}
+		indexes[i] = index
+	}
+
+	result := &table{header: data.header}
+	seen := make(map[string]bool)
+	for _, row := range data.rows {
+		key := rowKey(row, indexes)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result.rows = append(result.rows, row)
+	}
+	return result, nil
+}
+
+// sortRows sorts the rows by the given keys, keeping the original order of equal rows
+func sortRows(data *table, keys []sortKey) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	indexes := make([]int, len(keys))
+	for i, key := range keys {
+		index, err := data.column(key.column)
+		if err != nil {
+			return fmt.Errorf("invalid sort: %w", err)
+		}
+		indexes[i] = index
+	}
+
+	sort.SliceStable(data.rows, func(a, b int) bool {
+		for i, key := range keys {
+			c := compareValues(cell(data.rows[a], indexes[i]), cell(data.rows[b], indexes[i]))
+			if c == 0 {
+				continue
+			}
+			if key.descending {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	})
+	return nil
+}
+
+// cell returns a row's value at index, or an empty string for short rows
+func cell(row []string, index int) string {
+	if index < len(row) {
+		return row[index]
+	}
+	return ""
+}
+
+// rowKey joins the values of the given columns into a map key
+func rowKey(row []string, indexes []int) string {
+	values := make([]string, len(indexes))
+	for i, index := range indexes {
+		values[i] = cell(row, index)
+	}
+	return strings.Join(values, "\x00")
+}
@@ -0,0 +1,220 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const salesCSV = `region,product,amount
+west,widget,120
+east,gadget,80
+west,gadget,200
+east,widget,150
+west,widget,120
+`
+
+func TestRunTransform(t *testing.T) {
+	tests := []struct {
+		name        string
+		file        string
+		content     string
+		transform   TransformConfig
+		want        string
+		expectError string
+	}{
+		{
+			name:      "filter and select",
+			transform: TransformConfig{Filter: "amount > 100", Select: []interface{}{"product", "amount"}},
+			want:      "product,amount\nwidget,120\ngadget,200\nwidget,150\nwidget,120\n",
+		},
+		{
+			name:      "multiple filters",
+			transform: TransformConfig{Filter: []interface{}{"region == west", "product contains widg"}},
+			want:      "region,product,amount\nwest,widget,120\nwest,widget,120\n",
+		},
+		{
+			name:      "dedupe and sort descending",
+			transform: TransformConfig{Dedupe: true, Sort: "amount desc"},
+			want:      "region,product,amount\nwest,gadget,200\neast,widget,150\nwest,widget,120\neast,gadget,80\n",
+		},
+		{
+			name:      "dedupe by column",
+			transform: TransformConfig{Dedupe: []interface{}{"region"}},
+			want:      "region,product,amount\nwest,widget,120\neast,gadget,80\n",
+		},
+		{
+			name: "aggregate by group",
+			transform: TransformConfig{
+				Aggregate: &AggregateConfig{GroupBy: "region", Functions: []interface{}{"count", "sum(amount)", "avg(amount)", "max(amount)"}},
+				Sort:      "region",
+			},
+			want: "region,count,sum_amount,avg_amount,max_amount\neast,2,230,115,150\nwest,3,440,146.66666666666666,200\n",
+		},
+		{
+			name:      "tsv input",
+			file:      "sales.tsv",
+			content:   "region\tamount\nwest\t5\neast\t7\n",
+			transform: TransformConfig{Sort: "amount desc"},
+			want:      "region,amount\neast,7\nwest,5\n",
+		},
+		{
+			name:        "unknown column",
+			transform:   TransformConfig{Select: "price"},
+			expectError: "unknown column: price",
+		},
+		{
+			name:        "non-numeric aggregate",
+			transform:   TransformConfig{Aggregate: &AggregateConfig{Functions: "sum(product)"}},
+			expectError: "non-numeric value",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, content := tt.file, tt.content
+			if file == "" {
+				file, content = "sales.csv", salesCSV
+			}
+			path := filepath.Join(t.TempDir(), file)
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatalf("Failed to create test file: %v", err)
+			}
+
+			processor := NewProcessor(&DSLConfig{}, createTestEnvConfig(), false)
+			if err := processor.processInputs([]string{path}); err != nil {
+				t.Fatalf("Failed to process input: %v", err)
+			}
+
+			got, err := processor.runTransform(&tt.transform)
+			if tt.expectError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectError) {
+					t.Fatalf("runTransform() error = %v, want error containing %q", err, tt.expectError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("runTransform() unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("runTransform() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateTransformStep(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      StepConfig
+		expectError string
+	}{
+		{
+			name: "valid transform step",
+			config: StepConfig{
+				Type:      "transform",
+				Input:     "sales.csv",
+				Output:    "STDOUT",
+				Transform: &TransformConfig{Filter: "amount >= 100", Sort: "amount desc"},
+			},
+		},
+		{
+			name:        "missing transform section",
+			config:      StepConfig{Type: "transform", Input: "sales.csv", Output: "STDOUT"},
+			expectError: "require a transform section",
+		},
+		{
+			name: "model not allowed",
+			config: StepConfig{
+				Type:      "transform",
+				Input:     "sales.csv",
+				Model:     "gpt-4o",
+				Output:    "STDOUT",
+				Transform: &TransformConfig{Select: "region"},
+			},
+			expectError: "cannot use a model",
+		},
+		{
+			name: "invalid filter",
+			config: StepConfig{
+				Type:      "transform",
+				Input:     "sales.csv",
+				Output:    "STDOUT",
+				Transform: &TransformConfig{Filter: "amount"},
+			},
+			expectError: "invalid filter",
+		},
+		{
+			name: "invalid aggregate function",
+			config: StepConfig{
+				Type:      "transform",
+				Input:     "sales.csv",
+				Output:    "STDOUT",
+				Transform: &TransformConfig{Aggregate: &AggregateConfig{Functions: "median(amount)"}},
+			},
+			expectError: "invalid aggregate function",
+		},
+		{
+			name:        "unknown step type",
+			config:      StepConfig{Type: "shell", Input: "NA", Model: "NA", Action: "run", Output: "STDOUT"},
+			expectError: "unknown step type: shell",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := NewProcessor(&DSLConfig{}, createTestEnvConfig(), false)
+			err := processor.validateStepConfig("transform", tt.config)
+			if tt.expectError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectError) {
+					t.Fatalf("validateStepConfig() error = %v, want error containing %q", err, tt.expectError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validateStepConfig() unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestProcessTransformStep(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputFile := filepath.Join(tmpDir, "sales.csv")
+	outputFile := filepath.Join(tmpDir, "totals.csv")
+	if err := os.WriteFile(inputFile, []byte(salesCSV), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	config := DSLConfig{
+		Steps: []Step{
+			{
+				Name: "totals",
+				Config: StepConfig{
+					Type:   "transform",
+					Input:  []string{inputFile},
+					Output: []string{outputFile},
+					Transform: &TransformConfig{
+						Aggregate: &AggregateConfig{GroupBy: "product", Functions: "sum(amount)"},
+						Sort:      "sum_amount desc",
+					},
+				},
+			},
+		},
+	}
+
+	processor := NewProcessor(&config, createTestEnvConfig(), false)
+	if err := processor.Process(); err != nil {
+		t.Fatalf("Process() unexpected error: %v", err)
+	}
+
+	got, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	want := "product,sum_amount\nwidget,390\ngadget,280\n"
+	if string(got) != want {
+		t.Errorf("output = %q, want %q", string(got), want)
+	}
+}
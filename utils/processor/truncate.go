@@ -0,0 +1,109 @@
+package processor
+
+import (
+	"fmt"
+
+	"github.com/kris-hansen/comanda/utils/models"
+)
+
+const (
+	// charsPerToken is the rough number of characters per token used to estimate input size
+	charsPerToken = 4
+
+	truncateHead   = "head"
+	truncateTail   = "tail"
+	truncateMiddle = "middle"
+)
+
+// estimateTokens provides a rough estimate of the token count of text from its character count
+func estimateTokens(text string) int {
+	return len([]rune(text)) / charsPerToken
+}
+
+// truncationStrategy returns the step's truncate setting, defaulting to head
+func truncationStrategy(stepConfig StepConfig) string {
+	if stepConfig.Truncate == "" {
+		return truncateHead
+	}
+	return stepConfig.Truncate
+}
+
+// validateTruncation checks the step's max_input_tokens and truncate settings
+func validateTruncation(stepConfig StepConfig) error {
+	if stepConfig.MaxInputTokens < 0 {
+		return fmt.Errorf("max_input_tokens must be a positive number")
+	}
+	switch stepConfig.Truncate {
+	case "", truncateHead, truncateTail, truncateMiddle:
+	default:
+		return fmt.Errorf("truncate must be head, tail, or middle, got %s", stepConfig.Truncate)
+	}
+	if stepConfig.Truncate != "" && stepConfig.MaxInputTokens == 0 {
+		return fmt.Errorf("truncate requires max_input_tokens")
+	}
+	return nil
+}
+
+// inputTokenLimit returns the step's max_input_tokens, lowered so that the input and the response
+// fit in the model's context window. Zero means the input is not truncated.
+func (p *Processor) inputTokenLimit(modelName string, stepConfig StepConfig) int {
+	if stepConfig.MaxInputTokens <= 0 {
+		return 0
+	}
+	limits, ok := models.LookupLimits(modelName)
+	if !ok {
+		return stepConfig.MaxInputTokens
+	}
+	room := limits.Context - outputTokens(modelName, stepConfig)
+	if room > 0 && stepConfig.MaxInputTokens > room {
+		p.warnf("max_input_tokens %d does not fit in the context window of model %s; truncating to %d", stepConfig.MaxInputTokens, modelName, room)
+		return room
+	}
+	return stepConfig.MaxInputTokens
+}
+
+// checkContext compares the estimated prompt size with the model's context window before the
+// call. A prompt larger than the window fails, and one that leaves too little room for the
+// response is only warned about, since the estimate is rough. Steps with max_input_tokens are
+// not checked, as their input is truncated to fit.
+func (p *Processor) checkContext(modelName string, promptTokens int, stepConfig StepConfig) error {
+	if stepConfig.MaxInputTokens > 0 {
+		return nil
+	}
+	limits, ok := models.LookupLimits(modelName)
+	if !ok || limits.Context == 0 {
+		return nil
+	}
+	if promptTokens > limits.Context {
+		return fmt.Errorf("prompt for model %s is about %d tokens, more than its %d token context window; split the input into smaller pieces or set max_input_tokens to truncate it",
+			modelName, promptTokens, limits.Context)
+	}
+	if room := limits.Context - outputTokens(modelName, stepConfig); promptTokens > room {
+		p.warnf("Prompt for model %s is about %d tokens, leaving less than the %d tokens max_tokens allows for the response in its %d token context window",
+			modelName, promptTokens, outputTokens(modelName, stepConfig), limits.Context)
+	}
+	return nil
+}
+
+// truncateInput trims text to roughly maxTokens tokens. head keeps the beginning of the text,
+// tail keeps the end, and middle keeps both ends and drops the middle. A marker noting how much
+// was removed is left where the text was cut. It returns the text and whether it was truncated.
+func truncateInput(text string, maxTokens int, strategy string) (string, bool) {
+	runes := []rune(text)
+	limit := maxTokens * charsPerToken
+	if maxTokens <= 0 || len(runes) <= limit {
+		return text, false
+	}
+
+	marker := fmt.Sprintf("[... truncated %d characters ...]", len(runes)-limit)
+	switch strategy {
+	case truncateTail:
+		return marker + "\n" + string(runes[len(runes)-limit:]), true
+	case truncateMiddle:
+		head := limit / 2
+		tail := limit - head
+		return string(runes[:head]) + "\n" + marker + "\n" + string(runes[len(runes)-tail:]), true
+	default:
+		return string(runes[:limit]) + "\n" + marker, true
+	}
+}
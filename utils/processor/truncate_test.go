@@ -0,0 +1,171 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTruncateInput(t *testing.T) {
+	text := strings.Repeat("a", 20) + strings.Repeat("b", 20) + strings.Repeat("c", 20)
+
+	tests := []struct {
+		name          string
+		maxTokens     int
+		strategy      string
+		want          string
+		wantTruncated bool
+	}{
+		{
+			name:      "within limit",
+			maxTokens: 15,
+			strategy:  truncateHead,
+			want:      text,
+		},
+		{
+			name:          "head",
+			maxTokens:     5,
+			strategy:      truncateHead,
+			want:          strings.Repeat("a", 20) + "\n[... truncated 40 characters ...]",
+			wantTruncated: true,
+		},
+		{
+			name:          "tail",
+			maxTokens:     5,
+			strategy:      truncateTail,
+			want:          "[... truncated 40 characters ...]\n" + strings.Repeat("c", 20),
+			wantTruncated: true,
+		},
+		{
+			name:          "middle",
+			maxTokens:     5,
+			strategy:      truncateMiddle,
+			want:          strings.Repeat("a", 10) + "\n[... truncated 40 characters ...]\n" + strings.Repeat("c", 10),
+			wantTruncated: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, truncated := truncateInput(text, tt.maxTokens, tt.strategy)
+			if got != tt.want {
+				t.Errorf("truncateInput() = %q, want %q", got, tt.want)
+			}
+			if truncated != tt.wantTruncated {
+				t.Errorf("truncateInput() truncated = %v, want %v", truncated, tt.wantTruncated)
+			}
+		})
+	}
+}
+
+func TestValidateTruncation(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  StepConfig
+		wantErr bool
+	}{
+		{name: "not set", config: StepConfig{}},
+		{name: "limit only", config: StepConfig{MaxInputTokens: 1000}},
+		{name: "limit and strategy", config: StepConfig{MaxInputTokens: 1000, Truncate: "middle"}},
+		{name: "negative limit", config: StepConfig{MaxInputTokens: -1}, wantErr: true},
+		{name: "unknown strategy", config: StepConfig{MaxInputTokens: 1000, Truncate: "start"}, wantErr: true},
+		{name: "strategy without limit", config: StepConfig{Truncate: "tail"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateTruncation(tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateTruncation() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProcessActionsTruncate(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputFile := filepath.Join(tmpDir, "log.txt")
+	if err := os.WriteFile(inputFile, []byte(strings.Repeat("x", 4000)+"LAST LINE"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	processor := NewProcessor(&DSLConfig{}, createTestEnvConfig(), false)
+	if err := processor.processInputs([]string{inputFile}); err != nil {
+		t.Fatalf("Failed to process input: %v", err)
+	}
+
+	mock := NewMockProvider("openai")
+	mock.Configure("test-key")
+	recorder := &recordingProvider{Provider: mock}
+	processor.providers["openai"] = recorder
+
+	stepConfig := StepConfig{MaxInputTokens: 100, Truncate: "tail"}
+	if _, err := processor.processActions([]string{"gpt-4o"}, []string{"summarize"}, stepConfig); err != nil {
+		t.Fatalf("processActions() unexpected error: %v", err)
+	}
+
+	if len(recorder.prompts) != 1 {
+		t.Fatalf("expected 1 prompt, got %d", len(recorder.prompts))
+	}
+	prompt := recorder.prompts[0]
+	if !strings.Contains(prompt, "[... truncated") {
+		t.Errorf("prompt missing truncation marker: %q", prompt)
+	}
+	if !strings.Contains(prompt, "LAST LINE") {
+		t.Errorf("prompt should keep the end of the input: %q", prompt)
+	}
+	if len(prompt) > 600 {
+		t.Errorf("prompt length = %d, want input trimmed to about 400 characters", len(prompt))
+	}
+}
+
+func TestInputTokenLimit(t *testing.T) {
+	processor := NewProcessor(&DSLConfig{}, createTestEnvConfig(), false)
+	tests := []struct {
+		name   string
+		model  string
+		config StepConfig
+		want   int
+	}{
+		{"no limit", "gpt-4", StepConfig{}, 0},
+		{"fits the context window", "gpt-4o", StepConfig{MaxInputTokens: 100000}, 100000},
+		{"lowered to leave room for the response", "gpt-4", StepConfig{MaxInputTokens: 8000, MaxTokens: 1000}, 7192},
+		{"unknown model", "llama3.2", StepConfig{MaxInputTokens: 500000}, 500000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := processor.inputTokenLimit(tt.model, tt.config); got != tt.want {
+				t.Errorf("inputTokenLimit() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckContext(t *testing.T) {
+	processor := NewProcessor(&DSLConfig{}, createTestEnvConfig(), false)
+	tests := []struct {
+		name         string
+		model        string
+		promptTokens int
+		config       StepConfig
+		wantErr      bool
+	}{
+		{"fits", "gpt-4", 4000, StepConfig{}, false},
+		{"leaves little room for the response", "gpt-4", 7000, StepConfig{}, false},
+		{"larger than the context window", "gpt-4", 9000, StepConfig{}, true},
+		{"truncated to fit", "gpt-4", 9000, StepConfig{MaxInputTokens: 4000}, false},
+		{"unknown model", "llama3.2", 500000, StepConfig{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := processor.checkContext(tt.model, tt.promptTokens, tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkContext() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), "max_input_tokens") {
+				t.Errorf("checkContext() error = %v, want a suggestion to set max_input_tokens", err)
+			}
+		})
+	}
+}
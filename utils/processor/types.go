@@ -1,12 +1,80 @@
 package processor
 
+import "gopkg.in/yaml.v3"
+
 // StepConfig represents the configuration for a single step
 type StepConfig struct {
+	Type       string      `yaml:"type"`        // Empty for model steps, or "transform" for CSV transformations
 	Input      interface{} `yaml:"input"`       // Can be string or map[string]interface{}
 	Model      interface{} `yaml:"model"`       // Can be string or []string
+	Complexity string      `yaml:"complexity"`  // Tier used by model: auto: low, medium, or high
 	Action     interface{} `yaml:"action"`      // Can be string or []string
+	System     string      `yaml:"system"`      // System prompt, overriding the model's configured default
 	Output     interface{} `yaml:"output"`      // Can be string or []string
 	NextAction interface{} `yaml:"next-action"` // Can be string or []string
+	Fallback   interface{} `yaml:"fallback"`    // Can be string or []string
+	Aggregate  string      `yaml:"aggregate"`   // Combine the answers of several models: vote, concat, first, or judge
+	Judge      string      `yaml:"judge"`       // Model that picks the best answer for aggregate: judge
+	Redact     interface{} `yaml:"redact"`      // Can be bool or []string of redaction pattern names
+	OnError    string      `yaml:"on_error"`    // Step to run instead of aborting if this step fails
+	MaxCost    float64     `yaml:"max_cost"`    // Estimated cost limit of the step's model calls in US dollars
+
+	CacheContext   bool   `yaml:"cache_context"`    // Mark the step input as a reusable, cacheable prompt context
+	MaxInputTokens int    `yaml:"max_input_tokens"` // Trim the step input to roughly this many tokens
+	Truncate       string `yaml:"truncate"`         // Which part of oversized input to keep: head, tail, or middle
+
+	IncludeReasoning bool            `yaml:"include_reasoning"` // Keep a reasoning model's chain of thought in a <reasoning> block
+	Thinking         *ThinkingConfig `yaml:"thinking"`          // Extended thinking budget for models that support it
+	LiveSearch       string          `yaml:"live_search"`       // Let the model search the web: off, auto, or on
+
+	MaxTokens int         `yaml:"max_tokens"` // Most tokens the response may use, lowered to the model's limit
+	Seed      *int        `yaml:"seed"`       // Sampling seed for reproducible output, where the provider supports it
+	Stop      interface{} `yaml:"stop"`       // Can be string or []string of sequences that end generation
+
+	SanitizeInput bool `yaml:"sanitize_input"` // Remove suspected prompt injection phrases from URL and scraped inputs
+
+	OutputFormat   string                `yaml:"output_format"`   // Expected output: markdown, yaml, or json
+	FormatRetries  int                   `yaml:"format_retries"`  // Times to ask again when yaml or json output does not parse
+	SelfCorrect    *SelfCorrectConfig    `yaml:"self_correct"`    // Ask again with the reason yaml or json output did not parse
+	ResponseFormat *ResponseFormatConfig `yaml:"response_format"` // Constrain the model to JSON, where the provider supports it
+
+	PostProcess interface{} `yaml:"post_process"` // Can be string or []string of output cleanups applied in order
+
+	Transform *TransformConfig `yaml:"transform"` // Data operations for transform steps
+}
+
+// ThinkingConfig represents the extended thinking settings of a step
+type ThinkingConfig struct {
+	BudgetTokens int `yaml:"budget_tokens"` // Tokens the model may spend thinking before it answers
+}
+
+// SelfCorrectConfig represents the self-correction settings of a step
+type SelfCorrectConfig struct {
+	MaxAttempts int `yaml:"max_attempts"` // Calls to make in total, including the first; defaults to 3
+}
+
+// ResponseFormatConfig represents the structured output a step asks the model for
+type ResponseFormatConfig struct {
+	Type   string      `yaml:"type"`   // json_object or json_schema
+	Name   string      `yaml:"name"`   // Schema name sent to the provider; defaults to response
+	Schema interface{} `yaml:"schema"` // Can be a mapping holding the JSON schema, or the path of a JSON or YAML schema file
+	Strict bool        `yaml:"strict"` // Ask OpenAI and xAI models to enforce the schema strictly
+}
+
+// TransformConfig represents the CSV operations of a transform step
+type TransformConfig struct {
+	Delimiter string           `yaml:"delimiter"` // Input delimiter; defaults to tab for .tsv inputs and comma otherwise
+	Filter    interface{}      `yaml:"filter"`    // Can be string or []string of "column op value" conditions
+	Aggregate *AggregateConfig `yaml:"aggregate"` // Group rows and compute aggregate values
+	Select    interface{}      `yaml:"select"`    // Can be string or []string of columns to keep
+	Dedupe    interface{}      `yaml:"dedupe"`    // Can be bool or []string of columns identifying duplicates
+	Sort      interface{}      `yaml:"sort"`      // Can be string or []string of "column" or "column desc"
+}
+
+// AggregateConfig represents the grouping and aggregate functions of a transform step
+type AggregateConfig struct {
+	GroupBy   interface{} `yaml:"group_by"`  // Can be string or []string of columns
+	Functions interface{} `yaml:"functions"` // Can be string or []string of count, sum(col), avg(col), min(col) or max(col)
 }
 
 // Step represents a named step in the DSL
@@ -18,6 +86,16 @@ type Step struct {
 // DSLConfig represents the structure of the DSL configuration
 type DSLConfig struct {
 	Steps []Step
+	Vars  map[string]string // Workflow-level variables declared in the top-level vars block
+
+	// Step fields to patch per environment, from the top-level overrides block, keyed by
+	// environment and then step name
+	Overrides map[string]map[string]yaml.Node
+
+	// Optional descriptive metadata from the top-level name, description and version keys
+	Name        string
+	Description string
+	Version     string
 }
 
 // NormalizeOptions represents options for string slice normalization
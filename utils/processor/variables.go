@@ -0,0 +1,69 @@
+package processor
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/kris-hansen/comanda/utils/fileutil"
+	"gopkg.in/yaml.v3"
+)
+
+// variableNamePattern matches names that can be referenced as $name or {{ name }}
+var variableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// SetVariables sets workflow variables, such as those given on the command line. They take
+// precedence over the workflow's vars block.
+func (p *Processor) SetVariables(vars map[string]string) {
+	for name, value := range vars {
+		p.variables[name] = value
+	}
+}
+
+// ParseVariableAssignments parses name=value pairs into a variable map. Later assignments
+// to the same name win.
+func ParseVariableAssignments(assignments []string) (map[string]string, error) {
+	vars := make(map[string]string)
+	for _, assignment := range assignments {
+		name, value, ok := strings.Cut(assignment, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid variable %q: expected name=value", assignment)
+		}
+		name = strings.TrimSpace(name)
+		if !variableNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid variable name %q", name)
+		}
+		vars[name] = value
+	}
+	return vars, nil
+}
+
+// LoadVariablesFile reads variables from a YAML or JSON file containing a mapping of names to
+// scalar values
+func LoadVariablesFile(path string) (map[string]string, error) {
+	data, err := fileutil.SafeReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read variables file %s: %w", path, err)
+	}
+
+	var raw map[string]yaml.Node
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse variables file %s: %w", path, err)
+	}
+
+	vars := make(map[string]string, len(raw))
+	for name, node := range raw {
+		if !variableNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid variable name %q in %s", name, path)
+		}
+		if node.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("variable %s in %s must be a single value", name, path)
+		}
+		if node.Tag == "!!null" {
+			vars[name] = ""
+			continue
+		}
+		vars[name] = node.Value
+	}
+	return vars, nil
+}
@@ -0,0 +1,69 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseVariableAssignments(t *testing.T) {
+	vars, err := ParseVariableAssignments([]string{"region=us-east", "query=a=b", "region=eu-west", "empty="})
+	if err != nil {
+		t.Fatalf("ParseVariableAssignments() unexpected error: %v", err)
+	}
+	want := map[string]string{"region": "eu-west", "query": "a=b", "empty": ""}
+	for name, value := range want {
+		if vars[name] != value {
+			t.Errorf("variable %s = %q, want %q", name, vars[name], value)
+		}
+	}
+
+	for _, invalid := range []string{"novalue", "bad-name=x", "=x"} {
+		if _, err := ParseVariableAssignments([]string{invalid}); err == nil {
+			t.Errorf("ParseVariableAssignments(%q) expected an error", invalid)
+		}
+	}
+}
+
+func TestLoadVariablesFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	vars, err := LoadVariablesFile(write("vars.yaml", "region: us-east\nlimit: 10\nverbose: true\nnote:\n"))
+	if err != nil {
+		t.Fatalf("LoadVariablesFile() unexpected error: %v", err)
+	}
+	want := map[string]string{"region": "us-east", "limit": "10", "verbose": "true", "note": ""}
+	for name, value := range want {
+		if got, ok := vars[name]; !ok || got != value {
+			t.Errorf("variable %s = %q, want %q", name, got, value)
+		}
+	}
+
+	vars, err = LoadVariablesFile(write("vars.json", `{"region": "eu-west", "limit": 5}`))
+	if err != nil || vars["region"] != "eu-west" || vars["limit"] != "5" {
+		t.Errorf("LoadVariablesFile() with JSON = %v, %v", vars, err)
+	}
+
+	for name, content := range map[string]string{"nested.yaml": "region:\n  primary: us-east\n", "list.yaml": "- a\n- b\n", "badname.yaml": "bad-name: x\n"} {
+		if _, err := LoadVariablesFile(write(name, content)); err == nil {
+			t.Errorf("LoadVariablesFile(%s) expected an error", name)
+		}
+	}
+}
+
+func TestSetVariablesOverrideWorkflowVars(t *testing.T) {
+	processor := NewProcessor(&DSLConfig{Vars: map[string]string{"region": "us-east", "team": "data"}}, createTestEnvConfig(), false)
+	processor.SetVariables(map[string]string{"region": "eu-west"})
+	processor.loadWorkflowVariables()
+
+	if got := processor.substituteVariables("$team in $region"); got != "data in eu-west" {
+		t.Errorf("substituteVariables() = %q, want command line variables to win", got)
+	}
+}
@@ -0,0 +1,157 @@
+package retry
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Config controls how many times an operation is attempted and how long to wait between attempts
+type Config struct {
+	MaxAttempts int           // Total attempts, including the first
+	BaseDelay   time.Duration // Delay before the first retry, doubled for each retry after that
+	MaxDelay    time.Duration // Upper bound on the delay between attempts
+}
+
+// DefaultRetryConfig is used when no retry settings are configured
+var DefaultRetryConfig = Config{
+	MaxAttempts: 3,
+	BaseDelay:   time.Second,
+	MaxDelay:    30 * time.Second,
+}
+
+// RetryAfterError is implemented by errors that say how long to wait before trying again, such as
+// a rate limited API response with a Retry-After header
+type RetryAfterError interface {
+	error
+	RetryAfter() time.Duration
+}
+
+// StatusError is an error from an API response, with the response's HTTP status code
+type StatusError struct {
+	StatusCode int
+	Err        error
+}
+
+func (e *StatusError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
+// WithStatus attaches the HTTP status code of a failed response to err, so WithRetry can tell
+// whether the request is worth trying again
+func WithStatus(err error, statusCode int) error {
+	if err == nil {
+		return nil
+	}
+	return &StatusError{StatusCode: statusCode, Err: err}
+}
+
+// Retryable reports whether an operation that failed with err may succeed when tried again: the
+// request was rate limited, the server failed, or the connection failed on the way. Other errors,
+// such as an invalid request or a rejected API key, fail the same way every time.
+func Retryable(err error) bool {
+	var retryAfter RetryAfterError
+	if errors.As(err, &retryAfter) {
+		return true
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTimeout || dnsErr.IsTemporary
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr)
+}
+
+// ParseRetryAfter reads a Retry-After header value given in seconds or as an HTTP date.
+// It returns zero when the value is missing or cannot be parsed.
+func ParseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		if seconds <= 0 {
+			return 0
+		}
+		return time.Duration(seconds * float64(time.Second))
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(date); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
+
+// sleep is replaced in tests to avoid waiting between attempts
+var sleep = time.Sleep
+
+// WithRetry calls fn until it succeeds, fails with an error that is not Retryable, or the
+// configured number of attempts is used up, backing off exponentially between attempts. When an error says how long to wait, that wait is
+// used instead of the backoff delay for that attempt.
+func WithRetry[T any](fn func() (T, error), config Config) (T, error) {
+	attempts := config.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	delay := config.BaseDelay
+	var result T
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		result, err = fn()
+		if err == nil {
+			return result, nil
+		}
+		if !Retryable(err) {
+			if attempt > 1 {
+				return result, fmt.Errorf("failed after %d attempts: %w", attempt, err)
+			}
+			return result, err
+		}
+		if attempt == attempts {
+			break
+		}
+
+		var retryAfter RetryAfterError
+		if errors.As(err, &retryAfter) && retryAfter.RetryAfter() > 0 {
+			if config.MaxDelay > 0 && retryAfter.RetryAfter() > config.MaxDelay {
+				return result, fmt.Errorf("retry after %s is longer than the maximum delay of %s: %w", retryAfter.RetryAfter(), config.MaxDelay, err)
+			}
+			sleep(retryAfter.RetryAfter())
+		} else {
+			sleep(delay)
+		}
+		delay *= 2
+		if config.MaxDelay > 0 && delay > config.MaxDelay {
+			delay = config.MaxDelay
+		}
+	}
+
+	if attempts > 1 {
+		return result, fmt.Errorf("failed after %d attempts: %w", attempts, err)
+	}
+	return result, err
+}
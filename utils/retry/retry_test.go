@@ -0,0 +1,213 @@
+package retry
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestWithRetry(t *testing.T) {
+	var delays []time.Duration
+	sleep = func(d time.Duration) { delays = append(delays, d) }
+	defer func() { sleep = time.Sleep }()
+
+	tests := []struct {
+		name       string
+		config     Config
+		failures   int
+		wantCalls  int
+		wantErr    bool
+		wantDelays []time.Duration
+	}{
+		{
+			name:       "succeeds first time",
+			config:     Config{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: 5 * time.Second},
+			failures:   0,
+			wantCalls:  1,
+			wantDelays: nil,
+		},
+		{
+			name:       "succeeds after retries",
+			config:     Config{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: 5 * time.Second},
+			failures:   2,
+			wantCalls:  3,
+			wantDelays: []time.Duration{time.Second, 2 * time.Second},
+		},
+		{
+			name:       "delay capped at max",
+			config:     Config{MaxAttempts: 5, BaseDelay: 2 * time.Second, MaxDelay: 5 * time.Second},
+			failures:   10,
+			wantCalls:  5,
+			wantErr:    true,
+			wantDelays: []time.Duration{2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second},
+		},
+		{
+			name:       "single attempt",
+			config:     Config{MaxAttempts: 1},
+			failures:   1,
+			wantCalls:  1,
+			wantErr:    true,
+			wantDelays: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delays = nil
+			calls := 0
+			result, err := WithRetry(func() (string, error) {
+				calls++
+				if calls <= tt.failures {
+					return "", WithStatus(errors.New("service unavailable"), http.StatusServiceUnavailable)
+				}
+				return "ok", nil
+			}, tt.config)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WithRetry() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && result != "ok" {
+				t.Errorf("WithRetry() = %q, want %q", result, "ok")
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("WithRetry() made %d calls, want %d", calls, tt.wantCalls)
+			}
+			if !reflect.DeepEqual(delays, tt.wantDelays) {
+				t.Errorf("WithRetry() delays = %v, want %v", delays, tt.wantDelays)
+			}
+		})
+	}
+}
+
+func TestWithRetryStopsOnPermanentErrors(t *testing.T) {
+	var delays []time.Duration
+	sleep = func(d time.Duration) { delays = append(delays, d) }
+	defer func() { sleep = time.Sleep }()
+
+	calls := 0
+	_, err := WithRetry(func() (string, error) {
+		calls++
+		if calls == 1 {
+			return "", WithStatus(errors.New("rate limited"), http.StatusTooManyRequests)
+		}
+		return "", WithStatus(errors.New("invalid API key"), http.StatusUnauthorized)
+	}, Config{MaxAttempts: 5, BaseDelay: time.Second})
+	if err == nil || err.Error() != "failed after 2 attempts: invalid API key" {
+		t.Errorf("WithRetry() error = %v, want the invalid API key after 2 attempts", err)
+	}
+	if calls != 2 || len(delays) != 1 {
+		t.Errorf("WithRetry() made %d calls with delays %v, want 2 calls", calls, delays)
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"rate limited", WithStatus(errors.New("slow down"), http.StatusTooManyRequests), true},
+		{"server error", fmt.Errorf("request: %w", WithStatus(errors.New("oops"), http.StatusBadGateway)), true},
+		{"bad request", WithStatus(errors.New("bad request"), http.StatusBadRequest), false},
+		{"unauthorized", WithStatus(errors.New("unauthorized"), http.StatusUnauthorized), false},
+		{"retry after", waitError{wait: time.Second}, true},
+		{"connection reset", fmt.Errorf("failed to send request: %w", &net.OpError{Op: "read", Err: syscall.ECONNRESET}), true},
+		{"timeout", &url.Error{Op: "Post", URL: "http://localhost", Err: timeoutError{}}, true},
+		{"unknown host", &net.DNSError{Err: "no such host", Name: "nope.invalid", IsNotFound: true}, false},
+		{"other error", errors.New("invalid model"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Retryable(tt.err); got != tt.want {
+				t.Errorf("Retryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+type waitError struct{ wait time.Duration }
+
+func (e waitError) Error() string             { return "rate limited" }
+func (e waitError) RetryAfter() time.Duration { return e.wait }
+
+func TestWithRetryHonorsRetryAfter(t *testing.T) {
+	var delays []time.Duration
+	sleep = func(d time.Duration) { delays = append(delays, d) }
+	defer func() { sleep = time.Sleep }()
+
+	calls := 0
+	_, err := WithRetry(func() (string, error) {
+		calls++
+		switch calls {
+		case 1:
+			return "", fmt.Errorf("request failed: %w", waitError{wait: 4 * time.Second})
+		case 2:
+			return "", WithStatus(errors.New("service unavailable"), http.StatusServiceUnavailable)
+		}
+		return "ok", nil
+	}, Config{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: 5 * time.Second})
+	if err != nil {
+		t.Fatalf("WithRetry() unexpected error: %v", err)
+	}
+	// The requested wait replaces the backoff delay, which continues to grow for other errors
+	want := []time.Duration{4 * time.Second, 2 * time.Second}
+	if !reflect.DeepEqual(delays, want) {
+		t.Errorf("WithRetry() delays = %v, want %v", delays, want)
+	}
+}
+
+func TestWithRetryFailsWhenRetryAfterExceedsMaxDelay(t *testing.T) {
+	var delays []time.Duration
+	sleep = func(d time.Duration) { delays = append(delays, d) }
+	defer func() { sleep = time.Sleep }()
+
+	calls := 0
+	_, err := WithRetry(func() (string, error) {
+		calls++
+		return "", waitError{wait: time.Hour}
+	}, Config{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: 30 * time.Second})
+	var retryAfter RetryAfterError
+	if !errors.As(err, &retryAfter) || !strings.Contains(err.Error(), "longer than the maximum delay of 30s") {
+		t.Errorf("WithRetry() error = %v, want the Retry-After error", err)
+	}
+	if calls != 1 || delays != nil {
+		t.Errorf("WithRetry() made %d calls with delays %v, want 1 call without waiting", calls, delays)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"3", 3 * time.Second},
+		{"0.5", 500 * time.Millisecond},
+		{"-1", 0},
+		{"soon", 0},
+		{"Wed, 21 Oct 2015 07:28:00 GMT", 0}, // in the past
+	}
+	for _, tt := range tests {
+		if got := ParseRetryAfter(tt.value); got != tt.want {
+			t.Errorf("ParseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+
+	future := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	if got := ParseRetryAfter(future); got < 59*time.Minute || got > time.Hour {
+		t.Errorf("ParseRetryAfter(%q) = %v, want about an hour", future, got)
+	}
+}
@@ -2,7 +2,10 @@ package server
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"path"
+	"path/filepath"
 	"strings"
 
 	"github.com/kris-hansen/comanda/utils/config"
@@ -40,20 +43,109 @@ func checkAuth(serverConfig *ServerConfig, w http.ResponseWriter, r *http.Reques
 		return false
 	}
 
-	if parts[1] != serverConfig.BearerToken {
-		config.VerboseLog("Invalid bearer token")
-		config.DebugLog("Auth failed: invalid bearer token provided")
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(ProcessResponse{
-			Success: false,
-			Error:   "Invalid bearer token",
+	if parts[1] == serverConfig.BearerToken {
+		config.VerboseLog("Authentication successful")
+		config.DebugLog("Auth successful: valid bearer token")
+		return true
+	}
+
+	if key := serverConfig.findAPIKey(parts[1]); key != nil {
+		if err := key.allows(r, serverConfig.DataDir); err != nil {
+			config.VerboseLog("API key %s denied: %v", key.Name, err)
+			config.DebugLog("Auth failed: API key %s is not allowed to %s %s", key.Name, r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(ProcessResponse{
+				Success: false,
+				Error:   err.Error(),
+			})
+			return false
+		}
+		config.VerboseLog("Authentication successful with API key %s", key.Name)
+		config.DebugLog("Auth successful: API key %s allows %s", key.Name, r.URL.Path)
+		return true
+	}
+
+	config.VerboseLog("Invalid bearer token")
+	config.DebugLog("Auth failed: invalid bearer token provided")
+	w.WriteHeader(http.StatusUnauthorized)
+	json.NewEncoder(w).Encode(ProcessResponse{
+		Success: false,
+		Error:   "Invalid bearer token",
+	})
+	return false
+}
+
+// apiKeys converts the scoped API keys of the environment file to the server's configuration
+func apiKeys(keys []config.APIKeyConfig) []APIKey {
+	var converted []APIKey
+	for _, key := range keys {
+		converted = append(converted, APIKey{
+			Name:      key.Name,
+			Token:     key.Token,
+			Workflows: key.Workflows,
+			Endpoints: key.Endpoints,
 		})
-		return false
 	}
+	return converted
+}
+
+// findAPIKey returns the scoped API key with the given token, or nil if there is none
+func (c *ServerConfig) findAPIKey(token string) *APIKey {
+	if token == "" {
+		return nil
+	}
+	for i := range c.APIKeys {
+		if c.APIKeys[i].Token == token {
+			return &c.APIKeys[i]
+		}
+	}
+	return nil
+}
 
-	config.VerboseLog("Authentication successful")
-	config.DebugLog("Auth successful: valid bearer token")
-	return true
+// allows checks that the key may call the request's endpoint and, for /process, run its workflow.
+// A key limited to some workflows may only call /process unless it lists its endpoints, so the
+// workflow limit cannot be sidestepped through the file endpoints.
+func (k *APIKey) allows(r *http.Request, dataDir string) error {
+	endpoints := k.Endpoints
+	if len(endpoints) == 0 && len(k.Workflows) > 0 {
+		endpoints = []string{"/process"}
+	}
+	if len(endpoints) > 0 && !matchesEndpoint(endpoints, r.URL.Path) {
+		return fmt.Errorf("API key %s is not allowed to access %s", k.Name, r.URL.Path)
+	}
+	if r.URL.Path != "/process" || len(k.Workflows) == 0 {
+		return nil
+	}
+
+	workflow := workflowName(r.URL.Query().Get("filename"), dataDir)
+	for _, pattern := range k.Workflows {
+		if matched, err := path.Match(pattern, workflow); err == nil && matched {
+			return nil
+		}
+	}
+	return fmt.Errorf("API key %s is not allowed to run workflow %s", k.Name, workflow)
+}
+
+// matchesEndpoint reports whether requestPath is one of the endpoints or below one of them,
+// so /files also allows /files/bulk
+func matchesEndpoint(endpoints []string, requestPath string) bool {
+	for _, endpoint := range endpoints {
+		endpoint = strings.TrimSuffix(endpoint, "/")
+		if requestPath == endpoint || strings.HasPrefix(requestPath, endpoint+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// workflowName returns a /process filename as a slash-separated path relative to the data
+// directory, the form API key workflow patterns are written in
+func workflowName(filename, dataDir string) string {
+	cleanPath := filepath.Clean(filename)
+	if rel, err := filepath.Rel(dataDir, cleanPath); err == nil && strings.HasPrefix(cleanPath, dataDir) {
+		cleanPath = rel
+	}
+	return filepath.ToSlash(cleanPath)
 }
 
 // containsStdin checks if a string contains STDIN, handling variable assignments
@@ -1,6 +1,8 @@
 package server
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
 )
 
@@ -175,3 +177,50 @@ analyze_text:
 		})
 	}
 }
+
+func TestCheckAuthAPIKeys(t *testing.T) {
+	serverConfig := &ServerConfig{
+		Enabled:     true,
+		DataDir:     "examples",
+		BearerToken: "admin-token",
+		APIKeys: []APIKey{
+			{Name: "summarizer", Token: "summarize-token", Workflows: []string{"summarize.yaml", "reports/*.yaml"}, Endpoints: []string{"/process"}},
+			{Name: "reader", Token: "read-token", Endpoints: []string{"/list", "/files/"}},
+			{Name: "reporter", Token: "report-token", Workflows: []string{"reports/*.yaml"}},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		token      string
+		target     string
+		wantStatus int
+	}{
+		{"bearer token allows everything", "admin-token", "/process?filename=other.yaml", http.StatusOK},
+		{"scoped key runs its workflow", "summarize-token", "/process?filename=summarize.yaml", http.StatusOK},
+		{"workflow under the data directory", "summarize-token", "/process?filename=examples/summarize.yaml", http.StatusOK},
+		{"workflow matching a pattern", "summarize-token", "/process?filename=reports/weekly.yaml", http.StatusOK},
+		{"workflow outside its scope", "summarize-token", "/process?filename=other.yaml", http.StatusForbidden},
+		{"pattern does not cross directories", "summarize-token", "/process?filename=reports/old/weekly.yaml", http.StatusForbidden},
+		{"endpoint outside its scope", "summarize-token", "/list", http.StatusForbidden},
+		{"key without workflow limits", "read-token", "/list", http.StatusOK},
+		{"endpoint below an allowed one", "read-token", "/files/bulk", http.StatusOK},
+		{"similar endpoint name", "read-token", "/listing", http.StatusForbidden},
+		{"workflow limits default to /process", "report-token", "/process?filename=reports/weekly.yaml", http.StatusOK},
+		{"workflow limits without endpoints", "report-token", "/files?path=reports/weekly.yaml", http.StatusForbidden},
+		{"unknown token", "guess", "/list", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			w := httptest.NewRecorder()
+
+			ok := checkAuth(serverConfig, w, req)
+			if ok != (tt.wantStatus == http.StatusOK) || w.Code != tt.wantStatus {
+				t.Errorf("checkAuth() = %v with status %d, want status %d", ok, w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
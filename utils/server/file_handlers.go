@@ -9,6 +9,7 @@ import (
 	"strings"
 
 	"github.com/kris-hansen/comanda/utils/config"
+	"github.com/kris-hansen/comanda/utils/processor"
 )
 
 // handleListFiles returns a list of files with detailed metadata
@@ -59,28 +60,33 @@ func (s *Server) listFilesWithMetadata(dir string) ([]FileInfo, error) {
 			return err
 		}
 
-		// For YAML files, determine if they require STDIN
-		methods := ""
+		fileInfo := FileInfo{
+			Name:       info.Name(),
+			Path:       relPath,
+			Size:       info.Size(),
+			IsDir:      info.IsDir(),
+			CreatedAt:  info.ModTime(), // Note: CreatedAt falls back to ModTime on some systems
+			ModifiedAt: info.ModTime(),
+		}
+
+		// For YAML files, determine if they require STDIN and include the workflow metadata
 		if strings.HasSuffix(info.Name(), ".yaml") {
 			content, err := os.ReadFile(path)
 			if err == nil {
 				if hasStdinInput(content) {
-					methods = "POST"
+					fileInfo.Methods = "POST"
 				} else {
-					methods = "GET"
+					fileInfo.Methods = "GET"
+				}
+				if dslConfig, err := processor.ParseDSLFile(path, dir); err == nil {
+					fileInfo.WorkflowName = dslConfig.Name
+					fileInfo.WorkflowDescription = dslConfig.Description
+					fileInfo.WorkflowVersion = dslConfig.Version
 				}
 			}
 		}
 
-		files = append(files, FileInfo{
-			Name:       info.Name(),
-			Path:       relPath,
-			Size:       info.Size(),
-			IsDir:      info.IsDir(),
-			CreatedAt:  info.ModTime(), // Note: CreatedAt falls back to ModTime on some systems
-			ModifiedAt: info.ModTime(),
-			Methods:    methods,
-		})
+		files = append(files, fileInfo)
 
 		return nil
 	})
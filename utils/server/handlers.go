@@ -9,16 +9,17 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/kris-hansen/comanda/utils/config"
 	"github.com/kris-hansen/comanda/utils/fileutil"
 	"github.com/kris-hansen/comanda/utils/processor"
-	"gopkg.in/yaml.v3"
 )
 
-func handleProcess(w http.ResponseWriter, r *http.Request, serverConfig *ServerConfig, envConfig *config.EnvConfig) {
+func handleProcess(w http.ResponseWriter, r *http.Request, serverConfig *ServerConfig, envConfig *config.EnvConfig, metrics *serverMetrics) {
 	w.Header().Set("Content-Type", "application/json")
 
 	// Get filename from query parameters
@@ -125,9 +126,9 @@ func handleProcess(w http.ResponseWriter, r *http.Request, serverConfig *ServerC
 		return
 	}
 
-	// First unmarshal into a map to preserve step names (same as CLI)
-	var rawConfig map[string]processor.StepConfig
-	if err := yaml.Unmarshal(yamlContent, &rawConfig); err != nil {
+	// Parse the workflow preserving step order (same as CLI); includes must stay in the data directory
+	dslConfig, err := processor.ParseDSLFile(finalPath, serverConfig.DataDir)
+	if err != nil {
 		config.VerboseLog("Error parsing YAML: %v", err)
 		config.DebugLog("YAML parse error: %v", err)
 		w.WriteHeader(http.StatusBadRequest)
@@ -138,17 +139,11 @@ func handleProcess(w http.ResponseWriter, r *http.Request, serverConfig *ServerC
 		return
 	}
 
-	// Convert map to ordered Steps slice (same as CLI)
-	var dslConfig processor.DSLConfig
-	for name, config := range rawConfig {
-		dslConfig.Steps = append(dslConfig.Steps, processor.Step{
-			Name:   name,
-			Config: config,
-		})
-	}
-
 	// Create processor instance with validation enabled
-	proc := processor.NewProcessor(&dslConfig, envConfig, true)
+	proc := processor.NewProcessor(dslConfig, envConfig, true)
+	proc.SetUploadResolver(func(handle string) (string, error) {
+		return resolveUpload(uploadDir(serverConfig.DataDir), handle, uploadTTL(serverConfig.Uploads), time.Now())
+	})
 
 	// Handle POST input if present
 	if r.Method == http.MethodPost {
@@ -207,7 +202,9 @@ func handleProcess(w http.ResponseWriter, r *http.Request, serverConfig *ServerC
 	config.DebugLog("Starting DSL processing")
 
 	// Run the processor which includes validation
+	start := time.Now()
 	err = proc.Process()
+	metrics.observeWorkflow(err == nil, time.Since(start), proc.StepResults())
 
 	// Create a WaitGroup to ensure we capture all output
 	var wg sync.WaitGroup
@@ -228,15 +225,23 @@ func handleProcess(w http.ResponseWriter, r *http.Request, serverConfig *ServerC
 
 	// Get the final output from the processor
 	finalOutput := proc.LastOutput()
+	var report *processor.RunReport
+	if wantsReport(r) {
+		report = proc.Report()
+		report.File = filename
+	}
 
 	if err != nil {
 		config.VerboseLog("Error processing DSL: %v", err)
 		config.DebugLog("DSL processing error: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		category := processor.CategoryOf(err)
+		w.WriteHeader(processErrorStatus(category))
 		json.NewEncoder(w).Encode(ProcessResponse{
-			Success: false,
-			Error:   fmt.Sprintf("Error processing DSL file: %v", err),
-			Output:  finalOutput,
+			Success:       false,
+			Error:         fmt.Sprintf("Error processing DSL file: %v", err),
+			Output:        finalOutput,
+			Report:        report,
+			ErrorCategory: category,
 		})
 		return
 	}
@@ -250,5 +255,32 @@ func handleProcess(w http.ResponseWriter, r *http.Request, serverConfig *ServerC
 		Success: true,
 		Message: fmt.Sprintf("Successfully processed %s", filename),
 		Output:  finalOutput,
+		Report:  report,
 	})
 }
+
+// processErrorStatus returns the HTTP status for a workflow that failed with an error of the given
+// category. Uncategorized errors are internal server errors.
+func processErrorStatus(category processor.ErrorCategory) int {
+	switch category {
+	case processor.CategoryValidation, processor.CategoryBudget:
+		return http.StatusUnprocessableEntity
+	case processor.CategoryProvider:
+		return http.StatusBadGateway
+	case processor.CategoryTimeout:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// wantsReport reports whether a process request asks for the run report, with the verbose query
+// parameter or the X-Comanda-Report header
+func wantsReport(r *http.Request) bool {
+	for _, value := range []string{r.URL.Query().Get("verbose"), r.Header.Get("X-Comanda-Report")} {
+		if enabled, err := strconv.ParseBool(value); err == nil && enabled {
+			return true
+		}
+	}
+	return false
+}
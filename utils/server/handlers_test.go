@@ -1,10 +1,17 @@
 package server
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/kris-hansen/comanda/utils/config"
 	"github.com/kris-hansen/comanda/utils/processor"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"gopkg.in/yaml.v3"
 )
 
@@ -110,3 +117,68 @@ analyze_text:
 	assert.Empty(t, dslConfig.Steps,
 		"Direct parsing into DSLConfig should result in no steps due to YAML structure mismatch")
 }
+
+func TestHandleProcessReport(t *testing.T) {
+	dataDir := t.TempDir()
+	workflow := "greet:\n  input: NA\n  model: NA\n  action: hello\n  output: STDOUT\n"
+	if err := os.WriteFile(filepath.Join(dataDir, "greet.yaml"), []byte(workflow), 0644); err != nil {
+		t.Fatal(err)
+	}
+	serverConfig := &ServerConfig{DataDir: dataDir}
+
+	tests := []struct {
+		name       string
+		url        string
+		header     string
+		wantReport bool
+	}{
+		{"no report by default", "/process?filename=greet.yaml", "", false},
+		{"verbose query parameter", "/process?filename=greet.yaml&verbose=true", "", true},
+		{"report header", "/process?filename=greet.yaml", "1", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.header != "" {
+				req.Header.Set("X-Comanda-Report", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handleProcess(rec, req, serverConfig, &config.EnvConfig{}, nil)
+
+			var resp ProcessResponse
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+			require.True(t, resp.Success, "process failed: %s", resp.Error)
+			if !tt.wantReport {
+				assert.Nil(t, resp.Report)
+				return
+			}
+			require.NotNil(t, resp.Report)
+			assert.Equal(t, "greet.yaml", resp.Report.File)
+			require.Len(t, resp.Report.Steps, 1)
+			assert.Equal(t, "greet", resp.Report.Steps[0].Name)
+			assert.True(t, resp.Report.Steps[0].Success)
+		})
+	}
+}
+
+func TestHandleProcessErrorStatus(t *testing.T) {
+	dataDir := t.TempDir()
+	workflow := "read:\n  input: missing.txt\n  model: NA\n  action: hello\n  output: STDOUT\n"
+	if err := os.WriteFile(filepath.Join(dataDir, "read.yaml"), []byte(workflow), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/process?filename=read.yaml", nil)
+	rec := httptest.NewRecorder()
+	handleProcess(rec, req, &ServerConfig{DataDir: dataDir}, &config.EnvConfig{}, nil)
+
+	var resp ProcessResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	assert.False(t, resp.Success)
+	assert.Equal(t, processor.CategoryIO, resp.ErrorCategory)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, processErrorStatus(processor.CategoryValidation))
+	assert.Equal(t, http.StatusBadGateway, processErrorStatus(processor.CategoryProvider))
+	assert.Equal(t, http.StatusGatewayTimeout, processErrorStatus(processor.CategoryTimeout))
+}
@@ -0,0 +1,266 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/kris-hansen/comanda/utils/config"
+)
+
+const (
+	defaultRunHistoryPath     = "comanda-runs.json"
+	defaultRunHistoryMaxRuns  = 100
+	defaultRunHistoryMaxBytes = 4096
+)
+
+// runHistory stores a capped list of /process runs in a JSON file
+type runHistory struct {
+	mu       sync.Mutex
+	path     string
+	maxRuns  int
+	maxBytes int
+	nextID   int64
+	runs     []RunRecord // Oldest first
+}
+
+// newRunHistory loads the run history described by the configuration, or returns nil when disabled.
+// Without a configured path the history is stored in the data directory.
+func newRunHistory(cfg RunHistoryConfig, dataDir string) (*runHistory, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	h := &runHistory{
+		path:     cfg.Path,
+		maxRuns:  cfg.MaxRuns,
+		maxBytes: cfg.MaxOutputBytes,
+		nextID:   1,
+	}
+	if h.path == "" {
+		h.path = filepath.Join(dataDir, defaultRunHistoryPath)
+	}
+	if h.maxRuns <= 0 {
+		h.maxRuns = defaultRunHistoryMaxRuns
+	}
+	if h.maxBytes <= 0 {
+		h.maxBytes = defaultRunHistoryMaxBytes
+	}
+
+	data, err := os.ReadFile(h.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return h, nil
+		}
+		return nil, fmt.Errorf("error reading run history: %w", err)
+	}
+	if err := json.Unmarshal(data, &h.runs); err != nil {
+		return nil, fmt.Errorf("error parsing run history %s: %w", h.path, err)
+	}
+	for _, run := range h.runs {
+		if run.ID >= h.nextID {
+			h.nextID = run.ID + 1
+		}
+	}
+	return h, nil
+}
+
+// add records a run, applying the output and retention caps, and saves the history
+func (h *runHistory) add(run RunRecord) error {
+	if h == nil {
+		return nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	run.ID = h.nextID
+	h.nextID++
+	if len(run.Output) > h.maxBytes {
+		// Cut before a character that would be split, so the stored output stays valid UTF-8
+		cut := h.maxBytes
+		for cut > 0 && !utf8.RuneStart(run.Output[cut]) {
+			cut--
+		}
+		run.Output = run.Output[:cut]
+		run.Truncated = true
+	}
+
+	h.runs = append(h.runs, run)
+	if len(h.runs) > h.maxRuns {
+		h.runs = h.runs[len(h.runs)-h.maxRuns:]
+	}
+
+	return h.save()
+}
+
+// save writes the history atomically; callers must hold the lock
+func (h *runHistory) save() error {
+	data, err := json.MarshalIndent(h.runs, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding run history: %w", err)
+	}
+
+	if dir := filepath.Dir(h.path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("error creating run history directory: %w", err)
+		}
+	}
+
+	tmpPath := h.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("error writing run history: %w", err)
+	}
+	return os.Rename(tmpPath, h.path)
+}
+
+// list returns the recorded runs, newest first
+func (h *runHistory) list() []RunRecord {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	runs := make([]RunRecord, len(h.runs))
+	for i, run := range h.runs {
+		runs[len(h.runs)-1-i] = run
+	}
+	return runs
+}
+
+// get returns the run with the given ID
+func (h *runHistory) get(id int64) (RunRecord, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, run := range h.runs {
+		if run.ID == id {
+			return run, true
+		}
+	}
+	return RunRecord{}, false
+}
+
+// recordingWriter captures the status code and body written by a handler
+type recordingWriter struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (rw *recordingWriter) WriteHeader(code int) {
+	rw.statusCode = code
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *recordingWriter) Write(b []byte) (int, error) {
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
+
+// recordRun wraps a /process handler so that each call is added to the run history
+func (s *Server) recordRun(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.history == nil {
+			handler(w, r)
+			return
+		}
+
+		start := time.Now()
+		rw := &recordingWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		handler(rw, r)
+
+		run := RunRecord{
+			StartedAt:  start,
+			Workflow:   r.URL.Query().Get("filename"),
+			Method:     r.Method,
+			StatusCode: rw.statusCode,
+			DurationMs: time.Since(start).Milliseconds(),
+		}
+
+		var response ProcessResponse
+		if err := json.Unmarshal(rw.body.Bytes(), &response); err == nil {
+			run.Success = response.Success
+			run.Error = response.Error
+			run.Output = response.Output
+		}
+
+		if err := s.history.add(run); err != nil {
+			config.VerboseLog("Error saving run history: %v", err)
+		}
+	}
+}
+
+// handleListRuns returns the recorded runs, newest first
+func (s *Server) handleListRuns(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if s.history == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(RunListResponse{
+			Success: false,
+			Error:   "Run history is not enabled",
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(RunListResponse{
+		Success: true,
+		Runs:    s.history.list(),
+	})
+}
+
+// handleGetRun returns a single run identified by /runs/{id}
+func (s *Server) handleGetRun(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if s.history == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(RunResponse{
+			Success: false,
+			Error:   "Run history is not enabled",
+		})
+		return
+	}
+
+	id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/runs/"), 10, 64)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(RunResponse{
+			Success: false,
+			Error:   "Invalid run ID",
+		})
+		return
+	}
+
+	run, ok := s.history.get(id)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(RunResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Run %d not found", id),
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(RunResponse{
+		Success: true,
+		Run:     &run,
+	})
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunHistoryRetention(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "runs.json")
+	history, err := newRunHistory(RunHistoryConfig{
+		Enabled:        true,
+		Path:           path,
+		MaxRuns:        2,
+		MaxOutputBytes: 5,
+	}, "")
+	require.NoError(t, err)
+
+	for _, workflow := range []string{"a.yaml", "b.yaml", "c.yaml"} {
+		require.NoError(t, history.add(RunRecord{Workflow: workflow, Output: "0123456789"}))
+	}
+
+	// Only the newest runs are kept, newest first, with truncated output
+	runs := history.list()
+	require.Len(t, runs, 2)
+	assert.Equal(t, "c.yaml", runs[0].Workflow)
+	assert.Equal(t, int64(3), runs[0].ID)
+	assert.Equal(t, "b.yaml", runs[1].Workflow)
+	assert.Equal(t, "01234", runs[0].Output)
+	assert.True(t, runs[0].Truncated)
+
+	// The history survives a restart and IDs keep increasing
+	reloaded, err := newRunHistory(RunHistoryConfig{Enabled: true, Path: path, MaxRuns: 2}, "")
+	require.NoError(t, err)
+	require.Len(t, reloaded.list(), 2)
+	require.NoError(t, reloaded.add(RunRecord{Workflow: "d.yaml"}))
+	assert.Equal(t, int64(4), reloaded.list()[0].ID)
+}
+
+func TestRunHistoryDefaults(t *testing.T) {
+	dataDir := t.TempDir()
+	history, err := newRunHistory(RunHistoryConfig{Enabled: true, MaxOutputBytes: 4}, dataDir)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dataDir, "comanda-runs.json"), history.path)
+
+	// A cut at 4 bytes would split the three byte €
+	require.NoError(t, history.add(RunRecord{Workflow: "a.yaml", Output: "hé€llo"}))
+	run := history.list()[0]
+	assert.Equal(t, "hé", run.Output)
+	assert.True(t, utf8.ValidString(run.Output))
+	assert.FileExists(t, history.path)
+}
+
+func TestRunHistoryDisabled(t *testing.T) {
+	history, err := newRunHistory(RunHistoryConfig{Enabled: false}, "")
+	require.NoError(t, err)
+	assert.Nil(t, history)
+
+	s := &Server{config: &ServerConfig{}}
+	w := httptest.NewRecorder()
+	s.handleListRuns(w, httptest.NewRequest(http.MethodGet, "/runs", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestRecordRun(t *testing.T) {
+	history, err := newRunHistory(RunHistoryConfig{
+		Enabled: true,
+		Path:    filepath.Join(t.TempDir(), "runs.json"),
+	}, "")
+	require.NoError(t, err)
+	s := &Server{config: &ServerConfig{}, history: history}
+
+	handler := s.recordRun(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ProcessResponse{
+			Success: false,
+			Error:   "model failed",
+		})
+	})
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/process?filename=test.yaml", nil))
+
+	// List the recorded run
+	w := httptest.NewRecorder()
+	s.handleListRuns(w, httptest.NewRequest(http.MethodGet, "/runs", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var list RunListResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&list))
+	require.Len(t, list.Runs, 1)
+	assert.Equal(t, "test.yaml", list.Runs[0].Workflow)
+	assert.Equal(t, http.StatusInternalServerError, list.Runs[0].StatusCode)
+	assert.False(t, list.Runs[0].Success)
+	assert.Equal(t, "model failed", list.Runs[0].Error)
+
+	// Fetch it by ID
+	w = httptest.NewRecorder()
+	s.handleGetRun(w, httptest.NewRequest(http.MethodGet, "/runs/1", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var single RunResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&single))
+	require.NotNil(t, single.Run)
+	assert.Equal(t, int64(1), single.Run.ID)
+
+	// Unknown IDs are not found
+	w = httptest.NewRecorder()
+	s.handleGetRun(w, httptest.NewRequest(http.MethodGet, "/runs/42", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
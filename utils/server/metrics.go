@@ -0,0 +1,151 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/kris-hansen/comanda/utils/processor"
+)
+
+// durationBuckets are the upper bounds, in seconds, of the duration histograms
+var durationBuckets = []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+// serverMetrics collects request, workflow and provider metrics in a registry of its own, so
+// /metrics serves only the server's metrics
+type serverMetrics struct {
+	registry         *prometheus.Registry
+	requests         *prometheus.CounterVec
+	workflows        *prometheus.CounterVec
+	providerCalls    *prometheus.CounterVec
+	workflowDuration prometheus.Histogram
+	stepDuration     prometheus.Histogram
+}
+
+// newServerMetrics returns the metrics collector, or nil when metrics are disabled
+func newServerMetrics(cfg MetricsConfig) *serverMetrics {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	m := &serverMetrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "comanda_http_requests_total",
+			Help: "HTTP requests handled, by endpoint, method and status code.",
+		}, []string{"path", "method", "status"}),
+		workflows: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "comanda_workflows_total",
+			Help: "Workflow runs, by outcome.",
+		}, []string{"outcome"}),
+		providerCalls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "comanda_provider_calls_total",
+			Help: "Model calls made by workflow steps, by model and status.",
+		}, []string{"model", "status"}),
+		workflowDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "comanda_workflow_duration_seconds",
+			Help:    "Duration of workflow runs.",
+			Buckets: durationBuckets,
+		}),
+		stepDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "comanda_step_duration_seconds",
+			Help:    "Duration of workflow steps.",
+			Buckets: durationBuckets,
+		}),
+	}
+	m.registry.MustRegister(m.requests, m.workflows, m.providerCalls, m.workflowDuration, m.stepDuration)
+
+	// Both outcomes are exported from the start, so a rate over them works before the first failure
+	m.workflows.WithLabelValues("success")
+	m.workflows.WithLabelValues("failure")
+	return m
+}
+
+// observeRequest counts a handled HTTP request
+func (m *serverMetrics) observeRequest(path, method string, status int) {
+	if m == nil {
+		return
+	}
+	m.requests.WithLabelValues(path, method, strconv.Itoa(status)).Inc()
+}
+
+// observeWorkflow records the outcome and duration of a workflow run along with
+// the duration and model call of each of its steps
+func (m *serverMetrics) observeWorkflow(success bool, duration time.Duration, steps []processor.StepResult) {
+	if m == nil {
+		return
+	}
+
+	outcome := "success"
+	if !success {
+		outcome = "failure"
+	}
+	m.workflows.WithLabelValues(outcome).Inc()
+	m.workflowDuration.Observe(duration.Seconds())
+
+	for _, step := range steps {
+		m.stepDuration.Observe(float64(step.DurationMs) / 1000)
+		if step.Model == "" {
+			continue
+		}
+		status := "success"
+		if !step.Success {
+			status = "error"
+		}
+		m.providerCalls.WithLabelValues(step.Model, status).Inc()
+	}
+}
+
+// handler serves the metrics in the Prometheus exposition format
+func (m *serverMetrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// countRequest wraps a handler so that each request is counted by route pattern, method and status
+func (s *Server) countRequest(handler http.HandlerFunc) http.HandlerFunc {
+	if s.metrics == nil {
+		return handler
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		handler(rw, r)
+
+		// Label by the registered pattern rather than the raw path to keep cardinality bounded
+		_, pattern := s.mux.Handler(r)
+		if pattern == "" {
+			pattern = "other"
+		}
+		s.metrics.observeRequest(pattern, r.Method, rw.statusCode)
+	}
+}
+
+// metricsMiddleware applies CORS and logging to the metrics endpoint, and the bearer token
+// check only when metrics.require_auth is set
+func (s *Server) metricsMiddleware(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.handleCORS(w)
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		logRequest(func(w http.ResponseWriter, r *http.Request) {
+			if s.config.Metrics.RequireAuth && !checkAuth(s.config, w, r) {
+				return
+			}
+			handler(w, r)
+		})(w, r)
+	}
+}
+
+// handleMetrics serves the collected metrics
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	s.metrics.handler().ServeHTTP(w, r)
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kris-hansen/comanda/utils/processor"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerMetricsDisabled(t *testing.T) {
+	var m *serverMetrics = newServerMetrics(MetricsConfig{})
+	assert.Nil(t, m)
+
+	// Recording on disabled metrics is a no-op
+	m.observeRequest("/list", http.MethodGet, http.StatusOK)
+	m.observeWorkflow(true, time.Second, nil)
+}
+
+func TestServerMetricsHandler(t *testing.T) {
+	m := newServerMetrics(MetricsConfig{Enabled: true})
+	m.observeRequest("/process", http.MethodGet, http.StatusOK)
+	m.observeRequest("/process", http.MethodGet, http.StatusOK)
+	m.observeRequest("/list", http.MethodGet, http.StatusUnauthorized)
+	m.observeWorkflow(true, 2*time.Second, []processor.StepResult{
+		{Name: "summarize", Model: "gpt-4o", DurationMs: 1500, Success: true},
+		{Name: "save", DurationMs: 10, Success: true},
+	})
+	m.observeWorkflow(false, 200*time.Millisecond, []processor.StepResult{
+		{Name: "summarize", Model: "gpt-4o", DurationMs: 200, Success: false},
+	})
+
+	w := httptest.NewRecorder()
+	m.handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	out := w.Body.String()
+
+	assert.Contains(t, out, `comanda_http_requests_total{method="GET",path="/process",status="200"} 2`)
+	assert.Contains(t, out, `comanda_http_requests_total{method="GET",path="/list",status="401"} 1`)
+	assert.Contains(t, out, `comanda_workflows_total{outcome="success"} 1`)
+	assert.Contains(t, out, `comanda_workflows_total{outcome="failure"} 1`)
+	assert.Contains(t, out, `comanda_provider_calls_total{model="gpt-4o",status="success"} 1`)
+	assert.Contains(t, out, `comanda_provider_calls_total{model="gpt-4o",status="error"} 1`)
+	assert.Contains(t, out, `comanda_workflow_duration_seconds_bucket{le="0.5"} 1`)
+	assert.Contains(t, out, `comanda_workflow_duration_seconds_bucket{le="+Inf"} 2`)
+	assert.Contains(t, out, `comanda_workflow_duration_seconds_count 2`)
+	assert.Contains(t, out, `comanda_step_duration_seconds_bucket{le="0.1"} 1`)
+	assert.Contains(t, out, `comanda_step_duration_seconds_count 3`)
+}
+
+func TestMetricsEndpointAuth(t *testing.T) {
+	newServer := func(requireAuth bool) *Server {
+		s := &Server{
+			mux: http.NewServeMux(),
+			config: &ServerConfig{
+				Enabled:     true,
+				BearerToken: "secret",
+				Metrics:     MetricsConfig{Enabled: true, RequireAuth: requireAuth},
+			},
+			limiter: newRateLimiter(RateLimitConfig{}),
+		}
+		s.metrics = newServerMetrics(s.config.Metrics)
+		s.routes()
+		return s
+	}
+
+	// Scrapers can reach /metrics without the bearer token by default
+	s := newServer(false)
+	w := httptest.NewRecorder()
+	s.mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "# TYPE comanda_workflows_total counter")
+
+	// Requests to other endpoints are counted by route, including rejected ones
+	s.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/list", nil))
+	w = httptest.NewRecorder()
+	s.mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, w.Body.String(), `comanda_http_requests_total{method="GET",path="/list",status="401"} 1`)
+
+	// With require_auth the token is checked
+	s = newServer(true)
+	w = httptest.NewRecorder()
+	s.mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	w = httptest.NewRecorder()
+	s.mux.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,162 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kris-hansen/comanda/utils/config"
+)
+
+// tokenBucket tracks the remaining request allowance for a single client
+type tokenBucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// bucketIdleTime is how long a bucket takes to refill completely. A bucket idle for that long is
+// the same as a new one, so it is removed to keep the map from growing with every client seen.
+const bucketIdleTime = time.Minute
+
+// rateLimiter enforces per-client request rates and a global workflow concurrency cap
+type rateLimiter struct {
+	mu         sync.Mutex
+	enabled    bool
+	perMinute  int
+	buckets    map[string]*tokenBucket
+	lastPruned time.Time
+	workflows  chan struct{} // nil when concurrency is unlimited
+	now        func() time.Time
+}
+
+// newRateLimiter creates a rate limiter from the server configuration
+func newRateLimiter(cfg RateLimitConfig) *rateLimiter {
+	rl := &rateLimiter{
+		enabled:   cfg.Enabled,
+		perMinute: cfg.RequestsPerMinute,
+		buckets:   make(map[string]*tokenBucket),
+		now:       time.Now,
+	}
+	if cfg.Enabled && cfg.MaxConcurrent > 0 {
+		rl.workflows = make(chan struct{}, cfg.MaxConcurrent)
+	}
+	return rl
+}
+
+// clientKey identifies the caller by bearer token once the token has been authenticated, and by
+// remote IP otherwise, so a client cannot get a fresh allowance by sending a made-up token
+func clientKey(r *http.Request, authenticated bool) string {
+	if auth := r.Header.Get("Authorization"); authenticated && strings.HasPrefix(auth, "Bearer ") {
+		return "token:" + strings.TrimPrefix(auth, "Bearer ")
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// take consumes one request from the client's bucket, returning the wait time when empty
+func (rl *rateLimiter) take(key string) (bool, time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := rl.now()
+	if now.Sub(rl.lastPruned) >= bucketIdleTime {
+		rl.prune(now)
+	}
+	capacity := float64(rl.perMinute)
+	refillPerSecond := capacity / 60
+
+	bucket, exists := rl.buckets[key]
+	if !exists {
+		bucket = &tokenBucket{tokens: capacity, lastSeen: now}
+		rl.buckets[key] = bucket
+	} else {
+		elapsed := now.Sub(bucket.lastSeen).Seconds()
+		bucket.tokens = math.Min(capacity, bucket.tokens+elapsed*refillPerSecond)
+		bucket.lastSeen = now
+	}
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+
+	wait := time.Duration((1 - bucket.tokens) / refillPerSecond * float64(time.Second))
+	return false, wait
+}
+
+// prune removes the buckets that have been idle long enough to be full again; the caller must hold mu
+func (rl *rateLimiter) prune(now time.Time) {
+	for key, bucket := range rl.buckets {
+		if now.Sub(bucket.lastSeen) >= bucketIdleTime {
+			delete(rl.buckets, key)
+		}
+	}
+	rl.lastPruned = now
+}
+
+// allow checks the request rate for the caller and writes a 429 response when exceeded.
+// authenticated reports whether the request's bearer token has been checked.
+func (rl *rateLimiter) allow(w http.ResponseWriter, r *http.Request, authenticated bool) bool {
+	if rl == nil || !rl.enabled || rl.perMinute <= 0 {
+		return true
+	}
+
+	key := clientKey(r, authenticated)
+	ok, wait := rl.take(key)
+	if ok {
+		return true
+	}
+
+	config.VerboseLog("Rate limit exceeded")
+	config.DebugLog("Rate limit exceeded for client, retry after %v", wait)
+	writeTooManyRequests(w, wait, "Rate limit exceeded")
+	return false
+}
+
+// acquireWorkflow reserves a workflow slot, writing a 429 response when none are free
+func (rl *rateLimiter) acquireWorkflow(w http.ResponseWriter) bool {
+	if rl == nil || rl.workflows == nil {
+		return true
+	}
+
+	select {
+	case rl.workflows <- struct{}{}:
+		return true
+	default:
+		config.VerboseLog("Maximum concurrent workflows reached")
+		config.DebugLog("Rejecting workflow: %d already running", cap(rl.workflows))
+		writeTooManyRequests(w, time.Second, "Too many workflows are running, try again later")
+		return false
+	}
+}
+
+// releaseWorkflow frees a workflow slot reserved by acquireWorkflow
+func (rl *rateLimiter) releaseWorkflow() {
+	if rl == nil || rl.workflows == nil {
+		return
+	}
+	<-rl.workflows
+}
+
+// writeTooManyRequests sends an HTTP 429 response with a Retry-After header
+func writeTooManyRequests(w http.ResponseWriter, wait time.Duration, message string) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", fmt.Sprintf("%d", seconds))
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(ProcessResponse{
+		Success: false,
+		Error:   message,
+	})
+}
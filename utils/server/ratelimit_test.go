@@ -0,0 +1,124 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiterAllow(t *testing.T) {
+	limiter := newRateLimiter(RateLimitConfig{
+		Enabled:           true,
+		RequestsPerMinute: 2,
+	})
+	current := time.Now()
+	limiter.now = func() time.Time { return current }
+
+	newRequest := func(token string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/list", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		return req
+	}
+
+	// The first two requests fit within the allowance
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		assert.True(t, limiter.allow(w, newRequest("token-a"), true))
+	}
+
+	// The third request is rejected with a Retry-After header
+	w := httptest.NewRecorder()
+	assert.False(t, limiter.allow(w, newRequest("token-a"), true))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "30", w.Header().Get("Retry-After"))
+
+	// Other clients have their own allowance
+	w = httptest.NewRecorder()
+	assert.True(t, limiter.allow(w, newRequest("token-b"), true))
+
+	// The allowance refills over time
+	current = current.Add(30 * time.Second)
+	w = httptest.NewRecorder()
+	assert.True(t, limiter.allow(w, newRequest("token-a"), true))
+}
+
+func TestRateLimiterDisabled(t *testing.T) {
+	limiter := newRateLimiter(RateLimitConfig{
+		Enabled:           false,
+		RequestsPerMinute: 1,
+		MaxConcurrent:     1,
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/list", nil)
+	for i := 0; i < 5; i++ {
+		assert.True(t, limiter.allow(httptest.NewRecorder(), req, false))
+		assert.True(t, limiter.acquireWorkflow(httptest.NewRecorder()))
+	}
+}
+
+func TestRateLimiterMaxConcurrent(t *testing.T) {
+	limiter := newRateLimiter(RateLimitConfig{
+		Enabled:       true,
+		MaxConcurrent: 1,
+	})
+
+	assert.True(t, limiter.acquireWorkflow(httptest.NewRecorder()))
+
+	w := httptest.NewRecorder()
+	assert.False(t, limiter.acquireWorkflow(w))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+
+	limiter.releaseWorkflow()
+	assert.True(t, limiter.acquireWorkflow(httptest.NewRecorder()))
+}
+
+func TestClientKey(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/list", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	assert.Equal(t, "ip:10.0.0.1", clientKey(req, false))
+
+	req.Header.Set("Authorization", "Bearer abc")
+	assert.Equal(t, "token:abc", clientKey(req, true))
+
+	// An unchecked token does not identify the client
+	assert.Equal(t, "ip:10.0.0.1", clientKey(req, false))
+}
+
+func TestRateLimiterUnauthenticatedTokens(t *testing.T) {
+	limiter := newRateLimiter(RateLimitConfig{
+		Enabled:           true,
+		RequestsPerMinute: 1,
+	})
+
+	// Sending a different token on every request does not reset the allowance
+	for i, token := range []string{"random-1", "random-2"} {
+		req := httptest.NewRequest(http.MethodGet, "/list", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("Authorization", "Bearer "+token)
+		assert.Equal(t, i == 0, limiter.allow(httptest.NewRecorder(), req, false))
+	}
+}
+
+func TestRateLimiterPrunesIdleBuckets(t *testing.T) {
+	limiter := newRateLimiter(RateLimitConfig{
+		Enabled:           true,
+		RequestsPerMinute: 10,
+	})
+	current := time.Now()
+	limiter.now = func() time.Time { return current }
+
+	limiter.take("ip:10.0.0.1")
+	current = current.Add(30 * time.Second)
+	limiter.take("ip:10.0.0.2")
+	assert.Len(t, limiter.buckets, 2)
+
+	// A minute after the first client's last request, its bucket is full and is removed
+	current = current.Add(45 * time.Second)
+	limiter.take("ip:10.0.0.3")
+	assert.Len(t, limiter.buckets, 2)
+	assert.NotContains(t, limiter.buckets, "ip:10.0.0.1")
+}
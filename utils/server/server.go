@@ -1,22 +1,33 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/kris-hansen/comanda/utils/config"
 )
 
+// defaultShutdownTimeout is how long in-flight requests may run after a shutdown signal when
+// shutdown_timeout is not set
+const defaultShutdownTimeout = 30 * time.Second
+
 // Server represents the HTTP server
 type Server struct {
 	mux       *http.ServeMux
 	config    *ServerConfig
 	envConfig *config.EnvConfig
+	limiter   *rateLimiter
+	history   *runHistory    // nil when run history is disabled
+	metrics   *serverMetrics // nil when metrics are disabled
 }
 
 // validatePath ensures a path is relative and within the data directory
@@ -137,13 +148,17 @@ func (s *Server) combinedMiddleware(handler http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
-		// For non-OPTIONS requests, proceed with logging and auth
-		logRequest(func(w http.ResponseWriter, r *http.Request) {
+		// For non-OPTIONS requests, proceed with logging, auth and rate limiting
+		s.countRequest(logRequest(func(w http.ResponseWriter, r *http.Request) {
 			if !checkAuth(s.config, w, r) {
 				return
 			}
+			// With authentication enabled, the token has just been checked
+			if !s.limiter.allow(w, r, s.config.Enabled) {
+				return
+			}
 			handler(w, r)
-		})(w, r)
+		}))(w, r)
 	}
 }
 
@@ -165,6 +180,7 @@ func New(envConfig *config.EnvConfig) (*http.Server, error) {
 		Port:        serverConfig.Port,
 		DataDir:     serverConfig.DataDir,
 		BearerToken: serverConfig.BearerToken,
+		APIKeys:     apiKeys(serverConfig.APIKeys),
 		Enabled:     serverConfig.Enabled,
 		CORS: CORSConfig{
 			Enabled:        true,
@@ -173,12 +189,43 @@ func New(envConfig *config.EnvConfig) (*http.Server, error) {
 			AllowedHeaders: []string{"Authorization", "Content-Type"},
 			MaxAge:         3600,
 		},
+		RateLimit: RateLimitConfig{
+			Enabled:           serverConfig.RateLimit.Enabled,
+			RequestsPerMinute: serverConfig.RateLimit.RequestsPerMinute,
+			MaxConcurrent:     serverConfig.RateLimit.MaxConcurrent,
+		},
+		RunHistory: RunHistoryConfig{
+			Enabled:        serverConfig.RunHistory.Enabled,
+			Path:           serverConfig.RunHistory.Path,
+			MaxRuns:        serverConfig.RunHistory.MaxRuns,
+			MaxOutputBytes: serverConfig.RunHistory.MaxOutputBytes,
+		},
+		Uploads: UploadConfig{
+			TTLHours: serverConfig.Uploads.TTLHours,
+		},
+		Metrics: MetricsConfig{
+			Enabled:     serverConfig.Metrics.Enabled,
+			RequireAuth: serverConfig.Metrics.RequireAuth,
+		},
+	}
+
+	history, err := newRunHistory(srvConfig.RunHistory, srvConfig.DataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Remove uploads that expired while the server was stopped
+	if err := cleanupUploads(uploadDir(srvConfig.DataDir), uploadTTL(srvConfig.Uploads), time.Now()); err != nil {
+		return nil, err
 	}
 
 	s := &Server{
 		mux:       http.NewServeMux(),
 		config:    srvConfig,
 		envConfig: envConfig,
+		limiter:   newRateLimiter(srvConfig.RateLimit),
+		history:   history,
+		metrics:   newServerMetrics(srvConfig.Metrics),
 	}
 
 	// Register routes
@@ -212,6 +259,7 @@ func (s *Server) routes() {
 	s.mux.HandleFunc("/files/bulk", s.combinedMiddleware(s.handleBulkFileOperation))
 	s.mux.HandleFunc("/files/backup", s.combinedMiddleware(s.handleFileBackup))
 	s.mux.HandleFunc("/files/restore", s.combinedMiddleware(s.handleFileRestore))
+	s.mux.HandleFunc("/files/upload", s.combinedMiddleware(s.handleUpload))
 
 	// Provider operations - require auth
 	s.mux.HandleFunc("/providers", s.combinedMiddleware(func(w http.ResponseWriter, r *http.Request) {
@@ -258,8 +306,23 @@ func (s *Server) routes() {
 
 	// Process endpoint - requires auth
 	s.mux.HandleFunc("/process", s.combinedMiddleware(func(w http.ResponseWriter, r *http.Request) {
-		handleProcess(w, r, s.config, s.envConfig)
+		if !s.limiter.acquireWorkflow(w) {
+			return
+		}
+		defer s.limiter.releaseWorkflow()
+		s.recordRun(func(w http.ResponseWriter, r *http.Request) {
+			handleProcess(w, r, s.config, s.envConfig, s.metrics)
+		})(w, r)
 	}))
+
+	// Run history - requires auth
+	s.mux.HandleFunc("/runs", s.combinedMiddleware(s.handleListRuns))
+	s.mux.HandleFunc("/runs/", s.combinedMiddleware(s.handleGetRun))
+
+	// Metrics - only registered when enabled; auth is optional so scrapers can reach it
+	if s.metrics != nil {
+		s.mux.HandleFunc("/metrics", s.metricsMiddleware(s.handleMetrics))
+	}
 }
 
 // Run creates and starts the HTTP server with the given configuration
@@ -280,13 +343,57 @@ func Run(envConfig *config.EnvConfig) error {
 		fmt.Println("Authentication is enabled. Bearer token required.")
 		fmt.Printf("Example usage: curl -H 'Authorization: Bearer %s' 'http://localhost:%d/process?filename=examples/openai-example.yaml'\n",
 			serverConfig.BearerToken, serverConfig.Port)
+		if len(serverConfig.APIKeys) > 0 {
+			fmt.Printf("Scoped API keys: %d\n", len(serverConfig.APIKeys))
+		}
 	} else {
 		fmt.Printf("Example usage: curl 'http://localhost:%d/process?filename=examples/openai-example.yaml'\n", serverConfig.Port)
 	}
 
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
 		return fmt.Errorf("server failed to start: %v", err)
 	}
 
+	// Stop on SIGINT or SIGTERM, letting in-flight requests finish first
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return serve(ctx, server, listener, shutdownTimeout(serverConfig))
+}
+
+// shutdownTimeout returns how long in-flight requests may run after a shutdown signal
+func shutdownTimeout(serverConfig *config.ServerConfig) time.Duration {
+	if serverConfig.ShutdownTimeout > 0 {
+		return serverConfig.ShutdownTimeout
+	}
+	return defaultShutdownTimeout
+}
+
+// serve handles requests on listener until ctx is done. It then stops accepting connections and
+// waits up to timeout for in-flight requests, such as running workflows, before closing the rest.
+func serve(ctx context.Context, server *http.Server, listener net.Listener, timeout time.Duration) error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-errCh:
+		if err != nil && err != http.ErrServerClosed {
+			return fmt.Errorf("server failed: %v", err)
+		}
+		return nil
+	case <-ctx.Done():
+	}
+
+	fmt.Printf("Shutting down, waiting up to %s for in-flight requests to finish...\n", timeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		server.Close()
+		return fmt.Errorf("in-flight requests did not finish within %s and were stopped: %w", timeout, err)
+	}
+	fmt.Println("Server stopped.")
 	return nil
 }
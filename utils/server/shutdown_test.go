@@ -0,0 +1,72 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startSlowServer serves a handler that takes delay to respond and signals when a request starts
+func startSlowServer(t *testing.T, delay, timeout time.Duration) (string, context.CancelFunc, <-chan struct{}, <-chan error) {
+	started := make(chan struct{}, 1)
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		time.Sleep(delay)
+		w.Write([]byte("done"))
+	})}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- serve(ctx, server, listener, timeout)
+	}()
+	return "http://" + listener.Addr().String(), cancel, started, done
+}
+
+func TestServeDrainsInFlightRequests(t *testing.T) {
+	url, cancel, started, done := startSlowServer(t, 200*time.Millisecond, 5*time.Second)
+
+	responses := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			responses <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		responses <- string(body)
+	}()
+
+	<-started
+	cancel()
+
+	// The in-flight request completes and the server stops cleanly
+	assert.Equal(t, "done", <-responses)
+	assert.NoError(t, <-done)
+
+	// New connections are refused once the server has stopped
+	_, err := http.Get(url)
+	assert.Error(t, err)
+}
+
+func TestServeShutdownTimeout(t *testing.T) {
+	url, cancel, started, done := startSlowServer(t, 2*time.Second, 50*time.Millisecond)
+
+	go http.Get(url)
+	<-started
+	cancel()
+
+	err := <-done
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "did not finish within 50ms")
+}
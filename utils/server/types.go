@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/kris-hansen/comanda/utils/processor"
 )
 
 // CORSConfig holds CORS-related configuration options
@@ -16,21 +18,101 @@ type CORSConfig struct {
 	Enabled        bool     `json:"enabled"`
 }
 
+// RateLimitConfig holds request rate limiting options
+type RateLimitConfig struct {
+	Enabled           bool `json:"enabled"`
+	RequestsPerMinute int  `json:"requestsPerMinute"`
+	MaxConcurrent     int  `json:"maxConcurrent"`
+}
+
+// RunHistoryConfig holds run history persistence options
+type RunHistoryConfig struct {
+	Enabled        bool   `json:"enabled"`
+	Path           string `json:"path"`
+	MaxRuns        int    `json:"maxRuns"`
+	MaxOutputBytes int    `json:"maxOutputBytes"`
+}
+
+// UploadConfig holds options for uploaded files
+type UploadConfig struct {
+	TTLHours int `json:"ttlHours"`
+}
+
+// MetricsConfig holds options for the metrics endpoint
+type MetricsConfig struct {
+	Enabled     bool `json:"enabled"`
+	RequireAuth bool `json:"requireAuth"`
+}
+
+// APIKey holds a scoped bearer token and the workflows and endpoints it may use
+type APIKey struct {
+	Name      string   `json:"name"`
+	Token     string   `json:"token,omitempty"`
+	Workflows []string `json:"workflows,omitempty"`
+	Endpoints []string `json:"endpoints,omitempty"`
+}
+
 // ServerConfig holds the configuration for the HTTP server
 type ServerConfig struct {
-	Port        int        `json:"port"`
-	DataDir     string     `json:"dataDir"`
-	BearerToken string     `json:"bearerToken,omitempty"`
-	Enabled     bool       `json:"enabled"`
-	CORS        CORSConfig `json:"cors"`
+	Port        int              `json:"port"`
+	DataDir     string           `json:"dataDir"`
+	BearerToken string           `json:"bearerToken,omitempty"`
+	APIKeys     []APIKey         `json:"apiKeys,omitempty"`
+	Enabled     bool             `json:"enabled"`
+	CORS        CORSConfig       `json:"cors"`
+	RateLimit   RateLimitConfig  `json:"rateLimit"`
+	RunHistory  RunHistoryConfig `json:"runHistory"`
+	Uploads     UploadConfig     `json:"uploads"`
+	Metrics     MetricsConfig    `json:"metrics"`
 }
 
 // ProcessResponse represents the response for process operations
 type ProcessResponse struct {
-	Success bool   `json:"success"`
-	Message string `json:"message,omitempty"`
-	Error   string `json:"error,omitempty"`
-	Output  string `json:"output,omitempty"`
+	Success bool                 `json:"success"`
+	Message string               `json:"message,omitempty"`
+	Error   string               `json:"error,omitempty"`
+	Output  string               `json:"output,omitempty"`
+	Report  *processor.RunReport `json:"report,omitempty"` // Per-step timing and usage, when requested
+
+	ErrorCategory processor.ErrorCategory `json:"error_category,omitempty"` // Category of the failure, e.g. provider or io
+}
+
+// UploadResponse represents the response for a file upload
+type UploadResponse struct {
+	Success   bool      `json:"success"`
+	Handle    string    `json:"handle,omitempty"`
+	Name      string    `json:"name,omitempty"`
+	Size      int64     `json:"size,omitempty"`
+	ExpiresAt time.Time `json:"expiresAt,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// RunRecord represents a single recorded /process run
+type RunRecord struct {
+	ID         int64     `json:"id"`
+	StartedAt  time.Time `json:"startedAt"`
+	Workflow   string    `json:"workflow"`
+	Method     string    `json:"method"`
+	StatusCode int       `json:"statusCode"`
+	Success    bool      `json:"success"`
+	DurationMs int64     `json:"durationMs"`
+	Error      string    `json:"error,omitempty"`
+	Output     string    `json:"output,omitempty"`
+	Truncated  bool      `json:"truncated,omitempty"`
+}
+
+// RunListResponse represents the response for listing runs
+type RunListResponse struct {
+	Success bool        `json:"success"`
+	Runs    []RunRecord `json:"runs"`
+	Error   string      `json:"error,omitempty"`
+}
+
+// RunResponse represents the response for a single run
+type RunResponse struct {
+	Success bool       `json:"success"`
+	Run     *RunRecord `json:"run,omitempty"`
+	Error   string     `json:"error,omitempty"`
 }
 
 // HealthResponse represents the health check response
@@ -48,6 +130,11 @@ type FileInfo struct {
 	CreatedAt  time.Time `json:"createdAt"`
 	ModifiedAt time.Time `json:"modifiedAt"`
 	Methods    string    `json:"methods,omitempty"`
+
+	// Metadata from the top-level name, description and version keys of a workflow file
+	WorkflowName        string `json:"workflowName,omitempty"`
+	WorkflowDescription string `json:"workflowDescription,omitempty"`
+	WorkflowVersion     string `json:"workflowVersion,omitempty"`
 }
 
 // FileRequest represents a request to create/edit a file
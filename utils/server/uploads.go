@@ -0,0 +1,179 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/kris-hansen/comanda/utils/config"
+)
+
+const (
+	uploadDirName         = "uploads"
+	defaultUploadTTLHours = 24
+	maxUploadMemory       = 32 << 20 // Larger multipart bodies are buffered on disk while parsing
+)
+
+// uploadHandlePattern matches the handles generated for uploaded files
+var uploadHandlePattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// uploadDir returns the directory uploaded files are stored in
+func uploadDir(dataDir string) string {
+	return filepath.Join(dataDir, uploadDirName)
+}
+
+// uploadTTL returns how long uploaded files are kept
+func uploadTTL(cfg UploadConfig) time.Duration {
+	if cfg.TTLHours <= 0 {
+		return defaultUploadTTLHours * time.Hour
+	}
+	return time.Duration(cfg.TTLHours) * time.Hour
+}
+
+// handleUpload stores a multipart file upload and returns a handle that workflows can reference as upload:<handle>
+func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(UploadResponse{
+			Success: false,
+			Error:   "Method not allowed",
+		})
+		return
+	}
+
+	if err := r.ParseMultipartForm(maxUploadMemory); err != nil {
+		config.VerboseLog("Error parsing upload: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(UploadResponse{
+			Success: false,
+			Error:   "Request must be multipart/form-data with a 'file' field",
+		})
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(UploadResponse{
+			Success: false,
+			Error:   "Request must be multipart/form-data with a 'file' field",
+		})
+		return
+	}
+	defer file.Close()
+
+	dir := uploadDir(s.config.DataDir)
+	ttl := uploadTTL(s.config.Uploads)
+	if err := cleanupUploads(dir, ttl, time.Now()); err != nil {
+		config.VerboseLog("Error removing expired uploads: %v", err)
+	}
+
+	handle, size, err := storeUpload(dir, header.Filename, file)
+	if err != nil {
+		config.VerboseLog("Error storing upload: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(UploadResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Error storing upload: %v", err),
+		})
+		return
+	}
+
+	config.VerboseLog("Stored upload %s (%s, %d bytes)", handle, header.Filename, size)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(UploadResponse{
+		Success:   true,
+		Handle:    handle,
+		Name:      filepath.Base(header.Filename),
+		Size:      size,
+		ExpiresAt: time.Now().Add(ttl),
+	})
+}
+
+// storeUpload writes an uploaded file to its own directory under dir, keeping its base name
+// so the file type can still be detected from the extension
+func storeUpload(dir, filename string, content io.Reader) (string, int64, error) {
+	name := filepath.Base(filename)
+	if name == "." || name == string(filepath.Separator) {
+		name = "upload"
+	}
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", 0, fmt.Errorf("error generating upload handle: %w", err)
+	}
+	handle := hex.EncodeToString(buf)
+
+	handleDir := filepath.Join(dir, handle)
+	if err := os.MkdirAll(handleDir, 0755); err != nil {
+		return "", 0, fmt.Errorf("error creating upload directory: %w", err)
+	}
+
+	f, err := os.Create(filepath.Join(handleDir, name))
+	if err != nil {
+		return "", 0, fmt.Errorf("error creating upload file: %w", err)
+	}
+	defer f.Close()
+
+	size, err := io.Copy(f, content)
+	if err != nil {
+		os.RemoveAll(handleDir)
+		return "", 0, fmt.Errorf("error writing upload file: %w", err)
+	}
+	return handle, size, nil
+}
+
+// resolveUpload returns the path of the file stored for a handle
+func resolveUpload(dir, handle string, ttl time.Duration, now time.Time) (string, error) {
+	if !uploadHandlePattern.MatchString(handle) {
+		return "", fmt.Errorf("invalid upload handle: %s", handle)
+	}
+
+	handleDir := filepath.Join(dir, handle)
+	info, err := os.Stat(handleDir)
+	if err != nil || now.Sub(info.ModTime()) > ttl {
+		return "", fmt.Errorf("upload %s not found or expired", handle)
+	}
+
+	entries, err := os.ReadDir(handleDir)
+	if err != nil || len(entries) != 1 {
+		return "", fmt.Errorf("upload %s not found or expired", handle)
+	}
+	return filepath.Join(handleDir, entries[0].Name()), nil
+}
+
+// cleanupUploads removes uploads stored longer than the TTL
+func cleanupUploads(dir string, ttl time.Duration, now time.Time) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("error reading upload directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() || !uploadHandlePattern.MatchString(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if now.Sub(info.ModTime()) > ttl {
+			if err := os.RemoveAll(filepath.Join(dir, entry.Name())); err != nil {
+				return fmt.Errorf("error removing expired upload %s: %w", entry.Name(), err)
+			}
+		}
+	}
+	return nil
+}
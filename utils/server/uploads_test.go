@@ -0,0 +1,80 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleUpload(t *testing.T) {
+	dataDir := t.TempDir()
+	s := &Server{config: &ServerConfig{DataDir: dataDir}}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", "report.txt")
+	require.NoError(t, err)
+	part.Write([]byte("quarterly numbers"))
+	require.NoError(t, writer.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/files/upload", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+	s.handleUpload(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var response UploadResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+	assert.True(t, response.Success)
+	assert.Equal(t, "report.txt", response.Name)
+	assert.Equal(t, int64(17), response.Size)
+
+	// The handle resolves to the stored file, keeping its name
+	path, err := resolveUpload(uploadDir(dataDir), response.Handle, uploadTTL(UploadConfig{}), time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, "report.txt", filepath.Base(path))
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "quarterly numbers", string(content))
+
+	// Requests without a file are rejected
+	w = httptest.NewRecorder()
+	s.handleUpload(w, httptest.NewRequest(http.MethodPost, "/files/upload", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestUploadExpiry(t *testing.T) {
+	dir := uploadDir(t.TempDir())
+	handle, _, err := storeUpload(dir, "notes.md", bytes.NewReader([]byte("notes")))
+	require.NoError(t, err)
+
+	ttl := time.Hour
+	_, err = resolveUpload(dir, handle, ttl, time.Now())
+	require.NoError(t, err)
+
+	// Expired uploads cannot be resolved and are removed by cleanup
+	later := time.Now().Add(2 * time.Hour)
+	_, err = resolveUpload(dir, handle, ttl, later)
+	assert.Error(t, err)
+
+	require.NoError(t, cleanupUploads(dir, ttl, later))
+	_, err = os.Stat(filepath.Join(dir, handle))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestResolveUploadInvalidHandle(t *testing.T) {
+	dir := uploadDir(t.TempDir())
+	for _, handle := range []string{"../secrets", "", "ABCDEF", "0123456789abcdef0123456789abcdef/../x"} {
+		_, err := resolveUpload(dir, handle, time.Hour, time.Now())
+		assert.Error(t, err, "handle %q", handle)
+	}
+}